@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/joho/godotenv"
 
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/preflight"
 	"anti2api-golang/internal/server"
 )
 
@@ -17,6 +19,19 @@ func main() {
 	// 加载配置
 	cfg := config.Load()
 
+	// --validate 仅运行预检；PREFLIGHT=true 时预检通过后才启动
+	validate := hasFlag("validate")
+	if validate || cfg.Preflight {
+		report := preflight.Run(context.Background())
+		report.Print(os.Stdout)
+		if report.Failed() {
+			os.Exit(1)
+		}
+		if validate {
+			return
+		}
+	}
+
 	// 验证必要配置
 	if cfg.PanelPassword == "" {
 		fmt.Println("Error: PANEL_PASSWORD is required")
@@ -30,3 +45,13 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// hasFlag 检查命令行是否包含 -name 或 --name
+func hasFlag(name string) bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "-"+name || arg == "--"+name {
+			return true
+		}
+	}
+	return false
+}
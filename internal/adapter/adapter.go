@@ -0,0 +1,229 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+// 已注册的协议名称
+const (
+	ProtocolOpenAI            = "openai"
+	ProtocolOpenAICompletions = "openai-completions"
+	ProtocolOpenAIImages      = "openai-images"
+	ProtocolClaude            = "claude"
+	ProtocolGemini            = "gemini"
+	ProtocolGeminiRaw         = "gemini-raw"
+)
+
+// Request 解析后的客户端请求（协议无关视图）
+type Request interface {
+	// ModelName 客户端请求的模型名
+	ModelName() string
+	// SetModelName 替换模型名（A/B 路由转换时使用）
+	SetModelName(model string)
+	// IsStream 是否为流式请求
+	IsStream() bool
+	// Body 用于日志快照的请求体
+	Body() interface{}
+}
+
+// EndUserRequest 可选接口：携带客户端传入的终端用户标识（如 OpenAI 的 user 字段），
+// 记录到日志中以便多租户部署按终端用户归属用量
+type EndUserRequest interface {
+	EndUser() string
+}
+
+// Result 响应写出后的摘要（用于日志记录）
+type Result struct {
+	// Body 客户端响应体（流式时为合并后的 SSE 事件）
+	Body interface{}
+	// Backend 合并后的上游响应（仅流式）
+	Backend interface{}
+	// Output 模型输出文本
+	Output string
+	// FinishReason 上游结束原因（仅流式）
+	FinishReason string
+	// Usage 上游用量（仅流式）
+	Usage *core.UsageMetadata
+}
+
+// ErrorRenderer 按协议格式写出错误
+type ErrorRenderer interface {
+	// WriteError 写入非流式错误响应
+	WriteError(w http.ResponseWriter, status int, message string)
+	// WriteStreamError 写入流式错误（响应头尚未发送）
+	WriteStreamError(w http.ResponseWriter, status int, message string)
+}
+
+// CodedErrorWriter 可选接口：写出带错误码的错误（如 OpenAI 的 context_length_exceeded）
+type CodedErrorWriter interface {
+	WriteCodedError(w http.ResponseWriter, status int, code string, message string)
+}
+
+// DetailedErrorWriter 可选接口：写出带错误码与附加字段的错误，附加字段合并到 error 对象中
+type DetailedErrorWriter interface {
+	WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{})
+}
+
+// Error 携带 HTTP 状态码与错误码的协议无关错误
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Param   string // 出错的请求字段（OpenAI error.param），可为空
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// InvalidParam 请求字段取值无效（400 invalid_value，param 指明字段）
+func InvalidParam(param string, format string, args ...interface{}) error {
+	return &Error{Status: http.StatusBadRequest, Code: "invalid_value", Param: param, Message: fmt.Sprintf(format, args...)}
+}
+
+// Adapter 协议适配器：负责客户端格式与 Antigravity 内部格式之间的转换
+type Adapter interface {
+	ErrorRenderer
+
+	// Name 协议名称
+	Name() string
+	// ParseRequest 解析客户端请求体
+	ParseRequest(r *http.Request, body []byte) (Request, error)
+	// Convert 转换为 Antigravity 请求
+	Convert(req Request, account *store.Account) (*core.AntigravityRequest, error)
+	// EmitResponse 写出非流式响应
+	EmitResponse(w http.ResponseWriter, req Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*Result, error)
+	// EmitStream 将上游流式响应转写为客户端协议
+	EmitStream(w http.ResponseWriter, req Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*Result, error)
+}
+
+// HeartbeatStream bypass 模式下的下游流（上游非流式，下游以心跳保活）
+type HeartbeatStream interface {
+	// Heartbeat 发送一次心跳
+	Heartbeat() error
+	// Finish 写出完整响应并结束流
+	Finish(resp *core.AntigravityResponse) *Result
+	// Fail 写出错误并结束流
+	Fail(message string)
+}
+
+// HeartbeatStreamer 可选接口：支持 bypass 模式的适配器
+type HeartbeatStreamer interface {
+	StartHeartbeatStream(w http.ResponseWriter, req Request) HeartbeatStream
+}
+
+var (
+	mu       sync.RWMutex
+	adapters = make(map[string]Adapter)
+)
+
+// Register 注册协议适配器（同名覆盖）
+func Register(a Adapter) {
+	mu.Lock()
+	defer mu.Unlock()
+	adapters[a.Name()] = a
+}
+
+// Get 按名称获取适配器
+func Get(name string) (Adapter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	a, ok := adapters[name]
+	return a, ok
+}
+
+// MustGet 按名称获取适配器，未注册时 panic
+func MustGet(name string) Adapter {
+	a, ok := Get(name)
+	if !ok {
+		panic("adapter not registered: " + name)
+	}
+	return a
+}
+
+// Names 返回已注册的协议名称（已排序）
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteJSON 写入 JSON 响应
+func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// WriteAdapterError 按协议写出错误；*Error 携带的状态码与错误码优先
+func WriteAdapterError(w http.ResponseWriter, r ErrorRenderer, defaultStatus int, err error) {
+	var adapterErr *Error
+	if !errors.As(err, &adapterErr) {
+		r.WriteError(w, defaultStatus, err.Error())
+		return
+	}
+	if adapterErr.Param != "" {
+		WriteErrorDetails(w, r, adapterErr.Status, adapterErr.Code, adapterErr.Message, map[string]interface{}{"param": adapterErr.Param})
+		return
+	}
+	if cw, ok := r.(CodedErrorWriter); ok && adapterErr.Code != "" {
+		cw.WriteCodedError(w, adapterErr.Status, adapterErr.Code, adapterErr.Message)
+		return
+	}
+	r.WriteError(w, adapterErr.Status, adapterErr.Message)
+}
+
+// WriteErrorDetails 写出带附加字段的错误；适配器未实现 DetailedErrorWriter 时退化为普通错误
+func WriteErrorDetails(w http.ResponseWriter, r ErrorRenderer, status int, code string, message string, details map[string]interface{}) {
+	if dw, ok := r.(DetailedErrorWriter); ok {
+		dw.WriteDetailedError(w, status, code, message, details)
+		return
+	}
+	r.WriteError(w, status, message)
+}
+
+// ErrorObject 构建 OpenAI 风格的 error 对象（code 为空时省略，附加字段不覆盖基础字段）
+func ErrorObject(status int, code string, message string, details map[string]interface{}) map[string]interface{} {
+	obj := make(map[string]interface{}, len(details)+3)
+	for key, value := range details {
+		obj[key] = value
+	}
+	obj["message"] = message
+	obj["type"] = ErrorType(status)
+	if code != "" {
+		obj["code"] = code
+	}
+	return obj
+}
+
+// ErrorType 将 HTTP 状态码映射为 OpenAI 风格的错误类型
+func ErrorType(status int) string {
+	switch {
+	case status == 400:
+		return "invalid_request_error"
+	case status == 401:
+		return "authentication_error"
+	case status == 403:
+		return "permission_error"
+	case status == 404:
+		return "not_found_error"
+	case status == 429:
+		return "rate_limit_error"
+	default:
+		return "server_error"
+	}
+}
@@ -0,0 +1,121 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+// fakeAdapter 记录写出的错误，不实现任何可选接口
+type fakeAdapter struct {
+	name    string
+	status  int
+	message string
+}
+
+func (a *fakeAdapter) Name() string { return a.name }
+func (a *fakeAdapter) ParseRequest(r *http.Request, body []byte) (Request, error) {
+	return nil, nil
+}
+func (a *fakeAdapter) Convert(req Request, account *store.Account) (*core.AntigravityRequest, error) {
+	return nil, nil
+}
+func (a *fakeAdapter) EmitResponse(w http.ResponseWriter, req Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*Result, error) {
+	return nil, nil
+}
+func (a *fakeAdapter) EmitStream(w http.ResponseWriter, req Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*Result, error) {
+	return nil, nil
+}
+func (a *fakeAdapter) WriteError(w http.ResponseWriter, status int, message string) {
+	a.status, a.message = status, message
+}
+func (a *fakeAdapter) WriteStreamError(w http.ResponseWriter, status int, message string) {
+	a.WriteError(w, status, message)
+}
+
+// detailedAdapter 实现 CodedErrorWriter 与 DetailedErrorWriter
+type detailedAdapter struct {
+	fakeAdapter
+	code    string
+	details map[string]interface{}
+}
+
+func (a *detailedAdapter) WriteCodedError(w http.ResponseWriter, status int, code string, message string) {
+	a.status, a.code, a.message = status, code, message
+}
+func (a *detailedAdapter) WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	a.status, a.code, a.message, a.details = status, code, message, details
+}
+
+func TestRegistry(t *testing.T) {
+	defer func(old map[string]Adapter) { adapters = old }(adapters)
+	adapters = make(map[string]Adapter)
+
+	first := &fakeAdapter{name: "b"}
+	Register(first)
+	Register(&fakeAdapter{name: "a"})
+	if names := Names(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Names() = %v, want [a b]", names)
+	}
+	if a, ok := Get("b"); !ok || a != first {
+		t.Errorf("Get(b) = %v, %v", a, ok)
+	}
+
+	// 同名注册覆盖原适配器
+	second := &fakeAdapter{name: "b"}
+	Register(second)
+	if a := MustGet("b"); a != second || len(Names()) != 2 {
+		t.Errorf("re-registering should replace the adapter, got %v (%v)", a, Names())
+	}
+
+	if _, ok := Get("missing"); ok {
+		t.Error("Get(missing) should fail")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGet(missing) should panic")
+		}
+	}()
+	MustGet("missing")
+}
+
+func TestWriteAdapterError(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	plain := &fakeAdapter{}
+	WriteAdapterError(w, plain, http.StatusBadGateway, errors.New("boom"))
+	if plain.status != http.StatusBadGateway || plain.message != "boom" {
+		t.Errorf("plain error: %d %q", plain.status, plain.message)
+	}
+
+	// *Error 的状态码优先；适配器不支持错误码时退化为普通错误
+	coded := &Error{Status: http.StatusRequestEntityTooLarge, Code: "request_too_large", Message: "too large"}
+	WriteAdapterError(w, plain, http.StatusBadRequest, coded)
+	if plain.status != http.StatusRequestEntityTooLarge || plain.message != "too large" {
+		t.Errorf("coded error on plain adapter: %d %q", plain.status, plain.message)
+	}
+
+	detailed := &detailedAdapter{}
+	WriteAdapterError(w, detailed, http.StatusBadRequest, coded)
+	if detailed.status != http.StatusRequestEntityTooLarge || detailed.code != "request_too_large" || detailed.details != nil {
+		t.Errorf("coded error: %d %q %v", detailed.status, detailed.code, detailed.details)
+	}
+
+	WriteAdapterError(w, detailed, http.StatusInternalServerError, InvalidParam("n", "n must be %d", 1))
+	if detailed.status != http.StatusBadRequest || detailed.code != "invalid_value" || detailed.message != "n must be 1" || detailed.details["param"] != "n" {
+		t.Errorf("param error: %d %q %q %v", detailed.status, detailed.code, detailed.message, detailed.details)
+	}
+}
+
+func TestErrorObject(t *testing.T) {
+	obj := ErrorObject(http.StatusTooManyRequests, "", "slow down", map[string]interface{}{"retry_after": 3, "message": "ignored"})
+	data, _ := json.Marshal(obj)
+	if got, want := string(data), `{"message":"slow down","retry_after":3,"type":"rate_limit_error"}`; got != want {
+		t.Errorf("ErrorObject = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+// Package adaptertest 协议适配器一致性测试的公共工具
+// 上游录制流存放于 internal/adapter/testdata/upstream，各协议期望输出存放于各自包的 testdata/golden
+package adaptertest
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新生成 golden 文件")
+
+// upstreamDir 返回录制的上游流目录
+func upstreamDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "testdata", "upstream")
+}
+
+// UpstreamStreams 返回所有录制的上游流名称（不含扩展名）
+func UpstreamStreams(t *testing.T) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(upstreamDir(), "*.sse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("no upstream fixtures found")
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, filepath.Base(m[:len(m)-len(".sse")]))
+	}
+	return names
+}
+
+// Upstream 以录制的上游流构造 HTTP 响应
+func Upstream(t *testing.T, name string) *http.Response {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(upstreamDir(), name+".sse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}
+}
+
+// AssertGolden 与 golden 文件逐字节比较；-update 时改为写入
+func AssertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden %s: %v (run with -update to create)", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output mismatch with %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,255 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+func init() {
+	adapter.Register(&Adapter{})
+}
+
+// ModelName 实现 adapter.Request
+func (r *ClaudeMessagesRequest) ModelName() string { return r.Model }
+
+// SetModelName 实现 adapter.Request
+func (r *ClaudeMessagesRequest) SetModelName(model string) { r.Model = model }
+
+// IsStream 实现 adapter.Request
+func (r *ClaudeMessagesRequest) IsStream() bool { return r.Stream }
+
+// Body 实现 adapter.Request
+func (r *ClaudeMessagesRequest) Body() interface{} { return r }
+
+// Adapter Claude 协议适配器
+type Adapter struct{}
+
+// Name 协议名称
+func (a *Adapter) Name() string { return adapter.ProtocolClaude }
+
+// ParseRequest 解析 Claude Messages 请求
+func (a *Adapter) ParseRequest(r *http.Request, body []byte) (adapter.Request, error) {
+	var req ClaudeMessagesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if r != nil {
+		req.mergeBetas(r.Header.Get("anthropic-beta"))
+	}
+	return &req, nil
+}
+
+// singleToolUse 是否要求只返回一个工具调用（tool_choice.disable_parallel_tool_use）
+// Gemini 的 toolConfig 没有限制并行调用的选项，因此由代理只保留第一个 tool_use 块
+func (r *ClaudeMessagesRequest) singleToolUse() bool {
+	c, ok := r.ToolChoice.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	disable, _ := c["disable_parallel_tool_use"].(bool)
+	return disable
+}
+
+// keepFirstToolUse 只保留第一个 tool_use 块
+func keepFirstToolUse(resp *ClaudeMessagesResponse) {
+	blocks := resp.Content[:0]
+	seen := false
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" {
+			if seen {
+				continue
+			}
+			seen = true
+		}
+		blocks = append(blocks, block)
+	}
+	resp.Content = blocks
+}
+
+// Convert 直接转换为 Antigravity 格式（跳过 OpenAI 中间层）
+func (a *Adapter) Convert(req adapter.Request, account *store.Account) (*core.AntigravityRequest, error) {
+	return ConvertClaudeToAntigravity(req.(*ClaudeMessagesRequest), account)
+}
+
+// EmitResponse 写出非流式响应
+func (a *Adapter) EmitResponse(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*adapter.Result, error) {
+	claudeReq := req.(*ClaudeMessagesRequest)
+
+	claudeResp := ConvertAntigravityToClaudeResponse(resp, upstreamReq.RequestID, claudeReq.Model, countInputTokens(claudeReq))
+	if claudeReq.singleToolUse() {
+		keepFirstToolUse(claudeResp)
+	}
+	if prefill := PrefillText(claudeReq); prefill != "" {
+		for i := range claudeResp.Content {
+			if claudeResp.Content[i].Type == "text" {
+				claudeResp.Content[i].Text = StripPrefill(claudeResp.Content[i].Text, prefill)
+				break
+			}
+		}
+	}
+
+	adapter.WriteJSON(w, http.StatusOK, claudeResp)
+	return &adapter.Result{Body: claudeResp, Output: claudeResponseText(claudeResp)}, nil
+}
+
+// EmitStream 将上游流式响应转写为 Claude SSE
+func (a *Adapter) EmitStream(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*adapter.Result, error) {
+	claudeReq := req.(*ClaudeMessagesRequest)
+
+	// 设置 SSE 响应头
+	SetSSEHeaders(w)
+
+	// 创建 Claude SSE 发射器
+	emitter := NewSSEEmitter(w, upstreamReq.RequestID, claudeReq.Model, countInputTokens(claudeReq))
+	emitter.SetPrefill(PrefillText(claudeReq))
+	emitter.SetFineGrainedToolStreaming(claudeReq.HasBeta(BetaFineGrainedToolStreaming))
+	emitter.SetSingleToolUse(claudeReq.singleToolUse())
+	emitter.Start()
+
+	streamResult, err := vertex.ParseStreamWithResult(upstream, func(data *vertex.StreamData) error {
+		if len(data.Response.Candidates) == 0 && data.Response.PromptFeedback.Blocked() {
+			emitter.SetStopReason(StopReasonRefusal)
+		}
+		if len(data.Response.Candidates) > 0 {
+			candidate := data.Response.Candidates[0]
+			if candidate.FinishReason == "MAX_TOKENS" {
+				emitter.SetStopReason(StopReasonMaxTokens)
+			}
+
+			parts := make([]StreamDataPart, 0, len(candidate.Content.Parts))
+			for _, part := range candidate.Content.Parts {
+				parts = append(parts, StreamDataPart{
+					Text:             part.Text,
+					FunctionCall:     part.FunctionCall,
+					Thought:          part.Thought,
+					ThoughtSignature: part.ThoughtSignature,
+				})
+			}
+			// signature 的归属（含迟到的 signature）由发射器处理
+			if err := emitter.ProcessChunk(parts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// 发送结束事件
+	var usageData *Usage
+	if streamResult.Usage != nil {
+		usageData = ConvertUsage(streamResult.Usage)
+	}
+	// Finish 会自动从 Emitter 内部状态判断 stopReason
+	emitter.Finish(usageData)
+
+	return &adapter.Result{
+		Body:         emitter.GetMergedResponse(),
+		Backend:      streamResult.MergedResponse,
+		Output:       streamResult.Text,
+		FinishReason: streamResult.FinishReason,
+		Usage:        streamResult.Usage,
+	}, err
+}
+
+// WriteError 写入 Claude 格式错误响应
+func (a *Adapter) WriteError(w http.ResponseWriter, status int, message string) {
+	WriteClaudeError(w, status, ErrorType(status), message)
+}
+
+// WriteDetailedError 写入带附加字段的 Claude 错误响应（Claude 错误类型由状态码决定，code 作为附加字段返回）
+func (a *Adapter) WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	obj := make(map[string]interface{}, len(details)+3)
+	for key, value := range details {
+		obj[key] = value
+	}
+	obj["type"] = ErrorType(status)
+	obj["message"] = message
+	if code != "" {
+		obj["code"] = code
+	}
+	adapter.WriteJSON(w, status, map[string]interface{}{"type": "error", "error": obj})
+}
+
+// WriteStreamError 写入 Claude 流式错误
+func (a *Adapter) WriteStreamError(w http.ResponseWriter, status int, message string) {
+	SetSSEHeaders(w)
+	WriteClaudeStreamError(w, message)
+}
+
+// countInputTokens 估算请求的输入 token
+func countInputTokens(req *ClaudeMessagesRequest) int {
+	tokenStats, _ := CountClaudeTokens(req)
+	if tokenStats == nil {
+		return 0
+	}
+	return tokenStats.InputTokens
+}
+
+// claudeResponseText 提取响应中的正文文本
+func claudeResponseText(resp *ClaudeMessagesResponse) string {
+	var text string
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+	return text
+}
+
+// ErrorType 将 HTTP 状态码映射为 Claude 错误类型
+func ErrorType(status int) string {
+	switch status {
+	case 400:
+		return "invalid_request_error"
+	case 401:
+		return "authentication_error"
+	case 403:
+		return "permission_error"
+	case 404:
+		return "not_found_error"
+	case 429:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// WriteClaudeError 写入 Claude 格式错误响应
+func WriteClaudeError(w http.ResponseWriter, status int, errorType string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ClaudeErrorResponse{
+		Type: "error",
+		Error: struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		}{
+			Type:    errorType,
+			Message: message,
+		},
+	})
+}
+
+// WriteClaudeStreamError 写入 Claude 流式错误
+func WriteClaudeStreamError(w http.ResponseWriter, message string) {
+	errData := map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    "api_error",
+			"message": message,
+		},
+	}
+	jsonData, _ := json.Marshal(errData)
+	w.Write([]byte("event: error\ndata: "))
+	w.Write(jsonData)
+	w.Write([]byte("\n\n"))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
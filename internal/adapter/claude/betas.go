@@ -0,0 +1,42 @@
+package claude
+
+import "strings"
+
+// 已知的 Anthropic beta 功能
+const (
+	// BetaFineGrainedToolStreaming 工具参数分片流式下发
+	BetaFineGrainedToolStreaming = "fine-grained-tool-streaming-2025-05-14"
+	// BetaTokenEfficientTools 节省 token 的工具调用格式（Gemini 函数调用本身即为紧凑格式，无需处理）
+	BetaTokenEfficientTools = "token-efficient-tools-2025-02-19"
+	// BetaInterleavedThinking 工具调用之间穿插思考（上游原生支持，无需处理）
+	BetaInterleavedThinking = "interleaved-thinking-2025-05-14"
+)
+
+// toolInputFragmentSize 启用 fine-grained-tool-streaming 时每个 input_json_delta 的最大字节数
+const toolInputFragmentSize = 128
+
+// mergeBetas 合并 anthropic-beta 请求头（逗号分隔）与请求体中的 betas，去重并保持顺序
+// 未知的 beta 同样保留，随请求体记录到日志中
+func (r *ClaudeMessagesRequest) mergeBetas(header string) {
+	seen := make(map[string]bool, len(r.Betas))
+	var betas []string
+	for _, beta := range append(r.Betas, strings.Split(header, ",")...) {
+		beta = strings.TrimSpace(beta)
+		if beta == "" || seen[beta] {
+			continue
+		}
+		seen[beta] = true
+		betas = append(betas, beta)
+	}
+	r.Betas = betas
+}
+
+// HasBeta 请求是否启用了指定 beta 功能
+func (r *ClaudeMessagesRequest) HasBeta(name string) bool {
+	for _, beta := range r.Betas {
+		if beta == name {
+			return true
+		}
+	}
+	return false
+}
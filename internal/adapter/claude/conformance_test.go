@@ -0,0 +1,29 @@
+package claude
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"anti2api-golang/internal/adapter/adaptertest"
+	"anti2api-golang/internal/core"
+)
+
+func TestStreamConformance(t *testing.T) {
+	a := &Adapter{}
+	for _, name := range adaptertest.UpstreamStreams(t) {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/messages", nil)
+			req, err := a.ParseRequest(r, []byte(`{"model":"claude-sonnet-4-5","max_tokens":1024,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			if _, err := a.EmitStream(w, req, &core.AntigravityRequest{RequestID: "agent-golden"}, adaptertest.Upstream(t, name)); err != nil {
+				t.Fatal(err)
+			}
+			adaptertest.AssertGolden(t, filepath.Join("testdata", "golden", name+".sse"), w.Body.Bytes())
+		})
+	}
+}
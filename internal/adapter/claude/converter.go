@@ -1,12 +1,13 @@
 package claude
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/bytedance/sonic"
 
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/i18n"
 	"anti2api-golang/internal/store"
 	"anti2api-golang/internal/utils"
 )
@@ -14,13 +15,13 @@ import (
 // ConvertClaudeToAntigravity 将 Claude 请求直接转换为 Antigravity 格式（跳过 OpenAI 中间层）
 func ConvertClaudeToAntigravity(req *ClaudeMessagesRequest, account *store.Account) (*AntigravityRequest, error) {
 	if req == nil {
-		return nil, fmt.Errorf("请求体格式不合法")
+		return nil, i18n.New(i18n.InvalidRequestBody)
 	}
 	if req.MaxTokens <= 0 {
-		return nil, fmt.Errorf("max_tokens 是必填数字")
+		return nil, i18n.New(i18n.MaxTokensRequired)
 	}
 	if len(req.Messages) == 0 {
-		return nil, fmt.Errorf("messages 不能为空")
+		return nil, i18n.New(i18n.MessagesRequired)
 	}
 
 	modelName := ResolveModelName(req.Model)
@@ -38,13 +39,8 @@ func ConvertClaudeToAntigravity(req *ClaudeMessagesRequest, account *store.Accou
 	}
 
 	// 处理 system 字段
-	if req.System != nil {
-		systemText := extractClaudeSystem(req.System)
-		if systemText != "" {
-			innerReq.SystemInstruction = &SystemInstruction{
-				Parts: []Part{{Text: systemText}},
-			}
-		}
+	if parts := claudeSystemParts(req.System); len(parts) > 0 {
+		innerReq.SystemInstruction = &SystemInstruction{Parts: parts}
 	}
 
 	// 检查是否为 Prefill 请求（最后一条消息是 assistant）
@@ -66,13 +62,20 @@ func ConvertClaudeToAntigravity(req *ClaudeMessagesRequest, account *store.Accou
 	contents := convertClaudeMessagesToContents(req.Messages, thinkingEnabled)
 	innerReq.Contents = contents
 
+	// Prefill：保留末尾的 model 轮次，并要求上游从该处续写而不是开始新的回复
+	if isPrefill && len(contents) > 0 && contents[len(contents)-1].Role == "model" {
+		trimPrefillWhitespace(contents[len(contents)-1].Parts)
+		if innerReq.SystemInstruction == nil {
+			innerReq.SystemInstruction = &SystemInstruction{}
+		}
+		innerReq.SystemInstruction.Parts = append(innerReq.SystemInstruction.Parts, Part{Text: prefillInstruction})
+	}
+
 	// 转换工具
 	if len(req.Tools) > 0 {
 		innerReq.Tools = ConvertClaudeToolsToAntigravity(req.Tools)
 		innerReq.ToolConfig = &ToolConfig{
-			FunctionCallingConfig: &FunctionCallingConfig{
-				Mode: "AUTO",
-			},
+			FunctionCallingConfig: convertClaudeToolChoice(req.ToolChoice),
 		}
 	}
 
@@ -90,6 +93,72 @@ func ConvertClaudeToAntigravity(req *ClaudeMessagesRequest, account *store.Accou
 	return antigravityReq, nil
 }
 
+// convertClaudeToolChoice 将 Claude tool_choice 转换为函数调用配置
+// auto → AUTO，any → ANY，tool → ANY 且仅允许指定工具，none → NONE；未指定时为 AUTO
+func convertClaudeToolChoice(choice interface{}) *FunctionCallingConfig {
+	c, ok := choice.(map[string]interface{})
+	if !ok {
+		return &FunctionCallingConfig{Mode: "AUTO"}
+	}
+	switch c["type"] {
+	case "any":
+		return &FunctionCallingConfig{Mode: "ANY"}
+	case "tool":
+		if name, _ := c["name"].(string); name != "" {
+			return &FunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{name}}
+		}
+	case "none":
+		return &FunctionCallingConfig{Mode: "NONE"}
+	}
+	return &FunctionCallingConfig{Mode: "AUTO"}
+}
+
+// prefillInstruction 附加到系统指令中，要求模型续写末尾的 assistant 消息
+const prefillInstruction = "The last assistant message is a partial response. Continue it exactly from where it ends, without repeating it and without starting a new message."
+
+// trimPrefillWhitespace 去除 prefill 末尾的空白（Anthropic 同样不允许以空白结尾，否则续写容易出现重复空格）
+func trimPrefillWhitespace(parts []Part) {
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].Text != "" && !parts[i].Thought {
+			parts[i].Text = strings.TrimRight(parts[i].Text, " \t\r\n")
+			return
+		}
+	}
+}
+
+// PrefillText 返回请求末尾 assistant 消息中的文本（非 prefill 请求返回空字符串）
+func PrefillText(req *ClaudeMessagesRequest) string {
+	if len(req.Messages) == 0 {
+		return ""
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != "assistant" {
+		return ""
+	}
+
+	var text string
+	switch v := last.Content.(type) {
+	case string:
+		text = v
+	case []interface{}:
+		for _, item := range v {
+			if block, ok := item.(map[string]interface{}); ok && block["type"] == "text" {
+				t, _ := block["text"].(string)
+				text += t
+			}
+		}
+	}
+	return strings.TrimRight(text, " \t\r\n")
+}
+
+// StripPrefill 上游若在续写前重复了 prefill，则将其去除（按规范，响应只包含续写部分）
+func StripPrefill(text, prefill string) string {
+	if prefill == "" {
+		return text
+	}
+	return strings.TrimPrefix(text, prefill)
+}
+
 // getClaudeProjectID 获取项目ID
 func getClaudeProjectID(account *store.Account) string {
 	if account.ProjectID != "" {
@@ -216,6 +285,32 @@ func convertClaudeContentToParts(content interface{}, toolIDToName map[string]st
 						})
 					}
 
+				case "image":
+					// 仅支持 base64 图片来源，转换为内联数据
+					if source, ok := block["source"].(map[string]interface{}); ok && source["type"] == "base64" {
+						mediaType, _ := source["media_type"].(string)
+						data, _ := source["data"].(string)
+						if mediaType != "" && data != "" {
+							parts = append(parts, Part{InlineData: &InlineData{MimeType: mediaType, Data: data}})
+						}
+					}
+
+				case "document":
+					// base64 PDF 转换为内联数据（上游 Gemini 模型支持 PDF 输入），纯文本文档作为文本发送
+					if source, ok := block["source"].(map[string]interface{}); ok {
+						data, _ := source["data"].(string)
+						switch source["type"] {
+						case "base64":
+							if data != "" {
+								parts = append(parts, Part{InlineData: &InlineData{MimeType: "application/pdf", Data: data}})
+							}
+						case "text":
+							if data != "" {
+								parts = append(parts, Part{Text: data})
+							}
+						}
+					}
+
 				case "tool_use":
 					name, _ := block["name"].(string)
 					id, _ := block["id"].(string)
@@ -313,9 +408,9 @@ func ConvertClaudeToolsToAntigravity(tools []ClaudeTool) []Tool {
 	var result []Tool
 	for _, tool := range tools {
 		// 深拷贝 schema 以避免修改原始数据
-		params := deepCopyMap(tool.InputSchema)
+		params := core.DeepCopyMap(tool.InputSchema)
 		// 递归清理 Vertex AI 不支持的 JSON Schema 字段
-		cleanSchemaForVertexAI(params)
+		core.CleanSchemaForVertexAI(params)
 
 		result = append(result, Tool{
 			FunctionDeclarations: []FunctionDeclaration{{
@@ -328,125 +423,6 @@ func ConvertClaudeToolsToAntigravity(tools []ClaudeTool) []Tool {
 	return result
 }
 
-// cleanSchemaForVertexAI 递归清理 Vertex AI 不支持的 JSON Schema 字段
-// 同时将 exclusiveMinimum/exclusiveMaximum 转换为 minimum/maximum
-func cleanSchemaForVertexAI(schema map[string]interface{}) {
-	if schema == nil {
-		return
-	}
-
-	// 将 exclusiveMinimum 转换为 minimum（+1）
-	if exMin, ok := schema["exclusiveMinimum"].(float64); ok {
-		if _, hasMin := schema["minimum"]; !hasMin {
-			schema["minimum"] = exMin + 1
-		}
-		delete(schema, "exclusiveMinimum")
-	}
-
-	// 将 exclusiveMaximum 转换为 maximum（-1）
-	if exMax, ok := schema["exclusiveMaximum"].(float64); ok {
-		if _, hasMax := schema["maximum"]; !hasMax {
-			schema["maximum"] = exMax - 1
-		}
-		delete(schema, "exclusiveMaximum")
-	}
-
-	// 移除 Vertex AI 不支持的字段
-	unsupportedFields := []string{
-		"$schema",
-		"$ref",
-		"$id",
-		"$defs",
-		"definitions",
-		"minItems",
-		"maxItems",
-		"uniqueItems",
-		"pattern",
-		"additionalProperties",
-		"patternProperties",
-		"dependencies",
-		"if",
-		"then",
-		"else",
-		"allOf",
-		"anyOf",
-		"oneOf",
-		"not",
-		"contentMediaType",
-		"contentEncoding",
-		"examples",
-		"default",
-		"const",
-		"minLength",
-		"maxLength",
-		"format",
-	}
-	for _, field := range unsupportedFields {
-		delete(schema, field)
-	}
-
-	// 递归处理 properties
-	if props, ok := schema["properties"].(map[string]interface{}); ok {
-		for _, propValue := range props {
-			if propSchema, ok := propValue.(map[string]interface{}); ok {
-				cleanSchemaForVertexAI(propSchema)
-			}
-		}
-	}
-
-	// 递归处理 items（数组类型）
-	if items, ok := schema["items"].(map[string]interface{}); ok {
-		cleanSchemaForVertexAI(items)
-	}
-
-	// 递归处理 items 数组形式
-	if itemsArr, ok := schema["items"].([]interface{}); ok {
-		for _, item := range itemsArr {
-			if itemSchema, ok := item.(map[string]interface{}); ok {
-				cleanSchemaForVertexAI(itemSchema)
-			}
-		}
-	}
-}
-
-// deepCopyMap 深拷贝 map 以避免修改原始数据
-func deepCopyMap(m map[string]interface{}) map[string]interface{} {
-	if m == nil {
-		return nil
-	}
-	result := make(map[string]interface{}, len(m))
-	for k, v := range m {
-		switch val := v.(type) {
-		case map[string]interface{}:
-			result[k] = deepCopyMap(val)
-		case []interface{}:
-			result[k] = deepCopySlice(val)
-		default:
-			result[k] = v
-		}
-	}
-	return result
-}
-
-// deepCopySlice 深拷贝 slice
-func deepCopySlice(s []interface{}) []interface{} {
-	if s == nil {
-		return nil
-	}
-	result := make([]interface{}, len(s))
-	for i, v := range s {
-		switch val := v.(type) {
-		case map[string]interface{}:
-			result[i] = deepCopyMap(val)
-		case []interface{}:
-			result[i] = deepCopySlice(val)
-		default:
-			result[i] = v
-		}
-	}
-	return result
-}
-
 // buildClaudeGenerationConfig 构建 Claude 请求的生成配置
 func buildClaudeGenerationConfig(req *ClaudeMessagesRequest, modelName string) *GenerationConfig {
 	cfg := &GenerationConfig{
@@ -514,7 +490,7 @@ func ConvertAntigravityToClaudeResponse(resp *AntigravityResponse, requestID, mo
 			Role:       "assistant",
 			Model:      model,
 			Content:    []ClaudeContentBlock{},
-			StopReason: "end_turn",
+			StopReason: StopReasonRefusal,
 			Usage: ClaudeUsage{
 				InputTokens:  inputTokens,
 				OutputTokens: 0,
@@ -626,6 +602,28 @@ func extractClaudeSystem(system interface{}) string {
 	return ""
 }
 
+// claudeSystemParts 将 Claude system 转换为系统指令 parts
+// 数组形式的每个文本块保持为独立的 part（按原顺序），保留块边界以便按块处理（如缓存标记、引用）
+func claudeSystemParts(system interface{}) []Part {
+	switch v := system.(type) {
+	case string:
+		if v != "" {
+			return []Part{{Text: v}}
+		}
+	case []interface{}:
+		var parts []Part
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok && text != "" {
+					parts = append(parts, Part{Text: text})
+				}
+			}
+		}
+		return parts
+	}
+	return nil
+}
+
 // extractToolResultContent 提取工具结果内容
 func extractToolResultContent(content interface{}) string {
 	switch v := content.(type) {
@@ -696,7 +694,7 @@ func EstimateClaudeTokens(text string) int {
 // CountClaudeTokens 计算 Claude 请求的 token 数量
 func CountClaudeTokens(req *ClaudeMessagesRequest) (*ClaudeTokenCountResponse, error) {
 	if req == nil || len(req.Messages) == 0 {
-		return nil, fmt.Errorf("messages 不能为空")
+		return nil, i18n.New(i18n.MessagesRequired)
 	}
 
 	var totalText string
@@ -759,6 +757,12 @@ func extractClaudeMessageText(content interface{}) string {
 	return ""
 }
 
+// 覆盖默认值的 stop_reason
+const (
+	StopReasonRefusal   = "refusal"    // 提示词被上游拦截、未生成任何内容
+	StopReasonMaxTokens = "max_tokens" // 达到输出上限（或流式响应达到最长持续时间）
+)
+
 // GetClaudeStopReason 根据工具调用情况返回 stop_reason
 func GetClaudeStopReason(hasToolCalls bool) string {
 	if hasToolCalls {
@@ -1,6 +1,9 @@
 package claude
 
 import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"anti2api-golang/internal/store"
@@ -23,6 +26,49 @@ func TestConvertClaudeContentToParts(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Text + Base64 Image",
+			content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "What is in this image?"},
+				map[string]interface{}{
+					"type":   "image",
+					"source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="},
+				},
+				map[string]interface{}{
+					"type":   "image",
+					"source": map[string]interface{}{"type": "url", "url": "https://example.com/cat.png"},
+				},
+			},
+			expected: 2,
+			verify: func(t *testing.T, parts []Part) {
+				if parts[1].InlineData == nil || parts[1].InlineData.MimeType != "image/png" || parts[1].InlineData.Data != "iVBORw0KGgo=" {
+					t.Errorf("Expected inline image/png data, got %+v", parts[1].InlineData)
+				}
+			},
+		},
+		{
+			name: "PDF and Text Documents",
+			content: []interface{}{
+				map[string]interface{}{
+					"type":   "document",
+					"source": map[string]interface{}{"type": "base64", "media_type": "application/pdf", "data": "JVBERi0xLjQ="},
+				},
+				map[string]interface{}{
+					"type":   "document",
+					"source": map[string]interface{}{"type": "text", "media_type": "text/plain", "data": "plain notes"},
+				},
+				map[string]interface{}{"type": "text", "text": "Summarize these documents."},
+			},
+			expected: 3,
+			verify: func(t *testing.T, parts []Part) {
+				if parts[0].InlineData == nil || parts[0].InlineData.MimeType != "application/pdf" || parts[0].InlineData.Data != "JVBERi0xLjQ=" {
+					t.Errorf("Expected inline application/pdf data, got %+v", parts[0].InlineData)
+				}
+				if parts[1].Text != "plain notes" {
+					t.Errorf("Expected text document as text part, got %+v", parts[1])
+				}
+			},
+		},
 		{
 			name: "Thinking + Tool Use",
 			content: []interface{}{
@@ -225,3 +271,222 @@ func TestConvertClaudeToAntigravity(t *testing.T) {
 		t.Errorf("Expected functionResponse name 'get_weather', got '%s'", respPart.FunctionResponse.Name)
 	}
 }
+
+func TestClaudeSystemBlocksAsParts(t *testing.T) {
+	req := &ClaudeMessagesRequest{
+		Model:     "claude-3-5-sonnet",
+		MaxTokens: 1024,
+		System: []interface{}{
+			map[string]interface{}{"type": "text", "text": "You are a helpful assistant."},
+			map[string]interface{}{"type": "text", "text": ""},
+			map[string]interface{}{"type": "text", "text": "Long reference document", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+		},
+		Messages: []ClaudeMessage{{Role: "user", Content: "hi"}},
+	}
+
+	antireq, err := ConvertClaudeToAntigravity(req, &store.Account{ProjectID: "p", SessionID: "s"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	si := antireq.Request.SystemInstruction
+	if si == nil || len(si.Parts) != 2 {
+		t.Fatalf("Expected 2 system parts, got %+v", si)
+	}
+	if si.Parts[0].Text != "You are a helpful assistant." || si.Parts[1].Text != "Long reference document" {
+		t.Errorf("Unexpected system parts order: %+v", si.Parts)
+	}
+
+	req.System = "single"
+	antireq, err = ConvertClaudeToAntigravity(req, &store.Account{ProjectID: "p", SessionID: "s"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if si := antireq.Request.SystemInstruction; si == nil || len(si.Parts) != 1 || si.Parts[0].Text != "single" {
+		t.Errorf("Expected single system part, got %+v", si)
+	}
+}
+
+func TestConvertAntigravityToClaudeResponseNoCandidates(t *testing.T) {
+	resp := &AntigravityResponse{}
+	claudeResp := ConvertAntigravityToClaudeResponse(resp, "req_1", "claude-sonnet-4-5", 12)
+	if claudeResp.StopReason != StopReasonRefusal {
+		t.Errorf("Expected stop_reason refusal, got %q", claudeResp.StopReason)
+	}
+	if claudeResp.Content == nil || len(claudeResp.Content) != 0 {
+		t.Errorf("Expected empty content array, got %+v", claudeResp.Content)
+	}
+}
+
+func FuzzConvertClaudeContentToParts(f *testing.F) {
+	f.Add(`"hello"`)
+	f.Add(`[{"type":"text","text":"hi"},{"type":"thinking","thinking":"hmm","signature":"sig"}]`)
+	f.Add(`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"London"}}]`)
+	f.Add(`[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"{\"ok\":true}"}],"is_error":false}]`)
+	f.Add(`[{"type":123,"text":456},{"type":"text","text":["x"]},{"type":"tool_use","input":"oops","name":7}]`)
+	f.Add(`[null,1,"x",{"type":"tool_result","content":42,"is_error":"yes"}]`)
+
+	toolIDToName := map[string]string{"toolu_1": "get_weather"}
+	f.Fuzz(func(t *testing.T, data string) {
+		var content interface{}
+		if err := json.Unmarshal([]byte(data), &content); err != nil {
+			return
+		}
+
+		parts := convertClaudeContentToParts(content, toolIDToName)
+
+		// 签名只允许出现在一个 Part 上
+		signed := 0
+		for _, part := range parts {
+			if part.ThoughtSignature != "" {
+				signed++
+			}
+		}
+		if signed > 1 {
+			t.Errorf("thoughtSignature applied to %d parts", signed)
+		}
+	})
+}
+
+func TestPrefillContinuation(t *testing.T) {
+	req := &ClaudeMessagesRequest{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 1024,
+		Messages: []ClaudeMessage{
+			{Role: "user", Content: "Give me JSON"},
+			{Role: "assistant", Content: `{"name": `},
+		},
+	}
+
+	antireq, err := ConvertClaudeToAntigravity(req, &store.Account{ProjectID: "test-project"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	contents := antireq.Request.Contents
+	last := contents[len(contents)-1]
+	if last.Role != "model" || last.Parts[len(last.Parts)-1].Text != `{"name":` {
+		t.Fatalf("expected trailing model turn with trimmed prefill, got %+v", last)
+	}
+	si := antireq.Request.SystemInstruction
+	if si == nil || si.Parts[len(si.Parts)-1].Text != prefillInstruction {
+		t.Fatalf("expected continuation instruction, got %+v", si)
+	}
+
+	prefill := PrefillText(req)
+	if got := StripPrefill(`{"name": "Ada"}`, prefill); got != ` "Ada"}` {
+		t.Errorf("StripPrefill repeated = %q", got)
+	}
+	if got := StripPrefill(` "Ada"}`, prefill); got != ` "Ada"}` {
+		t.Errorf("StripPrefill continuation = %q", got)
+	}
+}
+
+func TestSSEEmitterStripsPrefill(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"repeated across chunks", []string{`{"na`, `me":`, ` "Ada"}`}, ` "Ada"}`},
+		{"continuation only", []string{` "Ada"}`}, ` "Ada"}`},
+		{"partial match at end", []string{`{"na`}, `{"na`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e := NewSSEEmitter(w, "req", "claude-sonnet-4-5", 0)
+			e.SetPrefill(`{"name":`)
+			e.Start()
+			for _, c := range tt.chunks {
+				if err := e.ProcessPart(StreamDataPart{Text: c}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			e.Finish(nil)
+
+			var got string
+			for _, line := range strings.Split(w.Body.String(), "\n") {
+				if !strings.HasPrefix(line, "data: ") {
+					continue
+				}
+				var ev struct {
+					Delta struct {
+						Type string `json:"type"`
+						Text string `json:"text"`
+					} `json:"delta"`
+				}
+				if json.Unmarshal([]byte(line[6:]), &ev) == nil && ev.Delta.Type == "text_delta" {
+					got += ev.Delta.Text
+				}
+			}
+			if got != tt.want {
+				t.Errorf("streamed text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertClaudeToolChoice(t *testing.T) {
+	tests := []struct {
+		choice  string
+		mode    string
+		allowed string
+	}{
+		{`null`, "AUTO", ""},
+		{`{"type":"auto"}`, "AUTO", ""},
+		{`{"type":"any"}`, "ANY", ""},
+		{`{"type":"tool","name":"get_weather"}`, "ANY", "get_weather"},
+		{`{"type":"none"}`, "NONE", ""},
+	}
+	for _, tt := range tests {
+		var choice interface{}
+		json.Unmarshal([]byte(tt.choice), &choice)
+		req := &ClaudeMessagesRequest{
+			Model:      "claude-sonnet-4-5",
+			MaxTokens:  1024,
+			Messages:   []ClaudeMessage{{Role: "user", Content: "weather?"}},
+			Tools:      []ClaudeTool{{Name: "get_weather", InputSchema: map[string]interface{}{"type": "object"}}},
+			ToolChoice: choice,
+		}
+		antireq, err := ConvertClaudeToAntigravity(req, &store.Account{ProjectID: "p", SessionID: "s"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.choice, err)
+		}
+		fc := antireq.Request.ToolConfig.FunctionCallingConfig
+		if fc.Mode != tt.mode || strings.Join(fc.AllowedFunctionNames, ",") != tt.allowed {
+			t.Errorf("%s: expected %s [%s], got %s %v", tt.choice, tt.mode, tt.allowed, fc.Mode, fc.AllowedFunctionNames)
+		}
+	}
+}
+
+func TestDisableParallelToolUse(t *testing.T) {
+	req := &ClaudeMessagesRequest{ToolChoice: map[string]interface{}{"type": "auto", "disable_parallel_tool_use": true}}
+	if !req.singleToolUse() {
+		t.Fatal("expected disable_parallel_tool_use to request a single tool use")
+	}
+
+	resp := &ClaudeMessagesResponse{Content: []ClaudeContentBlock{
+		{Type: "text", Text: "checking"},
+		{Type: "tool_use", ID: "toolu_1", Name: "get_weather"},
+		{Type: "tool_use", ID: "toolu_2", Name: "get_time"},
+	}}
+	keepFirstToolUse(resp)
+	if len(resp.Content) != 2 || resp.Content[1].ID != "toolu_1" {
+		t.Errorf("expected text and first tool_use only, got %+v", resp.Content)
+	}
+
+	w := httptest.NewRecorder()
+	e := NewSSEEmitter(w, "req", "claude-sonnet-4-5", 0)
+	e.SetSingleToolUse(true)
+	e.Start()
+	for _, name := range []string{"get_weather", "get_time"} {
+		if err := e.ProcessPart(StreamDataPart{FunctionCall: &FunctionCall{Name: name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Finish(nil)
+	if body := w.Body.String(); !strings.Contains(body, "get_weather") || strings.Contains(body, "get_time") {
+		t.Errorf("expected only the first tool_use to be streamed, got %s", body)
+	}
+}
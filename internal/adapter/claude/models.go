@@ -0,0 +1,38 @@
+package claude
+
+import "time"
+
+// ClaudeModelInfo Anthropic 模型列表中的模型
+type ClaudeModelInfo struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ClaudeModelsResponse Anthropic 模型列表响应（一次返回全部模型，不分页）
+type ClaudeModelsResponse struct {
+	Data    []ClaudeModelInfo `json:"data"`
+	HasMore bool              `json:"has_more"`
+	FirstID *string           `json:"first_id"`
+	LastID  *string           `json:"last_id"`
+}
+
+// GetClaudeModels 构建 Anthropic 格式的模型列表
+func GetClaudeModels(models []Model) *ClaudeModelsResponse {
+	resp := &ClaudeModelsResponse{Data: make([]ClaudeModelInfo, 0, len(models))}
+	for _, m := range models {
+		resp.Data = append(resp.Data, GetClaudeModel(m))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = &resp.Data[0].ID
+		resp.LastID = &resp.Data[len(resp.Data)-1].ID
+	}
+	return resp
+}
+
+// GetClaudeModel 构建 Anthropic 格式的单个模型；发布时间未知时 created_at 为 Unix 纪元
+func GetClaudeModel(m Model) ClaudeModelInfo {
+	createdAt := time.Unix(m.Created, 0).UTC().Format(time.RFC3339)
+	return ClaudeModelInfo{Type: "model", ID: m.ID, DisplayName: m.ID, CreatedAt: createdAt}
+}
@@ -3,6 +3,7 @@ package claude
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/bytedance/sonic"
@@ -11,6 +12,9 @@ import (
 	"anti2api-golang/internal/utils"
 )
 
+// sseJSON 按 key 排序序列化，保证同一上游流产生逐字节一致的 SSE 输出
+var sseJSON = sonic.Config{SortMapKeys: true}.Froze()
+
 // StreamData 原始流式数据（从 vertex 包复制，用于解耦）
 type StreamData struct {
 	Response struct {
@@ -37,22 +41,47 @@ type StreamDataPart struct {
 	ThoughtSignature string
 }
 
+// 内容块类型（tool_use 块一次性发送，不会保持打开）
+const (
+	blockText     = "text"
+	blockThinking = "thinking"
+)
+
+// openBlock 当前打开的内容块
+type openBlock struct {
+	index     int
+	kind      string
+	signature string // thinking 块待发送的 signature，在 content_block_stop 之前发送
+}
+
 // SSEEmitter Claude SSE 发射器
+//
+// 输出遵循 Anthropic 流式事件语法：
+//
+//	message_start (content_block_start content_block_delta* content_block_stop)* message_delta message_stop
+//
+// 同一时刻至多打开一个内容块，索引从 0 连续递增，切换块类型前先关闭当前块；
+// 增量只写入当前打开的块，signature_delta 是 thinking 块关闭前的最后一个增量。
 type SSEEmitter struct {
-	w                      http.ResponseWriter
-	requestID              string
-	model                  string
-	inputTokens            int
-	nextIndex              int
-	textBlockIndex         *int
-	thinkingBlockIndex     *int
-	finished               bool
-	totalOutputTokens      int
-	hasToolCalls           bool   // 记录是否遇到过工具调用
-	pendingSignature       string // 待发送的 thinking block signature
-	signatureSent          bool   // 标记 signature 是否已发送
-	lastThinkingBlockIndex *int   // 记录最近一个思考块的索引，用于处理迟到的 signature
-	mu                     sync.Mutex
+	w                 http.ResponseWriter
+	requestID         string
+	model             string
+	inputTokens       int
+	nextIndex         int
+	open              *openBlock
+	started           bool
+	finished          bool
+	totalOutputTokens int
+	hasToolCalls      bool   // 记录是否遇到过工具调用
+	unsignedThinking  bool   // 最近关闭的 thinking 块没有 signature
+	lateSignature     string // thinking 块关闭后才到达的 signature，在下一个块边界以空 thinking 块补发
+	lastSignature     string // 最近发送的 signature，避免同一 signature 重复发送
+	prefill           string // 待剥离的 prefill 文本（上游可能在续写前重复）
+	prefillBuf        string // 尚无法判断是否为 prefill 重复的文本
+	fineGrainedTools  bool   // fine-grained-tool-streaming：工具参数分片下发
+	stopReason        string // 覆盖默认的 stop_reason（提示词被拦截时为 refusal，达到输出上限时为 max_tokens）
+	singleToolUse     bool   // disable_parallel_tool_use：只下发第一个工具调用
+	mu                sync.Mutex
 	// 用于收集原始 JSON 以便日志记录（透传）
 	collectedEvents []map[string]interface{}
 }
@@ -67,89 +96,47 @@ func NewSSEEmitter(w http.ResponseWriter, requestID string, model string, inputT
 	}
 
 	return &SSEEmitter{
-		w:                      w,
-		requestID:              requestID,
-		model:                  model,
-		inputTokens:            inputTokens,
-		nextIndex:              0,
-		textBlockIndex:         nil,
-		thinkingBlockIndex:     nil,
-		finished:               false,
-		totalOutputTokens:      0,
-		pendingSignature:       "",
-		signatureSent:          false,
-		lastThinkingBlockIndex: nil,
-		collectedEvents:        nil,
+		w:           w,
+		requestID:   requestID,
+		model:       model,
+		inputTokens: inputTokens,
 	}
 }
 
 // ProcessData 处理 Vertex 原始流式数据并转换为 Claude 格式
 func (e *SSEEmitter) ProcessData(data *StreamData) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
 	if len(data.Response.Candidates) == 0 {
 		return nil
 	}
 
 	candidate := data.Response.Candidates[0]
-
+	parts := make([]StreamDataPart, 0, len(candidate.Content.Parts))
 	for _, part := range candidate.Content.Parts {
-		// 捕获 thinking block 的 signature (无论 thought 是否为 true)
-		if part.ThoughtSignature != "" {
-			e.pendingSignature = part.ThoughtSignature
-		}
-
-		if part.Thought {
-			// 1. 处理 Thinking
-			if err := e.sendThinkingLocked(part.Text); err != nil {
-				return err
-			}
-		} else if part.Text != "" {
-			// 2. 处理普通文本
-			if err := e.sendTextLocked(part.Text); err != nil {
-				return err
-			}
-		} else if part.FunctionCall != nil {
-			// 3. 处理工具调用
-			id := part.FunctionCall.ID
-			if id == "" {
-				id = utils.GenerateToolCallID()
-			}
-
-			// 单个 tool call
-			tc := core.ToolCallInfo{
-				ID:               id,
-				Name:             part.FunctionCall.Name,
-				Args:             part.FunctionCall.Args,
-				ThoughtSignature: part.ThoughtSignature,
-			}
-			if err := e.sendToolCallLocked(tc); err != nil {
-				return err
-			}
-		}
+		parts = append(parts, StreamDataPart{
+			Text:             part.Text,
+			FunctionCall:     part.FunctionCall,
+			Thought:          part.Thought,
+			ThoughtSignature: part.ThoughtSignature,
+		})
 	}
-
-	return nil
+	return e.ProcessChunk(parts)
 }
 
-// SetSignature 显式设置 signature，并尝试在合适时机发送
-func (e *SSEEmitter) SetSignature(signature string) error {
+// ProcessChunk 按序处理一个上游 chunk 中的所有 Part
+// Gemini 常把 signature 放在思考内容之后的 Part 上（如紧随其后的空文本 Part），
+// 关闭思考块前先在本 chunk 内向后查找，使 signature 落在所属的思考块上
+func (e *SSEEmitter) ProcessChunk(parts []StreamDataPart) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if signature == "" {
-		return nil
-	}
-
-	e.pendingSignature = signature
-
-	// 如果当前思考块还在打开，只需要存着，closeThinkingBlock 会发送
-	// 如果思考块已经“关闭”了（thinkingBlockIndex == nil），但我们有上一个索引，立即补发
-	if e.thinkingBlockIndex == nil && e.lastThinkingBlockIndex != nil {
-		return e.sendSignatureDeltaLocked(signature)
+	for i, part := range parts {
+		if e.open != nil && e.open.kind == blockThinking && e.open.signature == "" && closesThinking(part) {
+			e.open.signature = lookaheadSignature(parts[i:])
+		}
+		if err := e.processPartLocked(part); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
@@ -157,21 +144,51 @@ func (e *SSEEmitter) SetSignature(signature string) error {
 func (e *SSEEmitter) ProcessPart(part StreamDataPart) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	return e.processPartLocked(part)
+}
 
-	// 捕获 thinking block 的 signature (无论 thought 是否为 true)
-	if part.ThoughtSignature != "" {
-		e.pendingSignature = part.ThoughtSignature
-		// 尝试补发（如果块已关闭）
-		if e.thinkingBlockIndex == nil && e.lastThinkingBlockIndex != nil {
-			e.sendSignatureDeltaLocked(part.ThoughtSignature)
+// closesThinking Part 是否会结束当前打开的思考块
+func closesThinking(part StreamDataPart) bool {
+	return !part.Thought && (part.Text != "" || part.FunctionCall != nil)
+}
+
+// lookaheadSignature 返回下一段思考内容开始前出现的第一个 signature
+func lookaheadSignature(parts []StreamDataPart) string {
+	for _, part := range parts {
+		if part.ThoughtSignature != "" {
+			return part.ThoughtSignature
+		}
+		if part.Thought && part.Text != "" {
+			break
 		}
 	}
+	return ""
+}
+
+// processPartLocked 处理单个 Part（内部，需持有锁）
+func (e *SSEEmitter) processPartLocked(part StreamDataPart) error {
+	if e.finished {
+		return nil
+	}
 
+	// 思考 Part 上的 signature 属于它所在的思考块，先发送内容再捕获
 	if part.Thought {
-		return e.sendThinkingLocked(part.Text)
-	} else if part.Text != "" {
+		if err := e.sendThinkingLocked(part.Text); err != nil {
+			return err
+		}
+		e.captureSignatureLocked(part.ThoughtSignature)
+		return nil
+	}
+
+	// 其他 Part 上的 signature 属于此前的思考块 (无论 thought 是否为 true)
+	e.captureSignatureLocked(part.ThoughtSignature)
+
+	if part.Text != "" {
 		return e.sendTextLocked(part.Text)
 	} else if part.FunctionCall != nil {
+		if e.singleToolUse && e.hasToolCalls {
+			return nil
+		}
 		id := part.FunctionCall.ID
 		if id == "" {
 			id = utils.GenerateToolCallID()
@@ -187,9 +204,24 @@ func (e *SSEEmitter) ProcessPart(part StreamDataPart) error {
 	return nil
 }
 
+// captureSignatureLocked 将 signature 归属到思考块：
+// 思考块仍打开时在其关闭前发送；已关闭且缺少签名时留待下一个块边界补发；
+// 没有可归属的思考块（如未开启思考时函数调用上的 signature）则丢弃
+func (e *SSEEmitter) captureSignatureLocked(signature string) {
+	if signature == "" || signature == e.lastSignature {
+		return
+	}
+	switch {
+	case e.open != nil && e.open.kind == blockThinking:
+		e.open.signature = signature
+	case e.unsignedThinking:
+		e.lateSignature = signature
+	}
+}
+
 // writeSSE 写入 SSE 事件并收集原始 JSON
 func (e *SSEEmitter) writeSSE(event string, data interface{}) error {
-	jsonData, err := sonic.Marshal(data)
+	jsonData, err := sseJSON.Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -214,6 +246,15 @@ func (e *SSEEmitter) writeSSE(event string, data interface{}) error {
 func (e *SSEEmitter) Start() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	return e.startLocked()
+}
+
+// startLocked 发送 message_start 事件（只发送一次）
+func (e *SSEEmitter) startLocked() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
 
 	return e.writeSSE("message_start", ClaudeSSEMessageStart{
 		Type: "message_start",
@@ -233,66 +274,88 @@ func (e *SSEEmitter) Start() error {
 	})
 }
 
-// ensureTextBlock 确保文本块已启动
-func (e *SSEEmitter) ensureTextBlock() error {
-	if e.textBlockIndex != nil {
-		return nil
+// startBlockLocked 分配下一个索引并发送 content_block_start
+func (e *SSEEmitter) startBlockLocked(block map[string]interface{}) (int, error) {
+	if err := e.startLocked(); err != nil {
+		return 0, err
 	}
 	index := e.nextIndex
 	e.nextIndex++
-	e.textBlockIndex = &index
-
-	return e.writeSSE("content_block_start", ClaudeSSEContentBlockStart{
+	return index, e.writeSSE("content_block_start", ClaudeSSEContentBlockStart{
 		Type:         "content_block_start",
 		Index:        index,
-		ContentBlock: NewTextContentBlock(),
+		ContentBlock: block,
 	})
 }
 
-// ensureThinkingBlock 确保思考块已启动
-func (e *SSEEmitter) ensureThinkingBlock() error {
-	if e.thinkingBlockIndex != nil {
-		return nil
+// boundaryLocked 块边界：关闭当前块并补发迟到的 signature，之后可以开始新块
+func (e *SSEEmitter) boundaryLocked() error {
+	if err := e.closeBlockLocked(); err != nil {
+		return err
 	}
-	index := e.nextIndex
-	e.nextIndex++
-	e.thinkingBlockIndex = &index
-	e.lastThinkingBlockIndex = &index // 记录最近的索引
-	e.signatureSent = false           // 新块开始，重置标志
+	return e.flushLateSignatureLocked()
+}
 
-	return e.writeSSE("content_block_start", ClaudeSSEContentBlockStart{
-		Type:         "content_block_start",
-		Index:        index,
-		ContentBlock: NewThinkingContentBlock(),
-	})
+// ensureBlockLocked 确保当前打开的是指定类型的块，必要时关闭当前块并开始新块
+func (e *SSEEmitter) ensureBlockLocked(kind string) (int, error) {
+	if e.open != nil && e.open.kind == kind {
+		return e.open.index, nil
+	}
+	if err := e.boundaryLocked(); err != nil {
+		return 0, err
+	}
+
+	block := NewTextContentBlock()
+	if kind == blockThinking {
+		block = NewThinkingContentBlock()
+	}
+	index, err := e.startBlockLocked(block)
+	if err != nil {
+		return 0, err
+	}
+	e.open = &openBlock{index: index, kind: kind}
+	return index, nil
 }
 
-// closeTextBlock 关闭文本块
-func (e *SSEEmitter) closeTextBlock() error {
-	if e.textBlockIndex == nil {
+// closeBlockLocked 关闭当前块，thinking 块先发送 signature_delta
+func (e *SSEEmitter) closeBlockLocked() error {
+	if e.open == nil {
 		return nil
 	}
-	index := *e.textBlockIndex
-	e.textBlockIndex = nil
+	block := e.open
+	e.open = nil
+
+	if block.kind == blockThinking {
+		e.unsignedThinking = block.signature == ""
+		if block.signature != "" {
+			if err := e.sendSignatureDeltaLocked(block.index, block.signature); err != nil {
+				return err
+			}
+		}
+	}
+
 	return e.writeSSE("content_block_stop", ClaudeSSEContentBlockStop{
 		Type:  "content_block_stop",
-		Index: index,
+		Index: block.index,
 	})
 }
 
-// closeThinkingBlock 关闭思考块
-func (e *SSEEmitter) closeThinkingBlock() error {
-	if e.thinkingBlockIndex == nil {
+// flushLateSignatureLocked 以仅含 signature 的 thinking 块补发迟到的 signature（需无打开的块）
+func (e *SSEEmitter) flushLateSignatureLocked() error {
+	signature := e.lateSignature
+	if signature == "" {
 		return nil
 	}
-	index := *e.thinkingBlockIndex
-	e.thinkingBlockIndex = nil
+	e.lateSignature = ""
+	e.unsignedThinking = false
 
-	// 在关闭 thinking block 之前尝试发送 signature_delta
-	if e.pendingSignature != "" {
-		e.sendSignatureDeltaLocked(e.pendingSignature)
+	index, err := e.startBlockLocked(NewThinkingContentBlock())
+	if err != nil {
+		return err
+	}
+	if err := e.sendSignatureDeltaLocked(index, signature); err != nil {
+		return err
 	}
-
 	return e.writeSSE("content_block_stop", ClaudeSSEContentBlockStop{
 		Type:  "content_block_stop",
 		Index: index,
@@ -300,13 +363,9 @@ func (e *SSEEmitter) closeThinkingBlock() error {
 }
 
 // sendSignatureDeltaLocked 发送 signature_delta（内部，需持有锁）
-func (e *SSEEmitter) sendSignatureDeltaLocked(signature string) error {
-	if signature == "" || e.signatureSent || e.lastThinkingBlockIndex == nil {
-		return nil
-	}
-
-	index := *e.lastThinkingBlockIndex
-	err := e.writeSSE("content_block_delta", ClaudeSSEContentBlockDelta{
+func (e *SSEEmitter) sendSignatureDeltaLocked(index int, signature string) error {
+	e.lastSignature = signature
+	return e.writeSSE("content_block_delta", ClaudeSSEContentBlockDelta{
 		Type:  "content_block_delta",
 		Index: index,
 		Delta: ClaudeSSEDelta{
@@ -314,26 +373,62 @@ func (e *SSEEmitter) sendSignatureDeltaLocked(signature string) error {
 			Signature: signature,
 		},
 	})
+}
+
+// SetPrefill 设置 prefill 文本，正文开头与之重复的部分不会发送
+func (e *SSEEmitter) SetPrefill(prefill string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prefill = prefill
+}
 
-	if err == nil {
-		e.signatureSent = true
-		e.pendingSignature = "" // 已发送，清空
+// SetFineGrainedToolStreaming 设置是否将工具参数拆分为多个 input_json_delta 下发
+func (e *SSEEmitter) SetFineGrainedToolStreaming(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fineGrainedTools = enabled
+}
+
+// SetSingleToolUse 设置是否只下发第一个工具调用（disable_parallel_tool_use）
+func (e *SSEEmitter) SetSingleToolUse(single bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.singleToolUse = single
+}
+
+// SetStopReason 设置结束时的 stop_reason，覆盖按工具调用判断的默认值
+func (e *SSEEmitter) SetStopReason(reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopReason = reason
+}
+
+// stripPrefillLocked 缓冲正文开头直至可以判断是否重复了 prefill，返回可发送的文本
+func (e *SSEEmitter) stripPrefillLocked(text string) string {
+	if e.prefill == "" {
+		return text
 	}
-	return err
+
+	buf := e.prefillBuf + text
+	if len(buf) < len(e.prefill) && strings.HasPrefix(e.prefill, buf) {
+		e.prefillBuf = buf
+		return ""
+	}
+
+	prefill := e.prefill
+	e.prefill, e.prefillBuf = "", ""
+	return StripPrefill(buf, prefill)
 }
 
 // sendTextLocked 发送文本增量（内部）
 func (e *SSEEmitter) sendTextLocked(text string) error {
+	text = e.stripPrefillLocked(text)
 	if text == "" {
 		return nil
 	}
 
-	// 确保思考块先关闭，避免与正文交叉
-	if err := e.closeThinkingBlock(); err != nil {
-		return err
-	}
-
-	if err := e.ensureTextBlock(); err != nil {
+	index, err := e.ensureBlockLocked(blockText)
+	if err != nil {
 		return err
 	}
 
@@ -341,7 +436,7 @@ func (e *SSEEmitter) sendTextLocked(text string) error {
 
 	return e.writeSSE("content_block_delta", ClaudeSSEContentBlockDelta{
 		Type:  "content_block_delta",
-		Index: *e.textBlockIndex,
+		Index: index,
 		Delta: ClaudeSSEDelta{
 			Type: "text_delta",
 			Text: text,
@@ -355,12 +450,8 @@ func (e *SSEEmitter) sendThinkingLocked(thinking string) error {
 		return nil
 	}
 
-	// thinking 到来时关闭已有正文块，避免嵌套
-	if err := e.closeTextBlock(); err != nil {
-		return err
-	}
-
-	if err := e.ensureThinkingBlock(); err != nil {
+	index, err := e.ensureBlockLocked(blockThinking)
+	if err != nil {
 		return err
 	}
 
@@ -368,7 +459,7 @@ func (e *SSEEmitter) sendThinkingLocked(thinking string) error {
 
 	return e.writeSSE("content_block_delta", ClaudeSSEContentBlockDelta{
 		Type:  "content_block_delta",
-		Index: *e.thinkingBlockIndex,
+		Index: index,
 		Delta: ClaudeSSEDelta{
 			Type:     "thinking_delta",
 			Thinking: thinking,
@@ -380,19 +471,13 @@ func (e *SSEEmitter) sendThinkingLocked(thinking string) error {
 func (e *SSEEmitter) sendToolCallLocked(tc core.ToolCallInfo) error {
 	e.hasToolCalls = true
 
-	// 先关闭所有已有块
-	if err := e.closeTextBlock(); err != nil {
-		return err
-	}
-	if err := e.closeThinkingBlock(); err != nil {
+	// 先关闭已有块
+	if err := e.boundaryLocked(); err != nil {
 		return err
 	}
 
-	index := e.nextIndex
-	e.nextIndex++
-
 	// 序列化 args
-	argsJSON, _ := sonic.Marshal(tc.Args)
+	argsJSON, _ := sseJSON.Marshal(tc.Args)
 	args := string(argsJSON)
 	if args == "" || args == "null" {
 		args = "{}"
@@ -401,35 +486,34 @@ func (e *SSEEmitter) sendToolCallLocked(tc core.ToolCallInfo) error {
 	e.totalOutputTokens += EstimateClaudeTokens(args)
 
 	// content_block_start
-	if err := e.writeSSE("content_block_start", ClaudeSSEContentBlockStart{
-		Type:         "content_block_start",
-		Index:        index,
-		ContentBlock: NewToolUseContentBlock(tc.ID, tc.Name),
-	}); err != nil {
+	index, err := e.startBlockLocked(NewToolUseContentBlock(tc.ID, tc.Name))
+	if err != nil {
 		return err
 	}
 
 	// content_block_delta
-	if err := e.writeSSE("content_block_delta", ClaudeSSEContentBlockDelta{
-		Type:  "content_block_delta",
-		Index: index,
-		Delta: ClaudeSSEDelta{
-			Type:        "input_json_delta",
-			PartialJSON: args,
-		},
-	}); err != nil {
-		return err
+	fragments := []string{args}
+	if e.fineGrainedTools {
+		fragments = utils.SplitUTF8(args, toolInputFragmentSize)
+	}
+	for _, fragment := range fragments {
+		if err := e.writeSSE("content_block_delta", ClaudeSSEContentBlockDelta{
+			Type:  "content_block_delta",
+			Index: index,
+			Delta: ClaudeSSEDelta{
+				Type:        "input_json_delta",
+				PartialJSON: fragment,
+			},
+		}); err != nil {
+			return err
+		}
 	}
 
 	// content_block_stop
-	if err := e.writeSSE("content_block_stop", ClaudeSSEContentBlockStop{
+	return e.writeSSE("content_block_stop", ClaudeSSEContentBlockStop{
 		Type:  "content_block_stop",
 		Index: index,
-	}); err != nil {
-		return err
-	}
-
-	return nil
+	})
 }
 
 // HasToolCalls 返回是否遇到过工具调用
@@ -445,13 +529,23 @@ func (e *SSEEmitter) Finish(usage *Usage) error {
 	if e.finished {
 		return nil
 	}
+
+	// 流结束时仍在缓冲的文本不是完整的 prefill 重复，原样发送
+	if buf := e.prefillBuf; buf != "" {
+		e.prefill, e.prefillBuf = "", ""
+		e.sendTextLocked(buf)
+	}
 	e.finished = true
 
 	// 关闭所有打开的块
-	e.closeTextBlock()
-	e.closeThinkingBlock()
+	if err := e.startLocked(); err != nil {
+		return err
+	}
+	if err := e.boundaryLocked(); err != nil {
+		return err
+	}
 
-	// 计算 token
+	// 计算 token（message_delta 中的 usage 为累计值）
 	outputTokens := e.totalOutputTokens
 	inputTokens := e.inputTokens
 	if usage != nil {
@@ -464,6 +558,9 @@ func (e *SSEEmitter) Finish(usage *Usage) error {
 	}
 
 	stopReason := GetClaudeStopReason(e.hasToolCalls)
+	if e.stopReason != "" {
+		stopReason = e.stopReason
+	}
 
 	// message_delta
 	if err := e.writeSSE("message_delta", ClaudeSSEMessageDelta{
@@ -0,0 +1,341 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/core"
+)
+
+// sseEvent 解析后的 SSE 事件
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+func parseSSEEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	for _, frame := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var ev sseEvent
+		for _, line := range strings.Split(frame, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+					t.Fatalf("invalid event data %q: %v", line, err)
+				}
+			}
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// validateStreamGrammar 按 Anthropic 文档中的流式事件语法校验事件序列：
+//
+//	message_start (content_block_start content_block_delta* content_block_stop)* message_delta message_stop
+//
+// 块索引从 0 连续递增、同一时刻至多一个打开的块、增量类型与块类型匹配、
+// signature_delta 只出现在打开的 thinking 块中且是该块的最后一个增量
+func validateStreamGrammar(events []sseEvent) error {
+	if len(events) < 3 {
+		return fmt.Errorf("stream too short: %d events", len(events))
+	}
+	deltaTypes := map[string]string{
+		"text_delta":       "text",
+		"thinking_delta":   "thinking",
+		"signature_delta":  "thinking",
+		"input_json_delta": "tool_use",
+	}
+
+	var (
+		openIndex = -1
+		openType  string
+		signed    bool
+		started   int
+	)
+	for i, ev := range events {
+		typ, _ := ev.data["type"].(string)
+		if typ != ev.name {
+			return fmt.Errorf("event %d: name %q does not match type %q", i, ev.name, typ)
+		}
+		index := -1
+		if v, ok := ev.data["index"].(float64); ok {
+			index = int(v)
+		}
+
+		switch {
+		case i == 0 && typ != "message_start":
+			return fmt.Errorf("first event is %s, want message_start", typ)
+		case i == len(events)-1 && typ != "message_stop":
+			return fmt.Errorf("last event is %s, want message_stop", typ)
+		case i == len(events)-2 && typ != "message_delta":
+			return fmt.Errorf("event before message_stop is %s, want message_delta", typ)
+		}
+
+		switch typ {
+		case "message_start":
+			if i != 0 {
+				return fmt.Errorf("event %d: repeated message_start", i)
+			}
+		case "content_block_start":
+			if openIndex >= 0 {
+				return fmt.Errorf("event %d: block %d started while block %d is open", i, index, openIndex)
+			}
+			if index != started {
+				return fmt.Errorf("event %d: block index %d, want %d", i, index, started)
+			}
+			block, _ := ev.data["content_block"].(map[string]interface{})
+			openIndex, openType, signed = index, block["type"].(string), false
+			started++
+		case "content_block_delta":
+			if index != openIndex {
+				return fmt.Errorf("event %d: delta for block %d, open block is %d", i, index, openIndex)
+			}
+			delta, _ := ev.data["delta"].(map[string]interface{})
+			deltaType, _ := delta["type"].(string)
+			if deltaTypes[deltaType] != openType {
+				return fmt.Errorf("event %d: %s inside %s block", i, deltaType, openType)
+			}
+			if signed {
+				return fmt.Errorf("event %d: %s after signature_delta", i, deltaType)
+			}
+			signed = deltaType == "signature_delta"
+		case "content_block_stop":
+			if index != openIndex {
+				return fmt.Errorf("event %d: stop for block %d, open block is %d", i, index, openIndex)
+			}
+			openIndex = -1
+		case "message_delta":
+			if openIndex >= 0 {
+				return fmt.Errorf("event %d: message_delta while block %d is open", i, openIndex)
+			}
+			if i != len(events)-2 {
+				return fmt.Errorf("event %d: message_delta is not followed by message_stop", i)
+			}
+		case "message_stop":
+		default:
+			return fmt.Errorf("event %d: unexpected event %s", i, typ)
+		}
+	}
+	return nil
+}
+
+// 组合测试使用的 Part 类型
+var grammarParts = map[byte]StreamDataPart{
+	'T': {Text: "t", Thought: true},
+	'X': {Text: "x"},
+	'F': {FunctionCall: &core.FunctionCall{ID: "call", Name: "fn", Args: map[string]interface{}{}}},
+	'S': {}, // 只携带 signature 的空文本 Part
+}
+
+// buildParts 由形如 "T X* F S" 的描述构造 Part，带 * 的 Part 携带唯一 signature
+func buildParts(spec []string) []StreamDataPart {
+	parts := make([]StreamDataPart, len(spec))
+	for i, s := range spec {
+		part := grammarParts[s[0]]
+		if strings.HasSuffix(s, "*") || s == "S" {
+			part.ThoughtSignature = fmt.Sprintf("sig_%d", i)
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// emitChunks 按 chunk 划分驱动发射器并返回事件序列
+func emitChunks(t *testing.T, chunks [][]StreamDataPart) []sseEvent {
+	t.Helper()
+	w := httptest.NewRecorder()
+	e := NewSSEEmitter(w, "req", "claude-sonnet-4-5", 0)
+	e.Start()
+	for _, chunk := range chunks {
+		if err := e.ProcessChunk(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Finish(nil)
+	return parseSSEEvents(t, w.Body.String())
+}
+
+// splitChunks 按位掩码把 Part 划分为 chunk：第 i 位为 1 表示第 i 个 Part 之后断开
+func splitChunks(parts []StreamDataPart, mask int) [][]StreamDataPart {
+	var chunks [][]StreamDataPart
+	start := 0
+	for i := range parts {
+		if i == len(parts)-1 || mask&(1<<i) != 0 {
+			chunks = append(chunks, parts[start:i+1])
+			start = i + 1
+		}
+	}
+	return chunks
+}
+
+func TestSSEEmitterGrammarExhaustive(t *testing.T) {
+	alphabet := []string{"T", "T*", "X", "X*", "F", "F*", "S"}
+	const maxLen = 4
+
+	var specs [][]string
+	var grow func(prefix []string)
+	grow = func(prefix []string) {
+		if len(prefix) > 0 {
+			specs = append(specs, append([]string(nil), prefix...))
+		}
+		if len(prefix) == maxLen {
+			return
+		}
+		for _, a := range alphabet {
+			grow(append(prefix, a))
+		}
+	}
+	grow(nil)
+
+	for _, spec := range specs {
+		parts := buildParts(spec)
+		for mask := 0; mask < 1<<(len(parts)-1); mask++ {
+			events := emitChunks(t, splitChunks(parts, mask))
+			if err := validateStreamGrammar(events); err != nil {
+				t.Fatalf("%v (chunk mask %b): %v", spec, mask, err)
+			}
+
+			// 内容不丢失，signature 不重复发送
+			var thinking, text string
+			var tools int
+			sigs := map[string]bool{}
+			for _, ev := range events {
+				if block, ok := ev.data["content_block"].(map[string]interface{}); ok && block["type"] == "tool_use" {
+					tools++
+				}
+				delta, _ := ev.data["delta"].(map[string]interface{})
+				switch delta["type"] {
+				case "thinking_delta":
+					thinking += delta["thinking"].(string)
+				case "text_delta":
+					text += delta["text"].(string)
+				case "signature_delta":
+					sig := delta["signature"].(string)
+					if sigs[sig] {
+						t.Fatalf("%v (chunk mask %b): signature %s sent twice", spec, mask, sig)
+					}
+					sigs[sig] = true
+				}
+			}
+			joined := strings.Join(spec, "")
+			if want := strings.Count(joined, "T"); len(thinking) != want {
+				t.Fatalf("%v (chunk mask %b): thinking %q, want %d parts", spec, mask, thinking, want)
+			}
+			if want := strings.Count(joined, "X"); len(text) != want {
+				t.Fatalf("%v (chunk mask %b): text %q, want %d parts", spec, mask, text, want)
+			}
+			if want := strings.Count(joined, "F"); tools != want {
+				t.Fatalf("%v (chunk mask %b): %d tool_use blocks, want %d", spec, mask, tools, want)
+			}
+
+			// 思考内容之后出现的 signature 至少有一个被发送
+			if i := strings.Index(joined, "T"); i >= 0 && strings.ContainsAny(joined[i:], "*S") && len(sigs) == 0 {
+				t.Fatalf("%v (chunk mask %b): signature dropped", spec, mask)
+			}
+		}
+	}
+}
+
+func TestSSEEmitterSignaturePlacement(t *testing.T) {
+	signatureOf := func(events []sseEvent) (index int, blockType string) {
+		types := map[int]string{}
+		index = -1
+		for _, ev := range events {
+			if block, ok := ev.data["content_block"].(map[string]interface{}); ok {
+				types[int(ev.data["index"].(float64))] = block["type"].(string)
+			}
+			if delta, ok := ev.data["delta"].(map[string]interface{}); ok && delta["type"] == "signature_delta" {
+				index = int(ev.data["index"].(float64))
+			}
+		}
+		return index, types[index]
+	}
+
+	// 同一 chunk 内思考之后的 signature 落在原思考块上
+	events := emitChunks(t, [][]StreamDataPart{buildParts([]string{"T", "X", "S"})})
+	if index, typ := signatureOf(events); index != 0 || typ != "thinking" {
+		t.Errorf("same-chunk signature landed on block %d (%s), want thinking block 0", index, typ)
+	}
+
+	// 后续 chunk 才到达的 signature 在下一个块边界以独立 thinking 块补发，位于工具调用之前
+	events = emitChunks(t, [][]StreamDataPart{buildParts([]string{"T", "X"}), buildParts([]string{"S", "F"})})
+	if err := validateStreamGrammar(events); err != nil {
+		t.Fatal(err)
+	}
+	if index, typ := signatureOf(events); index != 2 || typ != "thinking" {
+		t.Errorf("late signature landed on block %d (%s), want thinking block 2", index, typ)
+	}
+
+	// 未开启思考时函数调用上的 signature 不产生 thinking 块
+	events = emitChunks(t, [][]StreamDataPart{buildParts([]string{"X", "F*"})})
+	if index, _ := signatureOf(events); index != -1 {
+		t.Errorf("unexpected signature_delta on block %d", index)
+	}
+}
+
+func TestSSEEmitterIgnoresPartsAfterFinish(t *testing.T) {
+	w := httptest.NewRecorder()
+	e := NewSSEEmitter(w, "req", "claude-sonnet-4-5", 0)
+	e.ProcessPart(StreamDataPart{Text: "hi"})
+	e.Finish(&Usage{CompletionTokens: 5})
+	e.ProcessPart(StreamDataPart{Text: "late"})
+
+	events := parseSSEEvents(t, w.Body.String())
+	if err := validateStreamGrammar(events); err != nil {
+		t.Fatal(err)
+	}
+	usage, _ := events[len(events)-2].data["usage"].(map[string]interface{})
+	if usage["output_tokens"] != float64(5) {
+		t.Errorf("message_delta usage = %v, want cumulative output_tokens 5", usage)
+	}
+}
+
+func TestBetasAndFineGrainedToolStreaming(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/messages", nil)
+	r.Header.Set("anthropic-beta", "token-efficient-tools-2025-02-19, "+BetaFineGrainedToolStreaming)
+	parsed, err := (&Adapter{}).ParseRequest(r, []byte(`{"model":"claude-sonnet-4-5","max_tokens":64,"service_tier":"auto",
+		"betas":["fine-grained-tool-streaming-2025-05-14","some-future-beta"],"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := parsed.(*ClaudeMessagesRequest)
+	if req.ServiceTier != "auto" || len(req.Betas) != 3 || !req.HasBeta(BetaTokenEfficientTools) || !req.HasBeta("some-future-beta") {
+		t.Fatalf("service_tier/betas = %q %v", req.ServiceTier, req.Betas)
+	}
+
+	args := map[string]interface{}{"query": strings.Repeat("x", 300)}
+	for _, fineGrained := range []bool{false, true} {
+		w := httptest.NewRecorder()
+		e := NewSSEEmitter(w, "req", "claude-sonnet-4-5", 0)
+		e.SetFineGrainedToolStreaming(fineGrained)
+		e.ProcessPart(StreamDataPart{FunctionCall: &core.FunctionCall{ID: "call", Name: "search", Args: args}})
+		e.Finish(nil)
+
+		events := parseSSEEvents(t, w.Body.String())
+		if err := validateStreamGrammar(events); err != nil {
+			t.Fatal(err)
+		}
+		var fragments int
+		var input string
+		for _, ev := range events {
+			if delta, ok := ev.data["delta"].(map[string]interface{}); ok && delta["type"] == "input_json_delta" {
+				fragments++
+				input += delta["partial_json"].(string)
+			}
+		}
+		want, _ := json.Marshal(args)
+		if input != string(want) {
+			t.Errorf("fine-grained=%v: reassembled input %s", fineGrained, input)
+		}
+		if fineGrained != (fragments > 1) {
+			t.Errorf("fine-grained=%v: got %d input_json_delta fragments", fineGrained, fragments)
+		}
+	}
+}
@@ -113,6 +113,8 @@ type ClaudeMessagesRequest struct {
 	ToolChoice    interface{}     `json:"tool_choice,omitempty"`
 	Thinking      *ClaudeThinking `json:"thinking,omitempty"`
 	Metadata      *ClaudeMetadata `json:"metadata,omitempty"`
+	ServiceTier   string          `json:"service_tier,omitempty"` // auto / standard_only，仅记录
+	Betas         []string        `json:"betas,omitempty"`        // 启用的 beta 功能（含 anthropic-beta 请求头）
 }
 
 // ClaudeMessage Claude 消息
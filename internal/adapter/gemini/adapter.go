@@ -0,0 +1,246 @@
+package gemini
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+func init() {
+	adapter.Register(&Adapter{})
+	adapter.Register(&Adapter{raw: true})
+}
+
+// ParsedRequest 解析后的 Gemini 请求（model 与 action 来自路径）
+type ParsedRequest struct {
+	Model   string
+	Stream  bool
+	Request *GeminiRequest
+	// StripThoughts 从转换后的响应中移除 thought: true 的 parts（原始透传模式不受影响）
+	StripThoughts bool
+}
+
+// ModelName 实现 adapter.Request
+func (r *ParsedRequest) ModelName() string { return r.Model }
+
+// SetModelName 实现 adapter.Request
+func (r *ParsedRequest) SetModelName(model string) { r.Model = model }
+
+// IsStream 实现 adapter.Request
+func (r *ParsedRequest) IsStream() bool { return r.Stream }
+
+// Body 实现 adapter.Request
+func (r *ParsedRequest) Body() interface{} { return r.Request }
+
+// Adapter Gemini 协议适配器
+// raw 为 true 时直接透传 Antigravity 响应（/gemini 前缀）
+type Adapter struct {
+	raw bool
+}
+
+// Name 协议名称
+func (a *Adapter) Name() string {
+	if a.raw {
+		return adapter.ProtocolGeminiRaw
+	}
+	return adapter.ProtocolGemini
+}
+
+// ParseRequest 解析 Gemini 请求，model 与 action 由路由通过 PathValue 传入
+func (a *Adapter) ParseRequest(r *http.Request, body []byte) (adapter.Request, error) {
+	var req GeminiRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return &ParsedRequest{
+		Model:         r.PathValue("model"),
+		Stream:        r.PathValue("action") == "streamGenerateContent",
+		Request:       &req,
+		StripThoughts: !a.raw && !includeThoughts(r),
+	}, nil
+}
+
+// includeThoughts 是否在响应中保留思考内容：?thoughts=true|false 优先，否则使用 GEMINI_INCLUDE_THOUGHTS
+func includeThoughts(r *http.Request) bool {
+	if v, err := strconv.ParseBool(r.URL.Query().Get("thoughts")); err == nil {
+		return v
+	}
+	return config.Get().GeminiIncludeThoughts
+}
+
+// Convert 转换为 Antigravity 请求
+func (a *Adapter) Convert(req adapter.Request, account *store.Account) (*core.AntigravityRequest, error) {
+	parsed := req.(*ParsedRequest)
+	if err := normalizeToolConfig(parsed.Request.ToolConfig, parsed.Request.Tools); err != nil {
+		return nil, err
+	}
+	return ConvertGeminiToAntigravity(parsed.Model, parsed.Request, account), nil
+}
+
+// EmitResponse 写出非流式响应
+func (a *Adapter) EmitResponse(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*adapter.Result, error) {
+	var body interface{} = resp
+	if !a.raw {
+		gemResp := ExtractGeminiResponse(resp)
+		if req.(*ParsedRequest).StripThoughts {
+			gemResp.Candidates = stripThoughtCandidates(gemResp.Candidates)
+		}
+		body = gemResp
+	}
+	adapter.WriteJSON(w, http.StatusOK, body)
+	return &adapter.Result{Body: body, Output: responseText(resp.Response.Candidates)}, nil
+}
+
+// EmitStream 转发上游流式数据并收集日志
+func (a *Adapter) EmitStream(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*adapter.Result, error) {
+	defer upstream.Body.Close()
+
+	stripThoughts := req.(*ParsedRequest).StripThoughts
+
+	// 设置流式响应头
+	vertex.SetStreamHeaders(w)
+
+	// 处理 gzip
+	var reader io.Reader = upstream.Body
+	if upstream.Header.Get("Content-Encoding") == "gzip" {
+		gzReader, err := gzip.NewReader(upstream.Body)
+		if err != nil {
+			vertex.WriteStreamError(w, err.Error())
+			return &adapter.Result{}, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	// 16MB 缓冲区
+	scanner := bufio.NewScanner(reader)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 16*1024*1024)
+
+	// 收集所有 parts 用于构建原始响应
+	var allParts []core.Part
+	var finishReason string
+	var usage *core.UsageMetadata
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data: ") {
+			// 收集数据用于日志
+			jsonData := line[6:]
+			if jsonData != "[DONE]" {
+				var data vertex.StreamData
+				if json.Unmarshal([]byte(jsonData), &data) == nil {
+					if len(data.Response.Candidates) > 0 {
+						candidate := data.Response.Candidates[0]
+						if candidate.FinishReason != "" {
+							finishReason = candidate.FinishReason
+						}
+						for _, part := range candidate.Content.Parts {
+							allParts = append(allParts, core.Part{
+								Text:             part.Text,
+								Thought:          part.Thought,
+								ThoughtSignature: part.ThoughtSignature,
+								FunctionCall:     part.FunctionCall,
+								InlineData:       part.InlineData,
+							})
+						}
+					}
+					if data.Response.UsageMetadata != nil {
+						usage = data.Response.UsageMetadata
+					}
+				}
+			}
+			if !a.raw {
+				// 转换行格式（过滤思考内容后为空的数据块不再发送）
+				if transformed := transformStreamLine(line, stripThoughts); transformed != "" {
+					fmt.Fprintf(w, "%s\n\n", transformed)
+				}
+			}
+		}
+		if a.raw {
+			// 原始透传：不转换，保留所有行
+			fmt.Fprintf(w, "%s\n", line)
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	// 构建合并后的响应用于日志（提高可读性）
+	mergedResp := &core.AntigravityResponse{}
+	mergedResp.Response.Candidates = []core.Candidate{
+		{
+			Content: core.Content{
+				Role:  "model",
+				Parts: core.MergeParts(allParts),
+			},
+			FinishReason: finishReason,
+		},
+	}
+	mergedResp.Response.UsageMetadata = usage
+
+	result := &adapter.Result{
+		Backend:      mergedResp,
+		Body:         mergedResp,
+		Output:       responseText(mergedResp.Response.Candidates),
+		FinishReason: finishReason,
+		Usage:        usage,
+	}
+	if !a.raw {
+		// Gemini API 客户端响应格式与 Vertex 类似
+		gemResp := ExtractGeminiResponse(mergedResp)
+		if stripThoughts {
+			gemResp.Candidates = stripThoughtCandidates(gemResp.Candidates)
+		}
+		result.Body = gemResp
+	}
+
+	return result, scanner.Err()
+}
+
+// WriteError 写入错误响应
+func (a *Adapter) WriteError(w http.ResponseWriter, status int, message string) {
+	adapter.WriteJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    adapter.ErrorType(status),
+		},
+	})
+}
+
+// WriteDetailedError 写入带错误码与附加字段的错误响应
+func (a *Adapter) WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	adapter.WriteJSON(w, status, map[string]interface{}{
+		"error": adapter.ErrorObject(status, code, message, details),
+	})
+}
+
+// WriteStreamError 流式请求在响应头发送前失败，直接返回 JSON 错误
+func (a *Adapter) WriteStreamError(w http.ResponseWriter, status int, message string) {
+	a.WriteError(w, status, message)
+}
+
+// responseText 提取候选中的正文文本
+func responseText(candidates []Candidate) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range candidates[0].Content.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,36 @@
+package gemini
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"anti2api-golang/internal/adapter/adaptertest"
+	"anti2api-golang/internal/core"
+)
+
+func TestStreamConformance(t *testing.T) {
+	adapters := map[string]*Adapter{
+		"gemini": {},
+		"raw":    {raw: true},
+	}
+	for dir, a := range adapters {
+		for _, name := range adaptertest.UpstreamStreams(t) {
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				r := httptest.NewRequest("POST", "/v1beta/models/gemini-3-pro:streamGenerateContent", nil)
+				r.SetPathValue("model", "gemini-3-pro")
+				r.SetPathValue("action", "streamGenerateContent")
+				req, err := a.ParseRequest(r, []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				w := httptest.NewRecorder()
+				if _, err := a.EmitStream(w, req, &core.AntigravityRequest{RequestID: "agent-golden"}, adaptertest.Upstream(t, name)); err != nil {
+					t.Fatal(err)
+				}
+				adaptertest.AssertGolden(t, filepath.Join("testdata", "golden", dir, name+".sse"), w.Body.Bytes())
+			})
+		}
+	}
+}
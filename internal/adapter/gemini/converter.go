@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"strings"
 
+	"anti2api-golang/internal/adapter"
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
 	"anti2api-golang/internal/store"
 	"anti2api-golang/internal/utils"
 )
@@ -36,6 +38,9 @@ func buildGeminiGenerationConfig(reqConfig *GenerationConfig, modelName string)
 	}
 
 	if reqConfig != nil {
+		if reqConfig.CandidateCount > 1 {
+			config.CandidateCount = reqConfig.CandidateCount
+		}
 		if reqConfig.MaxOutputTokens > 0 {
 			config.MaxOutputTokens = reqConfig.MaxOutputTokens
 		}
@@ -48,6 +53,15 @@ func buildGeminiGenerationConfig(reqConfig *GenerationConfig, modelName string)
 		if reqConfig.TopK > 0 {
 			config.TopK = reqConfig.TopK
 		}
+		if reqConfig.Seed != nil {
+			config.Seed = reqConfig.Seed
+		}
+		if reqConfig.PresencePenalty != nil {
+			config.PresencePenalty = reqConfig.PresencePenalty
+		}
+		if reqConfig.FrequencyPenalty != nil {
+			config.FrequencyPenalty = reqConfig.FrequencyPenalty
+		}
 		if len(reqConfig.StopSequences) > 0 {
 			config.StopSequences = append(config.StopSequences, reqConfig.StopSequences...)
 		}
@@ -140,8 +154,13 @@ func sanitizeRequestContents(contents []Content) []Content {
 // ExtractGeminiResponse Antigravity 响应 → 标准 Gemini 响应
 func ExtractGeminiResponse(antigravityResp *AntigravityResponse) *GeminiResponse {
 	resp := &GeminiResponse{
-		Candidates:    antigravityResp.Response.Candidates,
-		UsageMetadata: antigravityResp.Response.UsageMetadata,
+		Candidates:     antigravityResp.Response.Candidates,
+		PromptFeedback: antigravityResp.Response.PromptFeedback,
+		UsageMetadata:  antigravityResp.Response.UsageMetadata,
+	}
+	// 客户端 SDK 按 candidates 数组读取结果，未返回候选时保持为空数组而不是 null
+	if resp.Candidates == nil {
+		resp.Candidates = []Candidate{}
 	}
 
 	// 清理非标准字段
@@ -160,6 +179,12 @@ func ExtractGeminiResponse(antigravityResp *AntigravityResponse) *GeminiResponse
 
 // TransformGeminiStreamLine 流式行转换
 func TransformGeminiStreamLine(line string) string {
+	return transformStreamLine(line, false)
+}
+
+// transformStreamLine 流式行转换，stripThoughts 为 true 时移除思考 parts
+// 移除后不再携带任何内容的数据块返回空字符串，调用方应跳过
+func transformStreamLine(line string, stripThoughts bool) string {
 	if !strings.HasPrefix(line, "data: ") {
 		return line
 	}
@@ -173,6 +198,9 @@ func TransformGeminiStreamLine(line string) string {
 	if resp, ok := data["response"].(map[string]interface{}); ok {
 		// 清理 candidates
 		sanitizeCandidates(resp)
+		if stripThoughts && !stripThoughtParts(resp) {
+			return ""
+		}
 		transformed, err := json.Marshal(resp)
 		if err != nil {
 			return line
@@ -213,6 +241,55 @@ func sanitizeCandidates(resp map[string]interface{}) {
 	}
 }
 
+// stripThoughtParts 移除流式数据块中 thought: true 的 parts
+// 返回数据块是否仍有需要发送的内容（正文、结束原因或用量）
+func stripThoughtParts(resp map[string]interface{}) bool {
+	keep := resp["usageMetadata"] != nil
+	candidates, _ := resp["candidates"].([]interface{})
+	for _, c := range candidates {
+		candidate, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if candidate["finishReason"] != nil {
+			keep = true
+		}
+		content, ok := candidate["content"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parts, _ := content["parts"].([]interface{})
+		filtered := make([]interface{}, 0, len(parts))
+		for _, p := range parts {
+			if part, ok := p.(map[string]interface{}); ok && part["thought"] == true {
+				continue
+			}
+			filtered = append(filtered, p)
+		}
+		content["parts"] = filtered
+		if len(filtered) > 0 {
+			keep = true
+		}
+	}
+	return keep
+}
+
+// stripThoughtCandidates 返回移除思考 parts 后的候选副本
+func stripThoughtCandidates(candidates []Candidate) []Candidate {
+	result := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		parts := make([]Part, 0, len(c.Content.Parts))
+		for _, p := range c.Content.Parts {
+			if !p.Thought {
+				parts = append(parts, p)
+			}
+		}
+		c.Content.Parts = parts
+		result[i] = c
+	}
+	return result
+}
+
 // GeminiModelsResponse Gemini 模型列表响应
 type GeminiModelsResponse struct {
 	Models []GeminiModel `json:"models"`
@@ -229,11 +306,11 @@ type GeminiModel struct {
 	SupportedGenerationMethods []string `json:"supportedGenerationMethods,omitempty"`
 }
 
-// GetGeminiModels 获取 Gemini 格式的模型列表
+// GetGeminiModels 获取 Gemini 格式的模型列表（不含 HIDDEN_MODELS 隐藏的模型）
 func GetGeminiModels() *GeminiModelsResponse {
 	models := []GeminiModel{}
 
-	for _, m := range SupportedModels {
+	for _, m := range core.VisibleModels(config.Get().HiddenModels) {
 		models = append(models, GeminiModel{
 			Name:        "models/" + m.ID,
 			DisplayName: m.ID,
@@ -247,3 +324,41 @@ func GetGeminiModels() *GeminiModelsResponse {
 
 	return &GeminiModelsResponse{Models: models}
 }
+
+// toolConfigModes 上游支持的函数调用模式
+var toolConfigModes = map[string]bool{"AUTO": true, "ANY": true, "NONE": true}
+
+// normalizeToolConfig 校验并规范化客户端传入的 toolConfig
+// mode 统一为大写；allowedFunctionNames 仅在 ANY 模式下有效，且必须是 tools 中声明的函数
+func normalizeToolConfig(tc *ToolConfig, tools []Tool) error {
+	if tc == nil || tc.FunctionCallingConfig == nil {
+		return nil
+	}
+	fc := tc.FunctionCallingConfig
+	fc.Mode = strings.ToUpper(strings.TrimSpace(fc.Mode))
+	if fc.Mode != "" && !toolConfigModes[fc.Mode] {
+		return adapter.InvalidParam("toolConfig.functionCallingConfig.mode",
+			"toolConfig.functionCallingConfig.mode must be one of AUTO, ANY, NONE, got %q", fc.Mode)
+	}
+	if len(fc.AllowedFunctionNames) == 0 {
+		return nil
+	}
+	if fc.Mode != "ANY" {
+		return adapter.InvalidParam("toolConfig.functionCallingConfig.allowedFunctionNames",
+			"allowedFunctionNames requires mode ANY, got %q", fc.Mode)
+	}
+
+	declared := make(map[string]bool)
+	for _, tool := range tools {
+		for _, fn := range tool.FunctionDeclarations {
+			declared[fn.Name] = true
+		}
+	}
+	for _, name := range fc.AllowedFunctionNames {
+		if !declared[name] {
+			return adapter.InvalidParam("toolConfig.functionCallingConfig.allowedFunctionNames",
+				"allowedFunctionNames contains %q, which is not declared in tools", name)
+		}
+	}
+	return nil
+}
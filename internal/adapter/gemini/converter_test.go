@@ -1,6 +1,7 @@
 package gemini
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -99,6 +100,27 @@ func TestSanitizeRequestContents(t *testing.T) {
 	}
 }
 
+func TestTransformStreamLineStripsThoughts(t *testing.T) {
+	thoughtOnly := `data: {"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"pondering","thought":true}]}}]}}`
+	if got := transformStreamLine(thoughtOnly, true); got != "" {
+		t.Errorf("thought-only chunk should be dropped, got %q", got)
+	}
+	if got := transformStreamLine(thoughtOnly, false); !strings.Contains(got, "pondering") {
+		t.Errorf("thoughts should be kept when not stripping, got %q", got)
+	}
+
+	mixed := `data: {"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"pondering","thought":true},{"text":"answer"}]},"finishReason":"STOP"}]}}`
+	got := transformStreamLine(mixed, true)
+	if strings.Contains(got, "pondering") || !strings.Contains(got, "answer") {
+		t.Errorf("unexpected stripped chunk: %q", got)
+	}
+
+	stripped := stripThoughtCandidates([]Candidate{{Content: Content{Parts: []Part{{Text: "t", Thought: true}, {Text: "a"}}}}})
+	if len(stripped[0].Content.Parts) != 1 || stripped[0].Content.Parts[0].Text != "a" {
+		t.Errorf("unexpected stripped candidates: %+v", stripped)
+	}
+}
+
 func TestSanitizeCandidates(t *testing.T) {
 	resp := map[string]interface{}{
 		"candidates": []interface{}{
@@ -161,6 +183,26 @@ func TestBuildGeminiGenerationConfig(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:  "Seed and penalties passthrough",
+			model: "gemini-2.5-flash",
+			reqConfig: &GenerationConfig{
+				Seed:             func() *int { v := 7; return &v }(),
+				PresencePenalty:  func() *float64 { v := 0.5; return &v }(),
+				FrequencyPenalty: func() *float64 { v := 1.2; return &v }(),
+			},
+			verify: func(t *testing.T, result *GenerationConfig) {
+				if result.Seed == nil || *result.Seed != 7 {
+					t.Errorf("Expected seed 7, got %v", result.Seed)
+				}
+				if result.PresencePenalty == nil || *result.PresencePenalty != 0.5 {
+					t.Errorf("Expected presencePenalty 0.5, got %v", result.PresencePenalty)
+				}
+				if result.FrequencyPenalty == nil || *result.FrequencyPenalty != 1.2 {
+					t.Errorf("Expected frequencyPenalty 1.2, got %v", result.FrequencyPenalty)
+				}
+			},
+		},
 		{
 			name:  "Gemini with thinking - auto add default maxOutputTokens",
 			model: "gemini-3-pro-high",
@@ -182,3 +224,35 @@ func TestBuildGeminiGenerationConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeToolConfig(t *testing.T) {
+	tools := []Tool{{FunctionDeclarations: []FunctionDeclaration{{Name: "get_weather"}, {Name: "search"}}}}
+	tests := []struct {
+		name    string
+		config  FunctionCallingConfig
+		wantErr string
+	}{
+		{name: "lowercase mode normalized", config: FunctionCallingConfig{Mode: "any", AllowedFunctionNames: []string{"search"}}},
+		{name: "invalid mode", config: FunctionCallingConfig{Mode: "required"}, wantErr: "must be one of AUTO, ANY, NONE"},
+		{name: "names without ANY", config: FunctionCallingConfig{Mode: "AUTO", AllowedFunctionNames: []string{"search"}}, wantErr: "requires mode ANY"},
+		{name: "undeclared name", config: FunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"lookup"}}, wantErr: `"lookup"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &ToolConfig{FunctionCallingConfig: &tt.config}
+			err := normalizeToolConfig(tc, tools)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if tc.FunctionCallingConfig.Mode != "ANY" {
+					t.Errorf("Expected mode ANY, got %q", tc.FunctionCallingConfig.Mode)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -37,6 +37,9 @@ type Tool = core.Tool
 // FunctionDeclaration 函数声明
 type FunctionDeclaration = core.FunctionDeclaration
 
+// PromptFeedback 提示词反馈
+type PromptFeedback = core.PromptFeedback
+
 // ToolConfig 工具配置
 type ToolConfig = core.ToolConfig
 
@@ -100,6 +103,7 @@ type GeminiRequest struct {
 
 // GeminiResponse 标准 Gemini 响应
 type GeminiResponse struct {
-	Candidates    []Candidate    `json:"candidates"`
-	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
 }
@@ -0,0 +1,414 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/signature"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
+)
+
+func init() {
+	adapter.Register(&Adapter{})
+}
+
+// 响应 ID 与时间戳来源（测试中替换为固定值）
+var (
+	newCompletionID = utils.GenerateChatCompletionID
+	nowUnix         = func() int64 { return time.Now().Unix() }
+)
+
+// thoughtSignatures 下发工具调用时记录签名，供丢弃 extra_content 的客户端回传时补齐
+var thoughtSignatures = signature.Get()
+
+// ModelName 实现 adapter.Request
+func (r *OpenAIChatRequest) ModelName() string { return r.Model }
+
+// SetModelName 实现 adapter.Request
+func (r *OpenAIChatRequest) SetModelName(model string) { r.Model = model }
+
+// IsStream 实现 adapter.Request
+func (r *OpenAIChatRequest) IsStream() bool { return r.Stream }
+
+// Body 实现 adapter.Request
+func (r *OpenAIChatRequest) Body() interface{} { return r }
+
+// EndUser 实现 adapter.EndUserRequest
+func (r *OpenAIChatRequest) EndUser() string { return r.User }
+
+// singleToolCall 是否要求只返回一个工具调用（parallel_tool_calls: false）
+// Gemini 的 toolConfig 没有限制并行调用的选项，因此由代理只保留每个 choice 的第一个工具调用
+func (r *OpenAIChatRequest) singleToolCall() bool {
+	return r.ParallelToolCalls != nil && !*r.ParallelToolCalls
+}
+
+// maxOutputTokens 输出上限：同时提供时以 max_completion_tokens 为准（max_tokens 已被 OpenAI 弃用）
+func (r *OpenAIChatRequest) maxOutputTokens() int {
+	if r.MaxCompletionTokens > 0 {
+		return r.MaxCompletionTokens
+	}
+	return r.MaxTokens
+}
+
+// keepFirstToolCall 每个 choice 只保留第一个工具调用
+func keepFirstToolCall(resp *OpenAIChatCompletion) {
+	for i := range resp.Choices {
+		if calls := resp.Choices[i].Message.ToolCalls; len(calls) > 1 {
+			resp.Choices[i].Message.ToolCalls = calls[:1]
+		}
+	}
+}
+
+// includeUsage 是否请求了 stream_options.include_usage（未设置 stream_options 时为 false）
+func (o *StreamOptions) includeUsage() bool {
+	return o != nil && o.IncludeUsage
+}
+
+// validatePenalties 校验 presence_penalty / frequency_penalty 取值范围（OpenAI 规定为 [-2, 2]），
+// 越界时直接返回 400，避免转发后由上游报出难以理解的错误
+func validatePenalties(presence, frequency *float64) error {
+	if presence != nil && (*presence < -2 || *presence > 2) {
+		return adapter.InvalidParam("presence_penalty", "presence_penalty must be between -2 and 2, got %g", *presence)
+	}
+	if frequency != nil && (*frequency < -2 || *frequency > 2) {
+		return adapter.InvalidParam("frequency_penalty", "frequency_penalty must be between -2 and 2, got %g", *frequency)
+	}
+	return nil
+}
+
+// Adapter OpenAI 协议适配器
+type Adapter struct{}
+
+// Name 协议名称
+func (a *Adapter) Name() string { return adapter.ProtocolOpenAI }
+
+// ParseRequest 解析 OpenAI 聊天请求
+func (a *Adapter) ParseRequest(r *http.Request, body []byte) (adapter.Request, error) {
+	var req OpenAIChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if err := validatePenalties(req.PresencePenalty, req.FrequencyPenalty); err != nil {
+		return nil, err
+	}
+	if req.ReasoningEffort != "" && !core.IsValidReasoningEffort(req.ReasoningEffort) {
+		return nil, adapter.InvalidParam("reasoning_effort", "reasoning_effort must be one of minimal, low, medium, high, got %q", req.ReasoningEffort)
+	}
+	// Claude 模型上游每次只返回一个候选，不支持 n > 1
+	if req.N > 1 && IsClaudeModel(req.Model) {
+		return nil, adapter.InvalidParam("n", "n > 1 is not supported for Claude models")
+	}
+	req.normalizeLegacyFunctions()
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	if err := req.fetchRemoteImages(ctx); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Convert 转换为 Antigravity 请求
+func (a *Adapter) Convert(req adapter.Request, account *store.Account) (*core.AntigravityRequest, error) {
+	return ConvertOpenAIToAntigravity(req.(*OpenAIChatRequest), account), nil
+}
+
+// EmitResponse 写出非流式响应
+func (a *Adapter) EmitResponse(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*adapter.Result, error) {
+	chatReq := req.(*OpenAIChatRequest)
+	openAIResp := ConvertToOpenAIResponse(resp, req.ModelName())
+	openAIResp.LogitBiasEmulation = EmulateLogitBias(chatReq.LogitBias)
+	if chatReq.singleToolCall() {
+		keepFirstToolCall(openAIResp)
+	}
+	if chatReq.legacyFunctions {
+		toLegacyFunctionCall(openAIResp)
+	}
+	if chatReq.Logprobs {
+		for i, candidate := range resp.Response.Candidates {
+			openAIResp.Choices[i].Logprobs = ConvertLogprobs(candidate.LogprobsResult)
+		}
+	}
+
+	responseContent := ""
+	if len(openAIResp.Choices) > 0 {
+		responseContent = openAIResp.Choices[0].Message.Content
+	}
+
+	adapter.WriteJSON(w, http.StatusOK, openAIResp)
+	return &adapter.Result{Body: openAIResp, Output: responseContent}, nil
+}
+
+// EmitStream 将上游流式响应转写为 OpenAI SSE
+func (a *Adapter) EmitStream(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*adapter.Result, error) {
+	id := newCompletionID()
+	created := nowUnix()
+
+	// NewSSEWriter 内部会设置响应头
+	streamWriter := NewSSEWriter(w, id, created, req.ModelName())
+	chatReq := req.(*OpenAIChatRequest)
+	streamWriter.SetLogitBiasEmulation(EmulateLogitBias(chatReq.LogitBias))
+	streamWriter.SetIncludeUsage(chatReq.StreamOptions.includeUsage())
+	streamWriter.SetSingleToolCall(chatReq.singleToolCall())
+	streamWriter.SetLegacyFunctions(chatReq.legacyFunctions)
+
+	// n > 1 时上游按 candidate.index 交错返回各候选，每个候选使用独立的 choice 写入器
+	writers := []*SSEWriter{streamWriter}
+	finishReasons := []string{""}
+	writerFor := func(index int) *SSEWriter {
+		for len(writers) <= index {
+			writers = append(writers, nil)
+			finishReasons = append(finishReasons, "")
+		}
+		if writers[index] == nil {
+			writers[index] = streamWriter.NewChoiceWriter(index)
+		}
+		return writers[index]
+	}
+
+	// 绑定 StreamWriter.ProcessPart 作为回调
+	streamResult, err := vertex.ParseStreamWithResult(upstream, func(data *vertex.StreamData) error {
+		for _, candidate := range data.Response.Candidates {
+			writer := writerFor(candidate.Index)
+			for _, part := range candidate.Content.Parts {
+				if err := writer.ProcessPart(StreamDataPart{
+					Text:             part.Text,
+					FunctionCall:     part.FunctionCall,
+					InlineData:       part.InlineData,
+					Thought:          part.Thought,
+					ThoughtSignature: part.ThoughtSignature,
+				}); err != nil {
+					return err
+				}
+			}
+			// 本段输出的 logprobs 以单独的 chunk 发送
+			if result := candidate.LogprobsResult; chatReq.Logprobs && result != nil {
+				if err := writer.WriteLogprobs(ConvertLogprobs(result)); err != nil {
+					return err
+				}
+			}
+			// 检查 FinishReason
+			if candidate.FinishReason != "" {
+				finishReasons[candidate.Index] = candidate.FinishReason
+			}
+		}
+		return nil
+	})
+
+	// 其余候选先各自结束，用量与 [DONE] 随第一个候选的结束 chunk 发送
+	for i, writer := range writers[1:] {
+		if writer == nil {
+			continue
+		}
+		reason := ConvertFinishReason(finishReasons[i+1], writer.HasToolCalls())
+		if chatReq.legacyFunctions && writer.HasToolCalls() {
+			reason = "function_call"
+		}
+		writer.WriteChoiceFinish(reason)
+	}
+
+	// 发送结束
+	upstreamReason := finishReasons[0]
+	if upstreamReason == "" {
+		upstreamReason = streamResult.FinishReason
+	}
+	finishReason := ConvertFinishReason(upstreamReason, streamWriter.HasToolCalls())
+	if chatReq.legacyFunctions && streamWriter.HasToolCalls() {
+		finishReason = "function_call"
+	}
+
+	var usageData *Usage
+	if streamResult.Usage != nil {
+		usageData = ConvertUsage(streamResult.Usage)
+	}
+
+	streamWriter.WriteFinish(finishReason, usageData)
+
+	merged := streamWriter.GetMergedResponse()
+	for _, writer := range writers[1:] {
+		if writer != nil {
+			merged = append(merged, writer.GetMergedResponse()...)
+		}
+	}
+
+	return &adapter.Result{
+		Body:         merged,
+		Backend:      streamResult.MergedResponse,
+		Output:       streamResult.Text,
+		FinishReason: streamResult.FinishReason,
+		Usage:        streamResult.Usage,
+	}, err
+}
+
+// WriteError 写入 OpenAI 格式错误响应
+func (a *Adapter) WriteError(w http.ResponseWriter, status int, message string) {
+	adapter.WriteJSON(w, status, map[string]interface{}{
+		"error": errorObject(status, "", message, nil),
+	})
+}
+
+// WriteCodedError 写入带错误码的 OpenAI 错误响应
+func (a *Adapter) WriteCodedError(w http.ResponseWriter, status int, code string, message string) {
+	adapter.WriteJSON(w, status, map[string]interface{}{
+		"error": errorObject(status, code, message, nil),
+	})
+}
+
+// WriteDetailedError 写入带错误码与附加字段的 OpenAI 错误响应
+func (a *Adapter) WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	adapter.WriteJSON(w, status, map[string]interface{}{
+		"error": errorObject(status, code, message, details),
+	})
+}
+
+// WriteStreamError 写入 OpenAI 流式错误
+func (a *Adapter) WriteStreamError(w http.ResponseWriter, status int, message string) {
+	SetSSEHeaders(w)
+	WriteSSEError(w, status, message)
+}
+
+// errorObject 构建 OpenAI SDK 期望的 error 对象：message、type、param、code 始终存在（未知时为 null）
+// 未给出错误码时按状态码补充常见错误码
+func errorObject(status int, code string, message string, details map[string]interface{}) map[string]interface{} {
+	if code == "" {
+		code = defaultErrorCode(status)
+	}
+	obj := adapter.ErrorObject(status, code, message, details)
+	for _, key := range []string{"param", "code"} {
+		if _, ok := obj[key]; !ok {
+			obj[key] = nil
+		}
+	}
+	return obj
+}
+
+// defaultErrorCode 按状态码返回 OpenAI 常见错误码，无对应错误码时返回空
+func defaultErrorCode(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "invalid_api_key"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_exceeded"
+	}
+	return ""
+}
+
+// StartHeartbeatStream 开始 bypass 模式的心跳流
+func (a *Adapter) StartHeartbeatStream(w http.ResponseWriter, req adapter.Request) adapter.HeartbeatStream {
+	id := newCompletionID()
+	created := nowUnix()
+	writer := NewSSEWriter(w, id, created, req.ModelName())
+	chatReq := req.(*OpenAIChatRequest)
+	writer.SetLogitBiasEmulation(EmulateLogitBias(chatReq.LogitBias))
+	writer.SetIncludeUsage(chatReq.StreamOptions.includeUsage())
+	writer.SetLegacyFunctions(chatReq.legacyFunctions)
+	return &heartbeatStream{
+		writer:          writer,
+		model:           req.ModelName(),
+		singleToolCall:  chatReq.singleToolCall(),
+		legacyFunctions: chatReq.legacyFunctions,
+	}
+}
+
+// heartbeatStream bypass 模式下的 OpenAI 流
+type heartbeatStream struct {
+	writer          *SSEWriter
+	model           string
+	singleToolCall  bool
+	legacyFunctions bool
+}
+
+func (s *heartbeatStream) Heartbeat() error {
+	if config.Get().HeartbeatStyle == "comment" {
+		return s.writer.WriteHeartbeatComment()
+	}
+	return s.writer.WriteHeartbeat()
+}
+
+func (s *heartbeatStream) Fail(message string) {
+	s.writer.WriteContent("Error: " + message)
+	s.writer.WriteFinish("stop", nil)
+}
+
+func (s *heartbeatStream) Finish(resp *core.AntigravityResponse) *adapter.Result {
+	openAIResp := ConvertToOpenAIResponse(resp, s.model)
+	if s.singleToolCall {
+		keepFirstToolCall(openAIResp)
+	}
+
+	if len(openAIResp.Choices) == 0 {
+		s.writer.WriteFinish("stop", nil)
+		return &adapter.Result{Body: openAIResp}
+	}
+
+	// 发送完整内容：n > 1 时每个候选使用独立的 choice 写入器
+	writers := make([]*SSEWriter, len(openAIResp.Choices))
+	for i, choice := range openAIResp.Choices {
+		writers[i] = s.writer
+		if i > 0 {
+			writers[i] = s.writer.NewChoiceWriter(choice.Index)
+		}
+		writeHeartbeatChoice(writers[i], choice.Message)
+	}
+
+	if s.legacyFunctions {
+		toLegacyFunctionCall(openAIResp)
+	}
+
+	// 其余候选先各自结束，用量与 [DONE] 随第一个候选的结束 chunk 发送
+	for i, writer := range writers[1:] {
+		writer.WriteChoiceFinish(choiceFinishReason(openAIResp.Choices[i+1]))
+	}
+	s.writer.WriteFinish(choiceFinishReason(openAIResp.Choices[0]), openAIResp.Usage)
+
+	return &adapter.Result{Body: openAIResp, Output: openAIResp.Choices[0].Message.Content}
+}
+
+// writeHeartbeatChoice 一次性写出单个候选的完整内容
+func writeHeartbeatChoice(writer *SSEWriter, msg Message) {
+	if reasoning := msg.reasoningText(); reasoning != "" {
+		writer.WriteReasoning(reasoning)
+	}
+	if len(msg.ToolCalls) > 0 {
+		// 转换为 core.ToolCallInfo 格式
+		coreToolCalls := make([]core.ToolCallInfo, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			var signature string
+			if tc.ExtraContent != nil && tc.ExtraContent.Google != nil {
+				signature = tc.ExtraContent.Google.ThoughtSignature
+			}
+			coreToolCalls[i] = core.ToolCallInfo{
+				ID:               tc.ID,
+				Name:             tc.Function.Name,
+				Args:             ParseArgs(tc.Function.Arguments),
+				ThoughtSignature: signature,
+			}
+		}
+		writer.WriteToolCalls(coreToolCalls)
+	}
+	if msg.Content != "" {
+		writer.WriteContent(msg.Content)
+	}
+	if len(msg.Images) > 0 {
+		writer.WriteImages(msg.Images)
+	}
+}
+
+// choiceFinishReason 候选的结束原因，缺失时为 stop
+func choiceFinishReason(choice Choice) string {
+	if choice.FinishReason != nil {
+		return *choice.FinishReason
+	}
+	return "stop"
+}
@@ -0,0 +1,264 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
+)
+
+func init() {
+	adapter.Register(&CompletionsAdapter{})
+}
+
+// newTextCompletionID 文本补全 ID 来源（测试中替换为固定值）
+var newTextCompletionID = utils.GenerateCompletionID
+
+// 文本补全通过系统指令让模型直接续写 prompt
+const (
+	completionInstruction = "You are a text completion engine. Continue the user's text exactly from where it ends. Output only the continuation, without repeating the text or adding any commentary."
+	suffixInstruction     = "The continuation will be followed by this text, so it must connect seamlessly to it:\n%s"
+)
+
+// ModelName 实现 adapter.Request
+func (r *OpenAICompletionRequest) ModelName() string { return r.Model }
+
+// SetModelName 实现 adapter.Request
+func (r *OpenAICompletionRequest) SetModelName(model string) { r.Model = model }
+
+// IsStream 实现 adapter.Request
+func (r *OpenAICompletionRequest) IsStream() bool { return r.Stream }
+
+// Body 实现 adapter.Request
+func (r *OpenAICompletionRequest) Body() interface{} { return r }
+
+// EndUser 实现 adapter.EndUserRequest
+func (r *OpenAICompletionRequest) EndUser() string { return r.User }
+
+// CompletionsAdapter 旧版 OpenAI /v1/completions 协议适配器
+// 错误格式与聊天接口一致；不支持 bypass 心跳流，bypass 模型按普通流式处理
+type CompletionsAdapter struct{}
+
+// Name 协议名称
+func (a *CompletionsAdapter) Name() string { return adapter.ProtocolOpenAICompletions }
+
+// ParseRequest 解析文本补全请求，prompt 仅支持单个字符串
+func (a *CompletionsAdapter) ParseRequest(r *http.Request, body []byte) (adapter.Request, error) {
+	var req OpenAICompletionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	switch p := req.Prompt.(type) {
+	case string:
+		req.prompt = p
+	case []interface{}:
+		if len(p) > 1 {
+			return nil, adapter.InvalidParam("prompt", "multiple prompts are not supported")
+		}
+		if len(p) == 1 {
+			s, ok := p[0].(string)
+			if !ok {
+				return nil, adapter.InvalidParam("prompt", "token array prompts are not supported")
+			}
+			req.prompt = s
+		}
+	case nil:
+	default:
+		return nil, adapter.InvalidParam("prompt", "prompt must be a string")
+	}
+	if req.prompt == "" {
+		return nil, adapter.InvalidParam("prompt", "prompt is required")
+	}
+	if err := validatePenalties(req.PresencePenalty, req.FrequencyPenalty); err != nil {
+		return nil, err
+	}
+
+	switch s := req.Stop.(type) {
+	case string:
+		req.stop = []string{s}
+	case []interface{}:
+		for _, v := range s {
+			if str, ok := v.(string); ok {
+				req.stop = append(req.stop, str)
+			}
+		}
+	}
+	return &req, nil
+}
+
+// Convert 转换为 Antigravity 请求：prompt 作为单条用户消息，续写要求放入系统指令
+func (a *CompletionsAdapter) Convert(req adapter.Request, account *store.Account) (*core.AntigravityRequest, error) {
+	completionReq := req.(*OpenAICompletionRequest)
+	antigravityReq := ConvertOpenAIToAntigravity(&OpenAIChatRequest{
+		Model:            completionReq.Model,
+		Messages:         []OpenAIMessage{{Role: "user", Content: completionReq.prompt}},
+		Temperature:      completionReq.Temperature,
+		TopP:             completionReq.TopP,
+		MaxTokens:        completionReq.MaxTokens,
+		Seed:             completionReq.Seed,
+		PresencePenalty:  completionReq.PresencePenalty,
+		FrequencyPenalty: completionReq.FrequencyPenalty,
+		Stop:             completionReq.stop,
+		LogitBias:        completionReq.LogitBias,
+	}, account)
+
+	parts := []Part{{Text: completionInstruction}}
+	if completionReq.Suffix != "" {
+		parts = append(parts, Part{Text: fmt.Sprintf(suffixInstruction, completionReq.Suffix)})
+	}
+	if antigravityReq.Request.SystemInstruction == nil {
+		antigravityReq.Request.SystemInstruction = &SystemInstruction{}
+	}
+	si := antigravityReq.Request.SystemInstruction
+	si.Parts = append(parts, si.Parts...)
+	return antigravityReq, nil
+}
+
+// EmitResponse 写出非流式文本补全响应
+func (a *CompletionsAdapter) EmitResponse(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*adapter.Result, error) {
+	completionReq := req.(*OpenAICompletionRequest)
+
+	var text strings.Builder
+	finishReason := completionFinishReason(core.NoCandidatesFinishReason)
+	if len(resp.Response.Candidates) > 0 {
+		candidate := resp.Response.Candidates[0]
+		text.WriteString(candidateText(candidate.Content.Parts))
+		finishReason = completionFinishReason(candidate.FinishReason)
+	}
+
+	output := text.String()
+	if completionReq.Echo {
+		output = completionReq.prompt + output
+	}
+
+	completion := &OpenAICompletion{
+		ID:                newTextCompletionID(),
+		Object:            "text_completion",
+		Created:           nowUnix(),
+		Model:             req.ModelName(),
+		SystemFingerprint: systemFingerprint(req.ModelName()),
+		Choices:           []CompletionChoice{{Text: output, FinishReason: &finishReason}},
+		Usage:             ConvertUsage(resp.Response.UsageMetadata),
+	}
+	adapter.WriteJSON(w, http.StatusOK, completion)
+	return &adapter.Result{Body: completion, Output: text.String()}, nil
+}
+
+// EmitStream 将上游流式响应转写为文本补全 SSE（每段正文一个 chunk，结束 chunk 携带 finish_reason）
+func (a *CompletionsAdapter) EmitStream(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*adapter.Result, error) {
+	completionReq := req.(*OpenAICompletionRequest)
+	id := newTextCompletionID()
+	created := nowUnix()
+
+	SetSSEHeaders(w)
+	writeChunk := func(text string, finishReason *string, usage *Usage) error {
+		return WriteSSEData(w, &OpenAICompletion{
+			ID:                id,
+			Object:            "text_completion",
+			Created:           created,
+			Model:             req.ModelName(),
+			SystemFingerprint: systemFingerprint(req.ModelName()),
+			Choices:           []CompletionChoice{{Text: text, FinishReason: finishReason}},
+			Usage:             usage,
+		})
+	}
+
+	var output strings.Builder
+	if completionReq.Echo {
+		output.WriteString(completionReq.prompt)
+		writeChunk(completionReq.prompt, nil, nil)
+	}
+
+	streamResult, err := vertex.ParseStreamWithResult(upstream, func(data *vertex.StreamData) error {
+		if len(data.Response.Candidates) == 0 {
+			return nil
+		}
+		for _, part := range data.Response.Candidates[0].Content.Parts {
+			if part.Thought || part.Text == "" {
+				continue
+			}
+			output.WriteString(part.Text)
+			if err := writeChunk(part.Text, nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// 用量仅在 include_usage 时以 choices 为空的独立 chunk 发送
+	finishReason := completionFinishReason(streamResult.FinishReason)
+	usage := ConvertUsage(streamResult.Usage)
+	writeChunk("", &finishReason, nil)
+	if completionReq.StreamOptions.includeUsage() && usage != nil {
+		WriteSSEData(w, &OpenAICompletion{
+			ID:                id,
+			Object:            "text_completion",
+			Created:           created,
+			Model:             req.ModelName(),
+			SystemFingerprint: systemFingerprint(req.ModelName()),
+			Choices:           []CompletionChoice{},
+			Usage:             usage,
+		})
+	}
+	WriteSSEDone(w)
+
+	merged := &OpenAICompletion{
+		ID:                id,
+		Object:            "text_completion",
+		Created:           created,
+		Model:             req.ModelName(),
+		SystemFingerprint: systemFingerprint(req.ModelName()),
+		Choices:           []CompletionChoice{{Text: output.String(), FinishReason: &finishReason}},
+		Usage:             usage,
+	}
+	return &adapter.Result{
+		Body:         merged,
+		Backend:      streamResult.MergedResponse,
+		Output:       streamResult.Text,
+		FinishReason: streamResult.FinishReason,
+		Usage:        streamResult.Usage,
+	}, err
+}
+
+// candidateText 合并非思考部分的正文
+func candidateText(parts []Part) string {
+	var b strings.Builder
+	for _, part := range parts {
+		if !part.Thought {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// completionFinishReason 将上游结束原因映射为文本补全的 finish_reason（文本补全不含工具调用）
+func completionFinishReason(reason string) string {
+	return ConvertFinishReason(reason, false)
+}
+
+// WriteError 写入 OpenAI 格式错误响应
+func (a *CompletionsAdapter) WriteError(w http.ResponseWriter, status int, message string) {
+	(&Adapter{}).WriteError(w, status, message)
+}
+
+// WriteCodedError 写入带错误码的 OpenAI 错误响应
+func (a *CompletionsAdapter) WriteCodedError(w http.ResponseWriter, status int, code string, message string) {
+	(&Adapter{}).WriteCodedError(w, status, code, message)
+}
+
+// WriteDetailedError 写入带错误码与附加字段的 OpenAI 错误响应
+func (a *CompletionsAdapter) WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	(&Adapter{}).WriteDetailedError(w, status, code, message, details)
+}
+
+// WriteStreamError 写入 OpenAI 流式错误
+func (a *CompletionsAdapter) WriteStreamError(w http.ResponseWriter, status int, message string) {
+	(&Adapter{}).WriteStreamError(w, status, message)
+}
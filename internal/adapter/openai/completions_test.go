@@ -0,0 +1,98 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/adapter/adaptertest"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+func TestCompletionsParseRequest(t *testing.T) {
+	a := &CompletionsAdapter{}
+	r := httptest.NewRequest("POST", "/v1/completions", nil)
+
+	tests := []struct {
+		body   string
+		prompt string
+		stop   []string
+		errMsg string
+	}{
+		{`{"model":"m","prompt":"def add(a, b):","stop":"\n\n","user":"u-1"}`, "def add(a, b):", []string{"\n\n"}, ""},
+		{`{"model":"m","prompt":["Once upon"],"stop":["END","."]}`, "Once upon", []string{"END", "."}, ""},
+		{`{"model":"m","prompt":["a","b"]}`, "", nil, "multiple prompts"},
+		{`{"model":"m","prompt":[[1,2,3]]}`, "", nil, "token array"},
+		{`{"model":"m"}`, "", nil, "prompt is required"},
+	}
+	for _, tt := range tests {
+		req, err := a.ParseRequest(r, []byte(tt.body))
+		if tt.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("%s: err = %v, want %q", tt.body, err, tt.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+		parsed := req.(*OpenAICompletionRequest)
+		if parsed.prompt != tt.prompt || strings.Join(parsed.stop, "|") != strings.Join(tt.stop, "|") {
+			t.Errorf("%s: prompt = %q, stop = %q", tt.body, parsed.prompt, parsed.stop)
+		}
+		if strings.Contains(tt.body, `"user"`) && req.(adapter.EndUserRequest).EndUser() != "u-1" {
+			t.Errorf("%s: user = %q", tt.body, parsed.User)
+		}
+	}
+}
+
+func TestCompletionsConvert(t *testing.T) {
+	a := &CompletionsAdapter{}
+	r := httptest.NewRequest("POST", "/v1/completions", nil)
+	req, err := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro-low","prompt":"func main() {","suffix":"}","max_tokens":64,"stop":"\n\n"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	antigravityReq, err := a.Convert(req, &store.Account{ProjectID: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := antigravityReq.Request
+	if len(inner.Contents) != 1 || inner.Contents[0].Parts[0].Text != "func main() {" {
+		t.Errorf("contents = %+v", inner.Contents)
+	}
+	parts := inner.SystemInstruction.Parts
+	if len(parts) != 2 || parts[0].Text != completionInstruction || !strings.HasSuffix(parts[1].Text, "\n}") {
+		t.Errorf("system instruction = %+v", parts)
+	}
+	gc := inner.GenerationConfig
+	if gc.MaxOutputTokens != 64 || gc.StopSequences[len(gc.StopSequences)-1] != "\n\n" {
+		t.Errorf("generation config = %+v", gc)
+	}
+}
+
+func TestCompletionsStreamConformance(t *testing.T) {
+	newTextCompletionID = func() string { return "cmpl-golden" }
+	nowUnix = func() int64 { return 1700000000 }
+
+	a := &CompletionsAdapter{}
+	for _, name := range adaptertest.UpstreamStreams(t) {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/completions", nil)
+			req, err := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro","stream":true,"stream_options":{"include_usage":true},"prompt":"hi"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			if _, err := a.EmitStream(w, req, &core.AntigravityRequest{RequestID: "agent-golden"}, adaptertest.Upstream(t, name)); err != nil {
+				t.Fatal(err)
+			}
+			adaptertest.AssertGolden(t, filepath.Join("testdata", "golden", "completions", name+".sse"), w.Body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/adapter/adaptertest"
+	"anti2api-golang/internal/core"
+)
+
+func TestStreamConformance(t *testing.T) {
+	newCompletionID = func() string { return "chatcmpl-golden" }
+	nowUnix = func() int64 { return 1700000000 }
+
+	a := &Adapter{}
+	for _, name := range adaptertest.UpstreamStreams(t) {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			req, err := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			if _, err := a.EmitStream(w, req, &core.AntigravityRequest{RequestID: "agent-golden"}, adaptertest.Upstream(t, name)); err != nil {
+				t.Fatal(err)
+			}
+			adaptertest.AssertGolden(t, filepath.Join("testdata", "golden", name+".sse"), w.Body.Bytes())
+		})
+	}
+}
+
+func TestStreamMultipleCandidates(t *testing.T) {
+	upstream := "" +
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"A"}]}},{"index":1,"content":{"parts":[{"text":"B"}]}}]}}` + "\n\n" +
+		`data: {"response":{"candidates":[{"index":1,"content":{"parts":[{"text":"b"}]},"finishReason":"MAX_TOKENS"}]}}` + "\n\n" +
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"a"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":1,"candidatesTokenCount":4,"totalTokenCount":5}}}` + "\n\n"
+
+	a := &Adapter{}
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	req, _ := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro","stream":true,"n":2,"messages":[{"role":"user","content":"hi"}]}`))
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(upstream))}
+
+	w := httptest.NewRecorder()
+	if _, err := a.EmitStream(w, req, &core.AntigravityRequest{}, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	content := map[int]string{}
+	finish := map[int]string{}
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		choice := chunk.Choices[0]
+		if choice.Delta != nil {
+			content[choice.Index] += choice.Delta.Content
+		}
+		if choice.FinishReason != nil {
+			finish[choice.Index] = *choice.FinishReason
+		}
+	}
+	if content[0] != "Aa" || content[1] != "Bb" {
+		t.Errorf("content = %v", content)
+	}
+	if finish[0] != "stop" || finish[1] != "length" {
+		t.Errorf("finish = %v", finish)
+	}
+	// 未设置 stream_options.include_usage 时不返回用量
+	if strings.Contains(w.Body.String(), `"usage"`) {
+		t.Errorf("usage without include_usage: %s", w.Body.String())
+	}
+	if !strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n") || strings.Count(w.Body.String(), "[DONE]") != 1 {
+		t.Errorf("body = %s", w.Body.String())
+	}
+}
+
+func TestHeartbeatStreamMultipleCandidates(t *testing.T) {
+	var resp core.AntigravityResponse
+	upstream := `{"response":{"candidates":[` +
+		`{"content":{"parts":[{"text":"Aa"}]},"finishReason":"STOP"},` +
+		`{"index":1,"content":{"parts":[{"text":"Bb"}]},"finishReason":"MAX_TOKENS"}],` +
+		`"usageMetadata":{"promptTokenCount":1,"candidatesTokenCount":4,"totalTokenCount":5}}}`
+	if err := json.Unmarshal([]byte(upstream), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Adapter{}
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	req, _ := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro-bypass","stream":true,"n":2,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`))
+
+	w := httptest.NewRecorder()
+	stream := a.StartHeartbeatStream(w, req)
+	stream.Heartbeat()
+	result := stream.Finish(&resp)
+
+	content := map[int]string{}
+	finish := map[int]string{}
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.Delta != nil {
+			content[choice.Index] += choice.Delta.Content
+		}
+		if choice.FinishReason != nil {
+			finish[choice.Index] = *choice.FinishReason
+		}
+	}
+	if content[0] != "Aa" || content[1] != "Bb" {
+		t.Errorf("content = %v", content)
+	}
+	if finish[0] != "stop" || finish[1] != "length" {
+		t.Errorf("finish = %v", finish)
+	}
+	// 用量与 [DONE] 只在最后发送一次
+	body := w.Body.String()
+	if strings.Count(body, `"usage"`) != 1 || !strings.HasSuffix(body, "data: [DONE]\n\n") || strings.Count(body, "[DONE]") != 1 {
+		t.Errorf("body = %s", body)
+	}
+	if result.Output != "Aa" {
+		t.Errorf("output = %q", result.Output)
+	}
+}
+
+func TestStreamParallelToolCallsDisabled(t *testing.T) {
+	a := &Adapter{}
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	req, _ := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro","stream":true,"parallel_tool_calls":false,"messages":[{"role":"user","content":"hi"}]}`))
+
+	w := httptest.NewRecorder()
+	if _, err := a.EmitStream(w, req, &core.AntigravityRequest{}, adaptertest.Upstream(t, "tool_call")); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"id":"call_weather_1"`) || strings.Contains(body, `"id":"call_time_2"`) {
+		t.Errorf("expected only the first tool call: %s", body)
+	}
+}
+
+func TestStreamLegacyFunctionCall(t *testing.T) {
+	a := &Adapter{}
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	req, _ := a.ParseRequest(r, []byte(`{"model":"gemini-3-pro","stream":true,"functions":[{"name":"get_weather","parameters":{"type":"object"}}],"messages":[{"role":"user","content":"hi"}]}`))
+
+	w := httptest.NewRecorder()
+	if _, err := a.EmitStream(w, req, &core.AntigravityRequest{}, adaptertest.Upstream(t, "tool_call")); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, `"tool_calls"`) || !strings.Contains(body, `"function_call":{"name":"get_weather"`) {
+		t.Errorf("expected a legacy function_call delta: %s", body)
+	}
+	if strings.Contains(body, `"name":"get_time"`) {
+		t.Errorf("legacy format should only return the first call: %s", body)
+	}
+	if !strings.Contains(body, `"finish_reason":"function_call"`) {
+		t.Errorf("expected finish_reason function_call: %s", body)
+	}
+}
+
+func TestStreamToolCallFragments(t *testing.T) {
+	w := httptest.NewRecorder()
+	sw := NewSSEWriter(w, "chatcmpl-frag", 1700000000, "gemini-3-pro")
+	long := strings.Repeat("天气", 60)
+	calls := []*core.FunctionCall{
+		{ID: "call_a", Name: "search", Args: map[string]interface{}{"query": long}},
+		{ID: "call_b", Name: "get_time", Args: map[string]interface{}{"zone": "UTC"}},
+	}
+	for _, call := range calls {
+		if err := sw.ProcessPart(StreamDataPart{FunctionCall: call}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type assembled struct {
+		id, name, args string
+		chunks         int
+	}
+	got := map[int]*assembled{}
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range chunk.Choices[0].Delta.ToolCalls {
+			a := got[tc.Index]
+			if a == nil {
+				// 首个片段携带 id、type 与函数名
+				if tc.ID == "" || tc.Type != "function" || tc.Function.Name == "" || tc.Function.Arguments != "" {
+					t.Fatalf("first fragment of call %d should carry id/name only: %s", tc.Index, line)
+				}
+				a = &assembled{id: tc.ID, name: tc.Function.Name}
+				got[tc.Index] = a
+			} else if tc.ID != "" || tc.Function.Name != "" {
+				t.Fatalf("later fragments of call %d should only carry arguments: %s", tc.Index, line)
+			}
+			a.args += tc.Function.Arguments
+			a.chunks++
+		}
+	}
+
+	for i, call := range calls {
+		a := got[i]
+		want, _ := json.Marshal(call.Args)
+		if a == nil || a.id != call.ID || a.name != call.Name || a.args != string(want) {
+			t.Fatalf("call %d assembled to %+v, want %s(%s)", i, a, call.Name, want)
+		}
+	}
+	if got[0].chunks < 3 {
+		t.Errorf("long arguments should be split into several fragments, got %d chunks", got[0].chunks)
+	}
+}
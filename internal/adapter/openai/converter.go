@@ -1,13 +1,17 @@
 package openai
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"strings"
 	"time"
 
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
 	"anti2api-golang/internal/store"
 	"anti2api-golang/internal/utils"
 )
@@ -40,13 +44,19 @@ func ConvertOpenAIToAntigravity(req *OpenAIChatRequest, account *store.Account)
 		}
 	}
 
+	// logit_bias 中"禁止输出"的条目以系统指令约束模拟
+	if constraint := EmulateLogitBias(req.LogitBias).instruction(); constraint != "" {
+		if innerReq.SystemInstruction == nil {
+			innerReq.SystemInstruction = &SystemInstruction{}
+		}
+		innerReq.SystemInstruction.Parts = append(innerReq.SystemInstruction.Parts, Part{Text: constraint})
+	}
+
 	// 转换工具
 	if len(req.Tools) > 0 {
 		innerReq.Tools = ConvertOpenAIToolsToAntigravity(req.Tools)
 		innerReq.ToolConfig = &ToolConfig{
-			FunctionCallingConfig: &FunctionCallingConfig{
-				Mode: "AUTO",
-			},
+			FunctionCallingConfig: convertToolChoice(req.ToolChoice),
 		}
 	}
 
@@ -57,6 +67,27 @@ func ConvertOpenAIToAntigravity(req *OpenAIChatRequest, account *store.Account)
 	return antigravityReq
 }
 
+// convertToolChoice 将 tool_choice 映射为函数调用模式
+// "none" → NONE，"required" → ANY，指定函数 → ANY + AllowedFunctionNames，其余（含 "auto"）→ AUTO
+func convertToolChoice(choice interface{}) *FunctionCallingConfig {
+	switch c := choice.(type) {
+	case string:
+		switch c {
+		case "none":
+			return &FunctionCallingConfig{Mode: "NONE"}
+		case "required":
+			return &FunctionCallingConfig{Mode: "ANY"}
+		}
+	case map[string]interface{}:
+		if fn, ok := c["function"].(map[string]interface{}); ok {
+			if name, _ := fn["name"].(string); name != "" {
+				return &FunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{name}}
+			}
+		}
+	}
+	return &FunctionCallingConfig{Mode: "AUTO"}
+}
+
 func getProjectID(account *store.Account) string {
 	if account.ProjectID != "" {
 		return account.ProjectID
@@ -66,10 +97,11 @@ func getProjectID(account *store.Account) string {
 
 func convertMessages(messages []OpenAIMessage) []Content {
 	var result []Content
+	resolver := newToolCallResolver()
 
-	for _, msg := range messages {
+	for i, msg := range messages {
 		switch msg.Role {
-		case "system":
+		case "system", "developer":
 			// 跳过，单独处理到 systemInstruction
 			continue
 
@@ -81,9 +113,13 @@ func convertMessages(messages []OpenAIMessage) []Content {
 			parts := []Part{}
 
 			// 首先尝试添加 thinking 内容（必须在最前面，Claude API 要求）
-			if msg.Reasoning != "" {
+			reasoning := msg.Reasoning
+			if reasoning == "" {
+				reasoning = msg.ReasoningContent
+			}
+			if reasoning != "" {
 				parts = append(parts, Part{
-					Text:    msg.Reasoning,
+					Text:    reasoning,
 					Thought: true,
 				})
 			}
@@ -92,17 +128,22 @@ func convertMessages(messages []OpenAIMessage) []Content {
 			if text := getTextContent(msg.Content); text != "" {
 				parts = append(parts, Part{Text: text})
 			}
-			// 转换工具调用
-			for _, tc := range msg.ToolCalls {
+			// 转换工具调用（缺失 id 时分配稳定的合成 id，供后续 tool 消息配对）
+			resolver.beginTurn()
+			for j, tc := range msg.ToolCalls {
 				args := ParseArgs(tc.Function.Arguments)
 				var signature string
 				if tc.ExtraContent != nil && tc.ExtraContent.Google != nil {
 					signature = tc.ExtraContent.Google.ThoughtSignature
 				}
+				if signature == "" {
+					// 客户端丢弃了 extra_content 时，从服务端缓存补齐签名
+					signature = thoughtSignatures.Lookup(tc.ID)
+				}
 
 				parts = append(parts, Part{
 					FunctionCall: &FunctionCall{
-						ID:   tc.ID,
+						ID:   resolver.addCall(tc.ID, tc.Function.Name, i, j),
 						Name: tc.Function.Name,
 						Args: args,
 					},
@@ -114,11 +155,11 @@ func convertMessages(messages []OpenAIMessage) []Content {
 			}
 
 		case "tool":
-			// 查找对应的 function name
-			funcName := findFunctionName(result, msg.ToolCallID)
+			// 解析对应的 function id 与 name
+			id, funcName := resolver.resolve(msg.ToolCallID, msg.Name)
 			part := Part{
 				FunctionResponse: &FunctionResponse{
-					ID:   msg.ToolCallID,
+					ID:   id,
 					Name: funcName,
 					Response: map[string]interface{}{
 						"output": getTextContent(msg.Content),
@@ -133,10 +174,11 @@ func convertMessages(messages []OpenAIMessage) []Content {
 	return result
 }
 
+// extractSystemInstruction 合并 system 与 developer 消息（新版 OpenAI SDK 用 developer 代替 system）
 func extractSystemInstruction(messages []OpenAIMessage) string {
 	var texts []string
 	for _, msg := range messages {
-		if msg.Role == "system" {
+		if msg.Role == "system" || msg.Role == "developer" {
 			texts = append(texts, getTextContent(msg.Content))
 		}
 	}
@@ -214,15 +256,68 @@ func ParseArgs(argsStr string) map[string]interface{} {
 	return args
 }
 
-func findFunctionName(contents []Content, toolCallID string) string {
-	for i := len(contents) - 1; i >= 0; i-- {
-		for _, part := range contents[i].Parts {
-			if part.FunctionCall != nil && part.FunctionCall.ID == toolCallID {
-				return part.FunctionCall.Name
-			}
+// toolCallResolver 工具调用 id → name 解析
+// OpenAI 的 tool 消息只携带 tool_call_id（部分客户端甚至缺失），而 Vertex 要求 functionResponse 必须有 name
+// 解析顺序：tool_call_id 精确匹配 → 按 name 匹配本轮未应答的调用 → 按顺序匹配本轮未应答的调用
+type toolCallResolver struct {
+	idToName map[string]string
+	pending  []pendingToolCall // 最近一轮 assistant 发起的调用
+}
+
+type pendingToolCall struct {
+	id       string
+	name     string
+	answered bool
+}
+
+func newToolCallResolver() *toolCallResolver {
+	return &toolCallResolver{idToName: make(map[string]string)}
+}
+
+// beginTurn 开始新的 assistant 轮次
+func (r *toolCallResolver) beginTurn() {
+	r.pending = r.pending[:0]
+}
+
+// addCall 记录工具调用，缺失 id 时按消息与调用下标生成合成 id
+func (r *toolCallResolver) addCall(id, name string, msgIndex, callIndex int) string {
+	if id == "" {
+		id = fmt.Sprintf("call_%d_%d", msgIndex, callIndex)
+	}
+	r.idToName[id] = name
+	r.pending = append(r.pending, pendingToolCall{id: id, name: name})
+	return id
+}
+
+// resolve 解析 tool 消息对应的调用 id 与 name
+func (r *toolCallResolver) resolve(toolCallID, name string) (string, string) {
+	if toolCallID != "" {
+		if callName, ok := r.idToName[toolCallID]; ok {
+			r.markAnswered(toolCallID)
+			return toolCallID, callName
+		}
+	}
+
+	for i := range r.pending {
+		call := &r.pending[i]
+		if call.answered || (name != "" && call.name != name) {
+			continue
+		}
+		call.answered = true
+		return call.id, call.name
+	}
+
+	// 无法配对：保留客户端提供的信息
+	return toolCallID, name
+}
+
+func (r *toolCallResolver) markAnswered(id string) {
+	for i := range r.pending {
+		if r.pending[i].id == id {
+			r.pending[i].answered = true
+			return
 		}
 	}
-	return ""
 }
 
 func appendFunctionResponse(contents *[]Content, part Part) {
@@ -269,6 +364,9 @@ func ConvertOpenAIToolsToAntigravity(tools []OpenAITool) []Tool {
 	return result
 }
 
+// claudeMinThinkingBudget Claude 思考预算下限
+const claudeMinThinkingBudget = 1024
+
 func buildGenerationConfig(req *OpenAIChatRequest, modelName string) *GenerationConfig {
 	config := &GenerationConfig{
 		CandidateCount: 1,
@@ -280,12 +378,41 @@ func buildGenerationConfig(req *OpenAIChatRequest, modelName string) *Generation
 		config.StopSequences = append(config.StopSequences, req.Stop...)
 	}
 
+	applyResponseFormat(config, req.ResponseFormat)
+
+	// 指定 reasoning_effort 时即使模型名不带 -thinking 也开启思考（bypass 模型除外）
+	var requested *ThinkingConfig
+	if req.ReasoningEffort != "" {
+		requested = &ThinkingConfig{IncludeThoughts: true}
+	}
+
 	// Claude 模型特殊处理
+	// 上游 Claude 不支持 seed、presence_penalty / frequency_penalty 与 logprobs，这些参数被忽略；
+	// n > 1 已在 ParseRequest 中拒绝
 	if IsClaudeModel(modelName) {
+		// 客户端指定 max_tokens 时使用其值（不超过模型上限），否则使用模型上限
 		config.MaxOutputTokens = GetClaudeMaxOutputTokens(modelName)
+		if maxTokens := req.maxOutputTokens(); maxTokens > 0 && maxTokens < config.MaxOutputTokens {
+			config.MaxOutputTokens = maxTokens
+		}
+		// Claude 的 temperature 与 top_p 取值范围均为 [0, 1]，超出时截断而不是丢弃
+		if req.Temperature != nil {
+			config.Temperature = clampFloat(*req.Temperature, 0, 1)
+		}
+		if req.TopP != nil {
+			config.TopP = clampFloat(*req.TopP, 0, 1)
+		}
 		// Claude thinking 模式不支持工具调用，当有工具时禁用 thinking
-		if len(req.Tools) == 0 && ShouldEnableThinking(modelName, nil) {
+		if len(req.Tools) == 0 && ShouldEnableThinking(modelName, requested) {
 			config.ThinkingConfig = BuildThinkingConfig(modelName)
+			ApplyReasoningEffort(config.ThinkingConfig, req.ReasoningEffort)
+			// 思考预算须小于输出上限；上限过小无法容纳最低预算时不开启思考
+			if budget := config.ThinkingConfig.ThinkingBudget; budget >= config.MaxOutputTokens {
+				config.ThinkingConfig.ThinkingBudget = config.MaxOutputTokens / 2
+				if config.ThinkingConfig.ThinkingBudget < claudeMinThinkingBudget {
+					config.ThinkingConfig = nil
+				}
+			}
 		}
 		return config
 	}
@@ -297,31 +424,107 @@ func buildGenerationConfig(req *OpenAIChatRequest, modelName string) *Generation
 	if req.TopP != nil {
 		config.TopP = req.TopP
 	}
-	if req.MaxTokens > 0 {
-		config.MaxOutputTokens = req.MaxTokens
+	if maxTokens := req.maxOutputTokens(); maxTokens > 0 {
+		config.MaxOutputTokens = maxTokens
+	}
+	if req.Seed != nil {
+		config.Seed = req.Seed
+	}
+	if req.PresencePenalty != nil {
+		config.PresencePenalty = req.PresencePenalty
+	}
+	if req.FrequencyPenalty != nil {
+		config.FrequencyPenalty = req.FrequencyPenalty
+	}
+	if req.N > 1 {
+		config.CandidateCount = req.N
+	}
+	if req.Logprobs {
+		config.ResponseLogprobs = true
+		config.Logprobs = req.TopLogprobs
 	}
 
 	// 思考模式
-	if ShouldEnableThinking(modelName, nil) {
+	if ShouldEnableThinking(modelName, requested) {
 		config.ThinkingConfig = BuildThinkingConfig(modelName)
+		ApplyReasoningEffort(config.ThinkingConfig, req.ReasoningEffort)
+		// reasoning_effort 给出的预算须小于输出上限，否则上游拒绝请求
+		if budget := config.ThinkingConfig.ThinkingBudget; req.ReasoningEffort != "" && config.MaxOutputTokens > 0 && budget >= config.MaxOutputTokens {
+			config.ThinkingConfig.ThinkingBudget = config.MaxOutputTokens / 2
+		}
 	}
 
 	return config
 }
 
-// ConvertToOpenAIResponse 将 Antigravity 响应转换为 OpenAI 格式
+// clampFloat 将 v 截断到 [lo, hi] 范围内
+func clampFloat(v, lo, hi float64) *float64 {
+	v = math.Max(lo, math.Min(hi, v))
+	return &v
+}
+
+// ConvertToOpenAIResponse 将 Antigravity 响应转换为 OpenAI 格式，每个候选对应一个 choice
 func ConvertToOpenAIResponse(antigravityResp *AntigravityResponse, model string) *OpenAIChatCompletion {
-	parts := antigravityResp.Response.Candidates[0].Content.Parts
+	choices := make([]Choice, len(antigravityResp.Response.Candidates))
+	for i, candidate := range antigravityResp.Response.Candidates {
+		choices[i] = convertCandidate(candidate.Content.Parts, candidate.FinishReason)
+		choices[i].Index = i
+	}
+	// 未返回任何候选（提示词被拦截）时仍返回一个内容为空的 choice，结束原因为 content_filter
+	if len(choices) == 0 {
+		choices = []Choice{convertCandidate(nil, core.NoCandidatesFinishReason)}
+	}
+
+	return &OpenAIChatCompletion{
+		ID:                utils.GenerateChatCompletionID(),
+		Object:            "chat.completion",
+		Created:           time.Now().Unix(),
+		Model:             model,
+		SystemFingerprint: systemFingerprint(model),
+		Choices:           choices,
+		Usage:             ConvertUsage(antigravityResp.Response.UsageMetadata),
+	}
+}
+
+// systemFingerprint 由实际上游模型生成稳定的 system_fingerprint，上游模型不变时保持一致，供确定性评测核对后端配置
+func systemFingerprint(model string) string {
+	sum := sha256.Sum256([]byte(ResolveModelName(model)))
+	return "fp_" + hex.EncodeToString(sum[:5])
+}
+
+// ConvertFinishReason 将上游 finishReason 映射为 OpenAI finish_reason
+// MAX_TOKENS → length；安全拦截类 → content_filter；其余（含未给出）正常结束，产生工具调用时为 tool_calls
+func ConvertFinishReason(reason string, hasToolCalls bool) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	}
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// convertCandidate 将单个候选的 parts 与结束原因转换为 OpenAI choice（不含 index）
+func convertCandidate(parts []Part, upstreamReason string) Choice {
 
 	var content, thinkingContent string
 	var toolCalls []OpenAIToolCall
 	var imageURLs []string
+	var contentParts []ContentPart
 
 	for _, part := range parts {
 		if part.Thought {
 			thinkingContent += part.Text
 		} else if part.Text != "" {
 			content += part.Text
+			if n := len(contentParts); n > 0 && contentParts[n-1].Type == "text" {
+				contentParts[n-1].Text += part.Text
+			} else {
+				contentParts = append(contentParts, ContentPart{Type: "text", Text: part.Text})
+			}
 		} else if part.FunctionCall != nil {
 			argsJSON, _ := json.Marshal(part.FunctionCall.Args)
 			id := part.FunctionCall.ID
@@ -331,6 +534,7 @@ func ConvertToOpenAIResponse(antigravityResp *AntigravityResponse, model string)
 
 			var extraContent *ExtraContent
 			if part.ThoughtSignature != "" {
+				thoughtSignatures.Put(id, part.ThoughtSignature)
 				extraContent = &ExtraContent{
 					Google: &GoogleExtra{
 						ThoughtSignature: part.ThoughtSignature,
@@ -348,45 +552,154 @@ func ConvertToOpenAIResponse(antigravityResp *AntigravityResponse, model string)
 				ExtraContent: extraContent,
 			})
 		} else if part.InlineData != nil {
-			dataURL := fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)
-			imageURLs = append(imageURLs, dataURL)
+			url := imageDataURL(part.InlineData)
+			imageURLs = append(imageURLs, url)
+			contentParts = append(contentParts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}})
 		}
 	}
 
-	// 处理图片输出
-	if len(imageURLs) > 0 {
+	// 处理图片输出：parts 模式以内容数组返回，images 模式以独立字段返回，否则内联为 markdown
+	var images []OpenAIImage
+	switch {
+	case len(imageURLs) == 0:
+		contentParts = nil
+	case imagesAsParts():
+	case imagesAsField():
+		contentParts = nil
+		for _, url := range imageURLs {
+			images = append(images, newOpenAIImage(url))
+		}
+	default:
+		contentParts = nil
 		var md strings.Builder
 		if content != "" {
 			md.WriteString(content + "\n\n")
 		}
 		for _, url := range imageURLs {
-			md.WriteString(fmt.Sprintf("![image](%s)\n\n", url))
+			md.WriteString(imageMarkdown(url))
 		}
 		content = md.String()
 	}
 
-	finishReason := "stop"
-	if len(toolCalls) > 0 {
-		finishReason = "tool_calls"
+	finishReason := ConvertFinishReason(upstreamReason, len(toolCalls) > 0)
+	reasoning, reasoningContent := reasoningFields(thinkingContent)
+
+	return Choice{
+		Message: Message{
+			Role:             "assistant",
+			Content:          content,
+			ToolCalls:        toolCalls,
+			Reasoning:        reasoning,
+			ReasoningContent: reasoningContent,
+			Images:           images,
+			ContentParts:     contentParts,
+		},
+		FinishReason: &finishReason,
 	}
+}
 
-	return &OpenAIChatCompletion{
-		ID:      utils.GenerateChatCompletionID(),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   model,
-		Choices: []Choice{{
-			Index: 0,
-			Message: Message{
-				Role:      "assistant",
-				Content:   content,
-				ToolCalls: toolCalls,
-				Reasoning: thinkingContent,
-			},
-			FinishReason: &finishReason,
-		}},
-		Usage: ConvertUsage(antigravityResp.Response.UsageMetadata),
+// reasoningFields 按 REASONING_FIELD 返回思考内容在 reasoning 与 reasoning_content 字段中的值
+func reasoningFields(text string) (reasoning, reasoningContent string) {
+	switch config.Get().ReasoningField {
+	case "reasoning_content":
+		return "", text
+	case "both":
+		return text, text
+	default:
+		return text, ""
+	}
+}
+
+// reasoningDelta 构造思考内容的流式增量
+func reasoningDelta(text string) *Delta {
+	reasoning, reasoningContent := reasoningFields(text)
+	return &Delta{Reasoning: reasoning, ReasoningContent: reasoningContent}
+}
+
+// reasoningText 返回消息中的思考内容（不论输出到哪个字段）
+func (m Message) reasoningText() string {
+	if m.Reasoning != "" {
+		return m.Reasoning
+	}
+	return m.ReasoningContent
+}
+
+// applyResponseFormat 将 response_format 映射为 JSON 输出：json_object 仅约束 MIME 类型，json_schema 同时设置 responseSchema
+func applyResponseFormat(config *GenerationConfig, format *ResponseFormat) {
+	if format == nil {
+		return
+	}
+	switch format.Type {
+	case "json_object":
+		config.ResponseMimeType = "application/json"
+	case "json_schema":
+		config.ResponseMimeType = "application/json"
+		if format.JSONSchema != nil && len(format.JSONSchema.Schema) > 0 {
+			config.ResponseSchema = ResponseSchema(format.JSONSchema.Schema)
+		}
+	}
+}
+
+// ConvertLogprobs 将上游 logprobsResult 转换为 OpenAI logprobs
+// 上游未返回（如 Claude 模型或端点不支持）时返回空的 content，保证请求 logprobs 的客户端总能拿到该字段
+func ConvertLogprobs(result *LogprobsResult) *ChoiceLogprobs {
+	logprobs := &ChoiceLogprobs{Content: []TokenLogprob{}}
+	if result == nil {
+		return logprobs
+	}
+	for i, chosen := range result.ChosenCandidates {
+		entry := TokenLogprob{
+			Token:       chosen.Token,
+			Logprob:     chosen.LogProbability,
+			Bytes:       tokenBytes(chosen.Token),
+			TopLogprobs: []TopLogprob{},
+		}
+		if i < len(result.TopCandidates) {
+			for _, top := range result.TopCandidates[i].Candidates {
+				entry.TopLogprobs = append(entry.TopLogprobs, TopLogprob{
+					Token:   top.Token,
+					Logprob: top.LogProbability,
+					Bytes:   tokenBytes(top.Token),
+				})
+			}
+		}
+		logprobs.Content = append(logprobs.Content, entry)
 	}
+	return logprobs
+}
+
+// tokenBytes token 的 UTF-8 字节（OpenAI 以整数数组表示）
+func tokenBytes(token string) []int {
+	bytes := make([]int, len(token))
+	for i := 0; i < len(token); i++ {
+		bytes[i] = int(token[i])
+	}
+	return bytes
+}
+
+// imagesAsField 是否以 images 字段返回生成的图片（IMAGE_OUTPUT=images；parts 模式的流式响应同样使用 images 字段）
+func imagesAsField() bool {
+	mode := config.Get().ImageOutput
+	return mode == "images" || mode == "parts"
+}
+
+// imagesAsParts 非流式响应是否以内容数组返回文本与图片（IMAGE_OUTPUT=parts）
+func imagesAsParts() bool {
+	return config.Get().ImageOutput == "parts"
+}
+
+// imageDataURL 将内联图片转为 data URL
+func imageDataURL(data *InlineData) string {
+	return fmt.Sprintf("data:%s;base64,%s", data.MimeType, data.Data)
+}
+
+// imageMarkdown 图片的 markdown 回退格式
+func imageMarkdown(url string) string {
+	return fmt.Sprintf("![image](%s)\n\n", url)
+}
+
+func newOpenAIImage(url string) OpenAIImage {
+	return OpenAIImage{Type: "image_url", ImageURL: ImageURL{URL: url}}
 }
 
 // ConvertUsage 转换使用统计
@@ -404,10 +717,11 @@ func ConvertUsage(metadata *UsageMetadata) *Usage {
 // CreateStreamChunk 创建流式 Chunk
 func CreateStreamChunk(id string, created int64, model string, delta *Delta, finishReason *string, usage *Usage) *OpenAIStreamChunk {
 	return &OpenAIStreamChunk{
-		ID:      id,
-		Object:  "chat.completion.chunk",
-		Created: created,
-		Model:   model,
+		ID:                id,
+		Object:            "chat.completion.chunk",
+		Created:           created,
+		Model:             model,
+		SystemFingerprint: systemFingerprint(model),
 		Choices: []Choice{{
 			Index:        0,
 			Delta:        delta,
@@ -1,7 +1,14 @@
 package openai
 
 import (
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
 	"anti2api-golang/internal/store"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -46,6 +53,88 @@ func TestConvertOpenAIToAntigravity(t *testing.T) {
 	}
 }
 
+func TestConvertDeveloperRoleAsSystem(t *testing.T) {
+	req := &OpenAIChatRequest{
+		Model: "gemini-3-pro",
+		Messages: []OpenAIMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "developer", Content: "Answer in French."},
+			{Role: "user", Content: "Hello"},
+		},
+	}
+
+	antigravityReq := ConvertOpenAIToAntigravity(req, &store.Account{ProjectID: "test-project"})
+	if len(antigravityReq.Request.Contents) != 1 || antigravityReq.Request.Contents[0].Role != "user" {
+		t.Fatalf("developer message should not become a content turn: %+v", antigravityReq.Request.Contents)
+	}
+	si := antigravityReq.Request.SystemInstruction
+	if si == nil || len(si.Parts) == 0 || si.Parts[0].Text != "Be brief.\n\nAnswer in French." {
+		t.Fatalf("expected system and developer messages in systemInstruction, got %+v", si)
+	}
+}
+
+func TestConvertMessagesToolCallPairing(t *testing.T) {
+	call := func(id, name string) OpenAIToolCall {
+		return OpenAIToolCall{ID: id, Type: "function", Function: OpenAIFunctionCall{Name: name, Arguments: "{}"}}
+	}
+	messages := []OpenAIMessage{
+		{Role: "user", Content: "weather and time?"},
+		// 第一轮：并行调用，带 id，工具结果乱序返回
+		{Role: "assistant", ToolCalls: []OpenAIToolCall{call("call_a", "get_weather"), call("call_b", "get_time")}},
+		{Role: "tool", ToolCallID: "call_b", Content: "12:00"},
+		{Role: "tool", ToolCallID: "call_a", Content: "sunny"},
+		// 第二轮：并行调用，缺失 id；结果按 name 或顺序配对
+		{Role: "assistant", ToolCalls: []OpenAIToolCall{call("", "get_weather"), call("", "get_time"), call("", "get_weather")}},
+		{Role: "tool", Name: "get_time", Content: "13:00"},
+		{Role: "tool", Content: "rainy"},
+		{Role: "tool", Content: "windy"},
+		// 第三轮：复用旧名称的新调用
+		{Role: "assistant", ToolCalls: []OpenAIToolCall{call("call_c", "get_weather")}},
+		{Role: "tool", ToolCallID: "call_c", Content: "snow"},
+	}
+
+	contents := convertMessages(messages)
+
+	type pair struct{ id, name string }
+	var calls, responses []pair
+	for _, c := range contents {
+		for _, p := range c.Parts {
+			if p.FunctionCall != nil {
+				calls = append(calls, pair{p.FunctionCall.ID, p.FunctionCall.Name})
+			}
+			if p.FunctionResponse != nil {
+				responses = append(responses, pair{p.FunctionResponse.ID, p.FunctionResponse.Name})
+			}
+		}
+	}
+
+	wantCalls := []pair{
+		{"call_a", "get_weather"}, {"call_b", "get_time"},
+		{"call_4_0", "get_weather"}, {"call_4_1", "get_time"}, {"call_4_2", "get_weather"},
+		{"call_c", "get_weather"},
+	}
+	wantResponses := []pair{
+		{"call_b", "get_time"}, {"call_a", "get_weather"},
+		{"call_4_1", "get_time"}, {"call_4_0", "get_weather"}, {"call_4_2", "get_weather"},
+		{"call_c", "get_weather"},
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(wantCalls) {
+		t.Errorf("calls = %v, want %v", calls, wantCalls)
+	}
+	if fmt.Sprint(responses) != fmt.Sprint(wantResponses) {
+		t.Errorf("responses = %v, want %v", responses, wantResponses)
+	}
+
+	// 每轮工具结果应合并为紧随 model 消息之后的单条 user 消息
+	roles := make([]string, len(contents))
+	for i, c := range contents {
+		roles[i] = c.Role
+	}
+	if got := strings.Join(roles, ","); got != "user,model,user,model,user,model,user" {
+		t.Errorf("roles = %s", got)
+	}
+}
+
 func TestConvertToOpenAIResponse(t *testing.T) {
 	resp := &AntigravityResponse{}
 	resp.Response.Candidates = []Candidate{
@@ -81,3 +170,592 @@ func TestConvertToOpenAIResponse(t *testing.T) {
 		t.Errorf("Expected signature 'sig_123' in extra_content, got %+v", tc.ExtraContent)
 	}
 }
+
+func TestConvertToOpenAIResponseNoCandidates(t *testing.T) {
+	resp := &AntigravityResponse{}
+	resp.Response.PromptFeedback = &core.PromptFeedback{BlockReason: "SAFETY"}
+
+	openAIResp := ConvertToOpenAIResponse(resp, "gemini-3-pro")
+	if len(openAIResp.Choices) != 1 {
+		t.Fatalf("Expected 1 choice, got %d", len(openAIResp.Choices))
+	}
+	choice := openAIResp.Choices[0]
+	if choice.FinishReason == nil || *choice.FinishReason != "content_filter" {
+		t.Errorf("Expected finish_reason content_filter, got %v", choice.FinishReason)
+	}
+	if choice.Message.Content != "" || len(choice.Message.ToolCalls) != 0 {
+		t.Errorf("Expected empty message, got %+v", choice.Message)
+	}
+}
+
+func TestConvertToOpenAIResponseImages(t *testing.T) {
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{{
+		Content: Content{
+			Role: "model",
+			Parts: []Part{
+				{Text: "Here you go"},
+				{InlineData: &InlineData{MimeType: "image/png", Data: "iVBORw0KGgo="}},
+			},
+		},
+	}}
+
+	cfg := config.Get()
+	defer func(mode string) { cfg.ImageOutput = mode }(cfg.ImageOutput)
+
+	cfg.ImageOutput = "markdown"
+	msg := ConvertToOpenAIResponse(resp, "gemini-3-pro-image").Choices[0].Message
+	if !strings.Contains(msg.Content, "![image](data:image/png;base64,iVBORw0KGgo=)") || len(msg.Images) != 0 {
+		t.Errorf("markdown mode: content = %q, images = %v", msg.Content, msg.Images)
+	}
+
+	cfg.ImageOutput = "images"
+	msg = ConvertToOpenAIResponse(resp, "gemini-3-pro-image").Choices[0].Message
+	if msg.Content != "Here you go" {
+		t.Errorf("images mode: content = %q", msg.Content)
+	}
+	if len(msg.Images) != 1 || msg.Images[0].Type != "image_url" || msg.Images[0].ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("images mode: images = %+v", msg.Images)
+	}
+
+	cfg.ImageOutput = "parts"
+	msg = ConvertToOpenAIResponse(resp, "gemini-3-pro-image").Choices[0].Message
+	data, _ := json.Marshal(msg)
+	want := `"content":[{"type":"text","text":"Here you go"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]`
+	if !strings.Contains(string(data), want) || len(msg.Images) != 0 || msg.Content != "Here you go" {
+		t.Errorf("parts mode: %s", data)
+	}
+
+	// 纯文本响应不受 parts 模式影响
+	textResp := &AntigravityResponse{}
+	textResp.Response.Candidates = []Candidate{{Content: Content{Role: "model", Parts: []Part{{Text: "hi"}}}}}
+	data, _ = json.Marshal(ConvertToOpenAIResponse(textResp, "gemini-3-pro").Choices[0].Message)
+	if !strings.Contains(string(data), `"content":"hi"`) {
+		t.Errorf("text-only parts mode: %s", data)
+	}
+}
+
+func TestReasoningField(t *testing.T) {
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{{
+		Content: Content{Role: "model", Parts: []Part{{Text: "let me think", Thought: true}, {Text: "answer"}}},
+	}}
+
+	cfg := config.Get()
+	defer func(field string) { cfg.ReasoningField = field }(cfg.ReasoningField)
+
+	tests := []struct {
+		field            string
+		reasoning        string
+		reasoningContent string
+	}{
+		{"reasoning", "let me think", ""},
+		{"reasoning_content", "", "let me think"},
+		{"both", "let me think", "let me think"},
+	}
+	for _, tt := range tests {
+		cfg.ReasoningField = tt.field
+		msg := ConvertToOpenAIResponse(resp, "gemini-3-pro").Choices[0].Message
+		if msg.Reasoning != tt.reasoning || msg.ReasoningContent != tt.reasoningContent {
+			t.Errorf("%s: message reasoning = %q, reasoning_content = %q", tt.field, msg.Reasoning, msg.ReasoningContent)
+		}
+		if d := reasoningDelta("x"); (d.Reasoning != "") != (tt.reasoning != "") || (d.ReasoningContent != "") != (tt.reasoningContent != "") {
+			t.Errorf("%s: delta = %+v", tt.field, d)
+		}
+	}
+
+	// 客户端回传 reasoning_content 时同样作为思考内容
+	contents := convertMessages([]OpenAIMessage{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "answer", ReasoningContent: "let me think"},
+		{Role: "user", Content: "more"},
+	})
+	if parts := contents[1].Parts; len(parts) != 2 || !parts[0].Thought || parts[0].Text != "let me think" {
+		t.Errorf("parts = %+v", parts)
+	}
+}
+
+func TestThoughtSignatureRestoredFromCache(t *testing.T) {
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{{
+		Content: Content{
+			Role: "model",
+			Parts: []Part{{
+				FunctionCall:     &FunctionCall{ID: "call_sig_cache", Name: "get_weather"},
+				ThoughtSignature: "sig_cached",
+			}},
+		},
+	}}
+	ConvertToOpenAIResponse(resp, "gemini-3-pro")
+
+	// 客户端回传时丢弃了 extra_content
+	contents := convertMessages([]OpenAIMessage{
+		{Role: "user", Content: "weather?"},
+		{Role: "assistant", ToolCalls: []OpenAIToolCall{{ID: "call_sig_cache", Type: "function", Function: OpenAIFunctionCall{Name: "get_weather", Arguments: "{}"}}}},
+		{Role: "tool", ToolCallID: "call_sig_cache", Content: "sunny"},
+	})
+
+	if got := contents[1].Parts[0].ThoughtSignature; got != "sig_cached" {
+		t.Errorf("ThoughtSignature = %q, want sig_cached", got)
+	}
+}
+
+func FuzzExtractParts(f *testing.F) {
+	f.Add(`"hello"`)
+	f.Add(`[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]`)
+	f.Add(`[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]`)
+	f.Add(`[{"type":"text","text":1},{"type":"image_url","image_url":"data:image/png;base64,xx"},{"type":["text"]}]`)
+	f.Add(`[null,true,{"type":"image_url","image_url":{"url":42}}]`)
+
+	f.Fuzz(func(t *testing.T, data string) {
+		var content interface{}
+		if err := json.Unmarshal([]byte(data), &content); err != nil {
+			return
+		}
+
+		for _, part := range extractParts(content) {
+			if part.InlineData != nil && !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				t.Errorf("unexpected inline mime type %q", part.InlineData.MimeType)
+			}
+		}
+	})
+}
+
+func TestLogitBiasEmulation(t *testing.T) {
+	req := &OpenAIChatRequest{
+		Model:     "gemini-3-pro",
+		Messages:  []OpenAIMessage{{Role: "user", Content: "hi"}},
+		LogitBias: map[string]float64{"1734": -100, "Sorry": -100, "maybe": 5},
+	}
+
+	emu := EmulateLogitBias(req.LogitBias)
+	if emu == nil || len(emu.Banned) != 1 || emu.Banned[0] != "Sorry" {
+		t.Fatalf("unexpected banned list: %+v", emu)
+	}
+	if strings.Join(emu.Ignored, ",") != "1734,maybe" {
+		t.Errorf("unexpected ignored list: %v", emu.Ignored)
+	}
+
+	antireq := ConvertOpenAIToAntigravity(req, &store.Account{ProjectID: "test-project"})
+	si := antireq.Request.SystemInstruction
+	if si == nil || !strings.Contains(si.Parts[len(si.Parts)-1].Text, `"Sorry"`) {
+		t.Fatalf("expected ban constraint in system instruction, got %+v", si)
+	}
+
+	if EmulateLogitBias(nil) != nil {
+		t.Error("expected nil emulation without logit_bias")
+	}
+}
+
+func TestBuildGenerationConfigSamplingParams(t *testing.T) {
+	seed := 42
+	cfg := buildGenerationConfig(&OpenAIChatRequest{Model: "gemini-3-pro", Seed: &seed}, "gemini-3-pro")
+	if cfg.Seed == nil || *cfg.Seed != 42 {
+		t.Fatalf("expected seed 42, got %v", cfg.Seed)
+	}
+
+	presence, frequency := 0.6, -0.4
+	cfg = buildGenerationConfig(&OpenAIChatRequest{Model: "gemini-3-pro", PresencePenalty: &presence, FrequencyPenalty: &frequency}, "gemini-3-pro")
+	if cfg.PresencePenalty == nil || *cfg.PresencePenalty != 0.6 || cfg.FrequencyPenalty == nil || *cfg.FrequencyPenalty != -0.4 {
+		t.Fatalf("expected penalties to be mapped, got %v / %v", cfg.PresencePenalty, cfg.FrequencyPenalty)
+	}
+
+	for _, body := range []string{
+		`{"model":"gemini-3-pro","presence_penalty":2.5}`,
+		`{"model":"gemini-3-pro","frequency_penalty":-3}`,
+	} {
+		if _, err := (&Adapter{}).ParseRequest(nil, []byte(body)); err == nil {
+			t.Errorf("expected out-of-range penalty to be rejected: %s", body)
+		}
+	}
+	if _, err := (&Adapter{}).ParseRequest(nil, []byte(`{"model":"gemini-3-pro","presence_penalty":2,"frequency_penalty":-2}`)); err != nil {
+		t.Errorf("boundary penalties should be accepted: %v", err)
+	}
+
+	if _, err := (&Adapter{}).ParseRequest(nil, []byte(`{"model":"claude-sonnet-4-5","n":2}`)); err == nil {
+		t.Error("expected n > 1 to be rejected for Claude models")
+	}
+	if _, err := (&Adapter{}).ParseRequest(nil, []byte(`{"model":"gemini-3-pro","n":2}`)); err != nil {
+		t.Errorf("n > 1 should be accepted for Gemini models: %v", err)
+	}
+
+	data, _ := json.Marshal(buildGenerationConfig(&OpenAIChatRequest{Model: "gemini-3-pro"}, "gemini-3-pro"))
+	if strings.Contains(string(data), "seed") {
+		t.Errorf("seed should be omitted when not provided: %s", data)
+	}
+}
+
+func TestBuildGenerationConfigClaudeSampling(t *testing.T) {
+	temperature, topP := 0.3, 0.9
+	cfg := buildGenerationConfig(&OpenAIChatRequest{Model: "claude-sonnet-4-5", Temperature: &temperature, TopP: &topP}, "claude-sonnet-4-5")
+	if cfg.Temperature == nil || *cfg.Temperature != 0.3 || cfg.TopP == nil || *cfg.TopP != 0.9 {
+		t.Fatalf("expected Claude sampling params to pass through, got %v / %v", cfg.Temperature, cfg.TopP)
+	}
+
+	temperature = 1.5
+	cfg = buildGenerationConfig(&OpenAIChatRequest{Model: "claude-sonnet-4-5", Temperature: &temperature}, "claude-sonnet-4-5")
+	if cfg.Temperature == nil || *cfg.Temperature != 1 {
+		t.Fatalf("expected Claude temperature clamped to 1, got %v", cfg.Temperature)
+	}
+	if cfg.TopP != nil {
+		t.Errorf("top_p should be omitted when not provided, got %v", *cfg.TopP)
+	}
+}
+
+func TestBuildGenerationConfigClaudeMaxTokens(t *testing.T) {
+	tests := []struct {
+		model     string
+		maxTokens int
+		wantMax   int
+		budget    int // 0 表示不开启思考
+	}{
+		{"claude-sonnet-4-5", 0, 64000, 0},
+		{"claude-sonnet-4-5", 500, 500, 0},
+		{"claude-sonnet-4-5", 100000, 64000, 0},
+		{"claude-sonnet-4-5-thinking", 0, 64000, 32000},
+		{"claude-sonnet-4-5-thinking", 8000, 8000, 4000},
+		{"claude-sonnet-4-5-thinking", 1500, 1500, 0},
+	}
+	for _, tt := range tests {
+		cfg := buildGenerationConfig(&OpenAIChatRequest{Model: tt.model, MaxTokens: tt.maxTokens}, tt.model)
+		if cfg.MaxOutputTokens != tt.wantMax {
+			t.Errorf("%s max_tokens=%d: expected maxOutputTokens %d, got %d", tt.model, tt.maxTokens, tt.wantMax, cfg.MaxOutputTokens)
+		}
+		budget := 0
+		if cfg.ThinkingConfig != nil {
+			budget = cfg.ThinkingConfig.ThinkingBudget
+		}
+		if budget != tt.budget {
+			t.Errorf("%s max_tokens=%d: expected thinking budget %d, got %d", tt.model, tt.maxTokens, tt.budget, budget)
+		}
+	}
+}
+
+func TestConvertToolChoice(t *testing.T) {
+	tests := []struct {
+		choice  string
+		mode    string
+		allowed string
+	}{
+		{`null`, "AUTO", ""},
+		{`"auto"`, "AUTO", ""},
+		{`"none"`, "NONE", ""},
+		{`"required"`, "ANY", ""},
+		{`{"type":"function","function":{"name":"get_weather"}}`, "ANY", "get_weather"},
+	}
+	for _, tt := range tests {
+		var choice interface{}
+		json.Unmarshal([]byte(tt.choice), &choice)
+		req := &OpenAIChatRequest{
+			Model:      "gemini-3-pro",
+			Messages:   []OpenAIMessage{{Role: "user", Content: "hi"}},
+			Tools:      []OpenAITool{{Type: "function", Function: OpenAIFunction{Name: "get_weather"}}},
+			ToolChoice: choice,
+		}
+		fc := ConvertOpenAIToAntigravity(req, &store.Account{}).Request.ToolConfig.FunctionCallingConfig
+		if fc.Mode != tt.mode || strings.Join(fc.AllowedFunctionNames, ",") != tt.allowed {
+			t.Errorf("%s: config = %+v", tt.choice, fc)
+		}
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	var req OpenAIChatRequest
+	json.Unmarshal([]byte(`{
+		"model": "gemini-3-pro-low",
+		"messages": [{"role": "user", "content": "hi"}],
+		"response_format": {"type": "json_schema", "json_schema": {"name": "answer", "strict": true,
+			"schema": {"type": "object", "properties": {"answer": {"type": "string"}}, "required": ["answer"], "additionalProperties": false}}}
+	}`), &req)
+
+	gc := ConvertOpenAIToAntigravity(&req, &store.Account{}).Request.GenerationConfig
+	if gc.ResponseMimeType != "application/json" || gc.ResponseSchema["type"] != "object" {
+		t.Errorf("json_schema: generation config = %+v", gc)
+	}
+	if _, ok := gc.ResponseSchema["additionalProperties"]; ok {
+		t.Errorf("unsupported field kept: %v", gc.ResponseSchema)
+	}
+
+	req.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	gc = ConvertOpenAIToAntigravity(&req, &store.Account{}).Request.GenerationConfig
+	if gc.ResponseMimeType != "application/json" || gc.ResponseSchema != nil {
+		t.Errorf("json_object: generation config = %+v", gc)
+	}
+
+	req.ResponseFormat = &ResponseFormat{Type: "text"}
+	if gc = ConvertOpenAIToAntigravity(&req, &store.Account{}).Request.GenerationConfig; gc.ResponseMimeType != "" {
+		t.Errorf("text: generation config = %+v", gc)
+	}
+}
+
+func TestConvertLogprobs(t *testing.T) {
+	req := &OpenAIChatRequest{Model: "gemini-3-pro-low", Logprobs: true, TopLogprobs: 2}
+	if gc := buildGenerationConfig(req, "gemini-3-pro-low"); !gc.ResponseLogprobs || gc.Logprobs != 2 {
+		t.Errorf("generation config = %+v", gc)
+	}
+
+	result := &LogprobsResult{
+		ChosenCandidates: []core.LogprobCandidate{{Token: "Hi", LogProbability: -0.1}},
+		TopCandidates: []core.TopLogprobCandidates{{Candidates: []core.LogprobCandidate{
+			{Token: "Hi", LogProbability: -0.1}, {Token: "Hello", LogProbability: -2.5},
+		}}},
+	}
+	data, _ := json.Marshal(ConvertLogprobs(result))
+	want := `{"content":[{"token":"Hi","logprob":-0.1,"bytes":[72,105],"top_logprobs":[{"token":"Hi","logprob":-0.1,"bytes":[72,105]},{"token":"Hello","logprob":-2.5,"bytes":[72,101,108,108,111]}]}]}`
+	if string(data) != want {
+		t.Errorf("logprobs = %s", data)
+	}
+
+	// 上游未返回时保留字段
+	if data, _ := json.Marshal(ConvertLogprobs(nil)); string(data) != `{"content":[]}` {
+		t.Errorf("empty logprobs = %s", data)
+	}
+}
+
+func TestMaxCompletionTokens(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"model":"gemini-3-pro","max_tokens":100}`, 100},
+		{`{"model":"gemini-3-pro","max_completion_tokens":200}`, 200},
+		{`{"model":"gemini-3-pro","max_tokens":100,"max_completion_tokens":200}`, 200},
+		{`{"model":"gemini-3-pro"}`, 0},
+	}
+	for _, tt := range tests {
+		parsed, err := (&Adapter{}).ParseRequest(nil, []byte(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buildGenerationConfig(parsed.(*OpenAIChatRequest), "gemini-3-pro").MaxOutputTokens; got != tt.want {
+			t.Errorf("%s: MaxOutputTokens = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestPredictionTolerated(t *testing.T) {
+	for _, prediction := range []string{
+		`{"type":"content","content":"func main() {}"}`,
+		`{"type":"content","content":[{"type":"text","text":"func main() {}"}]}`,
+		`"not an object"`,
+		`{"type":1}`,
+	} {
+		body := `{"model":"gemini-3-pro","prediction":` + prediction + `,"messages":[{"role":"user","content":"hi"}]}`
+		parsed, err := (&Adapter{}).ParseRequest(nil, []byte(body))
+		if err != nil {
+			t.Errorf("prediction %s should be accepted: %v", prediction, err)
+			continue
+		}
+		upstream := ConvertOpenAIToAntigravity(parsed.(*OpenAIChatRequest), &store.Account{ProjectID: "test-project"})
+		if data, _ := json.Marshal(upstream); strings.Contains(string(data), "func main") {
+			t.Errorf("prediction should not be forwarded upstream: %s", data)
+		}
+	}
+}
+
+func TestReasoningEffort(t *testing.T) {
+	tests := []struct {
+		model, effort string
+		maxTokens     int
+		level         string
+		budget        int
+	}{
+		{"gemini-3-pro-high", "", 0, "high", 0},
+		{"gemini-3-pro-high", "low", 0, "low", 0},
+		{"gemini-3-pro-high", "medium", 0, "high", 0},
+		{"gemini-2.5-flash-thinking", "", 0, "", 1024},
+		{"gemini-2.5-flash-thinking", "high", 0, "", 24576},
+		{"gemini-2.5-flash", "medium", 0, "", 12288},       // reasoning_effort 开启思考
+		{"gemini-2.5-flash", "high", 8000, "", 4000},       // 预算不超过输出上限
+		{"claude-sonnet-4-5-thinking", "low", 0, "", 4096}, // Claude 使用预算
+	}
+	for _, tt := range tests {
+		cfg := buildGenerationConfig(&OpenAIChatRequest{Model: tt.model, ReasoningEffort: tt.effort, MaxTokens: tt.maxTokens}, tt.model)
+		tc := cfg.ThinkingConfig
+		if tc == nil || tc.ThinkingLevel != tt.level || tc.ThinkingBudget != tt.budget {
+			t.Errorf("%s/%q: thinking config = %+v, want level %q budget %d", tt.model, tt.effort, tc, tt.level, tt.budget)
+		}
+	}
+
+	if cfg := buildGenerationConfig(&OpenAIChatRequest{Model: "gemini-2.5-flash"}, "gemini-2.5-flash"); cfg.ThinkingConfig != nil {
+		t.Errorf("thinking should stay off without reasoning_effort: %+v", cfg.ThinkingConfig)
+	}
+	if _, err := (&Adapter{}).ParseRequest(nil, []byte(`{"model":"gemini-3-pro","reasoning_effort":"extreme"}`)); err == nil {
+		t.Error("unknown reasoning_effort should be rejected")
+	}
+}
+
+func TestConvertToOpenAIResponseMultipleCandidates(t *testing.T) {
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{
+		{Content: Content{Parts: []Part{{Text: "first"}}}, FinishReason: "STOP"},
+		{Content: Content{Parts: []Part{{FunctionCall: &FunctionCall{ID: "call_2", Name: "lookup"}}}}, Index: 1},
+	}
+
+	openAIResp := ConvertToOpenAIResponse(resp, "gemini-3-pro")
+	if len(openAIResp.Choices) != 2 {
+		t.Fatalf("Expected 2 choices, got %d", len(openAIResp.Choices))
+	}
+	first, second := openAIResp.Choices[0], openAIResp.Choices[1]
+	if first.Index != 0 || first.Message.Content != "first" || *first.FinishReason != "stop" {
+		t.Errorf("choice 0 = %+v", first)
+	}
+	if second.Index != 1 || len(second.Message.ToolCalls) != 1 || *second.FinishReason != "tool_calls" {
+		t.Errorf("choice 1 = %+v", second)
+	}
+
+	cfg := buildGenerationConfig(&OpenAIChatRequest{N: 3}, "gemini-3-pro")
+	if cfg.CandidateCount != 3 {
+		t.Errorf("CandidateCount = %d, want 3", cfg.CandidateCount)
+	}
+}
+
+func TestKeepFirstToolCall(t *testing.T) {
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{{Content: Content{Parts: []Part{
+		{FunctionCall: &FunctionCall{ID: "call_1", Name: "a"}},
+		{FunctionCall: &FunctionCall{ID: "call_2", Name: "b"}},
+	}}}}
+
+	openAIResp := ConvertToOpenAIResponse(resp, "gemini-3-pro")
+	keepFirstToolCall(openAIResp)
+	calls := openAIResp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_1" {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	// index 只出现在流式 delta 中
+	if data, _ := json.Marshal(calls); strings.Contains(string(data), `"index"`) {
+		t.Errorf("non-streaming tool calls should not carry an index: %s", data)
+	}
+}
+
+func TestLegacyFunctions(t *testing.T) {
+	body := `{"model":"gemini-3-pro",
+		"functions":[{"name":"get_weather","parameters":{"type":"object"}}],
+		"function_call":{"name":"get_weather"},
+		"messages":[
+			{"role":"user","content":"Weather?"},
+			{"role":"assistant","content":null,"function_call":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"role":"function","name":"get_weather","content":"sunny"}
+		]}`
+	parsed, err := (&Adapter{}).ParseRequest(nil, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := parsed.(*OpenAIChatRequest)
+
+	antigravityReq := ConvertOpenAIToAntigravity(req, &store.Account{ProjectID: "test-project"})
+	inner := antigravityReq.Request
+	if len(inner.Tools) != 1 || inner.Tools[0].FunctionDeclarations[0].Name != "get_weather" {
+		t.Fatalf("functions should become function declarations: %+v", inner.Tools)
+	}
+	if fc := inner.ToolConfig.FunctionCallingConfig; fc.Mode != "ANY" || len(fc.AllowedFunctionNames) != 1 {
+		t.Errorf("function_call should force the named function: %+v", fc)
+	}
+	if len(inner.Contents) != 3 {
+		t.Fatalf("expected user, model and function response turns, got %+v", inner.Contents)
+	}
+	call := inner.Contents[1].Parts[0].FunctionCall
+	result := inner.Contents[2].Parts[0].FunctionResponse
+	if call == nil || result == nil || call.ID != result.ID || result.Name != "get_weather" {
+		t.Fatalf("function result should pair with the call: %+v / %+v", call, result)
+	}
+
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{{Content: Content{Parts: []Part{
+		{FunctionCall: &FunctionCall{ID: "call_1", Name: "get_weather", Args: map[string]interface{}{"city": "Rome"}}},
+	}}}}
+	w := httptest.NewRecorder()
+	(&Adapter{}).EmitResponse(w, req, antigravityReq, resp)
+	out := w.Body.String()
+	if strings.Contains(out, "tool_calls") || !strings.Contains(out, `"function_call":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}`) ||
+		!strings.Contains(out, `"finish_reason":"function_call"`) {
+		t.Errorf("expected legacy function_call response: %s", out)
+	}
+}
+
+func TestSystemFingerprint(t *testing.T) {
+	fp := systemFingerprint("gemini-3-pro")
+	if !strings.HasPrefix(fp, "fp_") || len(fp) != 13 {
+		t.Fatalf("unexpected fingerprint %q", fp)
+	}
+	if systemFingerprint("gemini-3-pro") != fp {
+		t.Error("fingerprint should be stable for the same model")
+	}
+	if systemFingerprint("gemini-2.5-flash") == fp {
+		t.Error("different upstream models should have different fingerprints")
+	}
+	if systemFingerprint("gemini-3-pro-high-bypass") != systemFingerprint("gemini-3-pro-high") {
+		t.Error("aliases of the same upstream model should share a fingerprint")
+	}
+
+	resp := ConvertToOpenAIResponse(&AntigravityResponse{}, "gemini-3-pro")
+	if resp.SystemFingerprint != fp {
+		t.Errorf("expected system_fingerprint %q, got %q", fp, resp.SystemFingerprint)
+	}
+}
+
+func TestConvertFinishReason(t *testing.T) {
+	tests := []struct {
+		reason    string
+		toolCalls bool
+		want      string
+	}{
+		{"STOP", false, "stop"},
+		{"", false, "stop"},
+		{"STOP", true, "tool_calls"},
+		{"MAX_TOKENS", false, "length"},
+		{"MAX_TOKENS", true, "length"},
+		{"SAFETY", false, "content_filter"},
+		{"RECITATION", false, "content_filter"},
+		{"PROHIBITED_CONTENT", false, "content_filter"},
+		{"OTHER", false, "stop"},
+	}
+	for _, tt := range tests {
+		if got := ConvertFinishReason(tt.reason, tt.toolCalls); got != tt.want {
+			t.Errorf("ConvertFinishReason(%q, %v) = %q, want %q", tt.reason, tt.toolCalls, got, tt.want)
+		}
+	}
+
+	resp := &AntigravityResponse{}
+	resp.Response.Candidates = []Candidate{{Content: Content{Role: "model", Parts: []Part{{Text: "trunc"}}}, FinishReason: "MAX_TOKENS"}}
+	if reason := ConvertToOpenAIResponse(resp, "gemini-3-pro").Choices[0].FinishReason; reason == nil || *reason != "length" {
+		t.Errorf("non-stream finish_reason = %v", reason)
+	}
+}
+
+func TestErrorObjects(t *testing.T) {
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var body struct {
+			Error map[string]interface{} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Error
+	}
+
+	a := &Adapter{}
+	w := httptest.NewRecorder()
+	a.WriteError(w, 429, "slow down")
+	obj := decode(w)
+	if obj["message"] != "slow down" || obj["type"] != "rate_limit_error" || obj["code"] != "rate_limit_exceeded" {
+		t.Errorf("rate limit error = %v", obj)
+	}
+	if param, ok := obj["param"]; !ok || param != nil {
+		t.Errorf("param should be null, got %v", obj)
+	}
+
+	w = httptest.NewRecorder()
+	a.WriteError(w, 500, "boom")
+	if obj := decode(w); obj["code"] != nil || obj["type"] != "server_error" {
+		t.Errorf("server error = %v", obj)
+	}
+
+	_, err := a.ParseRequest(nil, []byte(`{"model":"gemini-3-pro","reasoning_effort":"extreme","messages":[{"role":"user","content":"hi"}]}`))
+	w = httptest.NewRecorder()
+	adapter.WriteAdapterError(w, a, 400, err)
+	if obj := decode(w); w.Code != 400 || obj["param"] != "reasoning_effort" || obj["code"] != "invalid_value" {
+		t.Errorf("invalid param error = %d %v", w.Code, obj)
+	}
+}
@@ -0,0 +1,69 @@
+package openai
+
+import "fmt"
+
+// 旧版函数调用格式（functions / function_call）：解析时统一转换为 tools 格式处理，
+// 响应再转换回 message.function_call，finish_reason 为 "function_call"
+
+// normalizeLegacyFunctions 将旧版 functions / function_call 字段及历史消息转换为 tools 格式
+// 同时提供 tools 时以 tools 为准
+func (r *OpenAIChatRequest) normalizeLegacyFunctions() {
+	if len(r.Functions) > 0 && len(r.Tools) == 0 {
+		r.legacyFunctions = true
+		for _, fn := range r.Functions {
+			r.Tools = append(r.Tools, OpenAITool{Type: "function", Function: fn})
+		}
+		if r.ToolChoice == nil {
+			r.ToolChoice = legacyToolChoice(r.FunctionCall)
+		}
+		// 旧版格式每次只返回一个函数调用
+		single := false
+		r.ParallelToolCalls = &single
+	}
+
+	for i := range r.Messages {
+		msg := &r.Messages[i]
+		switch {
+		case msg.Role == "assistant" && msg.FunctionCall != nil && len(msg.ToolCalls) == 0:
+			msg.ToolCalls = []OpenAIToolCall{{
+				ID:       fmt.Sprintf("call_fn_%d", i),
+				Type:     "function",
+				Function: *msg.FunctionCall,
+			}}
+		case msg.Role == "function":
+			// 结果按函数名与上一个 assistant 轮次中的调用配对
+			msg.Role = "tool"
+		}
+	}
+}
+
+// legacyToolChoice 将 function_call 映射为 tool_choice
+func legacyToolChoice(functionCall interface{}) interface{} {
+	switch c := functionCall.(type) {
+	case string:
+		return c
+	case map[string]interface{}:
+		if name, _ := c["name"].(string); name != "" {
+			return map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": name},
+			}
+		}
+	}
+	return nil
+}
+
+// toLegacyFunctionCall 将响应中的工具调用转换为旧版 function_call（每个 choice 只保留第一个）
+func toLegacyFunctionCall(resp *OpenAIChatCompletion) {
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		if len(choice.Message.ToolCalls) == 0 {
+			continue
+		}
+		call := choice.Message.ToolCalls[0].Function
+		choice.Message.FunctionCall = &call
+		choice.Message.ToolCalls = nil
+		reason := "function_call"
+		choice.FinishReason = &reason
+	}
+}
@@ -0,0 +1,233 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+)
+
+func init() {
+	adapter.Register(&ImagesAdapter{})
+}
+
+// maxImages 单次请求最多生成的图片数（与 OpenAI 一致）
+const maxImages = 10
+
+// imageAspectRatios Gemini 图片模型支持的宽高比
+var imageAspectRatios = []struct {
+	name  string
+	ratio float64
+}{
+	{"1:1", 1}, {"2:3", 2.0 / 3}, {"3:2", 3.0 / 2}, {"3:4", 3.0 / 4}, {"4:3", 4.0 / 3},
+	{"4:5", 4.0 / 5}, {"5:4", 5.0 / 4}, {"9:16", 9.0 / 16}, {"16:9", 16.0 / 9}, {"21:9", 21.0 / 9},
+}
+
+// ModelName 实现 adapter.Request
+func (r *OpenAIImageRequest) ModelName() string { return r.Model }
+
+// SetModelName 实现 adapter.Request
+func (r *OpenAIImageRequest) SetModelName(model string) { r.Model = model }
+
+// IsStream 实现 adapter.Request（图片生成仅支持非流式）
+func (r *OpenAIImageRequest) IsStream() bool { return false }
+
+// Body 实现 adapter.Request
+func (r *OpenAIImageRequest) Body() interface{} { return r }
+
+// EndUser 实现 adapter.EndUserRequest
+func (r *OpenAIImageRequest) EndUser() string { return r.User }
+
+// ImagesAdapter OpenAI /v1/images/generations 协议适配器
+// 请求转换为 Gemini 图片模型的 generateContent，输出中的 inlineData 作为图片返回
+type ImagesAdapter struct{}
+
+// Name 协议名称
+func (a *ImagesAdapter) Name() string { return adapter.ProtocolOpenAIImages }
+
+// ParseRequest 解析图片生成请求；dall-e-*、gpt-image-* 或未指定的模型替换为 IMAGE_MODEL
+func (a *ImagesAdapter) ParseRequest(r *http.Request, body []byte) (adapter.Request, error) {
+	var req OpenAIImageRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Prompt == "" {
+		return nil, adapter.InvalidParam("prompt", "prompt is required")
+	}
+	if req.Stream {
+		return nil, adapter.InvalidParam("stream", "streaming image generation is not supported")
+	}
+	if req.N == 0 {
+		req.N = 1
+	}
+	if req.N < 1 || req.N > maxImages {
+		return nil, adapter.InvalidParam("n", "n must be between 1 and %d", maxImages)
+	}
+	switch req.ResponseFormat {
+	case "", "url", "b64_json":
+	default:
+		return nil, adapter.InvalidParam("response_format", "unsupported response_format: %s", req.ResponseFormat)
+	}
+
+	imageConfig, err := parseImageSize(req.Size)
+	if err != nil {
+		return nil, err
+	}
+	req.imageConfig = imageConfig
+
+	if req.Model == "" || strings.HasPrefix(req.Model, "dall-e") || strings.HasPrefix(req.Model, "gpt-image") {
+		req.Model = config.Get().ImageModel
+	}
+	return &req, nil
+}
+
+// parseImageSize 将 OpenAI 的 size（WxH）换算为最接近的 Gemini 宽高比与分辨率档位，auto 或为空时由模型决定
+func parseImageSize(size string) (*ImageConfig, error) {
+	if size == "" || size == "auto" {
+		return nil, nil
+	}
+
+	var width, height int
+	if n, _ := fmt.Sscanf(size, "%dx%d", &width, &height); n != 2 || width <= 0 || height <= 0 || fmt.Sprintf("%dx%d", width, height) != size {
+		return nil, adapter.InvalidParam("size", "invalid size: %s", size)
+	}
+
+	ratio := float64(width) / float64(height)
+	best := imageAspectRatios[0]
+	for _, ar := range imageAspectRatios[1:] {
+		if math.Abs(math.Log(ratio/ar.ratio)) < math.Abs(math.Log(ratio/best.ratio)) {
+			best = ar
+		}
+	}
+
+	imageSize := "1K"
+	switch long := max(width, height); {
+	case long > 3072:
+		imageSize = "4K"
+	case long > 1536:
+		imageSize = "2K"
+	}
+	return &ImageConfig{AspectRatio: best.name, ImageSize: imageSize}, nil
+}
+
+// Convert 转换为 Antigravity 请求：prompt 作为单条用户消息，n 对应 candidateCount
+func (a *ImagesAdapter) Convert(req adapter.Request, account *store.Account) (*core.AntigravityRequest, error) {
+	imageReq := req.(*OpenAIImageRequest)
+	return &AntigravityRequest{
+		Project:   getProjectID(account),
+		RequestID: utils.GenerateRequestID(),
+		Model:     ResolveModelName(imageReq.Model),
+		UserAgent: config.Get().UserAgent,
+		Request: AntigravityInnerReq{
+			Contents:  []Content{{Role: "user", Parts: []Part{{Text: imageReq.Prompt}}}},
+			SessionID: account.SessionID,
+			GenerationConfig: &GenerationConfig{
+				CandidateCount:     imageReq.N,
+				ResponseModalities: []string{"TEXT", "IMAGE"},
+				ImageConfig:        imageReq.imageConfig,
+			},
+		},
+	}, nil
+}
+
+// EmitResponse 写出图片生成响应；上游未返回图片时按结束原因返回错误
+func (a *ImagesAdapter) EmitResponse(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, resp *core.AntigravityResponse) (*adapter.Result, error) {
+	imageReq := req.(*OpenAIImageRequest)
+
+	var data []ImageData
+	var text strings.Builder
+	var finishReason string
+	for _, candidate := range resp.Response.Candidates {
+		if finishReason == "" {
+			finishReason = candidate.FinishReason
+		}
+		for _, part := range candidate.Content.Parts {
+			// 思考过程中的草图同样以 inlineData 返回，不计入结果
+			if part.Thought {
+				continue
+			}
+			if part.InlineData != nil {
+				data = append(data, newImageData(part.InlineData, imageReq.ResponseFormat))
+			} else {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+
+	if len(data) == 0 {
+		message := "upstream returned no image"
+		if text.Len() > 0 {
+			message += ": " + text.String()
+		}
+		if strings.Contains(finishReason, "SAFETY") || finishReason == "PROHIBITED_CONTENT" {
+			a.WriteCodedError(w, http.StatusBadRequest, "content_policy_violation", message)
+		} else {
+			a.WriteError(w, http.StatusBadGateway, message)
+		}
+		return nil, errors.New(message)
+	}
+
+	images := &OpenAIImagesResponse{
+		Created: nowUnix(),
+		Data:    data,
+		Usage:   convertImageUsage(resp.Response.UsageMetadata),
+	}
+	adapter.WriteJSON(w, http.StatusOK, images)
+	return &adapter.Result{Body: images, Output: text.String()}, nil
+}
+
+// newImageData 按 response_format 返回 base64 或 data URL
+func newImageData(data *InlineData, format string) ImageData {
+	if format == "b64_json" {
+		return ImageData{B64JSON: data.Data}
+	}
+	return ImageData{URL: imageDataURL(data)}
+}
+
+// convertImageUsage 转换为图片接口的用量格式
+func convertImageUsage(metadata *UsageMetadata) *ImageUsage {
+	if metadata == nil {
+		return nil
+	}
+	return &ImageUsage{
+		InputTokens:  metadata.PromptTokenCount,
+		OutputTokens: metadata.CandidatesTokenCount + metadata.ThoughtsTokenCount,
+		TotalTokens:  metadata.TotalTokenCount,
+	}
+}
+
+// EmitStream 图片生成不支持流式（ParseRequest 已拒绝 stream 请求）
+func (a *ImagesAdapter) EmitStream(w http.ResponseWriter, req adapter.Request, upstreamReq *core.AntigravityRequest, upstream *http.Response) (*adapter.Result, error) {
+	upstream.Body.Close()
+	err := errors.New("streaming image generation is not supported")
+	a.WriteError(w, http.StatusBadRequest, err.Error())
+	return &adapter.Result{}, err
+}
+
+// WriteError 写入 OpenAI 格式错误响应
+func (a *ImagesAdapter) WriteError(w http.ResponseWriter, status int, message string) {
+	(&Adapter{}).WriteError(w, status, message)
+}
+
+// WriteCodedError 写入带错误码的 OpenAI 错误响应
+func (a *ImagesAdapter) WriteCodedError(w http.ResponseWriter, status int, code string, message string) {
+	(&Adapter{}).WriteCodedError(w, status, code, message)
+}
+
+// WriteDetailedError 写入带错误码与附加字段的 OpenAI 错误响应
+func (a *ImagesAdapter) WriteDetailedError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	(&Adapter{}).WriteDetailedError(w, status, code, message, details)
+}
+
+// WriteStreamError 写入 OpenAI 流式错误
+func (a *ImagesAdapter) WriteStreamError(w http.ResponseWriter, status int, message string) {
+	(&Adapter{}).WriteStreamError(w, status, message)
+}
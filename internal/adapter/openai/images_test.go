@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+func TestParseImageSize(t *testing.T) {
+	tests := []struct {
+		size        string
+		aspectRatio string
+		imageSize   string
+	}{
+		{"1024x1024", "1:1", "1K"},
+		{"1792x1024", "16:9", "2K"},
+		{"1024x1536", "2:3", "1K"},
+		{"4096x1744", "21:9", "4K"},
+		{"auto", "", ""},
+	}
+	for _, tt := range tests {
+		cfg, err := parseImageSize(tt.size)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.size, err)
+		}
+		if tt.aspectRatio == "" {
+			if cfg != nil {
+				t.Errorf("%s: config = %+v", tt.size, cfg)
+			}
+			continue
+		}
+		if cfg.AspectRatio != tt.aspectRatio || cfg.ImageSize != tt.imageSize {
+			t.Errorf("%s: config = %+v", tt.size, cfg)
+		}
+	}
+
+	for _, size := range []string{"large", "0x512", "1024x"} {
+		if _, err := parseImageSize(size); err == nil {
+			t.Errorf("%s: expected error", size)
+		}
+	}
+}
+
+func TestImagesConvert(t *testing.T) {
+	a := &ImagesAdapter{}
+	r := httptest.NewRequest("POST", "/v1/images/generations", nil)
+	req, err := a.ParseRequest(r, []byte(`{"model":"dall-e-3","prompt":"a red fox","n":2,"size":"1792x1024"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ModelName() != "gemini-3-pro-image" {
+		t.Errorf("model = %s", req.ModelName())
+	}
+
+	antigravityReq, err := a.Convert(req, &store.Account{ProjectID: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc := antigravityReq.Request.GenerationConfig
+	if gc.CandidateCount != 2 || gc.ImageConfig.AspectRatio != "16:9" || len(gc.ResponseModalities) != 2 {
+		t.Errorf("generation config = %+v", gc)
+	}
+
+	for _, body := range []string{`{"prompt":"x","n":11}`, `{"prompt":"x","stream":true}`, `{"prompt":"x","response_format":"png"}`, `{"n":1}`} {
+		if _, err := a.ParseRequest(r, []byte(body)); err == nil {
+			t.Errorf("%s: expected error", body)
+		}
+	}
+}
+
+func TestImagesEmitResponse(t *testing.T) {
+	a := &ImagesAdapter{}
+	r := httptest.NewRequest("POST", "/v1/images/generations", nil)
+	req, _ := a.ParseRequest(r, []byte(`{"prompt":"a red fox","response_format":"b64_json"}`))
+
+	resp := &core.AntigravityResponse{}
+	resp.Response.Candidates = []core.Candidate{{Content: core.Content{Parts: []core.Part{
+		{Thought: true, InlineData: &core.InlineData{MimeType: "image/png", Data: "ZHJhZnQ="}},
+		{Text: "Here is your fox."},
+		{InlineData: &core.InlineData{MimeType: "image/png", Data: "Zm94"}},
+	}}}}
+
+	w := httptest.NewRecorder()
+	if _, err := a.EmitResponse(w, req, nil, resp); err != nil {
+		t.Fatal(err)
+	}
+	var body OpenAIImagesResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if len(body.Data) != 1 || body.Data[0].B64JSON != "Zm94" || body.Data[0].URL != "" {
+		t.Errorf("body = %s", w.Body.String())
+	}
+
+	// 安全拦截时没有图片
+	resp.Response.Candidates = []core.Candidate{{FinishReason: "IMAGE_SAFETY"}}
+	w = httptest.NewRecorder()
+	if _, err := a.EmitResponse(w, req, nil, resp); err == nil || w.Code != http.StatusBadRequest {
+		t.Errorf("safety: status = %d, err = %v", w.Code, err)
+	}
+}
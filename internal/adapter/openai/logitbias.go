@@ -0,0 +1,52 @@
+package openai
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// logitBiasBan 视为"禁止输出"的 bias 值（OpenAI 约定 -100 等同于禁用该 token）
+const logitBiasBan = -100
+
+// LogitBiasEmulation logit_bias 的模拟结果（以响应扩展字段返回给客户端）
+// 上游不支持 logit_bias，仅"禁止输出"的条目会转换为系统指令约束，其余条目被忽略
+type LogitBiasEmulation struct {
+	Mode    string   `json:"mode"`              // 固定为 system_instruction
+	Banned  []string `json:"banned,omitempty"`  // 已转换为约束的文本
+	Ignored []string `json:"ignored,omitempty"` // 无法模拟的条目
+}
+
+// EmulateLogitBias 解析 logit_bias，未提供时返回 nil
+// 由于没有 tokenizer 词表，数字 token ID 无法还原为文本而被忽略；
+// 作为扩展，键为非数字字符串时按字面文本处理，值为 -100 时禁止输出该文本
+func EmulateLogitBias(bias map[string]float64) *LogitBiasEmulation {
+	if len(bias) == 0 {
+		return nil
+	}
+
+	emu := &LogitBiasEmulation{Mode: "system_instruction"}
+	for key, value := range bias {
+		_, err := strconv.Atoi(key)
+		if err != nil && value <= logitBiasBan && strings.TrimSpace(key) != "" {
+			emu.Banned = append(emu.Banned, key)
+		} else {
+			emu.Ignored = append(emu.Ignored, key)
+		}
+	}
+	sort.Strings(emu.Banned)
+	sort.Strings(emu.Ignored)
+	return emu
+}
+
+// instruction 生成禁止输出指定文本的系统指令，无禁止项时返回空字符串
+func (e *LogitBiasEmulation) instruction() string {
+	if e == nil || len(e.Banned) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(e.Banned))
+	for i, b := range e.Banned {
+		quoted[i] = strconv.Quote(b)
+	}
+	return "Never output any of the following strings, in any part of your response: " + strings.Join(quoted, ", ") + "."
+}
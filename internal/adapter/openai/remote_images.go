@@ -0,0 +1,211 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+// errPrivateAddress 图片 URL 解析到内网 / 回环地址（IMAGE_FETCH_ALLOW_PRIVATE 关闭时拒绝）
+var errPrivateAddress = errors.New("image url resolves to a private address")
+
+// fetchRemoteImages 下载消息中的 http(s) 图片 URL 并替换为 data URI（IMAGE_URL_FETCH 开启时）
+// 在解析请求时执行一次，避免重试换号时重复下载；同一请求中相同的 URL 只下载一次
+func (r *OpenAIChatRequest) fetchRemoteImages(ctx context.Context) error {
+	cfg := config.Get()
+	if !cfg.ImageURLFetch {
+		return nil
+	}
+
+	fetched := make(map[string]string)
+	for _, msg := range r.Messages {
+		items, ok := msg.Content.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok || m["type"] != "image_url" {
+				continue
+			}
+			imgURL, ok := m["image_url"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rawURL, _ := imgURL["url"].(string)
+			if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+				continue
+			}
+
+			dataURI, ok := fetched[rawURL]
+			if !ok {
+				var err error
+				if dataURI, err = fetchImage(ctx, cfg, rawURL); err != nil {
+					return fmt.Errorf("failed to fetch image %s: %w", redactImageURL(rawURL), err)
+				}
+				fetched[rawURL] = dataURI
+			}
+			imgURL["url"] = dataURI
+		}
+	}
+	return nil
+}
+
+// fetchImage 下载图片并返回 data URI：限制大小与耗时，只接受 image/* 内容
+func fetchImage(ctx context.Context, cfg *config.Config, rawURL string) (string, error) {
+	timeout := time.Duration(cfg.ImageFetchTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "image/*")
+	if err := checkImageHost(ctx, newImageClientKey(cfg), req.URL); err != nil {
+		return "", err
+	}
+
+	resp, err := imageFetchClient(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	maxBytes := int64(cfg.ImageFetchMaxMB) << 20
+	if maxBytes <= 0 {
+		maxBytes = 10 << 20
+	}
+	if resp.ContentLength > maxBytes {
+		return "", fmt.Errorf("image exceeds %d MB", cfg.ImageFetchMaxMB)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("image exceeds %d MB", cfg.ImageFetchMaxMB)
+	}
+
+	// Content-Type 缺失或为通用二进制类型时按内容识别
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("unsupported content type %q", mimeType)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// imageClientKey 决定下载客户端行为的配置项
+type imageClientKey struct {
+	proxy        string
+	allowPrivate bool
+}
+
+func newImageClientKey(cfg *config.Config) imageClientKey {
+	return imageClientKey{proxy: cfg.Proxy, allowPrivate: cfg.ImageFetchAllowPrivate}
+}
+
+var (
+	imageClientsMu sync.Mutex
+	imageClients   = make(map[imageClientKey]*http.Client)
+)
+
+// imageFetchClient 返回下载图片的 HTTP 客户端，按 PROXY 与 IMAGE_FETCH_ALLOW_PRIVATE 复用，避免每次请求泄漏空闲连接
+func imageFetchClient(cfg *config.Config) *http.Client {
+	key := newImageClientKey(cfg)
+	imageClientsMu.Lock()
+	defer imageClientsMu.Unlock()
+	client, ok := imageClients[key]
+	if !ok {
+		client = newImageFetchClient(key)
+		imageClients[key] = client
+	}
+	return client
+}
+
+// newImageFetchClient 创建下载图片的 HTTP 客户端：沿用 PROXY 配置，未允许时拒绝连接内网地址
+// 直连时在建立连接时校验实际 IP；经代理时无法得知代理解析的地址，改为请求前解析域名校验
+func newImageFetchClient(key imageClientKey) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !key.allowPrivate && key.proxy == "" {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{DialContext: dialer.DialContext, MaxIdleConnsPerHost: 4, IdleConnTimeout: 90 * time.Second}
+	if key.proxy != "" {
+		if proxyURL, err := url.Parse(key.proxy); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			return checkImageHost(req.Context(), key, req.URL)
+		},
+	}
+}
+
+// checkImageHost 经代理下载时解析域名，拒绝指向内网地址的 URL
+func checkImageHost(ctx context.Context, key imageClientKey, u *url.URL) error {
+	if key.allowPrivate || key.proxy == "" {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// isPrivateIP 是否为回环、内网、链路本地或未指定地址
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsInterfaceLocalMulticast()
+}
+
+// redactImageURL 错误信息中只保留图片 URL 的协议、主机与路径（查询参数可能含签名）
+func redactImageURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid url)"
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
@@ -0,0 +1,112 @@
+package openai
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/config"
+)
+
+// pngHeader 足以被 http.DetectContentType 识别为 image/png
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestFetchRemoteImages(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/cat.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngHeader)
+		case "/sniffed":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(pngHeader)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/huge.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 2<<20))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.Get()
+	defer func(fetch, allow bool, maxMB int) {
+		cfg.ImageURLFetch, cfg.ImageFetchAllowPrivate, cfg.ImageFetchMaxMB = fetch, allow, maxMB
+	}(cfg.ImageURLFetch, cfg.ImageFetchAllowPrivate, cfg.ImageFetchMaxMB)
+	cfg.ImageFetchMaxMB = 1
+
+	parse := func(paths ...string) (*OpenAIChatRequest, error) {
+		var items []string
+		for _, p := range paths {
+			items = append(items, `{"type":"image_url","image_url":{"url":"`+srv.URL+p+`"}}`)
+		}
+		body := `{"model":"gemini-3-pro","messages":[{"role":"user","content":[{"type":"text","text":"what is this?"},` + strings.Join(items, ",") + `]}]}`
+		parsed, err := (&Adapter{}).ParseRequest(nil, []byte(body))
+		if err != nil {
+			return nil, err
+		}
+		return parsed.(*OpenAIChatRequest), nil
+	}
+
+	// 关闭时不下载，URL 原样保留（转换时被忽略）
+	cfg.ImageURLFetch = false
+	if _, err := parse("/cat.png"); err != nil || hits != 0 {
+		t.Fatalf("fetching disabled: err=%v hits=%d", err, hits)
+	}
+
+	// 默认拒绝回环地址
+	cfg.ImageURLFetch = true
+	cfg.ImageFetchAllowPrivate = false
+	if _, err := parse("/cat.png"); err == nil || !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("loopback address should be rejected, got %v", err)
+	}
+
+	cfg.ImageFetchAllowPrivate = true
+	hits = 0
+	req, err := parse("/cat.png", "/sniffed", "/cat.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits != 2 {
+		t.Errorf("duplicate URLs should be fetched once, got %d requests", hits)
+	}
+	parts := extractParts(req.Messages[0].Content)
+	if len(parts) != 4 {
+		t.Fatalf("expected text and 3 image parts, got %+v", parts)
+	}
+	for _, part := range parts[1:] {
+		if part.InlineData == nil || part.InlineData.MimeType != "image/png" || part.InlineData.Data != base64.StdEncoding.EncodeToString(pngHeader) {
+			t.Errorf("unexpected inline data %+v", part.InlineData)
+		}
+	}
+
+	for _, path := range []string{"/page.html", "/huge.png", "/missing.png"} {
+		if _, err := parse(path); err == nil {
+			t.Errorf("%s should be rejected", path)
+		}
+	}
+}
+
+// TestImageFetchClientReused 相同配置复用同一客户端，避免每次下载泄漏空闲连接
+func TestImageFetchClientReused(t *testing.T) {
+	cfg := config.Get()
+	defer func(allow bool) { cfg.ImageFetchAllowPrivate = allow }(cfg.ImageFetchAllowPrivate)
+
+	cfg.ImageFetchAllowPrivate = false
+	strict := imageFetchClient(cfg)
+	if imageFetchClient(cfg) != strict {
+		t.Error("expected the client to be reused for the same settings")
+	}
+	cfg.ImageFetchAllowPrivate = true
+	if imageFetchClient(cfg) == strict {
+		t.Error("expected a separate client when IMAGE_FETCH_ALLOW_PRIVATE changes")
+	}
+}
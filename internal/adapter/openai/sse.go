@@ -19,6 +19,7 @@ type StreamData struct {
 				Parts []struct {
 					Text             string             `json:"text,omitempty"`
 					FunctionCall     *core.FunctionCall `json:"functionCall,omitempty"`
+					InlineData       *core.InlineData   `json:"inlineData,omitempty"`
 					Thought          bool               `json:"thought,omitempty"`
 					ThoughtSignature string             `json:"thoughtSignature,omitempty"`
 				} `json:"parts"`
@@ -33,6 +34,7 @@ type StreamData struct {
 type StreamDataPart struct {
 	Text             string
 	FunctionCall     *core.FunctionCall
+	InlineData       *core.InlineData
 	Thought          bool
 	ThoughtSignature string
 }
@@ -43,10 +45,15 @@ type SSEWriter struct {
 	id              string
 	created         int64
 	model           string
+	index           int // choice 索引（n > 1 时每个候选一个写入器）
 	sentRole        bool
 	contentBuffer   []byte              // 缓冲不完整的 UTF-8 内容字节
 	reasoningBuffer []byte              // 缓冲不完整的 UTF-8 思考字节
-	toolCalls       []core.ToolCallInfo // 累积工具调用
+	toolCallIndex   int                 // 下一个工具调用在 delta 中的 index
+	singleToolCall  bool                // parallel_tool_calls 为 false：只下发第一个工具调用
+	legacyFunctions bool                // 旧版 functions 请求：工具调用以 delta.function_call 下发
+	logitBias       *LogitBiasEmulation // 结束 chunk 中返回的 logit_bias 模拟说明
+	includeUsage    bool                // stream_options.include_usage：结束后单独发送用量 chunk
 	mu              sync.Mutex          // 保护并发写入
 	// 用于收集原始 JSON 以便日志记录（透传）
 	collectedEvents []map[string]interface{}
@@ -63,6 +70,55 @@ func NewSSEWriter(w http.ResponseWriter, id string, created int64, model string)
 	}
 }
 
+// NewChoiceWriter 为第 index 个候选创建写入器，与 sw 共用响应与 ID（n > 1 时使用）
+// 响应头已由 sw 设置；各写入器须在同一 goroutine 中顺序使用
+func (sw *SSEWriter) NewChoiceWriter(index int) *SSEWriter {
+	return &SSEWriter{
+		w:               sw.w,
+		id:              sw.id,
+		created:         sw.created,
+		model:           sw.model,
+		index:           index,
+		singleToolCall:  sw.singleToolCall,
+		legacyFunctions: sw.legacyFunctions,
+	}
+}
+
+// newChunk 创建当前 choice 的流式 chunk
+func (sw *SSEWriter) newChunk(delta *Delta, finishReason *string, usage *Usage) *OpenAIStreamChunk {
+	chunk := CreateStreamChunk(sw.id, sw.created, sw.model, delta, finishReason, usage)
+	chunk.Choices[0].Index = sw.index
+	return chunk
+}
+
+// SetLogitBiasEmulation 设置结束 chunk 中返回的 logit_bias 模拟说明
+func (sw *SSEWriter) SetLogitBiasEmulation(emu *LogitBiasEmulation) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.logitBias = emu
+}
+
+// SetIncludeUsage 设置是否在 [DONE] 前发送用量 chunk（stream_options.include_usage）
+func (sw *SSEWriter) SetIncludeUsage(include bool) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.includeUsage = include
+}
+
+// SetSingleToolCall 设置是否只下发第一个工具调用（parallel_tool_calls: false）
+func (sw *SSEWriter) SetSingleToolCall(single bool) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.singleToolCall = single
+}
+
+// SetLegacyFunctions 设置是否以旧版 function_call 下发工具调用
+func (sw *SSEWriter) SetLegacyFunctions(legacy bool) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.legacyFunctions = legacy
+}
+
 // ProcessData 处理 Vertex 流式数据并转换为 OpenAI 格式
 func (sw *SSEWriter) ProcessData(data *StreamData) error {
 	sw.mu.Lock()
@@ -88,26 +144,15 @@ func (sw *SSEWriter) ProcessData(data *StreamData) error {
 
 		} else if part.FunctionCall != nil {
 			// 3. 处理工具调用
-			id := part.FunctionCall.ID
-			if id == "" {
-				id = utils.GenerateToolCallID()
+			if err := sw.addToolCallLocked(part.FunctionCall, part.ThoughtSignature); err != nil {
+				return err
+			}
+		} else if part.InlineData != nil {
+			// 4. 处理生成的图片
+			if err := sw.writeImageLocked(part.InlineData); err != nil {
+				return err
 			}
-
-			sw.toolCalls = append(sw.toolCalls, core.ToolCallInfo{
-				ID:               id,
-				Name:             part.FunctionCall.Name,
-				Args:             part.FunctionCall.Args,
-				ThoughtSignature: part.ThoughtSignature,
-			})
-		}
-	}
-
-	// 响应结束或遇到停止原因时发送工具调用
-	if candidate.FinishReason != "" && len(sw.toolCalls) > 0 {
-		if err := sw.writeToolCallsLocked(sw.toolCalls); err != nil {
-			return err
 		}
-		sw.toolCalls = nil
 	}
 
 	return nil
@@ -123,37 +168,35 @@ func (sw *SSEWriter) ProcessPart(part StreamDataPart) error {
 	} else if part.Text != "" {
 		return sw.writeContentLocked(part.Text)
 	} else if part.FunctionCall != nil {
-		id := part.FunctionCall.ID
-		if id == "" {
-			id = utils.GenerateToolCallID()
-		}
-		sw.toolCalls = append(sw.toolCalls, core.ToolCallInfo{
-			ID:               id,
-			Name:             part.FunctionCall.Name,
-			Args:             part.FunctionCall.Args,
-			ThoughtSignature: part.ThoughtSignature,
-		})
+		return sw.addToolCallLocked(part.FunctionCall, part.ThoughtSignature)
+	} else if part.InlineData != nil {
+		return sw.writeImageLocked(part.InlineData)
 	}
 	return nil
 }
 
-// FlushToolCalls 刷新累积的工具调用（当收到 FinishReason 时调用）
-func (sw *SSEWriter) FlushToolCalls() error {
-	sw.mu.Lock()
-	defer sw.mu.Unlock()
-
-	if len(sw.toolCalls) > 0 {
-		if err := sw.writeToolCallsLocked(sw.toolCalls); err != nil {
-			return err
-		}
-		sw.toolCalls = nil
+// addToolCallLocked 收到工具调用后立即以增量 delta 下发；parallel_tool_calls 为 false 时只下发第一个
+func (sw *SSEWriter) addToolCallLocked(call *core.FunctionCall, signature string) error {
+	if sw.singleToolCall && sw.toolCallIndex > 0 {
+		return nil
 	}
-	return nil
+	id := call.ID
+	if id == "" {
+		id = utils.GenerateToolCallID()
+	}
+	return sw.writeToolCallLocked(core.ToolCallInfo{
+		ID:               id,
+		Name:             call.Name,
+		Args:             call.Args,
+		ThoughtSignature: signature,
+	})
 }
 
 // HasToolCalls 检查是否有处理过的工具调用
 func (sw *SSEWriter) HasToolCalls() bool {
-	return false
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.toolCallIndex > 0
 }
 
 // writeRoleLocked 写入角色（内部使用，调用者必须持有锁）
@@ -163,11 +206,7 @@ func (sw *SSEWriter) writeRoleLocked() error {
 	}
 	sw.sentRole = true
 
-	chunk := CreateStreamChunk(
-		sw.id, sw.created, sw.model,
-		&Delta{Role: "assistant"},
-		nil, nil,
-	)
+	chunk := sw.newChunk(&Delta{Role: "assistant"}, nil, nil)
 	return sw.writeSSEDataAndCollect(chunk)
 }
 
@@ -271,11 +310,7 @@ func (sw *SSEWriter) writeContentLocked(content string) error {
 		return nil
 	}
 
-	chunk := CreateStreamChunk(
-		sw.id, sw.created, sw.model,
-		&Delta{Content: validContent},
-		nil, nil,
-	)
+	chunk := sw.newChunk(&Delta{Content: validContent}, nil, nil)
 	return sw.writeSSEDataAndCollect(chunk)
 }
 
@@ -300,11 +335,7 @@ func (sw *SSEWriter) writeReasoningLocked(reasoning string) error {
 		return nil
 	}
 
-	chunk := CreateStreamChunk(
-		sw.id, sw.created, sw.model,
-		&Delta{Reasoning: validReasoning},
-		nil, nil,
-	)
+	chunk := sw.newChunk(reasoningDelta(validReasoning), nil, nil)
 	return sw.writeSSEDataAndCollect(chunk)
 }
 
@@ -315,46 +346,98 @@ func (sw *SSEWriter) WriteReasoning(reasoning string) error {
 	return sw.writeReasoningLocked(reasoning)
 }
 
-// writeToolCallsLocked 写入工具调用（内部使用）
-func (sw *SSEWriter) writeToolCallsLocked(toolCalls []core.ToolCallInfo) error {
+// writeImageLocked 写入生成的图片（内部使用）
+// IMAGE_OUTPUT=images 时以 delta.images 发送，否则作为 markdown 内容发送
+func (sw *SSEWriter) writeImageLocked(data *core.InlineData) error {
+	url := imageDataURL(data)
+	if !imagesAsField() {
+		return sw.writeContentLocked(imageMarkdown(url))
+	}
+
 	sw.writeRoleLocked()
+	chunk := sw.newChunk(&Delta{Images: []OpenAIImage{newOpenAIImage(url)}}, nil, nil)
+	return sw.writeSSEDataAndCollect(chunk)
+}
 
-	openaiCalls := make([]OpenAIToolCall, len(toolCalls))
-	for i, tc := range toolCalls {
-		argsJSON, _ := json.Marshal(tc.Args)
-		var extraContent *ExtraContent
-		if tc.ThoughtSignature != "" {
-			extraContent = &ExtraContent{
-				Google: &GoogleExtra{
-					ThoughtSignature: tc.ThoughtSignature,
-				},
-			}
-		}
+// WriteImages 写入 images 字段（线程安全）
+func (sw *SSEWriter) WriteImages(images []OpenAIImage) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
 
-		openaiCalls[i] = OpenAIToolCall{
-			ID:   tc.ID,
-			Type: "function",
-			Function: OpenAIFunctionCall{
-				Name:      tc.Name,
-				Arguments: string(argsJSON),
+	sw.writeRoleLocked()
+	chunk := sw.newChunk(&Delta{Images: images}, nil, nil)
+	return sw.writeSSEDataAndCollect(chunk)
+}
+
+// WriteLogprobs 写入 logprobs（delta 为空，线程安全）
+func (sw *SSEWriter) WriteLogprobs(logprobs *ChoiceLogprobs) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.writeRoleLocked()
+	chunk := sw.newChunk(&Delta{}, nil, nil)
+	chunk.Choices[0].Logprobs = logprobs
+	return sw.writeSSEDataAndCollect(chunk)
+}
+
+// toolArgsFragmentSize 工具调用参数每个增量片段的最大字节数
+const toolArgsFragmentSize = 128
+
+// writeToolCallLocked 以增量 delta 写入单个工具调用（内部使用）：
+// 首个 chunk 携带 index、id、type 与函数名（arguments 为空），之后的 chunk 只携带 index 与参数片段
+func (sw *SSEWriter) writeToolCallLocked(tc core.ToolCallInfo) error {
+	sw.writeRoleLocked()
+
+	argsJSON, _ := json.Marshal(tc.Args)
+	var extraContent *ExtraContent
+	if tc.ThoughtSignature != "" {
+		thoughtSignatures.Put(tc.ID, tc.ThoughtSignature)
+		extraContent = &ExtraContent{
+			Google: &GoogleExtra{
+				ThoughtSignature: tc.ThoughtSignature,
 			},
-			ExtraContent: extraContent,
 		}
 	}
 
-	chunk := CreateStreamChunk(
-		sw.id, sw.created, sw.model,
-		&Delta{ToolCalls: openaiCalls},
-		nil, nil,
-	)
-	return sw.writeSSEDataAndCollect(chunk)
+	index := sw.toolCallIndex
+	sw.toolCallIndex++
+
+	head := &Delta{ToolCalls: []ToolCallDelta{{
+		Index:        index,
+		ID:           tc.ID,
+		Type:         "function",
+		Function:     FunctionCallDelta{Name: tc.Name},
+		ExtraContent: extraContent,
+	}}}
+	if sw.legacyFunctions {
+		head = &Delta{FunctionCall: &FunctionCallDelta{Name: tc.Name}}
+	}
+	if err := sw.writeSSEDataAndCollect(sw.newChunk(head, nil, nil)); err != nil {
+		return err
+	}
+
+	for _, fragment := range utils.SplitUTF8(string(argsJSON), toolArgsFragmentSize) {
+		delta := &Delta{ToolCalls: []ToolCallDelta{{Index: index, Function: FunctionCallDelta{Arguments: fragment}}}}
+		if sw.legacyFunctions {
+			delta = &Delta{FunctionCall: &FunctionCallDelta{Arguments: fragment}}
+		}
+		if err := sw.writeSSEDataAndCollect(sw.newChunk(delta, nil, nil)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// WriteToolCalls 写入工具调用（线程安全）
+// WriteToolCalls 依次以增量 delta 写入工具调用（线程安全）
 func (sw *SSEWriter) WriteToolCalls(toolCalls []core.ToolCallInfo) error {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
-	return sw.writeToolCallsLocked(toolCalls)
+	for _, tc := range toolCalls {
+		if err := sw.writeToolCallLocked(tc); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // flushLocked 刷新缓冲区中剩余的内容
@@ -363,11 +446,7 @@ func (sw *SSEWriter) flushLocked() error {
 		content := string(sw.contentBuffer)
 		sw.contentBuffer = nil
 		if content != "" {
-			chunk := CreateStreamChunk(
-				sw.id, sw.created, sw.model,
-				&Delta{Content: content},
-				nil, nil,
-			)
+			chunk := sw.newChunk(&Delta{Content: content}, nil, nil)
 			if err := WriteSSEData(sw.w, chunk); err != nil {
 				return err
 			}
@@ -378,11 +457,7 @@ func (sw *SSEWriter) flushLocked() error {
 		reasoning := string(sw.reasoningBuffer)
 		sw.reasoningBuffer = nil
 		if reasoning != "" {
-			chunk := CreateStreamChunk(
-				sw.id, sw.created, sw.model,
-				&Delta{Reasoning: reasoning},
-				nil, nil,
-			)
+			chunk := sw.newChunk(reasoningDelta(reasoning), nil, nil)
 			if err := WriteSSEData(sw.w, chunk); err != nil {
 				return err
 			}
@@ -400,24 +475,44 @@ func (sw *SSEWriter) Flush() error {
 }
 
 // WriteFinish 写入结束（线程安全）
+// 用量仅在 include_usage 时以 choices 为空的独立 chunk 发送，否则不返回
 func (sw *SSEWriter) WriteFinish(reason string, usage *Usage) error {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
 
 	sw.flushLocked()
 
-	chunk := CreateStreamChunk(
-		sw.id, sw.created, sw.model,
-		&Delta{},
-		&reason, usage,
-	)
+	chunk := sw.newChunk(&Delta{}, &reason, nil)
+	chunk.LogitBiasEmulation = sw.logitBias
 	if err := WriteSSEData(sw.w, chunk); err != nil {
 		return err
 	}
+	if sw.includeUsage && usage != nil {
+		if err := WriteSSEData(sw.w, &OpenAIStreamChunk{
+			ID:                sw.id,
+			Object:            "chat.completion.chunk",
+			Created:           sw.created,
+			Model:             sw.model,
+			SystemFingerprint: systemFingerprint(sw.model),
+			Choices:           []Choice{},
+			Usage:             usage,
+		}); err != nil {
+			return err
+		}
+	}
 	WriteSSEDone(sw.w)
 	return nil
 }
 
+// WriteChoiceFinish 写入当前 choice 的结束 chunk，不带用量与 [DONE]（n > 1 时用于其余候选，线程安全）
+func (sw *SSEWriter) WriteChoiceFinish(reason string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.flushLocked()
+	return WriteSSEData(sw.w, sw.newChunk(&Delta{}, &reason, nil))
+}
+
 // WriteHeartbeat 写入心跳（发送空 delta 的有效数据包，线程安全）
 func (sw *SSEWriter) WriteHeartbeat() error {
 	sw.mu.Lock()
@@ -425,14 +520,24 @@ func (sw *SSEWriter) WriteHeartbeat() error {
 
 	sw.writeRoleLocked()
 
-	chunk := CreateStreamChunk(
-		sw.id, sw.created, sw.model,
-		&Delta{},
-		nil, nil,
-	)
+	chunk := sw.newChunk(&Delta{}, nil, nil)
 	return WriteSSEData(sw.w, chunk)
 }
 
+// WriteHeartbeatComment 以 SSE 注释行写入心跳（客户端会忽略，线程安全）
+func (sw *SSEWriter) WriteHeartbeatComment() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if _, err := fmt.Fprint(sw.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
 // GetMergedResponse 返回收集的原始 SSE 事件（用于透传日志记录）
 // 合并连续的 content 和 reasoning delta 事件以提高可读性
 func (sw *SSEWriter) GetMergedResponse() []interface{} {
@@ -453,7 +558,7 @@ func (sw *SSEWriter) GetMergedResponse() []interface{} {
 				"model":   sw.model,
 				"choices": []interface{}{
 					map[string]interface{}{
-						"index": 0,
+						"index": sw.index,
 						"delta": map[string]interface{}{
 							"reasoning": pendingReasoning,
 						},
@@ -470,7 +575,7 @@ func (sw *SSEWriter) GetMergedResponse() []interface{} {
 				"model":   sw.model,
 				"choices": []interface{}{
 					map[string]interface{}{
-						"index": 0,
+						"index": sw.index,
 						"delta": map[string]interface{}{
 							"content": pendingContent,
 						},
@@ -514,8 +619,12 @@ func (sw *SSEWriter) GetMergedResponse() []interface{} {
 			continue
 		}
 
-		// 检查是否有 reasoning
-		if reasoning, ok := delta["reasoning"].(string); ok && reasoning != "" {
+		// 检查是否有 reasoning（REASONING_FIELD=reasoning_content 时只有 reasoning_content）
+		reasoning, ok := delta["reasoning"].(string)
+		if !ok {
+			reasoning, ok = delta["reasoning_content"].(string)
+		}
+		if ok && reasoning != "" {
 			if pendingContent != "" {
 				// 先刷新待处理的 content
 				flushPending()
@@ -568,12 +677,9 @@ func WriteSSEDone(w http.ResponseWriter) {
 }
 
 // WriteSSEError 写入流错误
-func WriteSSEError(w http.ResponseWriter, errMsg string) {
+func WriteSSEError(w http.ResponseWriter, status int, errMsg string) {
 	errResp := map[string]interface{}{
-		"error": map[string]interface{}{
-			"message": errMsg,
-			"type":    "server_error",
-		},
+		"error": errorObject(status, "", errMsg, nil),
 	}
 	WriteSSEData(w, errResp)
 	WriteSSEDone(w)
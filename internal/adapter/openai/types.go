@@ -1,6 +1,10 @@
 package openai
 
-import "anti2api-golang/internal/core"
+import (
+	"encoding/json"
+
+	"anti2api-golang/internal/core"
+)
 
 // ==================== Core 类型别名 ====================
 
@@ -46,9 +50,15 @@ type FunctionCallingConfig = core.FunctionCallingConfig
 // GenerationConfig 生成配置
 type GenerationConfig = core.GenerationConfig
 
+// ImageConfig 图片生成配置
+type ImageConfig = core.ImageConfig
+
 // ThinkingConfig 思考配置
 type ThinkingConfig = core.ThinkingConfig
 
+// LogprobsResult token 对数概率
+type LogprobsResult = core.LogprobsResult
+
 // Candidate 候选响应
 type Candidate = core.Candidate
 
@@ -84,32 +94,93 @@ var ShouldEnableThinking = core.ShouldEnableThinking
 // BuildThinkingConfig 构建思考配置
 var BuildThinkingConfig = core.BuildThinkingConfig
 
+// ApplyReasoningEffort 按 reasoning_effort 调整思考配置
+var ApplyReasoningEffort = core.ApplyReasoningEffort
+
 // GetClaudeMaxOutputTokens 获取 Claude 模型最大输出 Token
 var GetClaudeMaxOutputTokens = core.GetClaudeMaxOutputTokens
 
+// ResponseSchema 将 JSON Schema 转换为 Vertex responseSchema
+var ResponseSchema = core.ResponseSchema
+
 // ==================== OpenAI 格式 ====================
 
 // OpenAIChatRequest OpenAI 聊天请求
 type OpenAIChatRequest struct {
-	Model       string          `json:"model"`
-	Messages    []OpenAIMessage `json:"messages"`
-	Stream      bool            `json:"stream"`
-	Temperature *float64        `json:"temperature,omitempty"`
-	TopP        *float64        `json:"top_p,omitempty"`
-	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Stop        []string        `json:"stop,omitempty"`
-	Tools       []OpenAITool    `json:"tools,omitempty"`
-	ToolChoice  interface{}     `json:"tool_choice,omitempty"`
+	Model               string             `json:"model"`
+	Messages            []OpenAIMessage    `json:"messages"`
+	Stream              bool               `json:"stream"`
+	StreamOptions       *StreamOptions     `json:"stream_options,omitempty"`
+	N                   int                `json:"n,omitempty"`
+	Temperature         *float64           `json:"temperature,omitempty"`
+	TopP                *float64           `json:"top_p,omitempty"`
+	MaxTokens           int                `json:"max_tokens,omitempty"` // 已弃用，优先使用 max_completion_tokens
+	MaxCompletionTokens int                `json:"max_completion_tokens,omitempty"`
+	Seed                *int               `json:"seed,omitempty"`
+	PresencePenalty     *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64           `json:"frequency_penalty,omitempty"`
+	Stop                []string           `json:"stop,omitempty"`
+	Tools               []OpenAITool       `json:"tools,omitempty"`
+	ToolChoice          interface{}        `json:"tool_choice,omitempty"`
+	ParallelToolCalls   *bool              `json:"parallel_tool_calls,omitempty"`
+	Functions           []OpenAIFunction   `json:"functions,omitempty"`     // 旧版函数定义（tools 之前的格式）
+	FunctionCall        interface{}        `json:"function_call,omitempty"` // 旧版 "none"、"auto" 或 {"name": ...}
+	LogitBias           map[string]float64 `json:"logit_bias,omitempty"`
+	ResponseFormat      *ResponseFormat    `json:"response_format,omitempty"`
+	Logprobs            bool               `json:"logprobs,omitempty"`
+	TopLogprobs         int                `json:"top_logprobs,omitempty"`
+	ReasoningEffort     string             `json:"reasoning_effort,omitempty"` // minimal / low / medium / high
+	Prediction          *Prediction        `json:"prediction,omitempty"`       // 预测输出，仅记录
+	User                string             `json:"user,omitempty"`             // 终端用户标识，记录到日志
+
+	legacyFunctions bool // 使用旧版 functions：响应以 function_call 返回
+}
+
+// StreamOptions 流式选项：include_usage 时在 [DONE] 前单独发送 choices 为空的用量 chunk
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Prediction 预测输出（predicted outputs）：上游不支持推测解码，只接受并随请求记录，不影响生成
+type Prediction struct {
+	Type    string      `json:"type"`    // content
+	Content interface{} `json:"content"` // string 或 [{"type": "text", "text": ...}]
+}
+
+// UnmarshalJSON 宽松解析：prediction 形式不符时忽略而不是拒绝整个请求
+func (p *Prediction) UnmarshalJSON(data []byte) error {
+	type plain Prediction
+	var v plain
+	if err := json.Unmarshal(data, &v); err == nil {
+		*p = Prediction(v)
+	}
+	return nil
+}
+
+// ResponseFormat 结构化输出格式：text、json_object 或 json_schema
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema json_schema 格式的 schema 定义
+type JSONSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
 }
 
 // OpenAIMessage OpenAI 消息格式
 type OpenAIMessage struct {
-	Role       string           `json:"role"`
-	Content    interface{}      `json:"content"`
-	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string           `json:"tool_call_id,omitempty"`
-	Name       string           `json:"name,omitempty"`
-	Reasoning  string           `json:"reasoning,omitempty"`
+	Role             string              `json:"role"`
+	Content          interface{}         `json:"content"`
+	ToolCalls        []OpenAIToolCall    `json:"tool_calls,omitempty"`
+	FunctionCall     *OpenAIFunctionCall `json:"function_call,omitempty"` // 旧版函数调用
+	ToolCallID       string              `json:"tool_call_id,omitempty"`
+	Name             string              `json:"name,omitempty"`
+	Reasoning        string              `json:"reasoning,omitempty"`
+	ReasoningContent string              `json:"reasoning_content,omitempty"` // DeepSeek 风格的思考内容，reasoning 为空时使用
 }
 
 // OpenAIContentPart OpenAI 内容部分
@@ -164,36 +235,108 @@ type OpenAIFunctionCall struct {
 
 // OpenAIChatCompletion OpenAI 聊天完成响应
 type OpenAIChatCompletion struct {
-	ID      string   `json:"id"`
-	Object  string   `json:"object"`
-	Created int64    `json:"created"`
-	Model   string   `json:"model"`
-	Choices []Choice `json:"choices"`
-	Usage   *Usage   `json:"usage,omitempty"`
+	ID                string   `json:"id"`
+	Object            string   `json:"object"`
+	Created           int64    `json:"created"`
+	Model             string   `json:"model"`
+	SystemFingerprint string   `json:"system_fingerprint,omitempty"`
+	Choices           []Choice `json:"choices"`
+	Usage             *Usage   `json:"usage,omitempty"`
+	// LogitBiasEmulation 扩展字段：说明 logit_bias 的模拟方式
+	LogitBiasEmulation *LogitBiasEmulation `json:"logit_bias_emulation,omitempty"`
 }
 
 // Choice 选择
 type Choice struct {
-	Index        int     `json:"index"`
-	Message      Message `json:"message,omitempty"`
-	Delta        *Delta  `json:"delta,omitempty"`
-	FinishReason *string `json:"finish_reason"`
+	Index        int             `json:"index"`
+	Message      Message         `json:"message,omitempty"`
+	Delta        *Delta          `json:"delta,omitempty"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+// ChoiceLogprobs 选择的 token 对数概率（请求 logprobs 时返回）
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob 单个输出 token 的对数概率
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// TopLogprob 候选 token 的对数概率
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
 }
 
 // Message 消息
 type Message struct {
-	Role      string           `json:"role"`
-	Content   string           `json:"content"`
-	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
-	Reasoning string           `json:"reasoning,omitempty"`
+	Role             string              `json:"role"`
+	Content          string              `json:"content"`
+	ToolCalls        []OpenAIToolCall    `json:"tool_calls,omitempty"`
+	FunctionCall     *OpenAIFunctionCall `json:"function_call,omitempty"`
+	Reasoning        string              `json:"reasoning,omitempty"`
+	ReasoningContent string              `json:"reasoning_content,omitempty"` // DeepSeek 风格的思考字段，见 REASONING_FIELD
+	Images           []OpenAIImage       `json:"images,omitempty"`
+	// ContentParts 非空时 content 以内容数组输出（IMAGE_OUTPUT=parts），Content 仍保留纯文本用于日志
+	ContentParts []ContentPart `json:"-"`
+}
+
+// MarshalJSON ContentParts 非空时以内容数组替换 content 字段
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.ContentParts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []ContentPart `json:"content"`
+	}{plain(m), m.ContentParts})
+}
+
+// ContentPart 响应内容数组中的一项：text 或 image_url，按生成顺序排列
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImage 生成的图片（IMAGE_OUTPUT=images 时返回）
+type OpenAIImage struct {
+	Type     string   `json:"type"`
+	ImageURL ImageURL `json:"image_url"`
 }
 
 // Delta 流式增量
 type Delta struct {
-	Role      string           `json:"role,omitempty"`
-	Content   string           `json:"content,omitempty"`
-	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
-	Reasoning string           `json:"reasoning,omitempty"`
+	Role             string             `json:"role,omitempty"`
+	Content          string             `json:"content,omitempty"`
+	ToolCalls        []ToolCallDelta    `json:"tool_calls,omitempty"`
+	FunctionCall     *FunctionCallDelta `json:"function_call,omitempty"`
+	Reasoning        string             `json:"reasoning,omitempty"`
+	ReasoningContent string             `json:"reasoning_content,omitempty"` // DeepSeek 风格的思考字段，见 REASONING_FIELD
+	Images           []OpenAIImage      `json:"images,omitempty"`
+}
+
+// ToolCallDelta 流式工具调用片段：首个片段携带 id、type 与函数名，后续片段只携带参数增量
+type ToolCallDelta struct {
+	Index        int               `json:"index"` // 同一响应内工具调用的序号，客户端按此拼接片段
+	ID           string            `json:"id,omitempty"`
+	Type         string            `json:"type,omitempty"`
+	Function     FunctionCallDelta `json:"function"`
+	ExtraContent *ExtraContent     `json:"extra_content,omitempty"`
+}
+
+// FunctionCallDelta 流式函数调用片段
+type FunctionCallDelta struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 // Usage 使用统计
@@ -205,12 +348,90 @@ type Usage struct {
 
 // OpenAIStreamChunk 流式 Chunk
 type OpenAIStreamChunk struct {
-	ID      string   `json:"id"`
-	Object  string   `json:"object"`
-	Created int64    `json:"created"`
-	Model   string   `json:"model"`
-	Choices []Choice `json:"choices"`
-	Usage   *Usage   `json:"usage,omitempty"`
+	ID                string   `json:"id"`
+	Object            string   `json:"object"`
+	Created           int64    `json:"created"`
+	Model             string   `json:"model"`
+	SystemFingerprint string   `json:"system_fingerprint,omitempty"`
+	Choices           []Choice `json:"choices"`
+	Usage             *Usage   `json:"usage,omitempty"`
+	// LogitBiasEmulation 扩展字段，仅在结束 chunk 中返回
+	LogitBiasEmulation *LogitBiasEmulation `json:"logit_bias_emulation,omitempty"`
+}
+
+// OpenAICompletionRequest 旧版 /v1/completions 文本补全请求
+type OpenAICompletionRequest struct {
+	Model            string             `json:"model"`
+	Prompt           interface{}        `json:"prompt"` // 字符串或仅含一个字符串的数组
+	Suffix           string             `json:"suffix,omitempty"`
+	Echo             bool               `json:"echo,omitempty"`
+	Stream           bool               `json:"stream"`
+	StreamOptions    *StreamOptions     `json:"stream_options,omitempty"`
+	Temperature      *float64           `json:"temperature,omitempty"`
+	TopP             *float64           `json:"top_p,omitempty"`
+	MaxTokens        int                `json:"max_tokens,omitempty"`
+	Seed             *int               `json:"seed,omitempty"`
+	PresencePenalty  *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64           `json:"frequency_penalty,omitempty"`
+	Stop             interface{}        `json:"stop,omitempty"` // 字符串或字符串数组
+	LogitBias        map[string]float64 `json:"logit_bias,omitempty"`
+	User             string             `json:"user,omitempty"`
+
+	prompt string   // 解析后的 prompt
+	stop   []string // 解析后的停止序列
+}
+
+// OpenAICompletion 旧版文本补全响应（流式 chunk 结构相同）
+type OpenAICompletion struct {
+	ID                string             `json:"id"`
+	Object            string             `json:"object"`
+	Created           int64              `json:"created"`
+	Model             string             `json:"model"`
+	SystemFingerprint string             `json:"system_fingerprint,omitempty"`
+	Choices           []CompletionChoice `json:"choices"`
+	Usage             *Usage             `json:"usage,omitempty"`
+}
+
+// CompletionChoice 文本补全选择
+type CompletionChoice struct {
+	Text         string      `json:"text"`
+	Index        int         `json:"index"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// OpenAIImageRequest 图片生成请求（/v1/images/generations）
+type OpenAIImageRequest struct {
+	Model          string `json:"model,omitempty"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n,omitempty"`
+	Size           string `json:"size,omitempty"`            // 如 1024x1024、1792x1024、auto
+	ResponseFormat string `json:"response_format,omitempty"` // url 或 b64_json
+	Stream         bool   `json:"stream,omitempty"`
+	User           string `json:"user,omitempty"`
+
+	imageConfig *ImageConfig // 由 size 换算的 Gemini 图片配置
+}
+
+// OpenAIImagesResponse 图片生成响应
+type OpenAIImagesResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+	Usage   *ImageUsage `json:"usage,omitempty"`
+}
+
+// ImageData 单张生成的图片；url 格式返回 data URL（代理不托管图片）
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// ImageUsage 图片生成用量
+type ImageUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
 }
 
 // ModelsResponse 模型列表响应
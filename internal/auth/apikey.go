@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// GetAPIKey 从请求中获取客户端 API Key
+// 依次检查 Authorization（Bearer sk-xxx 或 sk-xxx）、x-api-key（Claude）、x-goog-api-key（Gemini）与 ?key= 参数
+func GetAPIKey(r *http.Request) string {
+	if key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); key != "" {
+		return key
+	}
+	if key := r.Header.Get("x-api-key"); key != "" {
+		return key
+	}
+	if key := r.Header.Get("x-goog-api-key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+// 管理 API 令牌：供 CI/脚本长期调用管理接口，仅保存哈希
+const (
+	// APITokenPrefix 令牌前缀，便于识别与扫描泄露
+	APITokenPrefix = "ata_"
+
+	// ScopeRead 只读（仅允许 GET）
+	ScopeRead = "read"
+	// ScopeAdmin 完全管理权限
+	ScopeAdmin = "admin"
+)
+
+// APIToken 管理 API 令牌（不含明文与哈希，可直接返回给管理接口）
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Hint       string     `json:"hint"` // 明文前几位，便于辨认
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// storedAPIToken 持久化的令牌，哈希仅保存在文件中
+type storedAPIToken struct {
+	APIToken
+	Hash string `json:"hash"`
+}
+
+// APITokenStore 管理 API 令牌存储
+type APITokenStore struct {
+	mu       sync.RWMutex
+	tokens   []storedAPIToken
+	filePath string
+}
+
+var (
+	apiTokenStore     *APITokenStore
+	apiTokenStoreOnce sync.Once
+)
+
+// GetAPITokenStore 获取令牌存储单例
+func GetAPITokenStore() *APITokenStore {
+	apiTokenStoreOnce.Do(func() {
+		apiTokenStore = &APITokenStore{
+			filePath: filepath.Join(config.Get().DataDir, "admin_tokens.json"),
+		}
+		apiTokenStore.load()
+	})
+	return apiTokenStore
+}
+
+func (s *APITokenStore) load() {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, &s.tokens)
+}
+
+// saveUnlocked 保存令牌（需要已持有锁）
+func (s *APITokenStore) saveUnlocked() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.filePath, data, 0600)
+}
+
+// Create 创建令牌，返回令牌信息与明文（明文仅此一次可见）
+func (s *APITokenStore) Create(name, scope string, ttl time.Duration) (APIToken, string, error) {
+	if name == "" {
+		return APIToken{}, "", errors.New("token name is required")
+	}
+	if scope == "" {
+		scope = ScopeAdmin
+	}
+	if scope != ScopeRead && scope != ScopeAdmin {
+		return APIToken{}, "", errors.New("scope must be read or admin")
+	}
+
+	plain := APITokenPrefix + generateSecureToken(24)
+	token := APIToken{
+		ID:        generateSecureToken(8),
+		Name:      name,
+		Scope:     scope,
+		Hint:      plain[:len(APITokenPrefix)+6],
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = append(s.tokens, storedAPIToken{APIToken: token, Hash: hashAPIToken(plain)})
+	if err := s.saveUnlocked(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return APIToken{}, "", err
+	}
+	return token, plain, nil
+}
+
+// List 列出全部令牌
+func (s *APITokenStore) List() []APIToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]APIToken, len(s.tokens))
+	for i, token := range s.tokens {
+		tokens[i] = token.APIToken
+	}
+	return tokens
+}
+
+// Revoke 吊销令牌
+func (s *APITokenStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.tokens {
+		if s.tokens[i].ID == id {
+			s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+			return s.saveUnlocked()
+		}
+	}
+	return errors.New("token not found")
+}
+
+// Validate 校验明文令牌，返回其权限范围
+func (s *APITokenStore) Validate(plain string) (string, bool) {
+	if !strings.HasPrefix(plain, APITokenPrefix) {
+		return "", false
+	}
+	hash := hashAPIToken(plain)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for i := range s.tokens {
+		token := &s.tokens[i]
+		if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+			return "", false
+		}
+		// 最近使用时间仅保存在内存中，避免每次请求写盘
+		token.LastUsedAt = &now
+		return token.Scope, true
+	}
+	return "", false
+}
+
+func hashAPIToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPITokenStore(t *testing.T) {
+	s := &APITokenStore{filePath: filepath.Join(t.TempDir(), "admin_tokens.json")}
+
+	if _, _, err := s.Create("", ScopeRead, 0); err == nil {
+		t.Error("expected error for empty name")
+	}
+	if _, _, err := s.Create("ci", "write", 0); err == nil {
+		t.Error("expected error for unknown scope")
+	}
+
+	token, plain, err := s.Create("ci", ScopeRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plain, APITokenPrefix) || !strings.HasPrefix(plain, token.Hint) {
+		t.Errorf("unexpected token %q (hint %q)", plain, token.Hint)
+	}
+	if scope, ok := s.Validate(plain); !ok || scope != ScopeRead {
+		t.Errorf("Validate = %q, %v", scope, ok)
+	}
+	if _, ok := s.Validate(plain + "x"); ok {
+		t.Error("wrong token should not validate")
+	}
+
+	// 文件中只保存哈希，API 视图中不含哈希
+	data, _ := os.ReadFile(s.filePath)
+	if strings.Contains(string(data), plain) || !strings.Contains(string(data), hashAPIToken(plain)) {
+		t.Errorf("persisted file should contain only the hash: %s", data)
+	}
+	view, _ := json.Marshal(s.List())
+	if strings.Contains(string(view), "hash") {
+		t.Errorf("token view should not expose the hash: %s", view)
+	}
+
+	// 重新加载后仍可校验
+	reloaded := &APITokenStore{filePath: s.filePath}
+	reloaded.load()
+	if _, ok := reloaded.Validate(plain); !ok {
+		t.Error("token should validate after reload")
+	}
+
+	// 过期
+	_, expired, err := s.Create("old", ScopeAdmin, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := s.Validate(expired); ok {
+		t.Error("expired token should not validate")
+	}
+
+	// 吊销
+	if err := s.Revoke(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Validate(plain); ok {
+		t.Error("revoked token should not validate")
+	}
+	if err := s.Revoke(token.ID); err == nil {
+		t.Error("expected error revoking an unknown token")
+	}
+}
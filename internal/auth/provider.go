@@ -0,0 +1,390 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+// Identity 第三方登录得到的身份
+type Identity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`        // 提供方内不可变的唯一标识（GitHub 数字用户 ID / OIDC sub）
+	Name     string `json:"name,omitempty"` // 仅用于显示（GitHub 用户名可被修改，不能用于授权）
+	Email    string `json:"email,omitempty"`
+}
+
+// String 用于日志与审计的身份描述
+func (id *Identity) String() string {
+	s := id.Provider + ":" + id.Subject
+	if id.Name != "" {
+		s += " (" + id.Name + ")"
+	}
+	if id.Email != "" {
+		s += " <" + id.Email + ">"
+	}
+	return s
+}
+
+// Provider 管理面板第三方登录提供方
+type Provider interface {
+	// Name 路由中使用的名称
+	Name() string
+	// Label 登录页按钮文字
+	Label() string
+	// AuthURL 授权跳转地址
+	AuthURL(redirectURI, state string) (string, error)
+	// Identify 用授权码换取身份
+	Identify(ctx context.Context, code, redirectURI string) (*Identity, error)
+}
+
+var providerClient = &http.Client{Timeout: 15 * time.Second}
+
+// Providers 返回已配置的登录提供方
+func Providers() []Provider {
+	cfg := config.Get()
+	var providers []Provider
+	if cfg.PanelGitHubClientID != "" {
+		providers = append(providers, &githubProvider{clientID: cfg.PanelGitHubClientID, clientSecret: cfg.PanelGitHubClientSecret})
+	}
+	if cfg.PanelOIDCIssuer != "" && cfg.PanelOIDCClientID != "" {
+		providers = append(providers, getOIDCProvider(cfg))
+	}
+	return providers
+}
+
+// GetProvider 按名称获取已配置的登录提供方
+func GetProvider(name string) (Provider, bool) {
+	for _, p := range Providers() {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// IsIdentityAllowed 检查身份是否在 PANEL_AUTH_ALLOWED 中，支持：
+// provider:subject（如 github:583231，GitHub 使用数字用户 ID）、完整邮箱、@域名（匹配该域名下的所有邮箱）
+// 未配置允许列表时拒绝所有第三方登录
+func IsIdentityAllowed(id *Identity) bool {
+	email := strings.ToLower(id.Email)
+	for _, rule := range config.Get().PanelAuthAllowed {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch {
+		case rule == "":
+		case strings.HasPrefix(rule, "@"):
+			if email != "" && strings.HasSuffix(email, rule) {
+				return true
+			}
+		case strings.Contains(rule, "@"):
+			if email == rule {
+				return true
+			}
+		case rule == strings.ToLower(id.Provider+":"+id.Subject):
+			return true
+		}
+	}
+	return false
+}
+
+// 登录 state：防止 CSRF，一次性使用
+var (
+	loginStatesMu sync.Mutex
+	loginStates   = make(map[string]loginState)
+	loginStateTTL = 10 * time.Minute
+	loginStateSeq uint64 // 创建顺序，用于淘汰最早的 state
+)
+
+// maxLoginStates 未消费 state 的数量上限，超过时淘汰最早创建的 state，避免未认证请求使其无限增长
+const maxLoginStates = 1000
+
+type loginState struct {
+	provider  string
+	expiresAt time.Time
+	seq       uint64
+}
+
+// CreateLoginState 为指定提供方生成一次性 state，同时清理过期的 state
+func CreateLoginState(provider string) string {
+	loginStatesMu.Lock()
+	defer loginStatesMu.Unlock()
+
+	now := time.Now()
+	for key, s := range loginStates {
+		if now.After(s.expiresAt) {
+			delete(loginStates, key)
+		}
+	}
+	for len(loginStates) >= maxLoginStates {
+		oldest := ""
+		for key, s := range loginStates {
+			if oldest == "" || s.seq < loginStates[oldest].seq {
+				oldest = key
+			}
+		}
+		delete(loginStates, oldest)
+	}
+
+	state := generateSecureToken(16)
+	loginStateSeq++
+	loginStates[state] = loginState{provider: provider, expiresAt: now.Add(loginStateTTL), seq: loginStateSeq}
+	return state
+}
+
+// ConsumeLoginState 校验并消费 state
+func ConsumeLoginState(state, provider string) bool {
+	loginStatesMu.Lock()
+	s, ok := loginStates[state]
+	delete(loginStates, state)
+	loginStatesMu.Unlock()
+	return ok && s.provider == provider && time.Now().Before(s.expiresAt)
+}
+
+// ===== GitHub =====
+
+type githubProvider struct {
+	clientID     string
+	clientSecret string
+}
+
+func (p *githubProvider) Name() string  { return "github" }
+func (p *githubProvider) Label() string { return "GitHub" }
+
+func (p *githubProvider) AuthURL(redirectURI, state string) (string, error) {
+	params := url.Values{
+		"client_id":    {p.clientID},
+		"redirect_uri": {redirectURI},
+		"scope":        {"read:user user:email"},
+		"state":        {state},
+	}
+	return "https://github.com/login/oauth/authorize?" + params.Encode(), nil
+}
+
+func (p *githubProvider) Identify(ctx context.Context, code, redirectURI string) (*Identity, error) {
+	token, err := exchangeCode(ctx, "https://github.com/login/oauth/access_token", url.Values{
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := getJSON(ctx, "https://api.github.com/user", token, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github user has no id")
+	}
+
+	// 只采用已验证的主邮箱
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	// 用户名可被修改并由他人注册，以不可变的数字 ID 作为授权标识
+	identity := &Identity{Provider: p.Name(), Subject: strconv.FormatInt(user.ID, 10), Name: user.Login}
+	if err := getJSON(ctx, "https://api.github.com/user/emails", token, &emails); err == nil {
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				identity.Email = e.Email
+			}
+		}
+	}
+	return identity, nil
+}
+
+// ===== OIDC =====
+
+type oidcProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	label        string
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+var (
+	oidcProviderCache *oidcProvider
+	oidcProviderMu    sync.Mutex
+)
+
+// getOIDCProvider 复用提供方实例以缓存 discovery 文档
+func getOIDCProvider(cfg *config.Config) *oidcProvider {
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+	issuer := strings.TrimSuffix(cfg.PanelOIDCIssuer, "/")
+	p := oidcProviderCache
+	if p == nil || p.issuer != issuer || p.clientID != cfg.PanelOIDCClientID {
+		p = &oidcProvider{
+			issuer:       issuer,
+			clientID:     cfg.PanelOIDCClientID,
+			clientSecret: cfg.PanelOIDCClientSecret,
+			label:        cfg.PanelOIDCLabel,
+		}
+		oidcProviderCache = p
+	}
+	return p
+}
+
+func (p *oidcProvider) Name() string { return "oidc" }
+
+func (p *oidcProvider) Label() string {
+	if p.label != "" {
+		return p.label
+	}
+	return "SSO"
+}
+
+// discover 获取并缓存 OpenID Provider 配置
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d oidcDiscovery
+	if err := getJSON(ctx, p.issuer+"/.well-known/openid-configuration", "", &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserinfoEndpoint == "" {
+		return nil, errors.New("oidc discovery document is incomplete")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *oidcProvider) AuthURL(redirectURI, state string) (string, error) {
+	d, err := p.discover(context.Background())
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{
+		"client_id":     {p.clientID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Identify 通过 userinfo 端点获取身份（令牌经后端直连 token 端点获得，无需再校验 id_token 签名）
+func (p *oidcProvider) Identify(ctx context.Context, code, redirectURI string) (*Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := exchangeCode(ctx, d.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	if err := getJSON(ctx, d.UserinfoEndpoint, token, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Sub == "" {
+		return nil, errors.New("oidc userinfo has no sub")
+	}
+	identity := &Identity{Provider: p.Name(), Subject: claims.Sub}
+	// 仅采用明确标记为已验证的邮箱；未提供 email_verified 的提供方不参与邮箱允许列表匹配
+	if claims.EmailVerified != nil && *claims.EmailVerified {
+		identity.Email = claims.Email
+	}
+	return identity, nil
+}
+
+// exchangeCode 用授权码换取 access_token
+func exchangeCode(ctx context.Context, tokenURL string, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := providerClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	var tokenResp struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.Unmarshal(body, &tokenResp)
+	if resp.StatusCode != http.StatusOK || tokenResp.AccessToken == "" {
+		if tokenResp.Error != "" {
+			return "", fmt.Errorf("token exchange failed: %s %s", tokenResp.Error, tokenResp.ErrorDescription)
+		}
+		return "", fmt.Errorf("token exchange failed: HTTP %d", resp.StatusCode)
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// getJSON 发送 GET 请求并解析 JSON，token 非空时附加 Bearer 认证
+func getJSON(ctx context.Context, endpoint, token string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := providerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
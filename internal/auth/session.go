@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -76,7 +77,15 @@ func GetSessionToken(r *http.Request) string {
 	}
 
 	// 从 header 获取
-	return r.Header.Get("X-Session-Token")
+	if token := r.Header.Get("X-Session-Token"); token != "" {
+		return token
+	}
+
+	// 管理 API 令牌也可通过 Authorization: Bearer ata_xxx 提供
+	if bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); strings.HasPrefix(bearer, APITokenPrefix) {
+		return bearer
+	}
+	return ""
 }
 
 func generateSecureToken(length int) string {
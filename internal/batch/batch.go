@@ -0,0 +1,496 @@
+// Package batch 批处理：异步执行一组请求并将结果持久化到数据目录，服务重启后自动续跑未完成的请求
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/logger"
+)
+
+// 批处理状态
+const (
+	StatusInProgress = "in_progress"
+	StatusCanceling  = "canceling"
+	StatusEnded      = "ended"
+)
+
+var (
+	// ErrNotFound 批处理不存在
+	ErrNotFound = errors.New("batch not found")
+	// ErrNotEnded 批处理尚未结束，结果不可用
+	ErrNotEnded = errors.New("batch has not ended yet")
+)
+
+// Item 批处理中的单个请求
+type Item struct {
+	CustomID string          `json:"custom_id"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Result 单个请求的执行结果；Canceled 表示请求在执行前被取消
+type Result struct {
+	CustomID string          `json:"custom_id"`
+	Status   int             `json:"status"`
+	Body     json.RawMessage `json:"body,omitempty"`
+	Canceled bool            `json:"canceled,omitempty"`
+}
+
+// Succeeded 请求是否成功
+func (r Result) Succeeded() bool {
+	return !r.Canceled && r.Status == http.StatusOK
+}
+
+// Counts 请求计数
+type Counts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+}
+
+// Batch 批处理元数据
+type Batch struct {
+	ID                string            `json:"id"`
+	Protocol          string            `json:"protocol"`
+	Status            string            `json:"status"`
+	Counts            Counts            `json:"counts"`
+	CreatedAt         time.Time         `json:"createdAt"`
+	EndedAt           *time.Time        `json:"endedAt,omitempty"`
+	CancelInitiatedAt *time.Time        `json:"cancelInitiatedAt,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`   // 客户端提供的元数据
+	Attributes        map[string]string `json:"attributes,omitempty"` // 协议相关属性（如输入文件 ID）
+}
+
+// Runner 通过常规请求流程执行单个请求，返回 HTTP 状态码与响应体
+type Runner func(ctx context.Context, protocol string, body []byte) (int, []byte)
+
+// Options 调度选项
+type Options struct {
+	// Concurrency 返回当前允许的并发请求数（每次调度时读取，可随账号数变化）
+	Concurrency func() int
+	// MaxRetries 被限流（429）或无可用账号（503）时的最大重试次数
+	MaxRetries int
+	// Backoff 首次重试等待时间，之后按指数增长
+	Backoff time.Duration
+	// MaxBackoff 重试等待上限
+	MaxBackoff time.Duration
+	// OnEnd 批处理结束后调用（可选）
+	OnEnd func(Batch)
+}
+
+// Manager 批处理管理器
+// 所有批处理共享一个并发上限；任一请求被限流时整体暂停一段时间，避免在限流期间持续消耗账号
+type Manager struct {
+	dir    string
+	runner Runner
+	opts   Options
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	batches     map[string]*Batch
+	inflight    int
+	pausedUntil time.Time
+}
+
+// NewManager 创建批处理管理器，dir 为持久化目录
+func NewManager(dir string, runner Runner, opts Options) *Manager {
+	if opts.Concurrency == nil {
+		opts.Concurrency = func() int { return 1 }
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 5 * time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+
+	m := &Manager{
+		dir:     dir,
+		runner:  runner,
+		opts:    opts,
+		batches: make(map[string]*Batch),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// Resume 加载已持久化的批处理，并继续执行未结束批处理中尚无结果的请求
+func (m *Manager) Resume() {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(m.dir, name))
+		if err != nil {
+			continue
+		}
+		var b Batch
+		if err := json.Unmarshal(data, &b); err != nil || b.ID == "" {
+			logger.Warn("Skipping invalid batch file %s", name)
+			continue
+		}
+
+		m.mu.Lock()
+		m.batches[b.ID] = &b
+		m.mu.Unlock()
+
+		if b.Status == StatusEnded {
+			continue
+		}
+		items, err := m.readItems(b.ID)
+		if err != nil {
+			logger.Error("Batch %s: failed to read requests: %v", b.ID, err)
+			continue
+		}
+		results, _ := m.readResults(b.ID)
+		done := make(map[string]bool, len(results))
+		for _, r := range results {
+			done[r.CustomID] = true
+		}
+
+		var pending []Item
+		for _, item := range items {
+			if !done[item.CustomID] {
+				pending = append(pending, item)
+			}
+		}
+		logger.Info("Resuming batch %s: %d of %d requests pending", b.ID, len(pending), len(items))
+		go m.run(b.ID, pending)
+	}
+}
+
+// Create 按模板（ID、协议、元数据与属性）创建批处理并在后台开始执行
+func (m *Manager) Create(spec Batch, items []Item) (Batch, error) {
+	if len(items) == 0 {
+		return Batch{}, fmt.Errorf("batch requires at least one request")
+	}
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.CustomID == "" {
+			return Batch{}, fmt.Errorf("custom_id is required")
+		}
+		if seen[item.CustomID] {
+			return Batch{}, fmt.Errorf("duplicate custom_id %q", item.CustomID)
+		}
+		seen[item.CustomID] = true
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return Batch{}, err
+	}
+	if err := m.writeItems(spec.ID, items); err != nil {
+		return Batch{}, err
+	}
+
+	b := &Batch{
+		ID:         spec.ID,
+		Protocol:   spec.Protocol,
+		Status:     StatusInProgress,
+		Counts:     Counts{Processing: len(items)},
+		CreatedAt:  time.Now(),
+		Metadata:   spec.Metadata,
+		Attributes: spec.Attributes,
+	}
+
+	m.mu.Lock()
+	m.batches[b.ID] = b
+	err := m.saveLocked(b)
+	snapshot := *b
+	m.mu.Unlock()
+	if err != nil {
+		return Batch{}, err
+	}
+
+	go m.run(b.ID, items)
+	return snapshot, nil
+}
+
+// Get 获取批处理
+func (m *Manager) Get(id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.batches[id]
+	if !ok {
+		return Batch{}, ErrNotFound
+	}
+	return *b, nil
+}
+
+// List 按创建时间倒序列出指定协议的批处理
+func (m *Manager) List(protocol string) []Batch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batches := make([]Batch, 0, len(m.batches))
+	for _, b := range m.batches {
+		if b.Protocol == protocol {
+			batches = append(batches, *b)
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+	return batches
+}
+
+// Cancel 取消批处理：执行中的请求继续完成，尚未开始的请求记为已取消
+func (m *Manager) Cancel(id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.batches[id]
+	if !ok {
+		return Batch{}, ErrNotFound
+	}
+	if b.Status == StatusInProgress {
+		now := time.Now()
+		b.Status = StatusCanceling
+		b.CancelInitiatedAt = &now
+		m.saveLocked(b)
+		m.cond.Broadcast()
+	}
+	return *b, nil
+}
+
+// Results 返回已结束批处理的全部结果（按完成顺序）
+func (m *Manager) Results(id string) ([]Result, error) {
+	b, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if b.Status != StatusEnded {
+		return nil, ErrNotEnded
+	}
+	return m.readResults(id)
+}
+
+// run 执行批处理中的请求，全部完成后标记结束
+func (m *Manager) run(id string, items []Item) {
+	var wg sync.WaitGroup
+	for _, item := range items {
+		if !m.acquire(id) {
+			m.record(id, Result{CustomID: item.CustomID, Canceled: true})
+			continue
+		}
+		wg.Add(1)
+		go func(item Item) {
+			defer wg.Done()
+			defer m.release()
+			m.record(id, m.execute(id, item))
+		}(item)
+	}
+	wg.Wait()
+	m.finish(id)
+}
+
+// execute 执行单个请求，被限流时暂停全部批处理并重试
+func (m *Manager) execute(id string, item Item) Result {
+	b, _ := m.Get(id)
+	backoff := m.opts.Backoff
+
+	for attempt := 0; ; attempt++ {
+		status, body := m.runner(context.Background(), b.Protocol, item.Body)
+		retryable := status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+		if !retryable || attempt >= m.opts.MaxRetries || m.canceled(id) {
+			return Result{CustomID: item.CustomID, Status: status, Body: body}
+		}
+
+		logger.Warn("Batch %s: request %s got %d, pausing batches for %s", id, item.CustomID, status, backoff)
+		m.pause(backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, m.opts.MaxBackoff)
+	}
+}
+
+// acquire 等待并发名额；批处理被取消时返回 false
+func (m *Manager) acquire(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		if b := m.batches[id]; b == nil || b.Status != StatusInProgress {
+			return false
+		}
+		if wait := time.Until(m.pausedUntil); wait > 0 {
+			m.mu.Unlock()
+			time.Sleep(wait)
+			m.mu.Lock()
+			continue
+		}
+		if m.inflight < max(m.opts.Concurrency(), 1) {
+			m.inflight++
+			return true
+		}
+		m.cond.Wait()
+	}
+}
+
+func (m *Manager) release() {
+	m.mu.Lock()
+	m.inflight--
+	m.mu.Unlock()
+	m.cond.Broadcast()
+}
+
+// pause 在 d 时间内暂停调度新请求
+func (m *Manager) pause(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if until := time.Now().Add(d); until.After(m.pausedUntil) {
+		m.pausedUntil = until
+	}
+}
+
+func (m *Manager) canceled(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.batches[id]
+	return b == nil || b.Status != StatusInProgress
+}
+
+// record 追加结果并更新计数
+func (m *Manager) record(id string, result Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.batches[id]
+	if !ok {
+		return
+	}
+	if err := m.appendResult(id, result); err != nil {
+		logger.Error("Batch %s: failed to save result %s: %v", id, result.CustomID, err)
+	}
+
+	b.Counts.Processing--
+	switch {
+	case result.Canceled:
+		b.Counts.Canceled++
+	case result.Succeeded():
+		b.Counts.Succeeded++
+	default:
+		b.Counts.Errored++
+	}
+	m.saveLocked(b)
+}
+
+// finish 标记批处理结束
+func (m *Manager) finish(id string) {
+	m.mu.Lock()
+	b, ok := m.batches[id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	b.Status = StatusEnded
+	b.EndedAt = &now
+	m.saveLocked(b)
+	snapshot := *b
+	m.mu.Unlock()
+
+	logger.Info("Batch %s ended: %d succeeded, %d errored, %d canceled", id, snapshot.Counts.Succeeded, snapshot.Counts.Errored, snapshot.Counts.Canceled)
+	if m.opts.OnEnd != nil {
+		m.opts.OnEnd(snapshot)
+	}
+}
+
+// ===== 持久化：{id}.json 元数据、{id}.requests.jsonl 请求、{id}.results.jsonl 结果 =====
+
+func (m *Manager) saveLocked(b *Batch) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, b.ID+".json"), data, 0644)
+}
+
+func (m *Manager) writeItems(id string, items []Item) error {
+	f, err := os.Create(filepath.Join(m.dir, id+".requests.jsonl"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) readItems(id string) ([]Item, error) {
+	var items []Item
+	err := readJSONL(filepath.Join(m.dir, id+".requests.jsonl"), func(data []byte) error {
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+func (m *Manager) appendResult(id string, result Result) error {
+	f, err := os.OpenFile(filepath.Join(m.dir, id+".results.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(result)
+}
+
+func (m *Manager) readResults(id string) ([]Result, error) {
+	results := []Result{}
+	err := readJSONL(filepath.Join(m.dir, id+".results.jsonl"), func(data []byte) error {
+		var result Result
+		if err := json.Unmarshal(data, &result); err != nil {
+			return err
+		}
+		results = append(results, result)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// readJSONL 逐行读取 JSONL 文件
+func readJSONL(path string, fn func([]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,138 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitEnded(t *testing.T, m *Manager, id string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if b, _ := m.Get(id); b.Status == StatusEnded {
+			return b
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("batch did not end")
+	return Batch{}
+}
+
+func TestManagerRunsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	runner := func(ctx context.Context, protocol string, body []byte) (int, []byte) {
+		switch string(body) {
+		case `"limited"`:
+			if calls.Add(1) == 1 {
+				return http.StatusTooManyRequests, []byte(`{"error":"rate limited"}`)
+			}
+			return http.StatusOK, []byte(`{"ok":true}`)
+		case `"bad"`:
+			return http.StatusBadRequest, []byte(`{"error":"bad"}`)
+		}
+		return http.StatusOK, body
+	}
+
+	dir := t.TempDir()
+	m := NewManager(dir, runner, Options{MaxRetries: 2, Backoff: time.Millisecond, Concurrency: func() int { return 2 }})
+	items := []Item{
+		{CustomID: "a", Body: json.RawMessage(`"ok"`)},
+		{CustomID: "b", Body: json.RawMessage(`"limited"`)},
+		{CustomID: "c", Body: json.RawMessage(`"bad"`)},
+	}
+
+	if _, err := m.Results("missing"); err != ErrNotFound {
+		t.Errorf("Results(missing) err = %v", err)
+	}
+	if _, err := m.Create(Batch{ID: "dup", Protocol: "claude"}, []Item{{CustomID: "x"}, {CustomID: "x"}}); err == nil {
+		t.Error("duplicate custom_id should be rejected")
+	}
+
+	b, err := m.Create(Batch{ID: "batch_1", Protocol: "claude"}, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Counts.Processing != 3 {
+		t.Errorf("processing = %d", b.Counts.Processing)
+	}
+
+	b = waitEnded(t, m, "batch_1")
+	if b.Counts != (Counts{Succeeded: 2, Errored: 1}) {
+		t.Errorf("counts = %+v", b.Counts)
+	}
+	results, err := m.Results("batch_1")
+	if err != nil || len(results) != 3 {
+		t.Fatalf("Results() = %v, %v", results, err)
+	}
+
+	// 重新加载：已结束的批处理只读取元数据
+	reloaded := NewManager(dir, runner, Options{})
+	reloaded.Resume()
+	if got, err := reloaded.Get("batch_1"); err != nil || got.Status != StatusEnded || got.Counts != b.Counts {
+		t.Errorf("reloaded = %+v, %v", got, err)
+	}
+	if list := reloaded.List("claude"); len(list) != 1 {
+		t.Errorf("List() = %+v", list)
+	}
+}
+
+func TestManagerResumesPending(t *testing.T) {
+	dir := t.TempDir()
+	block := make(chan struct{})
+	m := NewManager(dir, func(ctx context.Context, protocol string, body []byte) (int, []byte) {
+		<-block
+		return http.StatusOK, body
+	}, Options{})
+
+	items := []Item{{CustomID: "a", Body: json.RawMessage(`1`)}, {CustomID: "b", Body: json.RawMessage(`2`)}}
+	if _, err := m.Create(Batch{ID: "batch_2", Protocol: "claude"}, items); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟第一个请求已完成后服务重启
+	m.appendResult("batch_2", Result{CustomID: "a", Status: http.StatusOK, Body: json.RawMessage(`1`)})
+
+	var ran atomic.Int32
+	resumed := NewManager(dir, func(ctx context.Context, protocol string, body []byte) (int, []byte) {
+		ran.Add(1)
+		return http.StatusOK, body
+	}, Options{})
+	resumed.Resume()
+	waitEnded(t, resumed, "batch_2")
+
+	if ran.Load() != 1 {
+		t.Errorf("resumed manager ran %d requests, want 1", ran.Load())
+	}
+	results, _ := resumed.Results("batch_2")
+	if len(results) != 2 {
+		t.Errorf("results = %+v", results)
+	}
+
+	close(block)
+	waitEnded(t, m, "batch_2")
+}
+
+func TestManagerCancel(t *testing.T) {
+	release := make(chan struct{})
+	m := NewManager(t.TempDir(), func(ctx context.Context, protocol string, body []byte) (int, []byte) {
+		<-release
+		return http.StatusOK, body
+	}, Options{})
+
+	items := []Item{{CustomID: "a", Body: json.RawMessage(`1`)}, {CustomID: "b", Body: json.RawMessage(`2`)}}
+	if _, err := m.Create(Batch{ID: "batch_3", Protocol: "claude"}, items); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := m.Cancel("batch_3"); b.Status != StatusCanceling {
+		t.Errorf("status = %s", b.Status)
+	}
+	close(release)
+
+	b := waitEnded(t, m, "batch_3")
+	if b.Counts.Canceled == 0 || b.Counts.Succeeded+b.Counts.Canceled != 2 {
+		t.Errorf("counts = %+v", b.Counts)
+	}
+}
@@ -0,0 +1,149 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFileNotFound 文件不存在
+var ErrFileNotFound = errors.New("file not found")
+
+// File 上传或生成的文件元数据
+type File struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Purpose   string    `json:"purpose"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FileStore 文件存储：{id}.meta.json 保存元数据，{id}.data 保存内容
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore 创建文件存储，dir 为持久化目录
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// Save 保存文件内容（同 ID 覆盖）
+func (s *FileStore) Save(f File, data []byte) (File, error) {
+	if !validFileID(f.ID) {
+		return File{}, ErrFileNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return File{}, err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, f.ID+".data"), data, 0644); err != nil {
+		return File{}, err
+	}
+
+	f.Bytes = int64(len(data))
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now()
+	}
+	meta, _ := json.MarshalIndent(f, "", "  ")
+	if err := os.WriteFile(filepath.Join(s.dir, f.ID+".meta.json"), meta, 0644); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// Get 获取文件元数据
+func (s *FileStore) Get(id string) (File, error) {
+	if !validFileID(id) {
+		return File{}, ErrFileNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readMeta(id)
+}
+
+// Content 读取文件内容
+func (s *FileStore) Content(id string) ([]byte, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(s.dir, id+".data"))
+}
+
+// List 按创建时间倒序列出文件，purpose 非空时仅返回对应用途的文件
+func (s *FileStore) List(purpose string) ([]File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return []File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := []File{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".meta.json")
+		if !ok {
+			continue
+		}
+		f, err := s.readMeta(id)
+		if err != nil || (purpose != "" && f.Purpose != purpose) {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files, nil
+}
+
+// Delete 删除文件
+func (s *FileStore) Delete(id string) error {
+	if !validFileID(id) {
+		return ErrFileNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(s.dir, id+".meta.json")); err != nil {
+		if os.IsNotExist(err) {
+			return ErrFileNotFound
+		}
+		return err
+	}
+	os.Remove(filepath.Join(s.dir, id+".data"))
+	return nil
+}
+
+func (s *FileStore) readMeta(id string) (File, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".meta.json"))
+	if os.IsNotExist(err) {
+		return File{}, ErrFileNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// validFileID 文件 ID 只能是目录内的文件名
+func validFileID(id string) bool {
+	return id != "" && id == filepath.Base(id) && !strings.HasPrefix(id, ".")
+}
@@ -0,0 +1,39 @@
+package batch
+
+import "testing"
+
+func TestFileStore(t *testing.T) {
+	s := NewFileStore(t.TempDir())
+
+	f, err := s.Save(File{ID: "file-abc", Filename: "input.jsonl", Purpose: "batch"}, []byte("{}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Bytes != 3 || f.CreatedAt.IsZero() {
+		t.Errorf("Save() = %+v", f)
+	}
+	s.Save(File{ID: "file-out", Purpose: "batch_output"}, []byte("x"))
+
+	if data, err := s.Content("file-abc"); err != nil || string(data) != "{}\n" {
+		t.Errorf("Content() = %q, %v", data, err)
+	}
+	if list, _ := s.List("batch"); len(list) != 1 || list[0].ID != "file-abc" {
+		t.Errorf("List(batch) = %+v", list)
+	}
+	if list, _ := s.List(""); len(list) != 2 {
+		t.Errorf("List() = %+v", list)
+	}
+
+	if _, err := s.Get("../file-abc"); err != ErrFileNotFound {
+		t.Errorf("path traversal: err = %v", err)
+	}
+	if err := s.Delete("file-abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Content("file-abc"); err != ErrFileNotFound {
+		t.Errorf("deleted file: err = %v", err)
+	}
+	if err := s.Delete("file-abc"); err != ErrFileNotFound {
+		t.Errorf("double delete: err = %v", err)
+	}
+}
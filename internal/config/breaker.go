@@ -0,0 +1,171 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// EndpointBreaker 端点熔断器：按端点统计滚动窗口内的错误率，超过阈值时熔断一段时间
+type EndpointBreaker struct {
+	mu     sync.Mutex
+	states map[string]*breakerState
+	now    func() time.Time
+}
+
+type breakerState struct {
+	outcomes  []breakerOutcome
+	openUntil time.Time
+	manual    bool
+}
+
+type breakerOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// BreakerStatus 端点熔断状态
+type BreakerStatus struct {
+	Key       string    `json:"key"`
+	Open      bool      `json:"open"`
+	Manual    bool      `json:"manual,omitempty"`
+	OpenUntil time.Time `json:"openUntil,omitempty"`
+	Requests  int       `json:"requests"`
+	Failures  int       `json:"failures"`
+}
+
+var (
+	endpointBreaker     *EndpointBreaker
+	endpointBreakerOnce sync.Once
+)
+
+// GetEndpointBreaker 获取端点熔断器单例
+func GetEndpointBreaker() *EndpointBreaker {
+	endpointBreakerOnce.Do(func() {
+		endpointBreaker = NewEndpointBreaker()
+	})
+	return endpointBreaker
+}
+
+// NewEndpointBreaker 创建端点熔断器
+func NewEndpointBreaker() *EndpointBreaker {
+	return &EndpointBreaker{
+		states: make(map[string]*breakerState),
+		now:    time.Now,
+	}
+}
+
+func (b *EndpointBreaker) state(key string) *breakerState {
+	st, ok := b.states[key]
+	if !ok {
+		st = &breakerState{}
+		b.states[key] = st
+	}
+	return st
+}
+
+// prune 丢弃窗口外的记录
+func (b *EndpointBreaker) prune(st *breakerState, now time.Time) {
+	cutoff := now.Add(-time.Duration(Get().BreakerWindow) * time.Second)
+	i := 0
+	for i < len(st.outcomes) && st.outcomes[i].at.Before(cutoff) {
+		i++
+	}
+	st.outcomes = st.outcomes[i:]
+}
+
+// Record 记录一次请求结果，返回本次是否触发熔断
+func (b *EndpointBreaker) Record(key string, failed bool) bool {
+	cfg := Get()
+	if !cfg.BreakerEnabled {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	st := b.state(key)
+	b.prune(st, now)
+	st.outcomes = append(st.outcomes, breakerOutcome{at: now, failed: failed})
+
+	if now.Before(st.openUntil) || len(st.outcomes) < max(cfg.BreakerMinRequests, 1) {
+		return false
+	}
+
+	failures := 0
+	for _, o := range st.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+	if failures*100 < cfg.BreakerThreshold*len(st.outcomes) {
+		return false
+	}
+
+	// 熔断后清空统计，冷却结束时重新计算
+	st.openUntil = now.Add(time.Duration(cfg.BreakerCooldown) * time.Second)
+	st.manual = false
+	st.outcomes = nil
+	return true
+}
+
+// Available 判断端点当前是否可用（未熔断）
+func (b *EndpointBreaker) Available(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.states[key]
+	if !ok {
+		return true
+	}
+	return !b.now().Before(st.openUntil)
+}
+
+// Trip 手动熔断端点，duration 为 0 时使用配置的冷却时间
+func (b *EndpointBreaker) Trip(key string, duration time.Duration) {
+	if duration <= 0 {
+		duration = time.Duration(Get().BreakerCooldown) * time.Second
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.state(key)
+	st.openUntil = b.now().Add(duration)
+	st.manual = true
+	st.outcomes = nil
+}
+
+// Reset 手动恢复端点并清空统计
+func (b *EndpointBreaker) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, key)
+}
+
+// Status 返回指定端点的熔断状态
+func (b *EndpointBreaker) Status(key string) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{Key: key}
+	st, ok := b.states[key]
+	if !ok {
+		return status
+	}
+
+	now := b.now()
+	b.prune(st, now)
+	if now.Before(st.openUntil) {
+		status.Open = true
+		status.Manual = st.manual
+		status.OpenUntil = st.openUntil
+	}
+	status.Requests = len(st.outcomes)
+	for _, o := range st.outcomes {
+		if o.failed {
+			status.Failures++
+		}
+	}
+	return status
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndpointBreakerTripsAndRecovers(t *testing.T) {
+	c := Get()
+	saved := *c
+	defer func() { *c = saved }()
+	c.BreakerEnabled = true
+	c.BreakerWindow = 60
+	c.BreakerThreshold = 50
+	c.BreakerMinRequests = 4
+	c.BreakerCooldown = 30
+
+	now := time.Unix(1700000000, 0)
+	b := NewEndpointBreaker()
+	b.now = func() time.Time { return now }
+
+	// 请求数不足时不熔断
+	for i := 0; i < 3; i++ {
+		if b.Record("daily", true) {
+			t.Fatalf("tripped before reaching min requests")
+		}
+	}
+	if !b.Record("daily", true) {
+		t.Fatalf("expected breaker to trip")
+	}
+	if b.Available("daily") {
+		t.Fatalf("daily should be unavailable while open")
+	}
+	if !b.Available("production") {
+		t.Fatalf("other endpoints should be unaffected")
+	}
+
+	now = now.Add(31 * time.Second)
+	if !b.Available("daily") {
+		t.Fatalf("daily should recover after cooldown")
+	}
+	if st := b.Status("daily"); st.Open || st.Requests != 0 {
+		t.Fatalf("unexpected status after cooldown: %+v", st)
+	}
+}
+
+func TestEndpointBreakerWindowAndOverride(t *testing.T) {
+	c := Get()
+	saved := *c
+	defer func() { *c = saved }()
+	c.BreakerEnabled = true
+	c.BreakerWindow = 10
+	c.BreakerThreshold = 50
+	c.BreakerMinRequests = 2
+	c.BreakerCooldown = 30
+
+	now := time.Unix(1700000000, 0)
+	b := NewEndpointBreaker()
+	b.now = func() time.Time { return now }
+
+	// 窗口外的失败不计入
+	b.Record("autopush", true)
+	now = now.Add(11 * time.Second)
+	if b.Record("autopush", false) {
+		t.Fatalf("stale failure should have been pruned")
+	}
+
+	b.Trip("autopush", time.Minute)
+	if st := b.Status("autopush"); !st.Open || !st.Manual {
+		t.Fatalf("expected manual open, got %+v", st)
+	}
+	b.Reset("autopush")
+	if !b.Available("autopush") {
+		t.Fatalf("reset should restore endpoint")
+	}
+}
+
+func TestNextAvailableSkipsOpenEndpoints(t *testing.T) {
+	b := GetEndpointBreaker()
+	b.Trip("autopush", time.Minute)
+	defer b.Reset("autopush")
+
+	index := 1
+	if got := nextAvailable(RoundRobinEndpoints, &index); got != "production" {
+		t.Fatalf("got %s, want production", got)
+	}
+	if index != 0 {
+		t.Fatalf("index = %d, want 0", index)
+	}
+}
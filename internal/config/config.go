@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -22,19 +23,140 @@ type Config struct {
 	APIKey        string
 	PanelUser     string
 	PanelPassword string
+	MaskEmails    bool // 管理接口中账号邮箱脱敏（可按请求 ?reveal=true 查看完整邮箱）
+
+	// 管理面板第三方登录（GitHub / OIDC），身份须匹配 PanelAuthAllowed
+	PanelPasswordLogin      bool     // 是否允许用户名/密码登录，面板暴露在公网时可关闭
+	PanelAuthAllowed        []string // 允许的身份：provider:subject、邮箱或 @域名
+	PanelGitHubClientID     string
+	PanelGitHubClientSecret string
+	PanelOIDCIssuer         string
+	PanelOIDCClientID       string
+	PanelOIDCClientSecret   string
+	PanelOIDCLabel          string // 登录按钮文字
 
 	// 请求限制
 	MaxRequestSize string
+	ContextGuard   bool // 请求前估算 token，超出模型上下文窗口时直接拒绝
+
+	// 历史截断：off 关闭，drop 超出预算时丢弃最早的对话轮次，summarize 以缓存的模型摘要替换早期轮次
+	HistoryTruncation   string
+	HistoryTokenBudget  int    // 截断预算，0 表示使用模型上下文窗口
+	HistorySummaryModel string // 生成摘要使用的模型
+
+	// 工具参数修复：按声明的 schema 强转类型、补齐缺失的必填字段
+	ToolArgRepair bool
+
+	// 工具调用循环检测：末尾连续出现相同工具与参数的调用达到阈值时注入提示（nudge）或拒绝请求（reject）
+	ToolLoopLimit  int // 阈值，0 表示关闭
+	ToolLoopAction string
+
+	// bypass 模型心跳配置
+	HeartbeatInterval int    // 心跳间隔（毫秒）
+	HeartbeatMaxWait  int    // 等待上游的最长时间（秒），0 表示不限制
+	HeartbeatStyle    string // 心跳形式：delta 空增量数据包，comment SSE 注释行
+
+	// 流式输出队列：上游读取与写给客户端解耦，队列满时按策略处理慢客户端
+	StreamQueueSize  int    // 每个连接的队列长度（写入次数），0 表示直接写出
+	StreamSlowClient string // block 等待客户端，drop 断开客户端但读完上游，abort 终止请求并取消上游
+
+	// 流式响应最长持续时间（秒，0 表示不限制），超时后以 length 结束原因结束流
+	StreamMaxDuration       int
+	ThinkingMaxDuration     int      // 开启思考的请求
+	StreamMaxDurationModels []string // 按模型覆盖：模型通配符=秒数
+
+	// OpenAI 响应中生成图片的返回方式：markdown 内联到 content，images 以独立的 images 字段返回
+	ImageOutput string
+	// /v1/images/generations 使用的图片模型（请求未指定 Gemini 模型时）
+	ImageModel string
+	// OpenAI 响应中思考内容的字段：reasoning、reasoning_content（DeepSeek 风格）或 both
+	ReasoningField string
+
+	// 视觉请求中的 http(s) 图片 URL：开启后由代理下载并以内联数据发送上游
+	ImageURLFetch          bool
+	ImageFetchMaxMB        int  // 单张图片大小上限（MB）
+	ImageFetchTimeout      int  // 单张图片下载超时（秒）
+	ImageFetchAllowPrivate bool // 是否允许访问内网 / 回环地址
+
+	// 内联数据（图片、文件）大小限制（MB，按解码后大小计算），0 表示不限制
+	InlineDataMaxMB        int
+	InlineDataRequestMaxMB int
+	InlineImageDownscale   bool // 单张图片超限时缩小到上限以内，而不是返回 413
+
+	// 图片预处理：发送上游前按最长边缩小，并将不透明的 PNG / GIF 转为 JPEG，减少请求体积与 token
+	// 仅处理标准库可解码的 JPEG / PNG / GIF；HEIC、WebP 等格式不缩小也不转换，原样发送给上游
+	InlineImageMaxDimension int // 最长边上限（像素），0 表示不限制
+	InlineImageTranscode    bool
+
+	// Gemini 转换模式响应是否保留 thought parts（可按请求 ?thoughts=true|false 覆盖，原始透传不受影响）
+	GeminiIncludeThoughts bool
 
 	// 重试配置
 	RetryStatusCodes []int
 	RetryMaxAttempts int
+	AccountCooldown  int // 账号被上游限流(429)后暂停轮询的秒数，0 表示关闭
+
+	// 每日 token 预算（本地时间零点重置），0 表示不限制
+	DailyTokenBudget       int // 全局预算
+	DailyTokenBudgetPerKey int // 按客户端 API Key 计算的预算
+
+	// 批处理配置
+	BatchConcurrency int // 批处理并发请求数，0 表示与启用账号数相同
+	BatchMaxRetries  int // 批处理请求被限流或无可用账号时的最大重试次数
+
+	// 上游错误详情是否原样返回给客户端（默认脱敏，完整内容仅记录在管理日志）
+	ExposeUpstreamErrors bool
+
+	// 校验上游响应结构，出现未知字段或缺失 candidates 时告警
+	SchemaDriftCheck bool
 
 	// 日志配置
-	Debug string
+	Debug          string
+	LogMaxBodySize int // 调试日志中单个请求/响应体的最大字符数，0 表示不截断
+	// 同一请求的调试日志块缓冲到请求结束后连续输出，避免并发请求交错
+	LogGroupByRequest bool
 
 	// 端点模式
-	EndpointMode string
+	EndpointMode         string
+	ExposeEndpointHeader bool // 在响应头 X-Upstream-Endpoint 中返回实际使用的端点
+	UsageHeaders         bool // 在响应头中返回 token 用量（X-Usage-*）与账号池状态（X-RateLimit-*）
+
+	// 端点熔断：滚动窗口内错误率超过阈值时将端点移出轮询，冷却后恢复
+	BreakerEnabled     bool
+	BreakerWindow      int // 统计窗口（秒）
+	BreakerThreshold   int // 错误率阈值（百分比）
+	BreakerMinRequests int // 窗口内最少请求数，不足时不判定
+	BreakerCooldown    int // 熔断冷却时间（秒）
+
+	// 内容审核配置（上游请求前拦截或脱敏）
+	ModerationBlockWords    []string
+	ModerationBlockPattern  string
+	ModerationRedactPattern string
+	ModerationURL           string
+
+	// /v1/moderations 分类配置
+	ModerationsBackend  string // keyword（本地关键词）或 model（上游模型打分）
+	ModerationsModel    string // model 后端使用的模型
+	ModerationsKeywords string // keyword 后端的分类关键词（JSON 对象：分类 → 关键词数组）
+
+	// 是否将未知模型名原样转发上游（关闭时未知模型返回 404 model_not_found）
+	ModelPassthrough bool
+
+	// 从模型列表中隐藏的模型（模型名或通配符，如 *-bypass），隐藏的模型仍可调用
+	HiddenModels []string
+
+	// 启动预检（--validate 仅运行预检后退出）
+	Preflight      bool   // 启动时先运行预检，失败则不启动
+	PreflightModel string // 上游试调用使用的模型
+
+	// 客户端错误消息的默认语言（zh 或 en），请求的 Accept-Language 优先
+	ErrorLanguage string
+
+	// 镜像流量配置（按百分比复制请求到另一端点/模型，仅记录结果）
+	// 镜像请求使用原请求的账号，同样消耗该账号的配额
+	MirrorPercent  int
+	MirrorEndpoint string
+	MirrorModel    string
 
 	// OAuth 配置
 	GoogleClientID     string
@@ -42,6 +164,14 @@ type Config struct {
 
 	// 数据目录
 	DataDir string
+
+	// warnings 加载时发现的无效配置（已按默认值处理），由服务启动时记录
+	warnings []string
+}
+
+// Warnings 返回加载配置时发现的问题
+func (c *Config) Warnings() []string {
+	return c.warnings
 }
 
 // Endpoint API 端点
@@ -88,22 +218,94 @@ var (
 func Load() *Config {
 	once.Do(func() {
 		cfg = &Config{
-			Port:               getEnvInt("PORT", 8045),
-			Host:               getEnv("HOST", "0.0.0.0"),
-			UserAgent:          getEnv("API_USER_AGENT", "antigravity/1.11.3 windows/amd64"),
-			Timeout:            getEnvInt("TIMEOUT", 180000),
-			Proxy:              getEnv("PROXY", ""),
-			APIKey:             getEnv("API_KEY", ""),
-			PanelUser:          getEnv("PANEL_USER", "admin"),
-			PanelPassword:      getEnv("PANEL_PASSWORD", ""),
-			MaxRequestSize:     getEnv("MAX_REQUEST_SIZE", "50mb"),
-			RetryStatusCodes:   getEnvIntSlice("RETRY_STATUS_CODES", []int{429, 500}),
-			RetryMaxAttempts:   getEnvInt("RETRY_MAX_ATTEMPTS", 3),
-			Debug:              getEnv("DEBUG", "off"),
-			EndpointMode:       getEnv("ENDPOINT_MODE", "daily"),
-			GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-			GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-			DataDir:            getEnv("DATA_DIR", "./data"),
+			Port:                    getEnvInt("PORT", 8045),
+			Host:                    getEnv("HOST", "0.0.0.0"),
+			UserAgent:               getEnv("API_USER_AGENT", "antigravity/1.11.3 windows/amd64"),
+			Timeout:                 getEnvInt("TIMEOUT", 180000),
+			Proxy:                   getEnv("PROXY", ""),
+			APIKey:                  getEnv("API_KEY", ""),
+			PanelUser:               getEnv("PANEL_USER", "admin"),
+			PanelPassword:           getEnv("PANEL_PASSWORD", ""),
+			MaskEmails:              getEnvBool("MASK_EMAILS", true),
+			PanelPasswordLogin:      getEnvBool("PANEL_PASSWORD_LOGIN", true),
+			PanelAuthAllowed:        getEnvStringSlice("PANEL_AUTH_ALLOWED", nil),
+			PanelGitHubClientID:     getEnv("PANEL_GITHUB_CLIENT_ID", ""),
+			PanelGitHubClientSecret: getEnv("PANEL_GITHUB_CLIENT_SECRET", ""),
+			PanelOIDCIssuer:         getEnv("PANEL_OIDC_ISSUER", ""),
+			PanelOIDCClientID:       getEnv("PANEL_OIDC_CLIENT_ID", ""),
+			PanelOIDCClientSecret:   getEnv("PANEL_OIDC_CLIENT_SECRET", ""),
+			PanelOIDCLabel:          getEnv("PANEL_OIDC_LABEL", ""),
+			MaxRequestSize:          getEnv("MAX_REQUEST_SIZE", "50mb"),
+			ContextGuard:            getEnvBool("CONTEXT_GUARD", true),
+			HistoryTruncation:       getEnv("HISTORY_TRUNCATION", "off"),
+			HistoryTokenBudget:      getEnvInt("HISTORY_TOKEN_BUDGET", 0),
+			HistorySummaryModel:     getEnv("HISTORY_SUMMARY_MODEL", "gemini-3-pro-low"),
+			ToolArgRepair:           getEnvBool("TOOL_ARG_REPAIR", false),
+			ToolLoopLimit:           getEnvInt("TOOL_LOOP_LIMIT", 0),
+			ToolLoopAction:          getEnv("TOOL_LOOP_ACTION", "nudge"),
+			HeartbeatInterval:       getEnvInt("HEARTBEAT_INTERVAL", 1000),
+			HeartbeatMaxWait:        getEnvInt("HEARTBEAT_MAX_WAIT", 0),
+			HeartbeatStyle:          getEnv("HEARTBEAT_STYLE", "delta"),
+			StreamQueueSize:         getEnvInt("STREAM_QUEUE_SIZE", 256),
+			StreamSlowClient:        getEnv("STREAM_SLOW_CLIENT", "block"),
+			StreamMaxDuration:       getEnvInt("STREAM_MAX_DURATION", 0),
+			ThinkingMaxDuration:     getEnvInt("STREAM_MAX_DURATION_THINKING", 0),
+			StreamMaxDurationModels: getEnvStringSlice("STREAM_MAX_DURATION_MODELS", nil),
+			ImageOutput:             getEnv("IMAGE_OUTPUT", "markdown"),
+			ImageModel:              getEnv("IMAGE_MODEL", "gemini-3-pro-image"),
+			ReasoningField:          getEnv("REASONING_FIELD", "reasoning"),
+			ImageURLFetch:           getEnvBool("IMAGE_URL_FETCH", false),
+			ImageFetchMaxMB:         getEnvInt("IMAGE_FETCH_MAX_MB", 10),
+			ImageFetchTimeout:       getEnvInt("IMAGE_FETCH_TIMEOUT", 10),
+			ImageFetchAllowPrivate:  getEnvBool("IMAGE_FETCH_ALLOW_PRIVATE", false),
+			InlineDataMaxMB:         getEnvInt("INLINE_DATA_MAX_MB", 0),
+			InlineDataRequestMaxMB:  getEnvInt("INLINE_DATA_REQUEST_MAX_MB", 0),
+			InlineImageDownscale:    getEnvBool("INLINE_IMAGE_DOWNSCALE", false),
+			InlineImageMaxDimension: getEnvInt("INLINE_IMAGE_MAX_DIMENSION", 0),
+			InlineImageTranscode:    getEnvBool("INLINE_IMAGE_TRANSCODE", false),
+			GeminiIncludeThoughts:   getEnvBool("GEMINI_INCLUDE_THOUGHTS", true),
+			RetryStatusCodes:        getEnvIntSlice("RETRY_STATUS_CODES", []int{429, 500}),
+			RetryMaxAttempts:        getEnvInt("RETRY_MAX_ATTEMPTS", 3),
+			AccountCooldown:         getEnvInt("ACCOUNT_COOLDOWN", 0),
+			DailyTokenBudget:        getEnvInt("DAILY_TOKEN_BUDGET", 0),
+			DailyTokenBudgetPerKey:  getEnvInt("DAILY_TOKEN_BUDGET_PER_KEY", 0),
+			BatchConcurrency:        getEnvInt("BATCH_CONCURRENCY", 0),
+			BatchMaxRetries:         getEnvInt("BATCH_MAX_RETRIES", 5),
+			ExposeUpstreamErrors:    getEnvBool("EXPOSE_UPSTREAM_ERRORS", false),
+			SchemaDriftCheck:        getEnvBool("SCHEMA_DRIFT_CHECK", true),
+			Debug:                   getEnv("DEBUG", "off"),
+			LogMaxBodySize:          getEnvInt("LOG_MAX_BODY_SIZE", 5000),
+			LogGroupByRequest:       getEnvBool("LOG_GROUP_BY_REQUEST", false),
+			EndpointMode:            getEnv("ENDPOINT_MODE", "daily"),
+			ExposeEndpointHeader:    getEnvBool("EXPOSE_ENDPOINT_HEADER", false),
+			UsageHeaders:            getEnvBool("USAGE_HEADERS", true),
+			BreakerEnabled:          getEnvBool("BREAKER_ENABLED", true),
+			BreakerWindow:           getEnvInt("BREAKER_WINDOW", 60),
+			BreakerThreshold:        getEnvInt("BREAKER_THRESHOLD", 50),
+			BreakerMinRequests:      getEnvInt("BREAKER_MIN_REQUESTS", 5),
+			BreakerCooldown:         getEnvInt("BREAKER_COOLDOWN", 120),
+			ModerationBlockWords:    getEnvStringSlice("MODERATION_BLOCK_WORDS", nil),
+			ModerationBlockPattern:  getEnv("MODERATION_BLOCK_PATTERN", ""),
+			ModerationRedactPattern: getEnv("MODERATION_REDACT_PATTERN", ""),
+			ModerationURL:           getEnv("MODERATION_URL", ""),
+			ModerationsBackend:      getEnv("MODERATIONS_BACKEND", "keyword"),
+			ModerationsModel:        getEnv("MODERATIONS_MODEL", "gemini-3-pro-low"),
+			ModerationsKeywords:     getEnv("MODERATIONS_KEYWORDS", ""),
+			ErrorLanguage:           getEnv("ERROR_LANGUAGE", "zh"),
+			ModelPassthrough:        getEnvBool("MODEL_PASSTHROUGH", true),
+			HiddenModels:            getEnvStringSlice("HIDDEN_MODELS", nil),
+			Preflight:               getEnvBool("PREFLIGHT", false),
+			PreflightModel:          getEnv("PREFLIGHT_MODEL", "gemini-3-pro-low"),
+			MirrorPercent:           getEnvInt("MIRROR_PERCENT", 0),
+			MirrorEndpoint:          getEnv("MIRROR_ENDPOINT", ""),
+			MirrorModel:             getEnv("MIRROR_MODEL", ""),
+			GoogleClientID:          getEnv("GOOGLE_CLIENT_ID", ""),
+			GoogleClientSecret:      getEnv("GOOGLE_CLIENT_SECRET", ""),
+			DataDir:                 getEnv("DATA_DIR", "./data"),
+		}
+
+		if _, ok := APIEndpoints[cfg.MirrorEndpoint]; cfg.MirrorEndpoint != "" && !ok {
+			cfg.warnings = append(cfg.warnings, fmt.Sprintf("MIRROR_ENDPOINT %q is not a known endpoint, mirroring to the current endpoint instead", cfg.MirrorEndpoint))
 		}
 
 		// 检查命令行参数
@@ -168,6 +370,31 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvStringSlice(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		parts := strings.Split(value, ",")
+		result := make([]string, 0, len(parts))
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p != "" {
+				result = append(result, p)
+			}
+		}
+		if len(result) > 0 {
+			return result
+		}
+	}
+	return defaultValue
+}
+
 func getEnvIntSlice(key string, defaultValue []int) []int {
 	if value := os.Getenv(key); value != "" {
 		parts := strings.Split(value, ",")
@@ -15,13 +15,23 @@ type EndpointManager struct {
 	roundRobinIndex   int
 	roundRobinDpIndex int
 	settingsPath      string
+	schedule          []ScheduleRule
+	compiled          []scheduleRule
+	now               func() time.Time
 }
 
 // Settings 持久化设置
 type Settings struct {
-	EndpointMode    string    `json:"endpointMode"`
-	CurrentEndpoint string    `json:"currentEndpoint"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	EndpointMode    string         `json:"endpointMode"`
+	CurrentEndpoint string         `json:"currentEndpoint"`
+	Schedule        []ScheduleRule `json:"schedule,omitempty"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+}
+
+// validModes 支持的端点模式
+var validModes = map[string]bool{
+	"daily": true, "autopush": true, "production": true,
+	"round-robin": true, "round-robin-dp": true,
 }
 
 var (
@@ -36,6 +46,7 @@ func GetEndpointManager() *EndpointManager {
 		endpointMgr = &EndpointManager{
 			mode:         cfg.EndpointMode,
 			settingsPath: filepath.Join(cfg.DataDir, "settings.json"),
+			now:          time.Now,
 		}
 		endpointMgr.loadSettings()
 	})
@@ -58,6 +69,12 @@ func (m *EndpointManager) loadSettings() {
 	if os.Getenv("ENDPOINT_MODE") == "" && settings.EndpointMode != "" {
 		m.mode = settings.EndpointMode
 	}
+
+	// 定时规则不受环境变量影响；无效规则整体忽略
+	if compiled, err := compileSchedule(settings.Schedule); err == nil {
+		m.schedule = settings.Schedule
+		m.compiled = compiled
+	}
 }
 
 // saveSettings 保存设置
@@ -65,6 +82,7 @@ func (m *EndpointManager) saveSettings() error {
 	settings := Settings{
 		EndpointMode:    m.mode,
 		CurrentEndpoint: m.getCurrentEndpointKey(),
+		Schedule:        m.schedule,
 		UpdatedAt:       time.Now(),
 	}
 
@@ -101,29 +119,97 @@ func (m *EndpointManager) getCurrentEndpointKey() string {
 	}
 }
 
-// GetActiveEndpoint 获取当前活动端点
+// GetActiveEndpoint 获取当前活动端点（按定时规则生效的模式）
+// 轮询模式下跳过已熔断的端点；若全部熔断则仍按顺序返回，避免请求无端点可用
 func (m *EndpointManager) GetActiveEndpoint() Endpoint {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	switch m.mode {
+	switch mode := m.activeMode(); mode {
+	case "round-robin":
+		return APIEndpoints[nextAvailable(RoundRobinEndpoints, &m.roundRobinIndex)]
+	case "round-robin-dp":
+		return APIEndpoints[nextAvailable(RoundRobinDpEndpoints, &m.roundRobinDpIndex)]
+	default:
+		if ep, ok := APIEndpoints[mode]; ok {
+			return ep
+		}
+		return APIEndpoints["daily"]
+	}
+}
+
+// CurrentEndpoint 获取当前活动端点但不推进轮询位置，用于镜像等旁路流量，避免影响正式请求的端点分配
+func (m *EndpointManager) CurrentEndpoint() Endpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch mode := m.activeMode(); mode {
 	case "round-robin":
-		key := RoundRobinEndpoints[m.roundRobinIndex]
-		m.roundRobinIndex = (m.roundRobinIndex + 1) % len(RoundRobinEndpoints)
-		return APIEndpoints[key]
+		return APIEndpoints[RoundRobinEndpoints[max(m.roundRobinIndex, 0)%len(RoundRobinEndpoints)]]
 	case "round-robin-dp":
-		key := RoundRobinDpEndpoints[m.roundRobinDpIndex]
-		m.roundRobinDpIndex = (m.roundRobinDpIndex + 1) % len(RoundRobinDpEndpoints)
-		return APIEndpoints[key]
+		return APIEndpoints[RoundRobinDpEndpoints[max(m.roundRobinDpIndex, 0)%len(RoundRobinDpEndpoints)]]
 	default:
-		if ep, ok := APIEndpoints[m.mode]; ok {
+		if ep, ok := APIEndpoints[mode]; ok {
 			return ep
 		}
 		return APIEndpoints["daily"]
 	}
 }
 
-// GetMode 获取当前模式
+// activeMode 当前生效的模式：首个覆盖当前时刻的定时规则，否则为基础模式（内部方法，需要已持有锁）
+func (m *EndpointManager) activeMode() string {
+	now := m.now()
+	for _, rule := range m.compiled {
+		if rule.matches(now) {
+			return rule.mode
+		}
+	}
+	return m.mode
+}
+
+// ActiveMode 获取当前生效的模式（含定时规则）
+func (m *EndpointManager) ActiveMode() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.activeMode()
+}
+
+// GetSchedule 获取定时规则
+func (m *EndpointManager) GetSchedule() []ScheduleRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ScheduleRule{}, m.schedule...)
+}
+
+// SetSchedule 校验并替换定时规则，按顺序匹配，先匹配者生效
+func (m *EndpointManager) SetSchedule(rules []ScheduleRule) error {
+	compiled, err := compileSchedule(rules)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedule = rules
+	m.compiled = compiled
+	return m.saveSettings()
+}
+
+// nextAvailable 从轮询列表中取下一个未熔断的端点并推进索引
+func nextAvailable(keys []string, index *int) string {
+	breaker := GetEndpointBreaker()
+	first := keys[*index]
+	for range keys {
+		key := keys[*index]
+		*index = (*index + 1) % len(keys)
+		if breaker.Available(key) {
+			return key
+		}
+	}
+	return first
+}
+
+// GetMode 获取基础模式（不含定时规则）
 func (m *EndpointManager) GetMode() string {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -136,10 +222,6 @@ func (m *EndpointManager) SetMode(mode string) error {
 	defer m.mu.Unlock()
 
 	// 验证模式
-	validModes := map[string]bool{
-		"daily": true, "autopush": true, "production": true,
-		"round-robin": true, "round-robin-dp": true,
-	}
 	if !validModes[mode] {
 		return nil // 忽略无效模式
 	}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// OutputFilterSet 一组输出过滤设置
+type OutputFilterSet struct {
+	StripThinking      bool                `json:"stripThinking,omitempty"`
+	StopArtifacts      bool                `json:"stopArtifacts,omitempty"`
+	CollapseWhitespace bool                `json:"collapseWhitespace,omitempty"`
+	Replacements       []OutputReplacement `json:"replacements,omitempty"`
+	JSONGuard          bool                `json:"jsonGuard,omitempty"`
+}
+
+// OutputReplacement 正则替换规则
+type OutputReplacement struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// Regexp 返回编译后的正则（由 ParseOutputFilters 编译）
+func (r OutputReplacement) Regexp() *regexp.Regexp {
+	return r.re
+}
+
+// OutputFilters 输出过滤配置：default 为全局设置，models 按模型名整体覆盖
+type OutputFilters struct {
+	Default OutputFilterSet            `json:"default"`
+	Models  map[string]OutputFilterSet `json:"models,omitempty"`
+}
+
+// OutputFilterManager 输出过滤配置管理器
+type OutputFilterManager struct {
+	mu      sync.RWMutex
+	filters OutputFilters
+}
+
+var (
+	outputFilterMgr     *OutputFilterManager
+	outputFilterMgrOnce sync.Once
+)
+
+// GetOutputFilterManager 获取输出过滤配置管理器单例
+func GetOutputFilterManager() *OutputFilterManager {
+	outputFilterMgrOnce.Do(func() {
+		outputFilterMgr = &OutputFilterManager{}
+		outputFilterMgr.load(filepath.Join(Get().DataDir, "output_filters.json"))
+	})
+	return outputFilterMgr
+}
+
+// load 加载配置（环境变量 OUTPUT_FILTERS 优先于 data/output_filters.json，均为 JSON 对象）
+func (m *OutputFilterManager) load(filePath string) {
+	data := []byte(os.Getenv("OUTPUT_FILTERS"))
+	if len(data) == 0 {
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			return
+		}
+	}
+
+	if filters, err := ParseOutputFilters(data); err == nil {
+		m.mu.Lock()
+		m.filters = *filters
+		m.mu.Unlock()
+	}
+}
+
+// For 返回模型适用的过滤设置：按顺序查找首个有单独配置的模型名，均未配置时使用全局设置
+func (m *OutputFilterManager) For(models ...string) OutputFilterSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, model := range models {
+		if set, ok := m.filters.Models[model]; ok {
+			return set
+		}
+	}
+	return m.filters.Default
+}
+
+// ParseOutputFilters 解析输出过滤配置并编译正则
+func ParseOutputFilters(data []byte) (*OutputFilters, error) {
+	var filters OutputFilters
+	if err := json.Unmarshal(data, &filters); err != nil {
+		return nil, err
+	}
+
+	if err := compileReplacements(filters.Default.Replacements); err != nil {
+		return nil, err
+	}
+	for model, set := range filters.Models {
+		if err := compileReplacements(set.Replacements); err != nil {
+			return nil, fmt.Errorf("model %s: %w", model, err)
+		}
+	}
+	return &filters, nil
+}
+
+func compileReplacements(replacements []OutputReplacement) error {
+	for i := range replacements {
+		re, err := regexp.Compile(replacements[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid replacement pattern %q: %w", replacements[i].Pattern, err)
+		}
+		replacements[i].re = re
+	}
+	return nil
+}
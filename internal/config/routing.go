@@ -0,0 +1,255 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/core"
+)
+
+// RoutingRule A/B 路由规则：将指定模型 Percent% 的流量转到 Variant
+type RoutingRule struct {
+	Model   string `json:"model"`
+	Variant string `json:"variant"`
+	Percent int    `json:"percent"`
+}
+
+// RoutingManager A/B 路由规则管理器
+type RoutingManager struct {
+	mu       sync.RWMutex
+	rules    []RoutingRule
+	filePath string
+	loadErr  error // 启动时无效的规则配置（已忽略），由服务启动时记录警告
+	fromEnv  bool  // 规则来自 ROUTING_RULES 环境变量，不可通过管理接口修改
+}
+
+// ErrRoutingRulesFromEnv 规则由 ROUTING_RULES 环境变量指定时拒绝修改（写入文件也会在重启后被环境变量覆盖）
+var ErrRoutingRulesFromEnv = errors.New("routing rules are set by the ROUTING_RULES environment variable; unset it to manage rules here")
+
+// routingFile 持久化格式
+type routingFile struct {
+	Rules     []RoutingRule `json:"rules"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+var (
+	routingMgr     *RoutingManager
+	routingMgrOnce sync.Once
+)
+
+// GetRoutingManager 获取路由规则管理器单例
+func GetRoutingManager() *RoutingManager {
+	routingMgrOnce.Do(func() {
+		cfg := Get()
+		routingMgr = &RoutingManager{
+			filePath: filepath.Join(cfg.DataDir, "routing.json"),
+		}
+		routingMgr.load()
+	})
+	return routingMgr
+}
+
+// load 加载规则（环境变量 ROUTING_RULES 优先于持久化文件）
+func (m *RoutingManager) load() {
+	if env := os.Getenv("ROUTING_RULES"); env != "" {
+		m.fromEnv = true
+		rules, err := ParseRoutingRules(env)
+		if err != nil {
+			m.loadErr = fmt.Errorf("ROUTING_RULES: %w", err)
+			return
+		}
+		m.rules = rules
+		return
+	}
+
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		return
+	}
+
+	var file routingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		m.loadErr = fmt.Errorf("%s: %w", m.filePath, err)
+		return
+	}
+	if err := ValidateRoutingRules(file.Rules); err != nil {
+		m.loadErr = fmt.Errorf("%s: %w", m.filePath, err)
+		return
+	}
+	m.rules = file.Rules
+}
+
+// FromEnv 规则是否由 ROUTING_RULES 环境变量指定
+func (m *RoutingManager) FromEnv() bool {
+	return m.fromEnv
+}
+
+// LoadError 返回启动时被忽略的无效规则配置错误
+func (m *RoutingManager) LoadError() error {
+	return m.loadErr
+}
+
+// save 保存规则
+func (m *RoutingManager) save() error {
+	data, err := json.MarshalIndent(routingFile{
+		Rules:     m.rules,
+		UpdatedAt: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(m.filePath, data, 0644)
+}
+
+// GetRules 获取当前规则
+func (m *RoutingManager) GetRules() []RoutingRule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make([]RoutingRule, len(m.rules))
+	copy(rules, m.rules)
+	return rules
+}
+
+// SetRules 替换全部规则并持久化
+func (m *RoutingManager) SetRules(rules []RoutingRule) error {
+	if m.fromEnv {
+		return ErrRoutingRulesFromEnv
+	}
+	if err := ValidateRoutingRules(rules); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules = append([]RoutingRule{}, rules...)
+	return m.save()
+}
+
+// SetRulesWith 替换规则后执行 apply，apply 失败时恢复原规则与持久化文件
+// 用于与其他配置一起整体导入，避免只生效一半
+func (m *RoutingManager) SetRulesWith(rules []RoutingRule, apply func() error) error {
+	if m.fromEnv {
+		return ErrRoutingRulesFromEnv
+	}
+	if err := ValidateRoutingRules(rules); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.rules
+	m.rules = append([]RoutingRule{}, rules...)
+	if err := m.save(); err != nil {
+		m.rules = previous
+		m.save()
+		return err
+	}
+	if err := apply(); err != nil {
+		m.rules = previous
+		if restoreErr := m.save(); restoreErr != nil {
+			return fmt.Errorf("%w (restoring routing rules failed: %v)", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Pick 按规则为模型抽取变体，未命中时返回空字符串
+// 同一模型的多条规则按百分比累加，剩余流量保持原模型
+func (m *RoutingManager) Pick(model string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.rules) == 0 {
+		return ""
+	}
+
+	roll := rand.Intn(100)
+	acc := 0
+	for _, rule := range m.rules {
+		if rule.Model != model {
+			continue
+		}
+		acc += rule.Percent
+		if roll < acc {
+			return rule.Variant
+		}
+	}
+	return ""
+}
+
+// ParseRoutingRules 解析规则字符串
+// 格式: model=variant:percent，多条以逗号分隔
+// 例如: gemini-3-pro-high=gemini-3-pro-low:10
+func ParseRoutingRules(s string) ([]RoutingRule, error) {
+	var rules []RoutingRule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		model, rest, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid routing rule %q", item)
+		}
+		variant, percentStr, ok := strings.Cut(rest, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid routing rule %q", item)
+		}
+		percent, err := strconv.Atoi(strings.TrimSpace(percentStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid routing percent in %q", item)
+		}
+
+		rules = append(rules, RoutingRule{
+			Model:   strings.TrimSpace(model),
+			Variant: strings.TrimSpace(variant),
+			Percent: percent,
+		})
+	}
+
+	if err := ValidateRoutingRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ValidateRoutingRules 校验规则：字段非空、变体为已知模型、百分比合法、同一模型累计不超过 100
+func ValidateRoutingRules(rules []RoutingRule) error {
+	totals := make(map[string]int)
+	for _, rule := range rules {
+		if rule.Model == "" || rule.Variant == "" {
+			return fmt.Errorf("routing rule requires model and variant")
+		}
+		if rule.Model == rule.Variant {
+			return fmt.Errorf("routing rule for %s routes to itself", rule.Model)
+		}
+		if !core.IsKnownModel(rule.Variant) {
+			return fmt.Errorf("routing variant %s for %s is not a known model", rule.Variant, rule.Model)
+		}
+		if rule.Percent < 0 || rule.Percent > 100 {
+			return fmt.Errorf("routing percent for %s must be between 0 and 100", rule.Model)
+		}
+		totals[rule.Model] += rule.Percent
+		if totals[rule.Model] > 100 {
+			return fmt.Errorf("routing percents for %s exceed 100", rule.Model)
+		}
+	}
+	return nil
+}
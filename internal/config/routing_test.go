@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRoutingRules(t *testing.T) {
+	rules, err := ParseRoutingRules("gemini-3-pro-high=gemini-3-pro-low:10, claude-sonnet-4-5=claude-sonnet-4-5-thinking:50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+	if rules[0] != (RoutingRule{Model: "gemini-3-pro-high", Variant: "gemini-3-pro-low", Percent: 10}) {
+		t.Errorf("Unexpected first rule: %+v", rules[0])
+	}
+
+	invalid := []string{
+		"gemini-3-pro-high",
+		"gemini-3-pro-high=gemini-3-pro-low",
+		"gemini-3-pro-high=gemini-3-pro-low:abc",
+		"gemini-3-pro-high=gemini-3-pro-low:101",
+		"gemini-3-pro-high=gemini-3-pro-high:10",
+		"gemini-3-pro-high=gemini-3-pro-low:60,gemini-3-pro-high=claude-sonnet-4-5:50",
+		"gemini-3-pro-high=no-such-model:10",
+	}
+	for _, s := range invalid {
+		if _, err := ParseRoutingRules(s); err == nil {
+			t.Errorf("ParseRoutingRules(%q) expected error", s)
+		}
+	}
+}
+
+func TestRoutingManagerLoadError(t *testing.T) {
+	t.Setenv("ROUTING_RULES", "gemini-3-pro-high=gemini-3-pro-low")
+	m := &RoutingManager{}
+	m.load()
+	if m.LoadError() == nil || len(m.GetRules()) != 0 {
+		t.Errorf("expected invalid ROUTING_RULES to be reported and ignored, err=%v rules=%v", m.LoadError(), m.GetRules())
+	}
+}
+
+func TestSetRulesRejectedWhenFromEnv(t *testing.T) {
+	t.Setenv("ROUTING_RULES", "gemini-3-pro-high=gemini-3-pro-low:10")
+	m := &RoutingManager{filePath: filepath.Join(t.TempDir(), "routing.json")}
+	m.load()
+	if !m.FromEnv() {
+		t.Fatal("expected rules to be reported as coming from ROUTING_RULES")
+	}
+
+	next := []RoutingRule{{Model: "gemini-3-pro-high", Variant: "gemini-3-pro-low", Percent: 50}}
+	if err := m.SetRules(next); !errors.Is(err, ErrRoutingRulesFromEnv) {
+		t.Errorf("SetRules: expected ErrRoutingRulesFromEnv, got %v", err)
+	}
+	if err := m.SetRulesWith(next, func() error { return nil }); !errors.Is(err, ErrRoutingRulesFromEnv) {
+		t.Errorf("SetRulesWith: expected ErrRoutingRulesFromEnv, got %v", err)
+	}
+	if rules := m.GetRules(); len(rules) != 1 || rules[0].Percent != 10 {
+		t.Errorf("env rules changed: %+v", rules)
+	}
+	if _, err := os.Stat(m.filePath); !os.IsNotExist(err) {
+		t.Errorf("routing file should not be written, stat err=%v", err)
+	}
+}
+
+func TestRoutingManagerPick(t *testing.T) {
+	m := &RoutingManager{rules: []RoutingRule{
+		{Model: "a", Variant: "b", Percent: 100},
+		{Model: "c", Variant: "d", Percent: 0},
+	}}
+
+	if got := m.Pick("a"); got != "b" {
+		t.Errorf("Expected variant 'b', got %q", got)
+	}
+	if got := m.Pick("c"); got != "" {
+		t.Errorf("Expected no variant for 0%%, got %q", got)
+	}
+	if got := m.Pick("x"); got != "" {
+		t.Errorf("Expected no variant for unmatched model, got %q", got)
+	}
+}
+
+// TestSetRulesWithRestoresOnFailure apply 失败时恢复原规则与持久化文件
+func TestSetRulesWithRestoresOnFailure(t *testing.T) {
+	m := &RoutingManager{filePath: filepath.Join(t.TempDir(), "routing.json")}
+	old := []RoutingRule{{Model: "gemini-3-pro-high", Variant: "gemini-3-pro-low", Percent: 10}}
+	if err := m.SetRules(old); err != nil {
+		t.Fatal(err)
+	}
+
+	next := []RoutingRule{{Model: "gemini-3-pro-high", Variant: "gemini-3-pro-low", Percent: 50}}
+	failure := errors.New("virtual models not saved")
+	if err := m.SetRulesWith(next, func() error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("expected apply error, got %v", err)
+	}
+	if rules := m.GetRules(); len(rules) != 1 || rules[0] != old[0] {
+		t.Errorf("rules not restored: %+v", rules)
+	}
+	reloaded := &RoutingManager{filePath: m.filePath}
+	reloaded.load()
+	if rules := reloaded.GetRules(); len(rules) != 1 || rules[0] != old[0] {
+		t.Errorf("persisted rules not restored: %+v", rules)
+	}
+
+	if err := m.SetRulesWith(next, func() error { return nil }); err != nil || m.GetRules()[0].Percent != 50 {
+		t.Errorf("expected rules to be applied, err=%v rules=%+v", err, m.GetRules())
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleRule 端点模式定时规则：在指定星期与时间段内使用 Mode 代替基础模式
+// 时间按服务器本地时区（TZ）计算
+type ScheduleRule struct {
+	Days  string `json:"days,omitempty"` // 星期，如 mon-fri、sat,sun；为空表示每天
+	Start string `json:"start"`          // 开始时间 HH:MM（含）
+	End   string `json:"end"`            // 结束时间 HH:MM（不含）；不晚于开始时间时跨越午夜
+	Mode  string `json:"mode"`
+}
+
+// scheduleRule 解析后的定时规则
+type scheduleRule struct {
+	days       [7]bool
+	start, end int // 当天的分钟数
+	mode       string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// compileSchedule 校验并解析定时规则
+func compileSchedule(rules []ScheduleRule) ([]scheduleRule, error) {
+	compiled := make([]scheduleRule, 0, len(rules))
+	for i, rule := range rules {
+		if !validModes[rule.Mode] {
+			return nil, fmt.Errorf("rule %d: invalid mode %q", i+1, rule.Mode)
+		}
+		days, err := parseDays(rule.Days)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		start, err := parseClock(rule.Start)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		end, err := parseClock(rule.End)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		compiled = append(compiled, scheduleRule{days: days, start: start, end: end, mode: rule.Mode})
+	}
+	return compiled, nil
+}
+
+// parseDays 解析星期列表，支持逗号分隔与区间（如 mon-fri,sun、fri-mon）
+func parseDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	if strings.TrimSpace(spec) == "" {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, item := range strings.Split(strings.ToLower(spec), ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return days, fmt.Errorf("invalid day %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches 规则是否覆盖指定时刻；跨午夜的时间段按开始时间所在的星期判断
+func (r scheduleRule) matches(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if r.start < r.end {
+		return r.days[day] && minute >= r.start && minute < r.end
+	}
+	yesterday := (day + 6) % 7
+	return (r.days[day] && minute >= r.start) || (r.days[yesterday] && minute < r.end)
+}
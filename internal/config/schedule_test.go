@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndpointSchedule(t *testing.T) {
+	rules := []ScheduleRule{
+		{Days: "mon-fri", Start: "09:00", End: "18:00", Mode: "production"},
+		{Start: "22:00", End: "06:00", Mode: "round-robin"},
+	}
+	compiled, err := compileSchedule(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var now time.Time
+	m := &EndpointManager{mode: "daily", schedule: rules, compiled: compiled, now: func() time.Time { return now }}
+
+	tests := []struct {
+		at   string
+		mode string
+	}{
+		{"2026-10-14 10:30", "production"},  // 周三工作时间
+		{"2026-10-14 18:00", "daily"},       // 结束时间不含
+		{"2026-10-17 10:30", "daily"},       // 周六
+		{"2026-10-17 23:15", "round-robin"}, // 跨午夜时段
+		{"2026-10-18 05:59", "round-robin"},
+		{"2026-10-18 06:00", "daily"},
+	}
+	for _, tt := range tests {
+		now, _ = time.ParseInLocation("2006-01-02 15:04", tt.at, time.Local)
+		if got := m.ActiveMode(); got != tt.mode {
+			t.Errorf("%s: mode = %s, want %s", tt.at, got, tt.mode)
+		}
+	}
+}
+
+func TestCompileScheduleInvalid(t *testing.T) {
+	for _, rule := range []ScheduleRule{
+		{Start: "09:00", End: "18:00", Mode: "nightly"},
+		{Days: "mon-fry", Start: "09:00", End: "18:00", Mode: "daily"},
+		{Start: "9am", End: "18:00", Mode: "daily"},
+	} {
+		if _, err := compileSchedule([]ScheduleRule{rule}); err == nil {
+			t.Errorf("%+v: expected error", rule)
+		}
+	}
+
+	days, err := parseDays("fri-mon")
+	if err != nil || !days[time.Saturday] || !days[time.Monday] || days[time.Wednesday] {
+		t.Errorf("fri-mon = %v, %v", days, err)
+	}
+}
+
+// TestCurrentEndpointDoesNotAdvance CurrentEndpoint 不推进轮询位置
+func TestCurrentEndpointDoesNotAdvance(t *testing.T) {
+	m := &EndpointManager{mode: "round-robin", now: time.Now}
+	if got := m.CurrentEndpoint(); got.Key != "daily" || m.CurrentEndpoint().Key != "daily" {
+		t.Errorf("CurrentEndpoint = %s, want daily without advancing", got.Key)
+	}
+	if got := m.GetActiveEndpoint(); got.Key != "daily" {
+		t.Errorf("GetActiveEndpoint = %s, want daily", got.Key)
+	}
+	if got := m.CurrentEndpoint(); got.Key != "autopush" {
+		t.Errorf("CurrentEndpoint after rotation = %s, want autopush", got.Key)
+	}
+}
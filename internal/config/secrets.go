@@ -0,0 +1,98 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// 敏感配置项脱敏方式
+const (
+	maskFull    = iota // 完全隐藏（密码、密钥）
+	maskPartial        // 保留首尾各 4 个字符，便于核对是哪一个（API Key）
+	maskURL            // 隐藏 URL 中的凭证、路径与查询参数，保留协议与主机（代理、Webhook）
+)
+
+// secretKeys 敏感配置项注册表：管理接口与审计日志输出配置值时统一经 MaskSetting 脱敏
+// 新增敏感配置时在此登记；未登记但名称形如 *_SECRET / *_PASSWORD / *_TOKEN / *_KEY / *_WEBHOOK* 的键默认完全隐藏
+var (
+	secretKeysMu sync.RWMutex
+	secretKeys   = map[string]int{
+		"API_KEY":                    maskPartial,
+		"PANEL_PASSWORD":             maskFull,
+		"GOOGLE_CLIENT_SECRET":       maskFull,
+		"PANEL_GITHUB_CLIENT_SECRET": maskFull,
+		"PANEL_OIDC_CLIENT_SECRET":   maskFull,
+		"PROXY":                      maskURL,
+		"MODERATION_URL":             maskURL,
+	}
+)
+
+// secretKeySuffixes 未登记时按名称判定为敏感的后缀
+var secretKeySuffixes = []string{"_SECRET", "_PASSWORD", "_TOKEN", "_KEY"}
+
+// RegisterSecretKey 登记完全隐藏的敏感配置项
+func RegisterSecretKey(keys ...string) {
+	secretKeysMu.Lock()
+	defer secretKeysMu.Unlock()
+	for _, key := range keys {
+		secretKeys[strings.ToUpper(key)] = maskFull
+	}
+}
+
+// secretMask 返回配置项的脱敏方式，非敏感项返回 false
+func secretMask(key string) (int, bool) {
+	key = strings.ToUpper(key)
+	secretKeysMu.RLock()
+	mode, ok := secretKeys[key]
+	secretKeysMu.RUnlock()
+	if ok {
+		return mode, true
+	}
+	if strings.Contains(key, "WEBHOOK") {
+		return maskURL, true
+	}
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return maskFull, true
+		}
+	}
+	return 0, false
+}
+
+// IsSecretKey 配置项是否敏感
+func IsSecretKey(key string) bool {
+	_, ok := secretMask(key)
+	return ok
+}
+
+// MaskSetting 按注册表对配置值脱敏，非敏感项原样返回；空值返回"未设置"
+func MaskSetting(key, value string) string {
+	mode, ok := secretMask(key)
+	if !ok {
+		return value
+	}
+	if value == "" {
+		return "未设置"
+	}
+
+	switch mode {
+	case maskPartial:
+		if len(value) <= 8 {
+			return "****"
+		}
+		return value[:4] + "****" + value[len(value)-4:]
+	case maskURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			return "******"
+		}
+		masked := u.Scheme + "://" + u.Host
+		if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			masked += "/****"
+		}
+		return masked
+	default:
+		return "******"
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// VirtualModel 虚拟模型：将目标模型、账号组与生成参数默认值打包为一个独立的模型名
+// 例如 team-a-sonnet → claude-sonnet-4-5，仅使用 team-a 的账号
+type VirtualModel struct {
+	Name     string   `json:"name"`
+	Model    string   `json:"model"`
+	Accounts []string `json:"accounts,omitempty"` // 账号 email 或 projectId，为空时使用全部账号
+
+	// 生成参数默认值（客户端未指定时生效；MaxTokens 为上限）
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	TopK        int      `json:"topK,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+}
+
+// VirtualModelManager 虚拟模型管理器
+type VirtualModelManager struct {
+	mu       sync.RWMutex
+	models   map[string]VirtualModel
+	order    []string
+	filePath string
+}
+
+var (
+	virtualModelMgr     *VirtualModelManager
+	virtualModelMgrOnce sync.Once
+)
+
+// GetVirtualModelManager 获取虚拟模型管理器单例
+func GetVirtualModelManager() *VirtualModelManager {
+	virtualModelMgrOnce.Do(func() {
+		virtualModelMgr = &VirtualModelManager{
+			models:   make(map[string]VirtualModel),
+			filePath: filepath.Join(Get().DataDir, "virtual_models.json"),
+		}
+		virtualModelMgr.load(virtualModelMgr.filePath)
+	})
+	return virtualModelMgr
+}
+
+// load 加载定义（环境变量 VIRTUAL_MODELS 优先于 data/virtual_models.json，均为 JSON 数组）
+func (m *VirtualModelManager) load(filePath string) {
+	data := []byte(os.Getenv("VIRTUAL_MODELS"))
+	if len(data) == 0 {
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			return
+		}
+	}
+
+	if models, err := ParseVirtualModels(data); err == nil {
+		m.set(models)
+	}
+}
+
+func (m *VirtualModelManager) set(models []VirtualModel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.models = make(map[string]VirtualModel, len(models))
+	m.order = m.order[:0]
+	for _, vm := range models {
+		m.models[vm.Name] = vm
+		m.order = append(m.order, vm.Name)
+	}
+}
+
+// SetModels 校验并替换全部虚拟模型，持久化到 data/virtual_models.json
+func (m *VirtualModelManager) SetModels(models []VirtualModel) error {
+	if err := ValidateVirtualModels(models); err != nil {
+		return err
+	}
+	if models == nil {
+		models = []VirtualModel{}
+	}
+
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err != nil {
+		return err
+	}
+	if m.filePath != "" {
+		if err := os.MkdirAll(filepath.Dir(m.filePath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(m.filePath, data, 0644); err != nil {
+			return err
+		}
+	}
+	m.set(models)
+	return nil
+}
+
+// Get 按名称查找虚拟模型
+func (m *VirtualModelManager) Get(name string) (VirtualModel, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	vm, ok := m.models[name]
+	return vm, ok
+}
+
+// List 按定义顺序返回全部虚拟模型
+func (m *VirtualModelManager) List() []VirtualModel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	models := make([]VirtualModel, 0, len(m.order))
+	for _, name := range m.order {
+		models = append(models, m.models[name])
+	}
+	return models
+}
+
+// ParseVirtualModels 解析并校验虚拟模型定义
+func ParseVirtualModels(data []byte) ([]VirtualModel, error) {
+	var models []VirtualModel
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, err
+	}
+	if err := ValidateVirtualModels(models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// ValidateVirtualModels 校验虚拟模型定义：名称与目标模型非空、不指向自身、名称不重复
+func ValidateVirtualModels(models []VirtualModel) error {
+	seen := make(map[string]bool)
+	for _, vm := range models {
+		if vm.Name == "" || vm.Model == "" {
+			return fmt.Errorf("virtual model requires name and model")
+		}
+		if vm.Name == vm.Model {
+			return fmt.Errorf("virtual model %s targets itself", vm.Name)
+		}
+		if seen[vm.Name] {
+			return fmt.Errorf("duplicate virtual model %s", vm.Name)
+		}
+		seen[vm.Name] = true
+	}
+	return nil
+}
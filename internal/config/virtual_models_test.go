@@ -0,0 +1,64 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVirtualModels(t *testing.T) {
+	models, err := ParseVirtualModels([]byte(`[{"name":"team-a-sonnet","model":"claude-sonnet-4-5","accounts":["a@example.com"],"temperature":0.3,"maxTokens":8192}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("Expected 1 model, got %d", len(models))
+	}
+	vm := models[0]
+	if vm.Name != "team-a-sonnet" || vm.Model != "claude-sonnet-4-5" || len(vm.Accounts) != 1 || vm.Temperature == nil || *vm.Temperature != 0.3 || vm.MaxTokens != 8192 {
+		t.Errorf("Unexpected model: %+v", vm)
+	}
+
+	m := &VirtualModelManager{}
+	m.set(models)
+	if _, ok := m.Get("team-a-sonnet"); !ok {
+		t.Error("Expected team-a-sonnet to be found")
+	}
+	if _, ok := m.Get("claude-sonnet-4-5"); ok {
+		t.Error("Target model should not be a virtual model")
+	}
+
+	invalid := []string{
+		`{}`,
+		`[{"name":"a"}]`,
+		`[{"name":"a","model":"a"}]`,
+		`[{"name":"a","model":"b"},{"name":"a","model":"c"}]`,
+	}
+	for _, s := range invalid {
+		if _, err := ParseVirtualModels([]byte(s)); err == nil {
+			t.Errorf("ParseVirtualModels(%s) expected error", s)
+		}
+	}
+}
+
+func TestVirtualModelManagerSetModels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "virtual_models.json")
+	m := &VirtualModelManager{models: make(map[string]VirtualModel), filePath: path}
+
+	if err := m.SetModels([]VirtualModel{{Name: "a", Model: "a"}}); err == nil {
+		t.Error("expected validation error")
+	}
+	if err := m.SetModels([]VirtualModel{{Name: "fast", Model: "gemini-3-pro-low", MaxTokens: 1024}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新加载持久化文件
+	reloaded := &VirtualModelManager{models: make(map[string]VirtualModel)}
+	reloaded.load(path)
+	if vm, ok := reloaded.Get("fast"); !ok || vm.MaxTokens != 1024 {
+		t.Errorf("reloaded = %+v, %v", vm, ok)
+	}
+
+	if err := m.SetModels(nil); err != nil || len(m.List()) != 0 {
+		t.Errorf("clear: err = %v, models = %v", err, m.List())
+	}
+}
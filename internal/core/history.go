@@ -0,0 +1,61 @@
+package core
+
+// TruncateHistory 当请求估算 token 超出预算时，从最早的对话轮次开始丢弃
+// system 指令始终保留；丢弃以完整轮次为单位（直到下一条真实的 user 消息），
+// 避免留下缺少对应 functionCall 的 functionResponse；最后一轮始终保留
+// 返回被丢弃的消息数
+func TruncateHistory(req *AntigravityRequest, budget int) int {
+	if budget <= 0 {
+		return 0
+	}
+
+	contents := req.Request.Contents
+	total := EstimateRequestTokens(req)
+	dropped := 0
+
+	for total > budget {
+		next := nextTurnStart(contents, 1)
+		if next <= 0 || next >= len(contents) {
+			break
+		}
+		for _, content := range contents[:next] {
+			total -= EstimatePartsTokens(content.Parts)
+		}
+		dropped += next
+		contents = contents[next:]
+	}
+
+	if dropped > 0 {
+		req.Request.Contents = contents
+	}
+	return dropped
+}
+
+// TurnStarts 返回除首条外所有以真实 user 消息开头的轮次下标（可安全切分的位置）
+func TurnStarts(contents []Content) []int {
+	var starts []int
+	for i := nextTurnStart(contents, 1); i > 0; i = nextTurnStart(contents, i+1) {
+		starts = append(starts, i)
+	}
+	return starts
+}
+
+// nextTurnStart 从 from 开始查找下一条以真实 user 消息开头的轮次
+func nextTurnStart(contents []Content, from int) int {
+	for i := from; i < len(contents); i++ {
+		if contents[i].Role == "user" && !isFunctionResponseTurn(contents[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isFunctionResponseTurn 判断消息是否包含工具结果
+func isFunctionResponseTurn(content Content) bool {
+	for _, part := range content.Parts {
+		if part.FunctionResponse != nil {
+			return true
+		}
+	}
+	return false
+}
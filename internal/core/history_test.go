@@ -0,0 +1,39 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateHistory(t *testing.T) {
+	long := strings.Repeat("x", 400) // 100 tokens
+	req := &AntigravityRequest{}
+	req.Request.SystemInstruction = &SystemInstruction{Parts: []Part{{Text: "sys"}}}
+	req.Request.Contents = []Content{
+		{Role: "user", Parts: []Part{{Text: long}}},
+		{Role: "model", Parts: []Part{{FunctionCall: &FunctionCall{ID: "c1", Name: "f"}}}},
+		{Role: "user", Parts: []Part{{FunctionResponse: &FunctionResponse{ID: "c1", Name: "f"}}}},
+		{Role: "model", Parts: []Part{{Text: long}}},
+		{Role: "user", Parts: []Part{{Text: "second"}}},
+		{Role: "model", Parts: []Part{{Text: "ok"}}},
+		{Role: "user", Parts: []Part{{Text: "latest"}}},
+	}
+
+	dropped := TruncateHistory(req, 50)
+	if dropped != 4 {
+		t.Fatalf("Expected 4 dropped messages, got %d", dropped)
+	}
+	if first := req.Request.Contents[0]; first.Role != "user" || first.Parts[0].Text != "second" {
+		t.Errorf("Expected history to start at a user turn, got %+v", first)
+	}
+	if req.Request.SystemInstruction == nil {
+		t.Error("Expected system instruction to be kept")
+	}
+
+	// 单轮超预算时保持不变，交由上下文检查拒绝
+	single := &AntigravityRequest{}
+	single.Request.Contents = []Content{{Role: "user", Parts: []Part{{Text: long}}}}
+	if dropped := TruncateHistory(single, 10); dropped != 0 || len(single.Request.Contents) != 1 {
+		t.Errorf("Expected last turn to be kept, dropped %d", dropped)
+	}
+}
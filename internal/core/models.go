@@ -1,26 +1,86 @@
 package core
 
-import "strings"
+import (
+	"path"
+	"strings"
+)
 
 // Model 模型定义
 type Model struct {
 	ID      string `json:"id"`
 	OwnedBy string `json:"owned_by"`
 	Object  string `json:"object"`
+	Created int64  `json:"created"` // 模型发布时间（Unix 秒）
+	// 上下文窗口（输入 token 上限）与最大输出 token，0 表示未知
+	ContextWindow   int                `json:"context_window,omitempty"`
+	MaxOutputTokens int                `json:"max_output_tokens,omitempty"`
+	Capabilities    *ModelCapabilities `json:"capabilities,omitempty"`
+}
+
+// ModelCapabilities 模型能力
+type ModelCapabilities struct {
+	Vision   bool `json:"vision"`
+	Tools    bool `json:"tools"`
+	Thinking bool `json:"thinking"`
+}
+
+// 模型元数据
+var (
+	gemini3Pro = Model{
+		OwnedBy: "google", Object: "model", Created: 1763424000,
+		ContextWindow: 1048576, MaxOutputTokens: 65536,
+		Capabilities: &ModelCapabilities{Vision: true, Tools: true, Thinking: true},
+	}
+	claudeOpus45 = Model{
+		OwnedBy: "anthropic", Object: "model", Created: 1763942400,
+		ContextWindow: 200000, MaxOutputTokens: 64000,
+		Capabilities: &ModelCapabilities{Vision: true, Tools: true, Thinking: true},
+	}
+	claudeSonnet45 = Model{
+		OwnedBy: "anthropic", Object: "model", Created: 1759104000,
+		ContextWindow: 200000, MaxOutputTokens: 64000,
+		Capabilities: &ModelCapabilities{Vision: true, Tools: true},
+	}
+)
+
+// withModel 以模板元数据生成指定 ID 的模型，thinking 为 true 时标记支持思考
+func withModel(template Model, id string, thinking bool) Model {
+	m := template
+	m.ID = id
+	caps := *template.Capabilities
+	caps.Thinking = caps.Thinking || thinking
+	m.Capabilities = &caps
+	return m
 }
 
 // SupportedModels 支持的模型列表
 var SupportedModels = []Model{
 	// Gemini 系列
-	{ID: "gemini-3-pro-high", OwnedBy: "google", Object: "model"},
-	{ID: "gemini-3-pro-low", OwnedBy: "google", Object: "model"},
+	withModel(gemini3Pro, "gemini-3-pro-high", false),
+	withModel(gemini3Pro, "gemini-3-pro-low", false),
 	// Gemini Bypass 模式（非流式规避截断）
-	{ID: "gemini-3-pro-high-bypass", OwnedBy: "google", Object: "model"},
-	{ID: "gemini-3-pro-low-bypass", OwnedBy: "google", Object: "model"},
+	withModel(gemini3Pro, "gemini-3-pro-high-bypass", false),
+	withModel(gemini3Pro, "gemini-3-pro-low-bypass", false),
 	// Claude 系列
-	{ID: "claude-opus-4-5-thinking", OwnedBy: "anthropic", Object: "model"},
-	{ID: "claude-sonnet-4-5", OwnedBy: "anthropic", Object: "model"},
-	{ID: "claude-sonnet-4-5-thinking", OwnedBy: "anthropic", Object: "model"},
+	withModel(claudeOpus45, "claude-opus-4-5-thinking", true),
+	withModel(claudeSonnet45, "claude-sonnet-4-5", false),
+	withModel(claudeSonnet45, "claude-sonnet-4-5-thinking", true),
+	// 图片生成（上下文窗口未知，不做上下文长度检查）
+	{
+		ID: "gemini-3-pro-image", OwnedBy: "google", Object: "model", Created: 1763596800,
+		MaxOutputTokens: 32768,
+		Capabilities:    &ModelCapabilities{Vision: true, Thinking: true},
+	},
+}
+
+// FindModel 按 ID 查找支持的模型（包括隐藏的模型）
+func FindModel(id string) (Model, bool) {
+	for _, m := range SupportedModels {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Model{}, false
 }
 
 // ModelAliasMap 模型别名映射（bypass 模式）
@@ -38,6 +98,36 @@ var DefaultStopSequences = []string{
 	"<|end_of_turn|>",
 }
 
+// IsKnownModel 是否为支持的模型或 bypass 别名
+func IsKnownModel(id string) bool {
+	if _, ok := ModelAliasMap[id]; ok {
+		return true
+	}
+	_, ok := FindModel(id)
+	return ok
+}
+
+// IsModelHidden 模型是否被隐藏；hidden 中的每一项为模型名或通配符模式（如 *-bypass）
+func IsModelHidden(id string, hidden []string) bool {
+	for _, pattern := range hidden {
+		if matched, _ := path.Match(pattern, id); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// VisibleModels 返回未被隐藏的支持模型（仅影响模型列表，隐藏的模型仍可调用）
+func VisibleModels(hidden []string) []Model {
+	models := make([]Model, 0, len(SupportedModels))
+	for _, m := range SupportedModels {
+		if !IsModelHidden(m.ID, hidden) {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
 // ResolveModelName 解析真实模型名
 func ResolveModelName(modelName string) string {
 	if alias, ok := ModelAliasMap[modelName]; ok {
@@ -113,8 +203,45 @@ func BuildThinkingConfig(modelName string) *ThinkingConfig {
 	}
 }
 
-// GetClaudeMaxOutputTokens 获取 Claude 模型最大输出 Token
+// reasoningEffortBudgets reasoning_effort 对应的 thinkingBudget（按预算控制思考的模型）
+var reasoningEffortBudgets = map[string]int{
+	"minimal": 1024,
+	"low":     4096,
+	"medium":  12288,
+	"high":    24576,
+}
+
+// reasoningEffortLevels reasoning_effort 对应的 thinking_level（Gemini 3 Pro 仅支持 low / high）
+var reasoningEffortLevels = map[string]string{
+	"minimal": "low",
+	"low":     "low",
+	"medium":  "high",
+	"high":    "high",
+}
+
+// IsValidReasoningEffort 检查 reasoning_effort 取值（minimal / low / medium / high）
+func IsValidReasoningEffort(effort string) bool {
+	_, ok := reasoningEffortBudgets[effort]
+	return ok
+}
+
+// ApplyReasoningEffort 按 reasoning_effort 覆盖默认思考程度：
+// 使用 thinking_level 的模型映射为等级，其余模型映射为 thinkingBudget；未知取值保持默认
+func ApplyReasoningEffort(cfg *ThinkingConfig, effort string) {
+	if cfg == nil || !IsValidReasoningEffort(effort) {
+		return
+	}
+	if cfg.ThinkingLevel != "" {
+		cfg.ThinkingLevel = reasoningEffortLevels[effort]
+		return
+	}
+	cfg.ThinkingBudget = reasoningEffortBudgets[effort]
+}
+
+// GetClaudeMaxOutputTokens 获取 Claude 模型最大输出 Token（模型元数据未声明时为 64000）
 func GetClaudeMaxOutputTokens(modelName string) int {
-	// 统一返回 64000
+	if m, ok := FindModel(modelName); ok && m.MaxOutputTokens > 0 {
+		return m.MaxOutputTokens
+	}
 	return 64000
 }
@@ -0,0 +1,19 @@
+package core
+
+import "testing"
+
+func TestVisibleModels(t *testing.T) {
+	models := VisibleModels([]string{"*-bypass", "claude-opus-4-5-thinking"})
+	if len(models) != len(SupportedModels)-3 {
+		t.Fatalf("got %d models, want %d", len(models), len(SupportedModels)-3)
+	}
+	for _, m := range models {
+		if IsBypassModel(m.ID) || m.ID == "claude-opus-4-5-thinking" {
+			t.Errorf("hidden model %s listed", m.ID)
+		}
+	}
+
+	if got := VisibleModels(nil); len(got) != len(SupportedModels) {
+		t.Errorf("no hidden models: got %d, want %d", len(got), len(SupportedModels))
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 泄漏到正文中的思考标签
+var thinkingTags = [][2]string{
+	{"<thinking>", "</thinking>"},
+	{"<think>", "</think>"},
+}
+
+// 常见的默认停止序列残留
+var stopArtifacts = []string{"<|im_end|>", "<|endoftext|>", "<|eot_id|>", "<|end|>", "<end_of_turn>", "</s>"}
+
+var (
+	trailingSpacePattern = regexp.MustCompile(`[ \t]+\n`)
+	blankLinesPattern    = regexp.MustCompile(`\n{3,}`)
+)
+
+// TextReplacement 正则替换规则
+type TextReplacement struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// TextFilterOptions 输出过滤选项
+type TextFilterOptions struct {
+	StripThinking      bool // 移除正文中的 <thinking>/<think> 块
+	StopArtifacts      bool // 移除默认停止序列残留（如 <|im_end|>）
+	CollapseWhitespace bool // 移除行尾空白并将连续空行压缩为一个
+	Replacements       []TextReplacement
+	JSONValue          bool // JSON 模式：只保留第一个完整的 JSON 值，丢弃前后的说明文字与代码围栏
+}
+
+// TextFilter 输出文本过滤链：按顺序处理文本增量
+// 可能与后续增量组成完整匹配的尾部片段会暂存，待下一段或 Flush 时输出
+type TextFilter struct {
+	stages []*filterStage
+}
+
+// textStage 单个过滤器：返回可立即输出的部分与需等待后续文本的剩余部分
+type textStage interface {
+	process(text string, final bool) (out, rest string)
+}
+
+type filterStage struct {
+	stage   textStage
+	pending string
+}
+
+// NewTextFilter 按选项构建过滤链，未启用任何过滤时返回 nil
+func NewTextFilter(opts TextFilterOptions) *TextFilter {
+	f := &TextFilter{}
+	if opts.StripThinking {
+		f.add(&thinkingStage{})
+	}
+	if opts.StopArtifacts {
+		f.add(stopArtifactStage{})
+	}
+	if opts.CollapseWhitespace {
+		f.add(whitespaceStage{})
+	}
+	if len(opts.Replacements) > 0 {
+		f.add(replacementStage(opts.Replacements))
+	}
+	if opts.JSONValue {
+		f.add(&jsonValueStage{})
+	}
+	if len(f.stages) == 0 {
+		return nil
+	}
+	return f
+}
+
+func (f *TextFilter) add(s textStage) {
+	f.stages = append(f.stages, &filterStage{stage: s})
+}
+
+// Write 处理一段文本增量，返回可立即输出的文本
+func (f *TextFilter) Write(delta string) string {
+	return f.run(delta, false)
+}
+
+// Flush 输出全部暂存文本（流结束时调用）
+func (f *TextFilter) Flush() string {
+	return f.run("", true)
+}
+
+// Apply 处理完整文本
+func (f *TextFilter) Apply(text string) string {
+	return f.Write(text) + f.Flush()
+}
+
+func (f *TextFilter) run(text string, final bool) string {
+	for _, s := range f.stages {
+		text, s.pending = s.stage.process(s.pending+text, final)
+	}
+	return text
+}
+
+// partialSuffix 返回 text 末尾可能是任一 token 前缀的最长长度
+func partialSuffix(text string, tokens ...string) int {
+	longest := 0
+	for _, token := range tokens {
+		for n := min(len(token)-1, len(text)); n > longest; n-- {
+			if strings.HasSuffix(text, token[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// thinkingStage 移除思考标签块（标签内文本直接丢弃）
+type thinkingStage struct {
+	closing string // 非空表示处于标签块内，值为待匹配的结束标签
+}
+
+func (s *thinkingStage) process(text string, final bool) (string, string) {
+	var b strings.Builder
+	for {
+		if s.closing != "" {
+			i := strings.Index(text, s.closing)
+			if i < 0 {
+				if final {
+					return b.String(), ""
+				}
+				return b.String(), text[len(text)-partialSuffix(text, s.closing):]
+			}
+			text = text[i+len(s.closing):]
+			s.closing = ""
+			continue
+		}
+
+		start, tag := -1, -1
+		for j, pair := range thinkingTags {
+			if i := strings.Index(text, pair[0]); i >= 0 && (start < 0 || i < start) {
+				start, tag = i, j
+			}
+		}
+		if start < 0 {
+			keep := 0
+			if !final {
+				keep = partialSuffix(text, thinkingTags[0][0], thinkingTags[1][0])
+			}
+			b.WriteString(text[:len(text)-keep])
+			return b.String(), text[len(text)-keep:]
+		}
+		b.WriteString(text[:start])
+		text = text[start+len(thinkingTags[tag][0]):]
+		s.closing = thinkingTags[tag][1]
+	}
+}
+
+// stopArtifactStage 移除停止序列残留
+type stopArtifactStage struct{}
+
+func (stopArtifactStage) process(text string, final bool) (string, string) {
+	for _, token := range stopArtifacts {
+		text = strings.ReplaceAll(text, token, "")
+	}
+	if final {
+		return text, ""
+	}
+	keep := partialSuffix(text, stopArtifacts...)
+	return text[:len(text)-keep], text[len(text)-keep:]
+}
+
+// whitespaceStage 移除行尾空白并压缩连续空行，末尾空白暂存以便跨段合并
+type whitespaceStage struct{}
+
+func (whitespaceStage) process(text string, final bool) (string, string) {
+	rest := ""
+	if !final {
+		trimmed := strings.TrimRight(text, " \t\n")
+		text, rest = trimmed, text[len(trimmed):]
+	}
+	text = trailingSpacePattern.ReplaceAllString(text, "\n")
+	text = blankLinesPattern.ReplaceAllString(text, "\n\n")
+	return text, rest
+}
+
+// replacementStage 正则替换：流式输出时按整行处理，跨行的模式仅在非流式响应中生效
+type replacementStage []TextReplacement
+
+func (r replacementStage) process(text string, final bool) (string, string) {
+	rest := ""
+	if !final {
+		i := strings.LastIndexByte(text, '\n')
+		text, rest = text[:i+1], text[i+1:]
+	}
+	for _, rep := range r {
+		text = rep.Pattern.ReplaceAllString(text, rep.Replacement)
+	}
+	return text, rest
+}
+
+// jsonValueStage 提取第一个完整的 JSON 对象或数组：
+// 起始括号之前的文本（如 "```json" 围栏）暂存并丢弃，值结束后的文本（如结尾围栏）直接丢弃
+// 始终找不到起始括号时在结束时原样输出
+type jsonValueStage struct {
+	started  bool
+	done     bool
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func (s *jsonValueStage) process(text string, final bool) (string, string) {
+	if s.done {
+		return "", ""
+	}
+	if !s.started {
+		start := strings.IndexAny(text, "{[")
+		if start < 0 {
+			if final {
+				return text, ""
+			}
+			return "", text
+		}
+		text = text[start:]
+		s.started = true
+	}
+
+	// 分隔符均为 ASCII，按字节扫描不会误判多字节字符
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case s.inString:
+			if c == '\\' {
+				s.escaped = true
+			} else if c == '"' {
+				s.inString = false
+			}
+		case c == '"':
+			s.inString = true
+		case c == '{' || c == '[':
+			s.depth++
+		case c == '}' || c == ']':
+			s.depth--
+			if s.depth == 0 {
+				s.done = true
+				return text[:i+1], ""
+			}
+		}
+	}
+	return text, ""
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTextFilter(t *testing.T) {
+	all := TextFilterOptions{
+		StripThinking:      true,
+		StopArtifacts:      true,
+		CollapseWhitespace: true,
+		Replacements:       []TextReplacement{{Pattern: regexp.MustCompile(`(?i)as an ai model,?\s*`), Replacement: ""}},
+	}
+
+	tests := []struct {
+		name   string
+		opts   TextFilterOptions
+		deltas []string
+		want   string
+	}{
+		{"thinking split across deltas", TextFilterOptions{StripThinking: true},
+			[]string{"Hi <thi", "nking>secret</thin", "king> there", " <think>x</think>!"}, "Hi  there !"},
+		{"unterminated thinking dropped", TextFilterOptions{StripThinking: true},
+			[]string{"ok <thinking>never closed"}, "ok "},
+		{"partial tag released at end", TextFilterOptions{StripThinking: true},
+			[]string{"a <thi"}, "a <thi"},
+		{"stop artifacts", TextFilterOptions{StopArtifacts: true},
+			[]string{"done<|im_", "end|> really</s>"}, "done really"},
+		{"whitespace", TextFilterOptions{CollapseWhitespace: true},
+			[]string{"a  \n\n", "\n\nb\t\n", "  c"}, "a\n\nb\n  c"},
+		{"replacement per line", TextFilterOptions{Replacements: all.Replacements},
+			[]string{"As an AI ", "model, I can\nhelp"}, "I can\nhelp"},
+		{"chain", all,
+			[]string{"<think>plan</think>As an AI model, ", "yes\n\n\n\nno<|eot_id|>"}, "yes\n\nno"},
+		{"json fence stripped", TextFilterOptions{JSONValue: true},
+			[]string{"```js", "on\n{\"a\": [1, ", "{\"b\": \"}]\\\"\"}]}\n``", "`\n"}, "{\"a\": [1, {\"b\": \"}]\\\"\"}]}"},
+		{"json preamble dropped", TextFilterOptions{JSONValue: true},
+			[]string{"Here is the result:\n", "[1, 2]", " Hope this helps!"}, "[1, 2]"},
+		{"non-json passed through", TextFilterOptions{JSONValue: true},
+			[]string{"no json ", "here"}, "no json here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewTextFilter(tt.opts)
+			got := ""
+			for _, d := range tt.deltas {
+				got += f.Write(d)
+			}
+			got += f.Flush()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if NewTextFilter(TextFilterOptions{}) != nil {
+		t.Error("empty options should return nil filter")
+	}
+}
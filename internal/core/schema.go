@@ -0,0 +1,204 @@
+package core
+
+// CleanSchemaForVertexAI 递归清理 Vertex AI 不支持的 JSON Schema 字段
+// 同时将 exclusiveMinimum/exclusiveMaximum 转换为 minimum/maximum
+func CleanSchemaForVertexAI(schema map[string]interface{}) {
+	if schema == nil {
+		return
+	}
+
+	// 将 exclusiveMinimum 转换为 minimum（+1）
+	if exMin, ok := schema["exclusiveMinimum"].(float64); ok {
+		if _, hasMin := schema["minimum"]; !hasMin {
+			schema["minimum"] = exMin + 1
+		}
+		delete(schema, "exclusiveMinimum")
+	}
+
+	// 将 exclusiveMaximum 转换为 maximum（-1）
+	if exMax, ok := schema["exclusiveMaximum"].(float64); ok {
+		if _, hasMax := schema["maximum"]; !hasMax {
+			schema["maximum"] = exMax - 1
+		}
+		delete(schema, "exclusiveMaximum")
+	}
+
+	// 移除 Vertex AI 不支持的字段
+	unsupportedFields := []string{
+		"$schema",
+		"$ref",
+		"$id",
+		"$defs",
+		"definitions",
+		"minItems",
+		"maxItems",
+		"uniqueItems",
+		"pattern",
+		"additionalProperties",
+		"patternProperties",
+		"dependencies",
+		"if",
+		"then",
+		"else",
+		"allOf",
+		"anyOf",
+		"oneOf",
+		"not",
+		"contentMediaType",
+		"contentEncoding",
+		"examples",
+		"default",
+		"const",
+		"minLength",
+		"maxLength",
+		"format",
+	}
+	for _, field := range unsupportedFields {
+		delete(schema, field)
+	}
+
+	// 递归处理 properties
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for _, propValue := range props {
+			if propSchema, ok := propValue.(map[string]interface{}); ok {
+				CleanSchemaForVertexAI(propSchema)
+			}
+		}
+	}
+
+	// 递归处理 items（数组类型）
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		CleanSchemaForVertexAI(items)
+	}
+
+	// 递归处理 items 数组形式
+	if itemsArr, ok := schema["items"].([]interface{}); ok {
+		for _, item := range itemsArr {
+			if itemSchema, ok := item.(map[string]interface{}); ok {
+				CleanSchemaForVertexAI(itemSchema)
+			}
+		}
+	}
+}
+
+// DeepCopyMap 深拷贝 map 以避免修改原始数据
+func DeepCopyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			result[k] = DeepCopyMap(val)
+		case []interface{}:
+			result[k] = deepCopySlice(val)
+		default:
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// deepCopySlice 深拷贝 slice
+func deepCopySlice(s []interface{}) []interface{} {
+	if s == nil {
+		return nil
+	}
+	result := make([]interface{}, len(s))
+	for i, v := range s {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			result[i] = DeepCopyMap(val)
+		case []interface{}:
+			result[i] = deepCopySlice(val)
+		default:
+			result[i] = v
+		}
+	}
+	return result
+}
+
+// maxSchemaRefDepth 内联 $ref 的最大嵌套深度（递归 schema 超出后保留原引用，由清理移除）
+const maxSchemaRefDepth = 8
+
+// ResponseSchema 将 JSON Schema 转换为 Vertex responseSchema：内联本地 $ref（$defs / definitions），
+// 将 ["T","null"] 类型数组与 anyOf [T, null] 转为 nullable，再清理不支持的字段；不修改原始 schema
+func ResponseSchema(schema map[string]interface{}) map[string]interface{} {
+	result := DeepCopyMap(schema)
+	defs := make(map[string]interface{})
+	for _, key := range []string{"$defs", "definitions"} {
+		if d, ok := result[key].(map[string]interface{}); ok {
+			for name, def := range d {
+				defs["#/"+key+"/"+name] = def
+			}
+		}
+	}
+
+	result, _ = inlineSchemaRefs(result, defs, 0).(map[string]interface{})
+	CleanSchemaForVertexAI(result)
+	return result
+}
+
+// inlineSchemaRefs 递归内联本地引用，并规整可空类型
+func inlineSchemaRefs(node interface{}, defs map[string]interface{}, depth int) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok && depth < maxSchemaRefDepth {
+			if def, ok := defs[ref].(map[string]interface{}); ok {
+				return inlineSchemaRefs(DeepCopyMap(def), defs, depth+1)
+			}
+		}
+		for key, value := range n {
+			if key == "$defs" || key == "definitions" {
+				continue
+			}
+			n[key] = inlineSchemaRefs(value, defs, depth)
+		}
+		normalizeNullable(n)
+		return n
+	case []interface{}:
+		for i, item := range n {
+			n[i] = inlineSchemaRefs(item, defs, depth)
+		}
+		return n
+	default:
+		return node
+	}
+}
+
+// normalizeNullable 将 type: ["T","null"] 与 anyOf: [T, {"type":"null"}] 转为 Vertex 的 nullable 写法
+func normalizeNullable(schema map[string]interface{}) {
+	if types, ok := schema["type"].([]interface{}); ok {
+		var nonNull []interface{}
+		for _, t := range types {
+			if t == "null" {
+				schema["nullable"] = true
+			} else {
+				nonNull = append(nonNull, t)
+			}
+		}
+		if len(nonNull) > 0 {
+			schema["type"] = nonNull[0]
+		}
+	}
+
+	anyOf, ok := schema["anyOf"].([]interface{})
+	if !ok || len(anyOf) != 2 {
+		return
+	}
+	for i, option := range anyOf {
+		if opt, ok := option.(map[string]interface{}); ok && opt["type"] == "null" {
+			if other, ok := anyOf[1-i].(map[string]interface{}); ok {
+				for key, value := range other {
+					if _, exists := schema[key]; !exists {
+						schema[key] = value
+					}
+				}
+				schema["nullable"] = true
+				delete(schema, "anyOf")
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResponseSchema(t *testing.T) {
+	var schema map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": ["string", "null"]},
+			"address": {"anyOf": [{"$ref": "#/$defs/address"}, {"type": "null"}]},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+		},
+		"required": ["name", "address", "tags"],
+		"$defs": {
+			"address": {"type": "object", "properties": {"city": {"type": "string"}}, "additionalProperties": false},
+			"tag": {"type": "string", "maxLength": 20}
+		}
+	}`), &schema)
+
+	got := ResponseSchema(schema)
+	data, _ := json.Marshal(got)
+	want := `{"properties":{"address":{"nullable":true,"properties":{"city":{"type":"string"}},"type":"object"},"name":{"nullable":true,"type":"string"},"tags":{"items":{"type":"string"},"type":"array"}},"required":["name","address","tags"],"type":"object"}`
+	if string(data) != want {
+		t.Errorf("schema =\n%s\nwant\n%s", data, want)
+	}
+
+	// 原始 schema 保持不变
+	if _, ok := schema["$defs"]; !ok {
+		t.Error("original schema modified")
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import "encoding/json"
+
+// ImageTokenEstimate 单张内联图片的估算 token 数（Gemini 按 258 token 计）
+const ImageTokenEstimate = 258
+
+// ModelContextWindows 模型上下文窗口（输入 token 上限），由 SupportedModels 生成
+var ModelContextWindows = contextWindows()
+
+func contextWindows() map[string]int {
+	windows := make(map[string]int)
+	for _, m := range SupportedModels {
+		if m.ContextWindow > 0 {
+			windows[m.ID] = m.ContextWindow
+		}
+	}
+	return windows
+}
+
+// GetContextWindow 获取模型上下文窗口，未知模型返回 0
+func GetContextWindow(modelName string) int {
+	return ModelContextWindows[ResolveModelName(modelName)]
+}
+
+// EstimateTokens 估算文本 token 数量（每 4 个字节约 1 个 token）
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	count := len(text) / 4
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// EstimateRequestTokens 估算 Antigravity 请求的输入 token 数量
+func EstimateRequestTokens(req *AntigravityRequest) int {
+	total := 0
+	if si := req.Request.SystemInstruction; si != nil {
+		total += EstimatePartsTokens(si.Parts)
+	}
+	for _, content := range req.Request.Contents {
+		total += EstimatePartsTokens(content.Parts)
+	}
+	if len(req.Request.Tools) > 0 {
+		toolsJSON, _ := json.Marshal(req.Request.Tools)
+		total += EstimateTokens(string(toolsJSON))
+	}
+	return total
+}
+
+// EstimatePartsTokens 估算 parts 的 token 数量
+func EstimatePartsTokens(parts []Part) int {
+	total := 0
+	for _, part := range parts {
+		total += EstimateTokens(part.Text)
+		if part.FunctionCall != nil {
+			argsJSON, _ := json.Marshal(part.FunctionCall.Args)
+			total += EstimateTokens(part.FunctionCall.Name + string(argsJSON))
+		}
+		if part.FunctionResponse != nil {
+			respJSON, _ := json.Marshal(part.FunctionResponse.Response)
+			total += EstimateTokens(part.FunctionResponse.Name + string(respJSON))
+		}
+		if part.InlineData != nil {
+			total += ImageTokenEstimate
+		}
+	}
+	return total
+}
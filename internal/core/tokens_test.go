@@ -0,0 +1,32 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateRequestTokens(t *testing.T) {
+	req := &AntigravityRequest{}
+	req.Request.SystemInstruction = &SystemInstruction{Parts: []Part{{Text: strings.Repeat("a", 40)}}}
+	req.Request.Contents = []Content{
+		{Role: "user", Parts: []Part{
+			{Text: strings.Repeat("b", 400)},
+			{InlineData: &InlineData{MimeType: "image/png", Data: strings.Repeat("x", 100000)}},
+		}},
+		{Role: "model", Parts: []Part{{FunctionCall: &FunctionCall{Name: "f", Args: map[string]interface{}{"k": "v"}}}}},
+	}
+
+	// 10 (system) + 100 (text) + 258 (image) + len(`f{"k":"v"}`)/4 = 2
+	if got := EstimateRequestTokens(req); got != 370 {
+		t.Errorf("Expected 370 tokens, got %d", got)
+	}
+}
+
+func TestGetContextWindow(t *testing.T) {
+	if got := GetContextWindow("gemini-3-pro-high-bypass"); got != 1048576 {
+		t.Errorf("Expected alias to resolve to gemini-3-pro-high window, got %d", got)
+	}
+	if got := GetContextWindow("unknown-model"); got != 0 {
+		t.Errorf("Expected 0 for unknown model, got %d", got)
+	}
+}
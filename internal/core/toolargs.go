@@ -0,0 +1,171 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FindFunctionDeclaration 按名称查找请求中声明的函数
+func FindFunctionDeclaration(tools []Tool, name string) *FunctionDeclaration {
+	for i := range tools {
+		for j := range tools[i].FunctionDeclarations {
+			if tools[i].FunctionDeclarations[j].Name == name {
+				return &tools[i].FunctionDeclarations[j]
+			}
+		}
+	}
+	return nil
+}
+
+// RepairToolArgs 按函数声明的参数 schema 修复工具调用参数
+// 仅做保守修复：类型强转（如 "3" → 3、"true" → true、JSON 字符串 → 对象/数组）与缺失必填字段补默认值
+// 返回修复后的新参数（不修改原参数）及是否发生修改
+func RepairToolArgs(schema map[string]interface{}, args map[string]interface{}) (map[string]interface{}, bool) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	repaired, _ := repairValue(schema, args).(map[string]interface{})
+	if repaired == nil {
+		return args, false
+	}
+	return repaired, !reflect.DeepEqual(args, repaired)
+}
+
+// schemaType 返回 schema 的类型（小写；联合类型取第一个非 null 类型）
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return strings.ToLower(t)
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && strings.ToLower(s) != "null" {
+				return strings.ToLower(s)
+			}
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+// repairValue 将值按 schema 强转，无法强转时原样返回
+func repairValue(schema map[string]interface{}, value interface{}) interface{} {
+	if schema == nil || value == nil {
+		return value
+	}
+
+	switch schemaType(schema) {
+	case "string":
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		case map[string]interface{}, []interface{}:
+			data, _ := json.Marshal(v)
+			return string(data)
+		}
+
+	case "integer", "number":
+		if s, ok := value.(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return n
+			}
+		}
+
+	case "boolean":
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return b
+			}
+		}
+
+	case "array":
+		if s, ok := value.(string); ok {
+			var parsed []interface{}
+			if json.Unmarshal([]byte(s), &parsed) == nil {
+				value = parsed
+			}
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		itemSchema, _ := schema["items"].(map[string]interface{})
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = repairValue(itemSchema, item)
+		}
+		return result
+
+	case "object":
+		if s, ok := value.(string); ok {
+			var parsed map[string]interface{}
+			if json.Unmarshal([]byte(s), &parsed) == nil {
+				value = parsed
+			}
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		return repairObject(schema, obj)
+	}
+	return value
+}
+
+// repairObject 修复对象的各属性并补齐缺失的必填字段
+func repairObject(schema map[string]interface{}, obj map[string]interface{}) map[string]interface{} {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	result := make(map[string]interface{}, len(obj))
+	for key, val := range obj {
+		propSchema, _ := properties[key].(map[string]interface{})
+		result[key] = repairValue(propSchema, val)
+	}
+
+	required, _ := schema["required"].([]interface{})
+	for _, item := range required {
+		key, ok := item.(string)
+		if !ok {
+			continue
+		}
+		if _, exists := result[key]; exists {
+			continue
+		}
+		propSchema, _ := properties[key].(map[string]interface{})
+		if def, ok := defaultValue(propSchema); ok {
+			result[key] = def
+		}
+	}
+	return result
+}
+
+// defaultValue 缺失必填字段的默认值：优先 schema default，其次 enum 首项，最后为类型零值
+func defaultValue(schema map[string]interface{}) (interface{}, bool) {
+	if schema == nil {
+		return nil, false
+	}
+	if def, ok := schema["default"]; ok {
+		return def, true
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0], true
+	}
+	switch schemaType(schema) {
+	case "string":
+		return "", true
+	case "integer", "number":
+		return float64(0), true
+	case "boolean":
+		return false, true
+	case "array":
+		return []interface{}{}, true
+	case "object":
+		return repairObject(schema, map[string]interface{}{}), true
+	}
+	return nil, false
+}
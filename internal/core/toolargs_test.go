@@ -0,0 +1,64 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRepairToolArgs(t *testing.T) {
+	var schema map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"city":    {"type": "string"},
+			"days":    {"type": "integer"},
+			"metric":  {"type": "boolean"},
+			"tags":    {"type": "array", "items": {"type": "string"}},
+			"filter":  {"type": "object", "properties": {"limit": {"type": "number"}}},
+			"unit":    {"type": "string", "enum": ["celsius", "fahrenheit"]},
+			"verbose": {"type": "boolean", "default": true}
+		},
+		"required": ["city", "unit", "verbose"]
+	}`), &schema)
+
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		changed bool
+	}{
+		{"valid", `{"city":"London","unit":"celsius","verbose":false}`, `{"city":"London","unit":"celsius","verbose":false}`, false},
+		{"coerce scalars", `{"city":42,"days":"3","metric":"true","unit":"celsius","verbose":false}`, `{"city":"42","days":3,"metric":true,"unit":"celsius","verbose":false}`, true},
+		{"json strings", `{"city":"x","tags":"[\"a\",1]","filter":"{\"limit\":\"5\"}","unit":"celsius","verbose":true}`, `{"city":"x","filter":{"limit":5},"tags":["a","1"],"unit":"celsius","verbose":true}`, true},
+		{"wrap array", `{"city":"x","tags":"solo","unit":"celsius","verbose":true}`, `{"city":"x","tags":["solo"],"unit":"celsius","verbose":true}`, true},
+		{"missing required", `{}`, `{"city":"","unit":"celsius","verbose":true}`, true},
+		{"uncoercible kept", `{"city":"x","days":"soon","unit":"celsius","verbose":true}`, `{"city":"x","days":"soon","unit":"celsius","verbose":true}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args map[string]interface{}
+			json.Unmarshal([]byte(tt.args), &args)
+			before, _ := json.Marshal(args)
+
+			got, changed := RepairToolArgs(schema, args)
+			gotJSON, _ := json.Marshal(got)
+			if string(gotJSON) != tt.want {
+				t.Errorf("got %s, want %s", gotJSON, tt.want)
+			}
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if after, _ := json.Marshal(args); string(after) != string(before) {
+				t.Errorf("original args modified: %s", after)
+			}
+		})
+	}
+}
+
+func TestRepairToolArgsNil(t *testing.T) {
+	got, changed := RepairToolArgs(map[string]interface{}{"type": "object"}, nil)
+	if got == nil || changed {
+		t.Errorf("got %v, changed %v", got, changed)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolLoop 对话末尾连续重复的工具调用
+type ToolLoop struct {
+	Name  string // 工具名（一轮调用多个工具时为逗号分隔的名称）
+	Count int    // 连续重复的轮次数
+}
+
+// DetectToolLoop 统计对话末尾以完全相同的工具与参数连续调用的轮次数
+// 只统计最近一条真实 user 消息之后的模型轮次：用户重新输入视为打断循环；
+// 中间出现不含工具调用的模型回复同样打断
+func DetectToolLoop(contents []Content) ToolLoop {
+	var loop ToolLoop
+	last := ""
+	for i := len(contents) - 1; i >= 0; i-- {
+		content := contents[i]
+		if content.Role != "model" {
+			if !isFunctionResponseTurn(content) {
+				break
+			}
+			continue
+		}
+
+		name, key := toolCallKey(content.Parts)
+		if key == "" || (last != "" && key != last) {
+			break
+		}
+		last = key
+		loop.Name = name
+		loop.Count++
+	}
+	return loop
+}
+
+// toolCallKey 返回一轮模型回复中全部工具调用的名称与比较键（名称 + 规范化参数），无工具调用时返回空
+// 参数经 json.Marshal 规范化（map 按键排序），调用 ID 与签名不参与比较
+func toolCallKey(parts []Part) (string, string) {
+	names, key := "", ""
+	for _, part := range parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		args, _ := json.Marshal(part.FunctionCall.Args)
+		if names != "" {
+			names += ","
+		}
+		names += part.FunctionCall.Name
+		key += part.FunctionCall.Name + string(args) + "\n"
+	}
+	return names, key
+}
+
+// InjectToolLoopNudge 在 system 指令末尾追加提示，要求模型停止重复调用并换用其他方法
+func InjectToolLoopNudge(req *AntigravityRequest, loop ToolLoop) {
+	nudge := fmt.Sprintf("You have called the tool %q with identical arguments %d times in a row and received the same kind of result. "+
+		"Do not repeat this call again. Use the results you already have, try a different approach or different arguments, "+
+		"or explain to the user what is blocking you.", loop.Name, loop.Count)
+
+	if req.Request.SystemInstruction == nil {
+		req.Request.SystemInstruction = &SystemInstruction{}
+	}
+	req.Request.SystemInstruction.Parts = append(req.Request.SystemInstruction.Parts, Part{Text: nudge})
+}
@@ -0,0 +1,42 @@
+package core
+
+import "testing"
+
+func TestDetectToolLoop(t *testing.T) {
+	call := func(id string, args map[string]interface{}) Content {
+		return Content{Role: "model", Parts: []Part{{FunctionCall: &FunctionCall{ID: id, Name: "read_file", Args: args}}}}
+	}
+	result := func(id string) Content {
+		return Content{Role: "user", Parts: []Part{{FunctionResponse: &FunctionResponse{ID: id, Name: "read_file"}}}}
+	}
+	same := map[string]interface{}{"path": "a.go", "lines": []interface{}{1.0, 2.0}}
+
+	contents := []Content{
+		{Role: "user", Parts: []Part{{Text: "fix the bug"}}},
+		call("c0", map[string]interface{}{"path": "b.go"}), result("c0"),
+		call("c1", same), result("c1"),
+		call("c2", map[string]interface{}{"lines": []interface{}{1.0, 2.0}, "path": "a.go"}), result("c2"),
+		call("c3", same), result("c3"),
+	}
+	if loop := DetectToolLoop(contents); loop.Name != "read_file" || loop.Count != 3 {
+		t.Errorf("expected 3 identical read_file calls, got %+v", loop)
+	}
+
+	// 用户重新输入打断循环
+	interrupted := append(append([]Content{}, contents[:5]...), Content{Role: "user", Parts: []Part{{Text: "try again"}}})
+	interrupted = append(interrupted, call("c4", same), result("c4"))
+	if loop := DetectToolLoop(interrupted); loop.Count != 1 {
+		t.Errorf("user message should reset the loop, got %+v", loop)
+	}
+
+	if loop := DetectToolLoop(contents[:1]); loop.Count != 0 {
+		t.Errorf("expected no loop, got %+v", loop)
+	}
+
+	req := &AntigravityRequest{}
+	req.Request.Contents = contents
+	InjectToolLoopNudge(req, DetectToolLoop(contents))
+	if si := req.Request.SystemInstruction; si == nil || len(si.Parts) != 1 || si.Parts[0].Text == "" {
+		t.Errorf("expected nudge in system instruction, got %+v", si)
+	}
+}
@@ -88,13 +88,31 @@ type FunctionCallingConfig struct {
 
 // GenerationConfig 生成配置
 type GenerationConfig struct {
-	CandidateCount  int             `json:"candidateCount,omitempty"`
-	StopSequences   []string        `json:"stopSequences,omitempty"`
-	MaxOutputTokens int             `json:"maxOutputTokens,omitempty"`
-	Temperature     *float64        `json:"temperature,omitempty"`
-	TopP            *float64        `json:"topP,omitempty"`
-	TopK            int             `json:"topK,omitempty"`
-	ThinkingConfig  *ThinkingConfig `json:"thinkingConfig,omitempty"`
+	CandidateCount   int             `json:"candidateCount,omitempty"`
+	StopSequences    []string        `json:"stopSequences,omitempty"`
+	MaxOutputTokens  int             `json:"maxOutputTokens,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"topP,omitempty"`
+	TopK             int             `json:"topK,omitempty"`
+	Seed             *int            `json:"seed,omitempty"`
+	PresencePenalty  *float64        `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequencyPenalty,omitempty"`
+	ThinkingConfig   *ThinkingConfig `json:"thinkingConfig,omitempty"`
+	// 返回所选 token 的对数概率，Logprobs 为每个位置返回的候选数
+	ResponseLogprobs bool `json:"responseLogprobs,omitempty"`
+	Logprobs         int  `json:"logprobs,omitempty"`
+	// 结构化输出：application/json 与可选的 responseSchema
+	ResponseMimeType string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]interface{} `json:"responseSchema,omitempty"`
+	// 图片生成：输出模态（如 TEXT、IMAGE）与图片尺寸
+	ResponseModalities []string     `json:"responseModalities,omitempty"`
+	ImageConfig        *ImageConfig `json:"imageConfig,omitempty"`
+}
+
+// ImageConfig 图片生成配置
+type ImageConfig struct {
+	AspectRatio string `json:"aspectRatio,omitempty"` // 如 1:1、16:9
+	ImageSize   string `json:"imageSize,omitempty"`   // 1K、2K、4K
 }
 
 // ThinkingConfig 思考配置
@@ -109,16 +127,51 @@ type ThinkingConfig struct {
 // AntigravityResponse Antigravity 响应
 type AntigravityResponse struct {
 	Response struct {
-		Candidates    []Candidate    `json:"candidates"`
-		UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+		Candidates     []Candidate     `json:"candidates"`
+		PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+		UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
 	} `json:"response"`
 }
 
+// PromptFeedback 提示词反馈，提示词被拦截时上游不返回任何候选
+type PromptFeedback struct {
+	BlockReason        string `json:"blockReason,omitempty"`
+	BlockReasonMessage string `json:"blockReasonMessage,omitempty"`
+}
+
+// NoCandidatesFinishReason 上游未返回任何候选（如提示词被完全拦截）时视为安全拦截
+const NoCandidatesFinishReason = "SAFETY"
+
+// Blocked 提示词是否被上游拦截
+func (f *PromptFeedback) Blocked() bool {
+	return f != nil && f.BlockReason != ""
+}
+
 // Candidate 候选响应
 type Candidate struct {
-	Content      Content `json:"content"`
-	FinishReason string  `json:"finishReason,omitempty"`
-	Index        int     `json:"index"`
+	Content        Content         `json:"content"`
+	FinishReason   string          `json:"finishReason,omitempty"`
+	Index          int             `json:"index"`
+	AvgLogprobs    float64         `json:"avgLogprobs,omitempty"`
+	LogprobsResult *LogprobsResult `json:"logprobsResult,omitempty"`
+}
+
+// LogprobsResult token 对数概率（请求 responseLogprobs 时返回）
+type LogprobsResult struct {
+	TopCandidates    []TopLogprobCandidates `json:"topCandidates,omitempty"`    // 每个位置的候选 token
+	ChosenCandidates []LogprobCandidate     `json:"chosenCandidates,omitempty"` // 每个位置实际选择的 token
+}
+
+// TopLogprobCandidates 单个位置的候选 token（按概率降序）
+type TopLogprobCandidates struct {
+	Candidates []LogprobCandidate `json:"candidates"`
+}
+
+// LogprobCandidate token 及其对数概率
+type LogprobCandidate struct {
+	Token          string  `json:"token"`
+	TokenID        int     `json:"tokenId,omitempty"`
+	LogProbability float64 `json:"logProbability"`
 }
 
 // UsageMetadata 使用统计
@@ -0,0 +1,266 @@
+// Package history 长对话压缩：用模型生成的摘要替换早期对话轮次
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
+)
+
+// SummaryPrefix 摘要文本前缀（插入到保留的第一条 user 消息之前）
+const SummaryPrefix = "[Summary of earlier conversation]\n"
+
+// maxCacheEntries 摘要缓存上限
+const maxCacheEntries = 256
+
+const summaryInstruction = `You compress conversation transcripts for an AI assistant.
+Write a concise summary of the transcript below that preserves every fact, decision, constraint, file name, identifier and tool result the assistant may still need.
+Do not add commentary. Write in the same language as the conversation.`
+
+// SendFunc 发送非流式上游请求
+type SendFunc func(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error)
+
+// Summarizer 对话摘要器
+// 摘要按「对话前缀哈希」缓存；未命中时在后台生成，供后续重复请求复用
+type Summarizer struct {
+	mu       sync.Mutex
+	cache    map[string]string
+	order    []string
+	inflight map[string]bool
+
+	model string
+	send  SendFunc
+}
+
+var (
+	summarizer     *Summarizer
+	summarizerOnce sync.Once
+)
+
+// Get 获取全局摘要器
+func Get() *Summarizer {
+	summarizerOnce.Do(func() {
+		summarizer = New(config.Get().HistorySummaryModel, func(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error) {
+			return vertex.GetClient().SendRequest(ctx, req, token)
+		})
+	})
+	return summarizer
+}
+
+// New 创建摘要器
+func New(model string, send SendFunc) *Summarizer {
+	return &Summarizer{
+		cache:    make(map[string]string),
+		inflight: make(map[string]bool),
+		model:    model,
+		send:     send,
+	}
+}
+
+// Compress 请求超出预算时，用缓存中最长的前缀摘要替换早期轮次
+// 若压缩后仍超出预算，则在后台为更长的前缀生成摘要（本次请求不等待）
+// 返回被摘要替换的消息数
+func (s *Summarizer) Compress(req *core.AntigravityRequest, budget int, token *store.Account) int {
+	if budget <= 0 || core.EstimateRequestTokens(req) <= budget {
+		return 0
+	}
+
+	contents := req.Request.Contents
+	starts := core.TurnStarts(contents)
+	if len(starts) == 0 {
+		return 0
+	}
+	hashes := prefixHashes(contents, starts)
+
+	// 查找缓存中最长的已摘要前缀
+	base, baseSummary := 0, ""
+	s.mu.Lock()
+	for i := len(starts) - 1; i >= 0; i-- {
+		if summary, ok := s.cache[hashes[i]]; ok {
+			base, baseSummary = starts[i], summary
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if base > 0 {
+		req.Request.Contents = applySummary(contents, base, baseSummary)
+		if core.EstimateRequestTokens(req) <= budget {
+			return base
+		}
+	}
+
+	// 选择目标前缀：保留的尾部不超过预算的一半，为后续增长留出余量
+	target := -1
+	for i, start := range starts {
+		if start <= base {
+			continue
+		}
+		tail := 0
+		for _, content := range contents[start:] {
+			tail += core.EstimatePartsTokens(content.Parts)
+		}
+		target = i
+		if tail <= budget/2 {
+			break
+		}
+	}
+	if target >= 0 {
+		s.summarizeAsync(hashes[target], baseSummary, contents[base:starts[target]], token)
+	}
+	return base
+}
+
+// summarizeAsync 后台生成前缀摘要（同一前缀只生成一次）
+func (s *Summarizer) summarizeAsync(hash, baseSummary string, turns []core.Content, token *store.Account) {
+	s.mu.Lock()
+	if s.inflight[hash] {
+		s.mu.Unlock()
+		return
+	}
+	if _, ok := s.cache[hash]; ok {
+		s.mu.Unlock()
+		return
+	}
+	s.inflight[hash] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, hash)
+			s.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Get().Timeout)*time.Millisecond)
+		defer cancel()
+
+		summary, err := s.summarize(ctx, baseSummary, turns, token)
+		if err != nil {
+			logger.Warn("History summarization failed: %v", err)
+			return
+		}
+		s.store(hash, summary)
+		logger.Info("History summarized: %d messages compressed", len(turns))
+	}()
+}
+
+// summarize 调用模型生成摘要
+func (s *Summarizer) summarize(ctx context.Context, baseSummary string, turns []core.Content, token *store.Account) (string, error) {
+	var transcript strings.Builder
+	if baseSummary != "" {
+		transcript.WriteString(SummaryPrefix)
+		transcript.WriteString(baseSummary)
+		transcript.WriteString("\n\n")
+	}
+	writeTranscript(&transcript, turns)
+
+	req := &core.AntigravityRequest{
+		Project:   token.ProjectID,
+		RequestID: utils.GenerateRequestID(),
+		Request: core.AntigravityInnerReq{
+			SystemInstruction: &core.SystemInstruction{Parts: []core.Part{{Text: summaryInstruction}}},
+			Contents:          []core.Content{{Role: "user", Parts: []core.Part{{Text: transcript.String()}}}},
+			GenerationConfig:  &core.GenerationConfig{CandidateCount: 1},
+			SessionID:         token.SessionID,
+		},
+		Model:     core.ResolveModelName(s.model),
+		UserAgent: config.Get().UserAgent,
+	}
+
+	resp, err := s.send(ctx, req, token)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Response.Candidates) == 0 {
+		return "", fmt.Errorf("empty summary response")
+	}
+
+	var summary strings.Builder
+	for _, part := range resp.Response.Candidates[0].Content.Parts {
+		if !part.Thought {
+			summary.WriteString(part.Text)
+		}
+	}
+	if summary.Len() == 0 {
+		return "", fmt.Errorf("empty summary response")
+	}
+	return summary.String(), nil
+}
+
+// store 写入缓存，超出上限时淘汰最早的条目
+func (s *Summarizer) store(hash, summary string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cache[hash]; !ok {
+		s.order = append(s.order, hash)
+	}
+	s.cache[hash] = summary
+
+	for len(s.order) > maxCacheEntries {
+		delete(s.cache, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// prefixHashes 计算每个切分点之前对话内容的哈希
+func prefixHashes(contents []core.Content, starts []int) []string {
+	hashes := make([]string, len(starts))
+	h := sha256.New()
+	next := 0
+	for i, content := range contents {
+		if next < len(starts) && starts[next] == i {
+			hashes[next] = hex.EncodeToString(h.Sum(nil))
+			next++
+		}
+		data, _ := json.Marshal(content)
+		h.Write(data)
+	}
+	return hashes
+}
+
+// applySummary 丢弃 [0, base) 的消息，并将摘要插入保留的第一条消息
+func applySummary(contents []core.Content, base int, summary string) []core.Content {
+	kept := make([]core.Content, len(contents)-base)
+	copy(kept, contents[base:])
+
+	first := kept[0]
+	first.Parts = append([]core.Part{{Text: SummaryPrefix + summary}}, first.Parts...)
+	kept[0] = first
+	return kept
+}
+
+// writeTranscript 将对话轮次转为纯文本记录
+func writeTranscript(b *strings.Builder, turns []core.Content) {
+	for _, content := range turns {
+		for _, part := range content.Parts {
+			switch {
+			case part.Thought:
+				continue
+			case part.FunctionCall != nil:
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				fmt.Fprintf(b, "%s: [tool call] %s(%s)\n", content.Role, part.FunctionCall.Name, args)
+			case part.FunctionResponse != nil:
+				resp, _ := json.Marshal(part.FunctionResponse.Response)
+				fmt.Fprintf(b, "%s: [tool result] %s: %s\n", content.Role, part.FunctionResponse.Name, resp)
+			case part.InlineData != nil:
+				fmt.Fprintf(b, "%s: [%s attachment]\n", content.Role, part.InlineData.MimeType)
+			case part.Text != "":
+				fmt.Fprintf(b, "%s: %s\n", content.Role, part.Text)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+func transcript(long string) []core.Content {
+	return []core.Content{
+		{Role: "user", Parts: []core.Part{{Text: "first " + long}}},
+		{Role: "model", Parts: []core.Part{{Text: "answer " + long}}},
+		{Role: "user", Parts: []core.Part{{Text: "second " + long}}},
+		{Role: "model", Parts: []core.Part{{Text: "ok"}}},
+		{Role: "user", Parts: []core.Part{{Text: "latest"}}},
+	}
+}
+
+func TestCompressUsesCachedSummary(t *testing.T) {
+	long := strings.Repeat("x", 400)
+
+	var mu sync.Mutex
+	var calls int
+	done := make(chan struct{}, 1)
+	s := New("gemini-3-pro-low", func(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		resp := &core.AntigravityResponse{}
+		resp.Response.Candidates = []core.Candidate{{Content: core.Content{Role: "model", Parts: []core.Part{{Text: "user asked twice"}}}}}
+		defer func() { done <- struct{}{} }()
+		return resp, nil
+	})
+	token := &store.Account{ProjectID: "p"}
+
+	// 首次请求：缓存未命中，后台生成摘要，请求本身不变
+	req := &core.AntigravityRequest{}
+	req.Request.Contents = transcript(long)
+	if n := s.Compress(req, 100, token); n != 0 {
+		t.Fatalf("Expected no compression on cache miss, got %d", n)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("summarizer was not called")
+	}
+	// 等待后台写入缓存
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		cached := len(s.cache)
+		s.mu.Unlock()
+		if cached > 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	// 重复请求：命中缓存，早期轮次被摘要替换
+	req = &core.AntigravityRequest{}
+	req.Request.Contents = transcript(long)
+	n := s.Compress(req, 100, token)
+	if n != 4 {
+		t.Fatalf("Expected 4 messages replaced, got %d", n)
+	}
+	first := req.Request.Contents[0]
+	if first.Role != "user" || first.Parts[0].Text != SummaryPrefix+"user asked twice" || first.Parts[1].Text != "latest" {
+		t.Errorf("Unexpected compressed head: %+v", first)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("Expected 1 summarization call, got %d", calls)
+	}
+}
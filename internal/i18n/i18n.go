@@ -0,0 +1,145 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"anti2api-golang/internal/config"
+)
+
+// Code 错误码，同时作为客户端错误对象中的 code 字段
+type Code string
+
+const (
+	NoAccounts         Code = "no_accounts"
+	NoAvailableToken   Code = "no_available_token"
+	NoGroupToken       Code = "no_available_token_in_group"
+	AccountNotFound    Code = "account_not_found"
+	CredentialNotFound Code = "credential_not_found"
+	IndexOutOfRange    Code = "index_out_of_range"
+	AccountNotArchived Code = "account_not_archived"
+	InvalidTOML        Code = "invalid_toml"
+	PoolExhausted      Code = "pool_exhausted"
+	InvalidRequestBody Code = "invalid_request_body"
+	MaxTokensRequired  Code = "max_tokens_required"
+	MessagesRequired   Code = "messages_required"
+	ModelNotFound      Code = "model_not_found"
+	BudgetExceeded     Code = "daily_budget_exceeded"
+)
+
+// 支持的语言
+const (
+	LangZh = "zh"
+	LangEn = "en"
+)
+
+// catalogs 各语言的消息目录，消息可包含 fmt 占位符
+var catalogs = map[string]map[Code]string{
+	LangZh: {
+		NoAccounts:         "没有可用的账号",
+		NoAvailableToken:   "没有可用的 token",
+		NoGroupToken:       "账号组内没有可用的 token",
+		AccountNotFound:    "未找到指定的账号",
+		CredentialNotFound: "未找到指定的凭证: %s",
+		IndexOutOfRange:    "索引超出范围",
+		AccountNotArchived: "只能永久删除已归档的账号",
+		InvalidTOML:        "无效的 TOML 格式",
+		PoolExhausted:      "所有账号均处于限流冷却中，预计 %s 恢复",
+		InvalidRequestBody: "请求体格式不合法",
+		MaxTokensRequired:  "max_tokens 是必填数字",
+		MessagesRequired:   "messages 不能为空",
+		ModelNotFound:      "模型 %s 不存在",
+		BudgetExceeded:     "今日 token 预算已用尽，将于 %s 重置",
+	},
+	LangEn: {
+		NoAccounts:         "No accounts configured",
+		NoAvailableToken:   "No available account token",
+		NoGroupToken:       "No available account token in the account group",
+		AccountNotFound:    "Account not found",
+		CredentialNotFound: "Credential not found: %s",
+		IndexOutOfRange:    "Account index out of range",
+		AccountNotArchived: "Only archived accounts can be purged",
+		InvalidTOML:        "Invalid TOML format",
+		PoolExhausted:      "All accounts are rate limited, retry after %s",
+		InvalidRequestBody: "Invalid request body",
+		MaxTokensRequired:  "max_tokens is required and must be a number",
+		MessagesRequired:   "messages must not be empty",
+		ModelNotFound:      "The model %s does not exist",
+		BudgetExceeded:     "Daily token budget exceeded, resets at %s",
+	},
+}
+
+// Error 带错误码的错误；Error() 使用默认语言（ERROR_LANGUAGE）
+type Error struct {
+	Code Code
+	Args []interface{}
+}
+
+// New 创建带错误码的错误
+func New(code Code, args ...interface{}) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+func (e *Error) Error() string {
+	return e.Message(DefaultLanguage())
+}
+
+// Message 返回指定语言的错误消息
+func (e *Error) Message(lang string) string {
+	return Message(lang, e.Code, e.Args...)
+}
+
+// Message 查找指定语言的消息；语言不支持时回退到英文，目录中不存在时返回错误码本身
+func Message(lang string, code Code, args ...interface{}) string {
+	format, ok := catalogs[lang][code]
+	if !ok {
+		if format, ok = catalogs[LangEn][code]; !ok {
+			return string(code)
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Localize 返回错误在指定语言下的消息；非 *Error 的错误原样返回 Error()
+func Localize(err error, lang string) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Message(lang)
+	}
+	return err.Error()
+}
+
+// DefaultLanguage 返回默认的客户端错误语言
+func DefaultLanguage() string {
+	if lang := normalize(config.Get().ErrorLanguage); lang != "" {
+		return lang
+	}
+	return LangZh
+}
+
+// Negotiate 按 Accept-Language 选择首个支持的语言（忽略权重，按出现顺序），均不支持时使用默认语言
+func Negotiate(acceptLanguage string) string {
+	for _, tag := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		if lang := normalize(tag); lang != "" {
+			return lang
+		}
+	}
+	return DefaultLanguage()
+}
+
+// normalize 将语言标签（如 en-US、zh_CN）归一为支持的语言，不支持时返回空串
+func normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for code := range catalogs[LangEn] {
+			if _, ok := catalog[code]; !ok {
+				t.Errorf("%s: missing message for %s", lang, code)
+			}
+		}
+		if len(catalog) != len(catalogs[LangEn]) {
+			t.Errorf("%s: %d messages, en has %d", lang, len(catalog), len(catalogs[LangEn]))
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"en-US,en;q=0.9":     LangEn,
+		"fr-FR, zh_CN;q=0.8": LangZh,
+		"EN":                 LangEn,
+		"fr":                 DefaultLanguage(),
+		"":                   DefaultLanguage(),
+	}
+	for header, want := range tests {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	err := fmt.Errorf("select account: %w", New(CredentialNotFound, "a@b.c"))
+	if got := Localize(err, LangEn); got != "Credential not found: a@b.c" {
+		t.Errorf("en = %q", got)
+	}
+	if got := Localize(err, LangZh); got != "未找到指定的凭证: a@b.c" {
+		t.Errorf("zh = %q", got)
+	}
+	if got := Message("fr", NoAccounts); got != "No accounts configured" {
+		t.Errorf("fallback = %q", got)
+	}
+	if got := Localize(errors.New("plain"), LangEn); got != "plain" {
+		t.Errorf("plain = %q", got)
+	}
+}
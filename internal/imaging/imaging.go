@@ -0,0 +1,175 @@
+// Package imaging 视觉请求的图片处理：解码、缩小与重新编码（仅使用标准库支持的 JPEG / PNG / GIF）
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	_ "image/gif"
+)
+
+// minDimension 缩小后的最短边下限，低于此尺寸视为无法压缩到目标大小
+const minDimension = 64
+
+// jpegQuality 重新编码 JPEG 的质量
+const jpegQuality = 85
+
+// maxPixels 允许解码的像素数上限：解码后每像素约占 4 字节，防止小文件声明超大尺寸（解压炸弹）耗尽内存
+const maxPixels = 50_000_000
+
+// ErrCannotFit 缩小到最小尺寸仍超过目标大小
+var ErrCannotFit = errors.New("image cannot be downscaled to fit the size limit")
+
+// ErrTooManyPixels 图片像素数超过 maxPixels，不进行解码
+var ErrTooManyPixels = errors.New("image dimensions exceed the decode limit")
+
+// checkPixels 在解码前按图片头中声明的尺寸检查像素数
+func checkPixels(cfg image.Config) error {
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+// DownscaleToFit 按比例缩小图片直到编码后不超过 maxBytes，返回新的数据与 MIME 类型
+// JPEG 保持 JPEG，其余格式输出 PNG（保留透明度）
+func DownscaleToFit(data []byte, maxBytes int) ([]byte, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+	if err := checkPixels(cfg); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	// 编码大小大致与像素数成正比：按面积比估算首次缩放比例，之后每次再缩小 20%
+	scale := math.Min(0.9, math.Sqrt(float64(maxBytes)/float64(len(data))))
+	for ; ; scale *= 0.8 {
+		w, h := int(float64(bounds.Dx())*scale), int(float64(bounds.Dy())*scale)
+		if w < minDimension || h < minDimension {
+			return nil, "", ErrCannotFit
+		}
+		out, mimeType, err := encode(Resize(img, w, h), format)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(out) <= maxBytes {
+			return out, mimeType, nil
+		}
+	}
+}
+
+// Options 图片预处理选项
+type Options struct {
+	MaxDimension int  // 最长边上限（像素），0 表示不限制
+	ToJPEG       bool // 将不透明的 PNG / GIF 转为 JPEG（仅在结果更小时替换）
+}
+
+// Transcode 按选项缩小并转换图片格式，返回新的数据、MIME 类型与是否发生修改
+// 标准库无法解码的格式（HEIC、WebP 等，上游可直接识别）原样返回，不缩小也不转换
+func Transcode(data []byte, opts Options) ([]byte, string, bool, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return data, "", false, nil
+	}
+	if err != nil {
+		return nil, "", false, fmt.Errorf("decode image: %w", err)
+	}
+
+	longest := max(cfg.Width, cfg.Height)
+	resize := opts.MaxDimension > 0 && longest > opts.MaxDimension
+	convert := opts.ToJPEG && format != "jpeg"
+	if !resize && !convert {
+		return data, "", false, nil
+	}
+	if err := checkPixels(cfg); err != nil {
+		return nil, "", false, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("decode image: %w", err)
+	}
+	if resize {
+		scale := float64(opts.MaxDimension) / float64(longest)
+		w, h := max(int(float64(cfg.Width)*scale), 1), max(int(float64(cfg.Height)*scale), 1)
+		img = Resize(img, w, h)
+	}
+
+	outFormat := format
+	if convert && isOpaque(img) {
+		outFormat = "jpeg"
+	}
+	out, mimeType, err := encode(img, outFormat)
+	if err != nil {
+		return nil, "", false, err
+	}
+	// 仅转换格式且结果更大时保留原图
+	if !resize && len(out) >= len(data) {
+		return data, "", false, nil
+	}
+	return out, mimeType, true, nil
+}
+
+// isOpaque 图片是否完全不透明（含透明像素的图片转为 JPEG 会丢失透明度）
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// encode 按原格式选择输出编码：JPEG 输出 JPEG，其余输出 PNG
+func encode(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// Resize 以区域平均（box filter）缩小图片到 w×h，适用于缩小；放大时退化为最近邻
+func Resize(src image.Image, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*sh/h
+		y1 := max(b.Min.Y+(y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*sw/w
+			x1 := max(b.Min.X+(x+1)*sw/w, x0+1)
+
+			// RGBA() 返回预乘 alpha 的 16 位分量，直接平均后按 RGBA64 写入即可正确换算
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
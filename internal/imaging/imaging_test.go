@@ -0,0 +1,130 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// noisyImage 随机噪点图片，压缩率低，便于构造较大的编码结果
+func noisyImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rng := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rng.Intn(256))
+	}
+	return img
+}
+
+func TestDownscaleToFit(t *testing.T) {
+	var pngData, jpegData bytes.Buffer
+	png.Encode(&pngData, noisyImage(400, 300))
+	jpeg.Encode(&jpegData, noisyImage(400, 300), &jpeg.Options{Quality: 95})
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		mime string
+	}{
+		{"png", pngData.Bytes(), "image/png"},
+		{"jpeg", jpegData.Bytes(), "image/jpeg"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := len(tt.data) / 3
+			out, mimeType, err := DownscaleToFit(tt.data, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) > limit || mimeType != tt.mime {
+				t.Errorf("got %d bytes (%s), limit %d", len(out), mimeType, limit)
+			}
+			img, _, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := img.Bounds(); b.Dx() >= 400 || b.Dy() >= 300 {
+				t.Errorf("unexpected size %v", b)
+			}
+		})
+	}
+
+	if _, _, err := DownscaleToFit(pngData.Bytes(), 100); err != ErrCannotFit {
+		t.Errorf("expected ErrCannotFit, got %v", err)
+	}
+	if _, _, err := DownscaleToFit([]byte("not an image"), 100); err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			if x < 2 {
+				src.Set(x, y, color.NRGBA{R: 255, A: 255})
+			} else {
+				src.Set(x, y, color.NRGBA{B: 255, A: 255})
+			}
+		}
+	}
+	dst := Resize(src, 2, 1)
+	if c := dst.NRGBAAt(0, 0); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("left pixel = %v", c)
+	}
+	if c := dst.NRGBAAt(1, 0); c != (color.NRGBA{B: 255, A: 255}) {
+		t.Errorf("right pixel = %v", c)
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	// 不透明的噪点图：PNG 几乎无法压缩，转为 JPEG 明显更小
+	opaque := noisyImage(300, 200)
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+	var opaquePNG bytes.Buffer
+	png.Encode(&opaquePNG, opaque)
+
+	out, mimeType, changed, err := Transcode(opaquePNG.Bytes(), Options{ToJPEG: true})
+	if err != nil || !changed || mimeType != "image/jpeg" || len(out) >= opaquePNG.Len() {
+		t.Errorf("png → jpeg: changed=%v mime=%s size=%d/%d err=%v", changed, mimeType, len(out), opaquePNG.Len(), err)
+	}
+
+	out, mimeType, changed, err = Transcode(opaquePNG.Bytes(), Options{MaxDimension: 150})
+	if err != nil || !changed || mimeType != "image/png" {
+		t.Fatalf("resize: changed=%v mime=%s err=%v", changed, mimeType, err)
+	}
+	if cfg, _, _ := image.DecodeConfig(bytes.NewReader(out)); cfg.Width != 150 || cfg.Height != 100 {
+		t.Errorf("resized to %dx%d, want 150x100", cfg.Width, cfg.Height)
+	}
+
+	// 含透明像素的 PNG 保持 PNG；已在尺寸内时不修改
+	transparent := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	var transparentPNG bytes.Buffer
+	png.Encode(&transparentPNG, transparent)
+	if _, _, changed, err := Transcode(transparentPNG.Bytes(), Options{ToJPEG: true, MaxDimension: 100}); changed || err != nil {
+		t.Errorf("transparent png should be kept, changed=%v err=%v", changed, err)
+	}
+
+	// 无法识别的格式原样返回
+	if _, _, changed, err := Transcode([]byte("ftypheic...."), Options{ToJPEG: true}); changed || err != nil {
+		t.Errorf("unknown format should pass through, changed=%v err=%v", changed, err)
+	}
+}
+
+// TestDecompressionBomb 图片头声明的像素数超过上限时不解码
+func TestDecompressionBomb(t *testing.T) {
+	// 仅含文件头的 GIF，声明 65535×65535 的画布
+	bomb := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00\x3b")
+	if _, _, _, err := Transcode(bomb, Options{MaxDimension: 1024}); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Transcode: expected ErrTooManyPixels, got %v", err)
+	}
+	if _, _, err := DownscaleToFit(bomb, 1024); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("DownscaleToFit: expected ErrTooManyPixels, got %v", err)
+	}
+}
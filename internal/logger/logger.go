@@ -2,11 +2,15 @@ package logger
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"anti2api-golang/internal/config"
 )
@@ -32,12 +36,24 @@ const (
 	ColorPurple = "\x1b[35m"
 )
 
-var currentLogLevel LogLevel
+var (
+	currentLogLevel atomic.Int32 // 可在运行时通过 SetLevel 切换
+	maxBodySize     int
+	groupByRequest  bool // 同一请求的日志块缓冲到请求结束后连续输出
+)
+
+// base64 数据匹配：inlineData/source 中的 "data" 字段与 data URL
+var (
+	base64FieldPattern = regexp.MustCompile(`("data"\s*:\s*")([A-Za-z0-9+/=_-]{64,})(")`)
+	dataURLPattern     = regexp.MustCompile(`(data:[\w.+-]+/[\w.+-]+;base64,)([A-Za-z0-9+/=]{64,})`)
+)
 
 // Init 初始化日志系统
 func Init() {
 	cfg := config.Get()
-	currentLogLevel = parseLogLevel(cfg.Debug)
+	currentLogLevel.Store(int32(parseLogLevel(cfg.Debug)))
+	maxBodySize = cfg.LogMaxBodySize
+	groupByRequest = cfg.LogGroupByRequest
 }
 
 func parseLogLevel(debug string) LogLevel {
@@ -51,9 +67,35 @@ func parseLogLevel(debug string) LogLevel {
 	}
 }
 
+// String 日志级别名称（与 DEBUG 取值一致）
+func (l LogLevel) String() string {
+	switch l {
+	case LogLow:
+		return "low"
+	case LogHigh:
+		return "high"
+	default:
+		return "off"
+	}
+}
+
 // GetLevel 获取当前日志级别
 func GetLevel() LogLevel {
-	return currentLogLevel
+	return LogLevel(currentLogLevel.Load())
+}
+
+// SetLevel 运行时切换日志级别（off、low、high），立即对所有请求生效
+func SetLevel(debug string) error {
+	switch strings.ToLower(debug) {
+	case "off", "low", "high":
+	default:
+		return fmt.Errorf("invalid debug level %q, expected off, low or high", debug)
+	}
+	level := parseLogLevel(debug)
+	if old := LogLevel(currentLogLevel.Swap(int32(level))); old != level {
+		Info("Debug level changed: %s -> %s", old, level)
+	}
+	return nil
 }
 
 // Info 信息日志
@@ -79,7 +121,7 @@ func Error(format string, args ...interface{}) {
 
 // Debug 调试日志
 func Debug(format string, args ...interface{}) {
-	if currentLogLevel < LogLow {
+	if GetLevel() < LogLow {
 		return
 	}
 	timestamp := time.Now().Format("15:04:05")
@@ -104,116 +146,93 @@ func Request(method, path string, status int, duration time.Duration) {
 }
 
 // ClientRequest 客户端请求日志（原始 JSON 透传）
-func ClientRequest(method, path string, rawJSON []byte) {
-	if currentLogLevel < LogLow {
+func ClientRequest(ctx context.Context, method, path string, rawJSON []byte) {
+	if GetLevel() < LogLow {
 		return
 	}
 
-	fmt.Printf("%s===================== 客户端请求 ======================%s\n", ColorPurple, ColorReset)
-	fmt.Printf("%s[客户端请求]%s %s%s%s %s\n", ColorPurple, ColorReset, ColorCyan, method, ColorReset, path)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s===================== 客户端请求 ======================%s\n", ColorPurple, ColorReset)
+	fmt.Fprintf(&b, "%s[客户端请求]%s %s%s%s%s %s\n", ColorPurple, ColorReset, RequestLogFrom(ctx).tag(), ColorCyan, method, ColorReset, path)
 	if len(rawJSON) > 0 {
-		fmt.Println(formatRawJSON(rawJSON))
+		fmt.Fprintln(&b, formatRawJSON(rawJSON))
 	}
-	fmt.Printf("%s=========================================================%s\n", ColorPurple, ColorReset)
+	fmt.Fprintf(&b, "%s=========================================================%s\n", ColorPurple, ColorReset)
+	writeBlock(ctx, &b)
 }
 
 // ClientResponse 客户端响应日志
-func ClientResponse(status int, duration time.Duration, body interface{}) {
-	if currentLogLevel < LogLow {
+func ClientResponse(ctx context.Context, status int, duration time.Duration, body interface{}) {
+	if GetLevel() < LogLow {
 		return
 	}
-
-	statusColor := ColorGreen
-	if status >= 400 {
-		statusColor = ColorRed
-	}
-
-	fmt.Printf("%s===================== 客户端响应 ======================%s\n", ColorPurple, ColorReset)
-	fmt.Printf("%s[客户端响应]%s %s%d%s %s%dms%s\n", ColorPurple, ColorReset, statusColor, status, ColorReset, ColorGray, duration.Milliseconds(), ColorReset)
-	if body != nil {
-		printJSON(body)
-	}
-	fmt.Printf("%s==========================================================%s\n", ColorPurple, ColorReset)
+	responseBlock(ctx, ColorPurple, "===================== 客户端响应 ======================", "[客户端响应]", status, duration, body)
 }
 
 // BackendRequest 后端请求日志（原始 JSON 透传）
-func BackendRequest(method, url string, rawJSON []byte) {
-	if currentLogLevel < LogHigh {
+func BackendRequest(ctx context.Context, method, url string, rawJSON []byte) {
+	if GetLevel() < LogHigh {
 		return
 	}
 
-	fmt.Printf("%s====================== 后端请求 ========================%s\n", ColorYellow, ColorReset)
-	fmt.Printf("%s[后端请求]%s %s%s%s %s\n", ColorYellow, ColorReset, ColorCyan, method, ColorReset, url)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s====================== 后端请求 ========================%s\n", ColorYellow, ColorReset)
+	fmt.Fprintf(&b, "%s[后端请求]%s %s%s%s%s %s\n", ColorYellow, ColorReset, RequestLogFrom(ctx).tag(), ColorCyan, method, ColorReset, url)
 	if len(rawJSON) > 0 {
-		fmt.Println(formatRawJSON(rawJSON))
+		fmt.Fprintln(&b, formatRawJSON(rawJSON))
 	}
-	fmt.Printf("%s==========================================================%s\n", ColorYellow, ColorReset)
+	fmt.Fprintf(&b, "%s==========================================================%s\n", ColorYellow, ColorReset)
+	writeBlock(ctx, &b)
 }
 
 // BackendResponse 后端响应日志
-func BackendResponse(status int, duration time.Duration, body interface{}) {
-	if currentLogLevel < LogHigh {
+func BackendResponse(ctx context.Context, status int, duration time.Duration, body interface{}) {
+	if GetLevel() < LogHigh {
 		return
 	}
-
-	statusColor := ColorGreen
-	if status >= 400 {
-		statusColor = ColorRed
-	}
-
-	fmt.Printf("%s====================== 后端响应 ========================%s\n", ColorGreen, ColorReset)
-	fmt.Printf("%s[后端响应]%s %s%d%s %s%dms%s\n", ColorGreen, ColorReset, statusColor, status, ColorReset, ColorGray, duration.Milliseconds(), ColorReset)
-	if body != nil {
-		printJSON(body)
-	}
-	fmt.Printf("%s==========================================================%s\n", ColorGreen, ColorReset)
+	responseBlock(ctx, ColorGreen, "====================== 后端响应 ========================", "[后端响应]", status, duration, body)
 }
 
 // BackendStreamResponse 后端流式响应日志（合并后的）
-func BackendStreamResponse(status int, duration time.Duration, body interface{}) {
-	if currentLogLevel < LogHigh {
+func BackendStreamResponse(ctx context.Context, status int, duration time.Duration, body interface{}) {
+	if GetLevel() < LogHigh {
 		return
 	}
-
-	statusColor := ColorGreen
-	if status >= 400 {
-		statusColor = ColorRed
-	}
-
-	fmt.Printf("%s==================== 后端流式响应 =======================%s\n", ColorGreen, ColorReset)
-	fmt.Printf("%s[后端流式]%s %s%d%s %s%dms%s\n", ColorGreen, ColorReset, statusColor, status, ColorReset, ColorGray, duration.Milliseconds(), ColorReset)
-	if body != nil {
-		printJSON(body)
-	}
-	fmt.Printf("%s==========================================================%s\n", ColorGreen, ColorReset)
+	responseBlock(ctx, ColorGreen, "==================== 后端流式响应 =======================", "[后端流式]", status, duration, body)
 }
 
 // ClientStreamResponse 客户端流式响应日志（合并后的）
-func ClientStreamResponse(status int, duration time.Duration, body interface{}) {
-	if currentLogLevel < LogLow {
+func ClientStreamResponse(ctx context.Context, status int, duration time.Duration, body interface{}) {
+	if GetLevel() < LogLow {
 		return
 	}
+	responseBlock(ctx, ColorPurple, "=================== 客户端流式响应 =======================", "[客户端流式]", status, duration, body)
+}
 
+// responseBlock 输出响应日志块：标题、状态码与耗时、响应体
+func responseBlock(ctx context.Context, color, title, label string, status int, duration time.Duration, body interface{}) {
 	statusColor := ColorGreen
 	if status >= 400 {
 		statusColor = ColorRed
 	}
 
-	fmt.Printf("%s=================== 客户端流式响应 =======================%s\n", ColorPurple, ColorReset)
-	fmt.Printf("%s[客户端流式]%s %s%d%s %s%dms%s\n", ColorPurple, ColorReset, statusColor, status, ColorReset, ColorGray, duration.Milliseconds(), ColorReset)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s%s\n", color, title, ColorReset)
+	fmt.Fprintf(&b, "%s%s%s %s%s%d%s %s%dms%s\n", color, label, ColorReset, RequestLogFrom(ctx).tag(), statusColor, status, ColorReset, ColorGray, duration.Milliseconds(), ColorReset)
 	if body != nil {
-		printJSON(body)
+		fmt.Fprintln(&b, formatJSON(body))
 	}
-	fmt.Printf("%s==========================================================%s\n", ColorPurple, ColorReset)
+	fmt.Fprintf(&b, "%s==========================================================%s\n", color, ColorReset)
+	writeBlock(ctx, &b)
 }
 
-func printJSON(v interface{}) {
+// formatJSON 格式化响应体，无法序列化时按 %v 输出
+func formatJSON(v interface{}) string {
 	jsonBytes, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		fmt.Printf("%v\n", v)
-		return
+		return fmt.Sprintf("%v", v)
 	}
-	fmt.Println(string(jsonBytes))
+	return sanitizeBody(string(jsonBytes))
 }
 
 // formatRawJSON 格式化原始 JSON 字节（直接透传，仅美化格式）
@@ -221,9 +240,39 @@ func formatRawJSON(rawJSON []byte) string {
 	var indented bytes.Buffer
 	if err := json.Indent(&indented, rawJSON, "", "  "); err != nil {
 		// 无法格式化时直接返回原始字符串
-		return string(rawJSON)
+		return sanitizeBody(string(rawJSON))
+	}
+	return sanitizeBody(indented.String())
+}
+
+// sanitizeBody 省略 base64 数据并按配置截断，避免刷屏及泄露图片内容
+func sanitizeBody(body string) string {
+	body = elideBase64(body)
+	if maxBodySize <= 0 || len(body) <= maxBodySize {
+		return body
+	}
+
+	// 按 UTF-8 边界截断
+	cut := maxBodySize
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n%s... (truncated, %d bytes total)%s", body[:cut], ColorGray, len(body), ColorReset)
+}
+
+// elideBase64 将 base64 数据替换为长度占位符
+func elideBase64(body string) string {
+	replace := func(pattern *regexp.Regexp, s string) string {
+		return pattern.ReplaceAllStringFunc(s, func(match string) string {
+			m := pattern.FindStringSubmatch(match)
+			elided := fmt.Sprintf("<base64 %d bytes elided>", len(m[2]))
+			if len(m) > 3 {
+				return m[1] + elided + m[3]
+			}
+			return m[1] + elided
+		})
 	}
-	return indented.String()
+	return replace(dataURLPattern, replace(base64FieldPattern, body))
 }
 
 // Banner 打印启动横幅
@@ -236,7 +285,7 @@ func Banner(port int, endpointMode string) {
 
 	Info("Server starting on port %d", port)
 	Info("Endpoint mode: %s", endpointMode)
-	Info("Debug level: %s", config.Get().Debug)
+	Info("Debug level: %s", GetLevel())
 
 	if os.Getenv("API_KEY") == "" {
 		Warn("API_KEY not set - API authentication disabled")
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeBodyElidesBase64(t *testing.T) {
+	maxBodySize = 0
+	payload := strings.Repeat("iVBORw0KGgo", 20)
+
+	tests := []string{
+		`{"inlineData":{"mimeType":"image/png","data":"` + payload + `"}}`,
+		`{"source":{"type":"base64","media_type":"image/png","data": "` + payload + `"}}`,
+		`{"image_url":{"url":"data:image/png;base64,` + payload + `"}}`,
+	}
+	for _, body := range tests {
+		got := sanitizeBody(body)
+		if strings.Contains(got, payload) {
+			t.Errorf("base64 not elided: %s", got)
+		}
+		if !strings.Contains(got, "<base64 220 bytes elided>") {
+			t.Errorf("missing elision marker: %s", got)
+		}
+	}
+
+	short := `{"data":"abc"}`
+	if got := sanitizeBody(short); got != short {
+		t.Errorf("short data should be kept, got %s", got)
+	}
+}
+
+func TestSanitizeBodyTruncates(t *testing.T) {
+	maxBodySize = 10
+	defer func() { maxBodySize = 0 }()
+
+	got := sanitizeBody("你好你好你好你好")
+	if !strings.HasPrefix(got, "你好你") || strings.Contains(got, "你好你好") {
+		t.Errorf("unexpected truncation: %q", got)
+	}
+	if !strings.Contains(got, "truncated, 24 bytes total") {
+		t.Errorf("missing truncation note: %q", got)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	defer currentLogLevel.Store(currentLogLevel.Load())
+
+	if err := SetLevel("HIGH"); err != nil || GetLevel() != LogHigh {
+		t.Fatalf("SetLevel(HIGH): level = %s, err = %v", GetLevel(), err)
+	}
+	if err := SetLevel("verbose"); err == nil || GetLevel() != LogHigh {
+		t.Errorf("invalid level accepted: level = %s, err = %v", GetLevel(), err)
+	}
+	if err := SetLevel("off"); err != nil || GetLevel().String() != "off" {
+		t.Errorf("SetLevel(off): level = %s, err = %v", GetLevel(), err)
+	}
+}
+
+func TestRequestLogGroupsBlocks(t *testing.T) {
+	defer currentLogLevel.Store(currentLogLevel.Load())
+	currentLogLevel.Store(int32(LogHigh))
+	groupByRequest = true
+	defer func() { groupByRequest = false }()
+
+	ctx, reqLog := WithRequestLog(context.Background())
+	ClientRequest(ctx, "POST", "/v1/chat/completions", []byte(`{"model":"m"}`))
+	reqLog.SetAccount("a@example.com")
+	BackendResponse(ctx, 200, 0, map[string]string{"ok": "yes"})
+
+	if len(reqLog.blocks) != 2 {
+		t.Fatalf("buffered %d blocks, want 2", len(reqLog.blocks))
+	}
+	if !strings.Contains(reqLog.blocks[0], "["+reqLog.ID()+"]") {
+		t.Errorf("request block missing id: %q", reqLog.blocks[0])
+	}
+	if !strings.Contains(reqLog.blocks[1], "["+reqLog.ID()+" a@example.com]") {
+		t.Errorf("response block missing id and account: %q", reqLog.blocks[1])
+	}
+
+	reqLog.Flush()
+	if len(reqLog.blocks) != 0 {
+		t.Errorf("blocks not flushed: %d", len(reqLog.blocks))
+	}
+	if RequestLogFrom(context.Background()).tag() != "" {
+		t.Error("tag without request log should be empty")
+	}
+}
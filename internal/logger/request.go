@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"anti2api-golang/internal/utils"
+)
+
+type requestLogKey struct{}
+
+// outputMu 保证每个日志块（或按请求分组的一组日志块）连续输出，不与并发请求交错
+var outputMu sync.Mutex
+
+// RequestLog 单个请求的调试日志上下文：为每个日志块添加请求 ID 与账号前缀
+// 开启 LOG_GROUP_BY_REQUEST 时日志块先缓冲，请求结束时由 Flush 一并输出
+type RequestLog struct {
+	mu       sync.Mutex
+	id       string
+	account  string
+	buffered bool
+	blocks   []string
+}
+
+// WithRequestLog 返回携带请求日志上下文的 context
+// 请求 ID 同时用作管理日志条目的 ID，便于将控制台调试输出与日志详情对应
+func WithRequestLog(ctx context.Context) (context.Context, *RequestLog) {
+	l := &RequestLog{id: utils.GenerateRequestID(), buffered: groupByRequest}
+	return context.WithValue(ctx, requestLogKey{}, l), l
+}
+
+// RequestLogFrom 获取 context 中的请求日志上下文，不存在时返回 nil
+func RequestLogFrom(ctx context.Context) *RequestLog {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(requestLogKey{}).(*RequestLog)
+	return l
+}
+
+// ID 返回请求 ID（nil 安全）
+func (l *RequestLog) ID() string {
+	if l == nil {
+		return ""
+	}
+	return l.id
+}
+
+// SetAccount 记录本次请求使用的账号，之后的日志块前缀中包含该账号（nil 安全）
+func (l *RequestLog) SetAccount(email string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.account = email
+	l.mu.Unlock()
+}
+
+// Flush 输出缓冲的日志块（nil 安全）
+func (l *RequestLog) Flush() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	blocks := l.blocks
+	l.blocks = nil
+	l.mu.Unlock()
+	if len(blocks) == 0 {
+		return
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	for _, block := range blocks {
+		fmt.Print(block)
+	}
+}
+
+// tag 日志块前缀 [请求 ID 账号]，没有请求上下文时为空
+func (l *RequestLog) tag() string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.account == "" {
+		return fmt.Sprintf("%s[%s]%s ", ColorGray, l.id, ColorReset)
+	}
+	return fmt.Sprintf("%s[%s %s]%s ", ColorGray, l.id, l.account, ColorReset)
+}
+
+// writeBlock 输出一个完整的日志块；请求开启分组时缓冲到 Flush
+func writeBlock(ctx context.Context, b *strings.Builder) {
+	if l := RequestLogFrom(ctx); l != nil && l.buffered {
+		l.mu.Lock()
+		l.blocks = append(l.blocks, b.String())
+		l.mu.Unlock()
+		return
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Print(b.String())
+}
@@ -0,0 +1,194 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+)
+
+// Categories OpenAI moderation 分类
+var Categories = []string{
+	"harassment", "harassment/threatening",
+	"hate", "hate/threatening",
+	"illicit", "illicit/violent",
+	"self-harm", "self-harm/intent", "self-harm/instructions",
+	"sexual", "sexual/minors",
+	"violence", "violence/graphic",
+}
+
+// flagThreshold 模型分类时判定为命中的分数阈值
+const flagThreshold = 0.5
+
+const classifyInstruction = `You are a content moderation classifier.
+For each input text, score how likely it belongs to each category on a scale from 0 to 1.
+Categories: %s.
+Reply with JSON only, no commentary: an array with one object per input, in order, mapping every category name to its score.`
+
+// Result 单条输入的分类结果（OpenAI moderation 格式）
+type Result struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// newResult 按分数构建分类结果
+func newResult(scores map[string]float64) Result {
+	r := Result{
+		Categories:     make(map[string]bool, len(Categories)),
+		CategoryScores: make(map[string]float64, len(Categories)),
+	}
+	for _, category := range Categories {
+		score := scores[category]
+		r.CategoryScores[category] = score
+		r.Categories[category] = score >= flagThreshold
+		r.Flagged = r.Flagged || r.Categories[category]
+	}
+	return r
+}
+
+// Classifier /v1/moderations 分类器
+type Classifier interface {
+	Classify(ctx context.Context, inputs []string, token *store.Account) ([]Result, error)
+	// NeedsAccount 是否需要上游账号
+	NeedsAccount() bool
+}
+
+// NewClassifier 按 MODERATIONS_BACKEND 构建分类器
+func NewClassifier(cfg *config.Config, send SendFunc) (Classifier, error) {
+	switch cfg.ModerationsBackend {
+	case "", "keyword":
+		return NewKeywordClassifier(cfg.ModerationsKeywords)
+	case "model":
+		return &ModelClassifier{Model: cfg.ModerationsModel, Send: send}, nil
+	default:
+		return nil, fmt.Errorf("unknown MODERATIONS_BACKEND %q", cfg.ModerationsBackend)
+	}
+}
+
+// KeywordClassifier 本地关键词分类：输入包含某分类的任一关键词（不区分大小写）即判定命中
+type KeywordClassifier struct {
+	keywords map[string][]string
+}
+
+// NewKeywordClassifier 解析分类关键词（JSON 对象：分类 → 关键词数组）
+func NewKeywordClassifier(data string) (*KeywordClassifier, error) {
+	c := &KeywordClassifier{keywords: make(map[string][]string)}
+	if data == "" {
+		return c, nil
+	}
+
+	var keywords map[string][]string
+	if err := json.Unmarshal([]byte(data), &keywords); err != nil {
+		return nil, fmt.Errorf("invalid MODERATIONS_KEYWORDS: %w", err)
+	}
+	for category, words := range keywords {
+		if !isCategory(category) {
+			return nil, fmt.Errorf("unknown moderation category %q", category)
+		}
+		for _, word := range words {
+			c.keywords[category] = append(c.keywords[category], strings.ToLower(word))
+		}
+	}
+	return c, nil
+}
+
+func (c *KeywordClassifier) NeedsAccount() bool { return false }
+
+func (c *KeywordClassifier) Classify(ctx context.Context, inputs []string, token *store.Account) ([]Result, error) {
+	results := make([]Result, len(inputs))
+	for i, input := range inputs {
+		lower := strings.ToLower(input)
+		scores := make(map[string]float64)
+		for category, words := range c.keywords {
+			for _, word := range words {
+				if strings.Contains(lower, word) {
+					scores[category] = 1
+					break
+				}
+			}
+		}
+		results[i] = newResult(scores)
+	}
+	return results, nil
+}
+
+// SendFunc 发送非流式上游请求
+type SendFunc func(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error)
+
+// ModelClassifier 使用上游模型打分分类
+type ModelClassifier struct {
+	Model string
+	Send  SendFunc
+}
+
+func (c *ModelClassifier) NeedsAccount() bool { return true }
+
+func (c *ModelClassifier) Classify(ctx context.Context, inputs []string, token *store.Account) ([]Result, error) {
+	payload, _ := json.Marshal(inputs)
+	temperature := 0.0
+
+	req := &core.AntigravityRequest{
+		Project:   token.ProjectID,
+		RequestID: utils.GenerateRequestID(),
+		Request: core.AntigravityInnerReq{
+			SystemInstruction: &core.SystemInstruction{Parts: []core.Part{{Text: fmt.Sprintf(classifyInstruction, strings.Join(Categories, ", "))}}},
+			Contents:          []core.Content{{Role: "user", Parts: []core.Part{{Text: string(payload)}}}},
+			GenerationConfig:  &core.GenerationConfig{CandidateCount: 1, Temperature: &temperature},
+			SessionID:         token.SessionID,
+		},
+		Model:     core.ResolveModelName(c.Model),
+		UserAgent: config.Get().UserAgent,
+	}
+
+	resp, err := c.Send(ctx, req, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	if len(resp.Response.Candidates) > 0 {
+		for _, part := range resp.Response.Candidates[0].Content.Parts {
+			if !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	return parseScores(text.String(), len(inputs))
+}
+
+// parseScores 解析模型输出的分数数组（容忍代码块包裹等多余文本）
+func parseScores(text string, n int) ([]Result, error) {
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid moderation model output")
+	}
+
+	var scores []map[string]float64
+	if err := json.Unmarshal([]byte(text[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("invalid moderation model output: %w", err)
+	}
+	if len(scores) != n {
+		return nil, fmt.Errorf("moderation model returned %d results for %d inputs", len(scores), n)
+	}
+
+	results := make([]Result, n)
+	for i, s := range scores {
+		results[i] = newResult(s)
+	}
+	return results, nil
+}
+
+func isCategory(name string) bool {
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
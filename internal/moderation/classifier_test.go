@@ -0,0 +1,53 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+func TestKeywordClassifier(t *testing.T) {
+	c, err := NewKeywordClassifier(`{"violence":["Kill You"],"harassment":["idiot"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, _ := c.Classify(context.Background(), []string{"I will kill you", "hello"}, nil)
+	if !results[0].Flagged || !results[0].Categories["violence"] || results[0].Categories["harassment"] {
+		t.Errorf("results[0] = %+v", results[0])
+	}
+	if results[1].Flagged || len(results[1].Categories) != len(Categories) {
+		t.Errorf("results[1] = %+v", results[1])
+	}
+
+	if _, err := NewKeywordClassifier(`{"spam":["x"]}`); err == nil {
+		t.Error("unknown category should be rejected")
+	}
+}
+
+func TestModelClassifier(t *testing.T) {
+	c := &ModelClassifier{Model: "gemini-3-pro-low", Send: func(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error) {
+		resp := &core.AntigravityResponse{}
+		resp.Response.Candidates = []core.Candidate{{Content: core.Content{Parts: []core.Part{
+			{Text: "```json\n[{\"hate\":0.9,\"violence\":0.1},{}]\n```"},
+		}}}}
+		return resp, nil
+	}}
+
+	results, err := c.Classify(context.Background(), []string{"a", "b"}, &store.Account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Flagged || !results[0].Categories["hate"] || results[0].CategoryScores["violence"] != 0.1 {
+		t.Errorf("results[0] = %+v", results[0])
+	}
+	if results[1].Flagged {
+		t.Errorf("results[1] = %+v", results[1])
+	}
+
+	if _, err := c.Classify(context.Background(), []string{"a"}, &store.Account{}); err == nil {
+		t.Error("mismatched result count should fail")
+	}
+}
@@ -0,0 +1,200 @@
+// Package moderation 上游请求前的内容审核：关键词/正则拦截、正则脱敏与外部审核接口
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
+)
+
+// RedactedText 脱敏后的替换文本
+const RedactedText = "[REDACTED]"
+
+// BlockedError 请求被审核拦截
+type BlockedError struct {
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return "Request blocked by content policy: " + e.Reason
+}
+
+// Moderator 审核器
+type Moderator struct {
+	blockWords    []string
+	blockPattern  *regexp.Regexp
+	redactPattern *regexp.Regexp
+	url           string
+	httpClient    *http.Client
+}
+
+var (
+	moderator     *Moderator
+	moderatorOnce sync.Once
+)
+
+// Get 获取基于配置构建的审核器单例
+func Get() *Moderator {
+	moderatorOnce.Do(func() {
+		moderator = New(config.Get())
+	})
+	return moderator
+}
+
+// New 根据配置构建审核器（无效正则会被忽略并告警）
+func New(cfg *config.Config) *Moderator {
+	m := &Moderator{
+		url:        cfg.ModerationURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, word := range cfg.ModerationBlockWords {
+		m.blockWords = append(m.blockWords, strings.ToLower(word))
+	}
+	m.blockPattern = compile("MODERATION_BLOCK_PATTERN", cfg.ModerationBlockPattern)
+	m.redactPattern = compile("MODERATION_REDACT_PATTERN", cfg.ModerationRedactPattern)
+	return m
+}
+
+func compile(name, pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		logger.Warn("Invalid %s ignored: %v", name, err)
+		return nil
+	}
+	return re
+}
+
+// Enabled 是否配置了任一审核规则
+func (m *Moderator) Enabled() bool {
+	return len(m.blockWords) > 0 || m.blockPattern != nil || m.redactPattern != nil || m.url != ""
+}
+
+// Apply 审核 Antigravity 请求：先脱敏，再检查拦截规则与外部接口
+// 仅检查 system 指令与 user 消息中的文本；被拦截时返回 *BlockedError
+func (m *Moderator) Apply(ctx context.Context, req *core.AntigravityRequest) error {
+	if !m.Enabled() {
+		return nil
+	}
+
+	texts := userTexts(req)
+
+	if m.redactPattern != nil {
+		for _, text := range texts {
+			*text = m.redactPattern.ReplaceAllString(*text, RedactedText)
+		}
+	}
+
+	var joined strings.Builder
+	for _, text := range texts {
+		joined.WriteString(*text)
+		joined.WriteString("\n")
+	}
+	input := joined.String()
+
+	lower := strings.ToLower(input)
+	for _, word := range m.blockWords {
+		if strings.Contains(lower, word) {
+			return &BlockedError{Reason: "blocked keyword"}
+		}
+	}
+	if m.blockPattern != nil && m.blockPattern.MatchString(input) {
+		return &BlockedError{Reason: "blocked pattern"}
+	}
+
+	if m.url != "" {
+		return m.checkRemote(ctx, input)
+	}
+	return nil
+}
+
+// userTexts 收集需要审核的文本指针（system 指令与 user 消息）
+func userTexts(req *core.AntigravityRequest) []*string {
+	var texts []*string
+	if si := req.Request.SystemInstruction; si != nil {
+		for i := range si.Parts {
+			if si.Parts[i].Text != "" {
+				texts = append(texts, &si.Parts[i].Text)
+			}
+		}
+	}
+	for i := range req.Request.Contents {
+		content := &req.Request.Contents[i]
+		if content.Role != "user" {
+			continue
+		}
+		for j := range content.Parts {
+			if content.Parts[j].Text != "" {
+				texts = append(texts, &content.Parts[j].Text)
+			}
+		}
+	}
+	return texts
+}
+
+// checkRemote 调用外部审核接口（OpenAI moderation 兼容格式）
+// 接口不可用时放行并告警，避免审核服务故障导致整体不可用
+func (m *Moderator) checkRemote(ctx context.Context, input string) error {
+	body, _ := json.Marshal(map[string]string{"input": input})
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.url, bytes.NewReader(body))
+	if err != nil {
+		logger.Warn("Moderation request failed: %v", err)
+		return nil
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		logger.Warn("Moderation request failed: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Warn("Moderation endpoint returned %d", resp.StatusCode)
+		return nil
+	}
+
+	var result struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logger.Warn("Invalid moderation response: %v", err)
+		return nil
+	}
+
+	for _, r := range result.Results {
+		if !r.Flagged {
+			continue
+		}
+		var categories []string
+		for name, flagged := range r.Categories {
+			if flagged {
+				categories = append(categories, name)
+			}
+		}
+		sort.Strings(categories)
+		if len(categories) == 0 {
+			return &BlockedError{Reason: "flagged by moderation"}
+		}
+		return &BlockedError{Reason: fmt.Sprintf("flagged by moderation (%s)", strings.Join(categories, ", "))}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+)
+
+func newRequest(system string, texts ...string) *core.AntigravityRequest {
+	req := &core.AntigravityRequest{}
+	if system != "" {
+		req.Request.SystemInstruction = &core.SystemInstruction{Parts: []core.Part{{Text: system}}}
+	}
+	for _, text := range texts {
+		req.Request.Contents = append(req.Request.Contents, core.Content{Role: "user", Parts: []core.Part{{Text: text}}})
+	}
+	req.Request.Contents = append(req.Request.Contents, core.Content{Role: "model", Parts: []core.Part{{Text: "secret word"}}})
+	return req
+}
+
+func TestApplyBlocksAndRedacts(t *testing.T) {
+	m := New(&config.Config{
+		ModerationBlockWords:    []string{"Forbidden"},
+		ModerationBlockPattern:  `(?i)drop\s+table`,
+		ModerationRedactPattern: `\b\d{16}\b`,
+	})
+
+	req := newRequest("card 1234567812345678", "hello", "my card is 1234567812345678")
+	if err := m.Apply(context.Background(), req); err != nil {
+		t.Fatalf("Expected request to pass, got %v", err)
+	}
+	if got := req.Request.Contents[1].Parts[0].Text; got != "my card is "+RedactedText {
+		t.Errorf("Expected redacted text, got %q", got)
+	}
+	if got := req.Request.SystemInstruction.Parts[0].Text; got != "card "+RedactedText {
+		t.Errorf("Expected redacted system text, got %q", got)
+	}
+
+	for _, text := range []string{"this is FORBIDDEN", "please DROP  TABLE users"} {
+		err := m.Apply(context.Background(), newRequest("", text))
+		if _, ok := err.(*BlockedError); !ok {
+			t.Errorf("Expected %q to be blocked, got %v", text, err)
+		}
+	}
+
+	// 只审核 user 消息，model 回复不参与
+	strict := New(&config.Config{ModerationBlockWords: []string{"secret"}})
+	if err := strict.Apply(context.Background(), newRequest("", "hi")); err != nil {
+		t.Errorf("Expected model turns to be ignored, got %v", err)
+	}
+}
+
+func TestApplyRemote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results":[{"flagged":true,"categories":{"violence":true,"hate":false}}]}`))
+	}))
+	defer srv.Close()
+
+	m := New(&config.Config{ModerationURL: srv.URL})
+	err := m.Apply(context.Background(), newRequest("", "hello"))
+	if err == nil || err.Error() != "Request blocked by content policy: flagged by moderation (violence)" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// 审核服务不可用时放行
+	srv.Close()
+	if err := m.Apply(context.Background(), newRequest("", "hello")); err != nil {
+		t.Errorf("Expected fail-open, got %v", err)
+	}
+}
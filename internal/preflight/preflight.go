@@ -0,0 +1,269 @@
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "anti2api-golang/internal/auth" // 注册 Token 刷新函数
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/moderation"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
+)
+
+// 检查结果状态
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// upstreamTimeout 试调用上游的超时时间
+const upstreamTimeout = 30 * time.Second
+
+// Check 单项检查结果
+type Check struct {
+	Name     string
+	Status   string
+	Details  []string
+	Duration time.Duration
+}
+
+// Report 预检报告
+type Report struct {
+	Checks []Check
+}
+
+// Failed 是否存在失败的检查项
+func (r *Report) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 输出可读的检查摘要
+func (r *Report) Print(w io.Writer) {
+	counts := make(map[string]int)
+	fmt.Fprintln(w, "Preflight checks:")
+	for _, c := range r.Checks {
+		counts[c.Status]++
+		fmt.Fprintf(w, "  [%-4s] %-10s %s\n", strings.ToUpper(c.Status), c.Name, c.Duration.Round(time.Millisecond))
+		for _, detail := range c.Details {
+			fmt.Fprintf(w, "         - %s\n", detail)
+		}
+	}
+	fmt.Fprintf(w, "Result: %d ok, %d warnings, %d failed, %d skipped\n",
+		counts[StatusOK], counts[StatusWarn], counts[StatusFail], counts[StatusSkip])
+}
+
+// checker 收集单项检查的问题：fail 优先于 warn
+type checker struct {
+	check Check
+}
+
+func (c *checker) fail(format string, args ...interface{}) {
+	c.check.Status = StatusFail
+	c.check.Details = append(c.check.Details, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) warn(format string, args ...interface{}) {
+	if c.check.Status != StatusFail {
+		c.check.Status = StatusWarn
+	}
+	c.check.Details = append(c.check.Details, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) info(format string, args ...interface{}) {
+	c.check.Details = append(c.check.Details, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) skip(format string, args ...interface{}) {
+	c.check.Status = StatusSkip
+	c.check.Details = append(c.check.Details, fmt.Sprintf(format, args...))
+}
+
+// Run 依次执行配置、数据目录、账号存储、Token 刷新与上游试调用检查
+// 前置检查失败时后续依赖它的检查会被跳过
+func Run(ctx context.Context) *Report {
+	report := &Report{}
+	run := func(name string, fn func(c *checker)) string {
+		start := time.Now()
+		c := &checker{check: Check{Name: name, Status: StatusOK}}
+		fn(c)
+		c.check.Duration = time.Since(start)
+		report.Checks = append(report.Checks, c.check)
+		return c.check.Status
+	}
+
+	cfg := config.Get()
+	run("config", func(c *checker) { checkConfig(c, cfg) })
+	dataStatus := run("data dir", func(c *checker) { checkDataDir(c, cfg.DataDir) })
+
+	var account *store.Account
+	accountsStatus := run("accounts", func(c *checker) {
+		if dataStatus == StatusFail {
+			c.skip("data dir is not usable")
+			return
+		}
+		checkAccounts(c, filepath.Join(cfg.DataDir, "accounts.json"))
+	})
+	run("token", func(c *checker) {
+		if accountsStatus == StatusFail || accountsStatus == StatusSkip {
+			c.skip("account storage is not usable")
+			return
+		}
+		account = checkTokenRefresh(c)
+	})
+	run("upstream", func(c *checker) {
+		if account == nil {
+			c.skip("no refreshed account available")
+			return
+		}
+		checkUpstream(ctx, c, cfg, account)
+	})
+	return report
+}
+
+func checkConfig(c *checker, cfg *config.Config) {
+	if cfg.PanelPassword == "" {
+		c.fail("PANEL_PASSWORD is required")
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		c.fail("PORT %d is out of range", cfg.Port)
+	}
+	if cfg.Timeout <= 0 {
+		c.fail("TIMEOUT must be positive, got %d", cfg.Timeout)
+	}
+	if _, ok := config.APIEndpoints[cfg.EndpointMode]; !ok && cfg.EndpointMode != "round-robin" && cfg.EndpointMode != "round-robin-dp" {
+		c.fail("unknown ENDPOINT_MODE %q", cfg.EndpointMode)
+	}
+	if cfg.Proxy != "" {
+		if u, err := url.Parse(cfg.Proxy); err != nil || u.Host == "" {
+			c.fail("PROXY %q is not a valid URL", cfg.Proxy)
+		}
+	}
+	if data := os.Getenv("OUTPUT_FILTERS"); data != "" {
+		if _, err := config.ParseOutputFilters([]byte(data)); err != nil {
+			c.fail("OUTPUT_FILTERS: %v", err)
+		}
+	}
+	if _, err := moderation.NewClassifier(cfg, nil); err != nil {
+		c.fail("%v", err)
+	}
+	if cfg.APIKey == "" {
+		c.warn("API_KEY not set - API authentication disabled")
+	}
+	if c.check.Status == StatusOK {
+		c.info("port %d, endpoint mode %s", cfg.Port, cfg.EndpointMode)
+	}
+}
+
+func checkDataDir(c *checker, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.fail("cannot create %s: %v", dir, err)
+		return
+	}
+	probe, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		c.fail("%s is not writable: %v", dir, err)
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	c.info("%s is writable", dir)
+}
+
+// checkAccounts 解析账号存储文件（启动时加载失败会被静默忽略，这里单独报告）
+func checkAccounts(c *checker, path string) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		c.warn("%s does not exist, no accounts configured", path)
+		return
+	}
+	if err != nil {
+		c.fail("cannot read %s: %v", path, err)
+		return
+	}
+
+	var accounts []store.Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		c.fail("cannot parse %s: %v", path, err)
+		return
+	}
+
+	enabled := 0
+	for _, a := range accounts {
+		if a.Selectable() {
+			enabled++
+		}
+	}
+	if enabled == 0 {
+		c.warn("%d accounts, none enabled", len(accounts))
+		return
+	}
+	c.info("%d accounts, %d enabled", len(accounts), enabled)
+}
+
+// checkTokenRefresh 刷新首个启用账号的 Token
+func checkTokenRefresh(c *checker) *store.Account {
+	accountStore := store.GetAccountStore()
+	for i, a := range accountStore.GetAll() {
+		if !a.Selectable() {
+			continue
+		}
+		if err := accountStore.RefreshAccount(i); err != nil {
+			c.fail("refresh %s: %v", a.Email, err)
+			return nil
+		}
+		refreshed, err := accountStore.Get(i)
+		if err != nil {
+			c.fail("%v", err)
+			return nil
+		}
+		c.info("refreshed %s, expires at %s", a.Email, refreshed.ExpiresAt().Format(time.RFC3339))
+		return &refreshed
+	}
+	c.skip("no enabled accounts")
+	return nil
+}
+
+// checkUpstream 以最小请求（单 token 输出）试调用上游
+func checkUpstream(ctx context.Context, c *checker, cfg *config.Config, account *store.Account) {
+	if account.SessionID == "" {
+		account.SessionID = utils.GenerateSessionID()
+	}
+	req := &core.AntigravityRequest{
+		Project:   account.ProjectID,
+		RequestID: utils.GenerateRequestID(),
+		Request: core.AntigravityInnerReq{
+			Contents:         []core.Content{{Role: "user", Parts: []core.Part{{Text: "ping"}}}},
+			GenerationConfig: &core.GenerationConfig{CandidateCount: 1, MaxOutputTokens: 1},
+			SessionID:        account.SessionID,
+		},
+		Model:     core.ResolveModelName(cfg.PreflightModel),
+		UserAgent: cfg.UserAgent,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, upstreamTimeout)
+	defer cancel()
+
+	endpoint := config.GetEndpointManager().GetActiveEndpoint()
+	if _, err := vertex.GetClient().SendRequestTo(ctx, req, account, endpoint); err != nil {
+		c.fail("%s via %s: %v", cfg.PreflightModel, endpoint.Key, err)
+		return
+	}
+	c.info("%s via %s responded", cfg.PreflightModel, endpoint.Key)
+}
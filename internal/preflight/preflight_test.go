@@ -0,0 +1,70 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckAccounts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accounts.json")
+
+	tests := []struct {
+		content string
+		status  string
+	}{
+		{"", StatusWarn}, // 文件不存在
+		{"{not json", StatusFail},
+		{`[{"email":"a@example.com","enable":false}]`, StatusWarn},
+		{`[{"email":"a@example.com","enable":true},{"email":"b@example.com"}]`, StatusOK},
+	}
+	for _, tt := range tests {
+		os.Remove(path)
+		if tt.content != "" {
+			os.WriteFile(path, []byte(tt.content), 0644)
+		}
+		c := &checker{check: Check{Status: StatusOK}}
+		checkAccounts(c, path)
+		if c.check.Status != tt.status {
+			t.Errorf("%q: status = %s (%v), want %s", tt.content, c.check.Status, c.check.Details, tt.status)
+		}
+	}
+}
+
+func TestCheckDataDir(t *testing.T) {
+	c := &checker{check: Check{Status: StatusOK}}
+	checkDataDir(c, filepath.Join(t.TempDir(), "nested"))
+	if c.check.Status != StatusOK {
+		t.Errorf("status = %s (%v)", c.check.Status, c.check.Details)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	c = &checker{check: Check{Status: StatusOK}}
+	checkDataDir(c, file)
+	if c.check.Status != StatusFail {
+		t.Errorf("file as data dir: status = %s", c.check.Status)
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	report := &Report{Checks: []Check{
+		{Name: "config", Status: StatusOK},
+		{Name: "accounts", Status: StatusFail, Details: []string{"cannot parse"}},
+		{Name: "upstream", Status: StatusSkip},
+	}}
+	if !report.Failed() {
+		t.Error("Failed() = false")
+	}
+
+	var b strings.Builder
+	report.Print(&b)
+	out := b.String()
+	for _, want := range []string{"[FAIL] accounts", "- cannot parse", "1 ok, 0 warnings, 1 failed, 1 skipped"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -2,15 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"anti2api-golang/internal/auth"
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/i18n"
+	"anti2api-golang/internal/logger"
 	"anti2api-golang/internal/store"
 	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
 )
 
 // HandleGetSettings 获取设置
@@ -24,7 +30,14 @@ func HandleGetSettings(w http.ResponseWriter, r *http.Request) {
 			"name": "面板配置",
 			"items": []map[string]interface{}{
 				{"key": "PANEL_USER", "label": "面板用户名", "value": cfg.PanelUser, "isDefault": cfg.PanelUser == "admin", "defaultValue": "admin"},
-				{"key": "PANEL_PASSWORD", "label": "面板密码", "value": "******", "sensitive": true, "isDefault": false},
+				{"key": "PANEL_PASSWORD", "label": "面板密码", "value": valueOrDefault(cfg.PanelPassword, "未设置"), "isDefault": cfg.PanelPassword == ""},
+				{"key": "PANEL_PASSWORD_LOGIN", "label": "允许密码登录", "value": cfg.PanelPasswordLogin, "isDefault": cfg.PanelPasswordLogin, "defaultValue": true},
+				{"key": "PANEL_AUTH_ALLOWED", "label": "第三方登录允许身份", "value": valueOrDefault(strings.Join(cfg.PanelAuthAllowed, ", "), "未设置"), "isDefault": len(cfg.PanelAuthAllowed) == 0},
+				{"key": "PANEL_GITHUB_CLIENT_ID", "label": "GitHub Client ID", "value": valueOrDefault(cfg.PanelGitHubClientID, "未设置"), "isDefault": cfg.PanelGitHubClientID == ""},
+				{"key": "PANEL_GITHUB_CLIENT_SECRET", "label": "GitHub Client Secret", "value": valueOrDefault(cfg.PanelGitHubClientSecret, "未设置"), "isDefault": cfg.PanelGitHubClientSecret == ""},
+				{"key": "PANEL_OIDC_ISSUER", "label": "OIDC Issuer", "value": valueOrDefault(cfg.PanelOIDCIssuer, "未设置"), "isDefault": cfg.PanelOIDCIssuer == ""},
+				{"key": "PANEL_OIDC_CLIENT_ID", "label": "OIDC Client ID", "value": valueOrDefault(cfg.PanelOIDCClientID, "未设置"), "isDefault": cfg.PanelOIDCClientID == ""},
+				{"key": "PANEL_OIDC_CLIENT_SECRET", "label": "OIDC Client Secret", "value": valueOrDefault(cfg.PanelOIDCClientSecret, "未设置"), "isDefault": cfg.PanelOIDCClientSecret == ""},
 			},
 		},
 		{
@@ -39,13 +52,29 @@ func HandleGetSettings(w http.ResponseWriter, r *http.Request) {
 		{
 			"name": "API 配置",
 			"items": []map[string]interface{}{
-				{"key": "API_KEY", "label": "API密钥", "value": maskString(cfg.APIKey), "sensitive": true, "isDefault": cfg.APIKey == ""},
+				{"key": "API_KEY", "label": "API密钥", "value": valueOrDefault(cfg.APIKey, "未设置"), "isDefault": cfg.APIKey == ""},
 				{"key": "ENDPOINT_MODE", "label": "端点模式", "value": epMgr.GetMode(), "isDefault": os.Getenv("ENDPOINT_MODE") == "", "defaultValue": "daily"},
-				{"key": "DEBUG", "label": "调试级别", "value": cfg.Debug, "isDefault": cfg.Debug == "off", "defaultValue": "off"},
+				{"key": "EXPOSE_ENDPOINT_HEADER", "label": "返回端点响应头", "value": cfg.ExposeEndpointHeader, "isDefault": !cfg.ExposeEndpointHeader, "defaultValue": false},
+				{"key": "USAGE_HEADERS", "label": "返回用量响应头", "value": cfg.UsageHeaders, "isDefault": cfg.UsageHeaders, "defaultValue": true},
+				{"key": "MASK_EMAILS", "label": "账号邮箱脱敏", "value": cfg.MaskEmails, "isDefault": cfg.MaskEmails, "defaultValue": true},
+				{"key": "DEBUG", "label": "调试级别", "value": logger.GetLevel().String(), "isDefault": logger.GetLevel() == logger.LogOff, "defaultValue": "off"},
+				{"key": "MIRROR_PERCENT", "label": "镜像流量比例(%，消耗原请求账号配额)", "value": cfg.MirrorPercent, "isDefault": cfg.MirrorPercent == 0, "defaultValue": 0},
+				{"key": "MIRROR_ENDPOINT", "label": "镜像端点", "value": valueOrDefault(cfg.MirrorEndpoint, "当前端点"), "isDefault": cfg.MirrorEndpoint == ""},
+				{"key": "MIRROR_MODEL", "label": "镜像模型", "value": valueOrDefault(cfg.MirrorModel, "同原请求"), "isDefault": cfg.MirrorModel == ""},
+				{"key": "LOG_MAX_BODY_SIZE", "label": "日志体最大长度", "value": cfg.LogMaxBodySize, "isDefault": cfg.LogMaxBodySize == 5000, "defaultValue": 5000},
+				{"key": "HEARTBEAT_INTERVAL", "label": "心跳间隔(ms)", "value": cfg.HeartbeatInterval, "isDefault": cfg.HeartbeatInterval == 1000, "defaultValue": 1000},
+				{"key": "HEARTBEAT_MAX_WAIT", "label": "心跳最长等待(s)", "value": cfg.HeartbeatMaxWait, "isDefault": cfg.HeartbeatMaxWait == 0, "defaultValue": 0},
+				{"key": "HEARTBEAT_STYLE", "label": "心跳形式", "value": cfg.HeartbeatStyle, "isDefault": cfg.HeartbeatStyle == "delta", "defaultValue": "delta"},
+				{"key": "STREAM_QUEUE_SIZE", "label": "流式输出队列长度", "value": cfg.StreamQueueSize, "isDefault": cfg.StreamQueueSize == 256, "defaultValue": 256},
+				{"key": "STREAM_SLOW_CLIENT", "label": "慢客户端处理", "value": cfg.StreamSlowClient, "isDefault": cfg.StreamSlowClient == "block", "defaultValue": "block"},
+				{"key": "STREAM_MAX_DURATION", "label": "流式最长持续时间(s)", "value": cfg.StreamMaxDuration, "isDefault": cfg.StreamMaxDuration == 0, "defaultValue": 0},
+				{"key": "STREAM_MAX_DURATION_THINKING", "label": "思考流式最长持续时间(s)", "value": cfg.ThinkingMaxDuration, "isDefault": cfg.ThinkingMaxDuration == 0, "defaultValue": 0},
 			},
 		},
 	}
 
+	maskSecretSettings(groups)
+
 	WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"groups":    groups,
 		"updatedAt": time.Now().Format(time.RFC3339),
@@ -59,14 +88,66 @@ func valueOrDefault(val, def string) string {
 	return val
 }
 
-func maskString(s string) string {
-	if s == "" {
-		return "未设置"
+// maskSecretSettings 按敏感配置注册表标记并脱敏配置项（未设置时显示的占位值不脱敏）
+func maskSecretSettings(groups []map[string]interface{}) {
+	for _, group := range groups {
+		for _, item := range group["items"].([]map[string]interface{}) {
+			key, _ := item["key"].(string)
+			if !config.IsSecretKey(key) {
+				continue
+			}
+			item["sensitive"] = true
+			if isDefault, _ := item["isDefault"].(bool); isDefault {
+				continue
+			}
+			if value, ok := item["value"].(string); ok {
+				item["value"] = config.MaskSetting(key, value)
+			}
+		}
 	}
-	if len(s) <= 8 {
-		return "****"
+}
+
+// auditCaller 审计日志中的调用方描述
+func auditCaller(r *http.Request) string {
+	if strings.HasPrefix(auth.GetSessionToken(r), auth.APITokenPrefix) {
+		return "api token"
 	}
-	return s[:4] + "****" + s[len(s)-4:]
+	return "panel session"
+}
+
+// auditSettingChange 记录配置变更审计日志，敏感项按注册表脱敏
+func auditSettingChange(r *http.Request, key, oldValue, newValue string) {
+	logger.Info("Audit: setting %s changed from %q to %q (%s from %s)",
+		key, config.MaskSetting(key, oldValue), config.MaskSetting(key, newValue), auditCaller(r), r.RemoteAddr)
+}
+
+// revealEmails 判断本次请求是否返回完整邮箱
+// MASK_EMAILS=false 时始终返回；否则需显式携带 ?reveal=true，只读令牌不允许，且每次都记录审计日志
+func revealEmails(r *http.Request) bool {
+	if !config.Get().MaskEmails {
+		return true
+	}
+	if r.URL.Query().Get("reveal") != "true" {
+		return false
+	}
+
+	if token := auth.GetSessionToken(r); strings.HasPrefix(token, auth.APITokenPrefix) {
+		scope, _ := auth.GetAPITokenStore().Validate(token)
+		if scope != auth.ScopeAdmin {
+			return false
+		}
+	}
+
+	logger.Info("Audit: unmasked account emails revealed via %s %s (%s from %s)", r.Method, r.URL.Path, auditCaller(r), r.RemoteAddr)
+	return true
+}
+
+// displayEmail 按请求的脱敏设置返回邮箱
+func displayEmail(email string, reveal bool) string {
+	if reveal {
+		return email
+	}
+	return maskEmail(email)
 }
 
 // maskEmail 对邮箱地址进行脱敏，只显示第一个字符
@@ -95,11 +176,35 @@ func maskEmail(email string) string {
 	return maskedUsername + "@" + maskedDomain
 }
 
+// HandleSetDebugLevel 运行时切换调试级别（不持久化，重启后恢复 DEBUG 配置）
+func HandleSetDebugLevel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Level string `json:"level"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	oldLevel := logger.GetLevel().String()
+	if err := logger.SetLevel(req.Level); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	auditSettingChange(r, "DEBUG", oldLevel, logger.GetLevel().String())
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"level":   logger.GetLevel().String(),
+	})
+}
+
 // HandleGetEndpoints 获取端点信息
 func HandleGetEndpoints(w http.ResponseWriter, r *http.Request) {
 	epMgr := config.GetEndpointManager()
 	allEndpoints := epMgr.GetAllEndpoints()
-	mode := epMgr.GetMode()
+	mode := epMgr.ActiveMode()
 
 	// 转换为前端期望的格式
 	endpoints := make([]map[string]interface{}, 0)
@@ -107,9 +212,10 @@ func HandleGetEndpoints(w http.ResponseWriter, r *http.Request) {
 
 	for key, ep := range allEndpoints {
 		item := map[string]interface{}{
-			"key":   key,
-			"label": ep.Label,
-			"host":  ep.Host,
+			"key":     key,
+			"label":   ep.Label,
+			"host":    ep.Host,
+			"breaker": config.GetEndpointBreaker().Status(key),
 		}
 		endpoints = append(endpoints, item)
 
@@ -132,6 +238,8 @@ func HandleGetEndpoints(w http.ResponseWriter, r *http.Request) {
 		"endpoints": endpoints,
 		"current":   current,
 		"mode":      mode,
+		"baseMode":  epMgr.GetMode(),
+		"schedule":  epMgr.GetSchedule(),
 	})
 }
 
@@ -191,10 +299,12 @@ func HandleSetEndpointMode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	epMgr := config.GetEndpointManager()
+	oldMode := epMgr.GetMode()
 	if err := epMgr.SetMode(req.Mode); err != nil {
 		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	auditSettingChange(r, "ENDPOINT_MODE", oldMode, req.Mode)
 
 	WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -203,6 +313,81 @@ func HandleSetEndpointMode(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleGetEndpointSchedule 获取端点模式定时规则
+func HandleGetEndpointSchedule(w http.ResponseWriter, r *http.Request) {
+	epMgr := config.GetEndpointManager()
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rules":      epMgr.GetSchedule(),
+		"activeMode": epMgr.ActiveMode(),
+	})
+}
+
+// HandleSetEndpointSchedule 替换端点模式定时规则
+func HandleSetEndpointSchedule(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Rules []config.ScheduleRule `json:"rules"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	epMgr := config.GetEndpointManager()
+	if err := epMgr.SetSchedule(req.Rules); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"rules":      epMgr.GetSchedule(),
+		"activeMode": epMgr.ActiveMode(),
+	})
+}
+
+// HandleSetEndpointBreaker 手动熔断或恢复端点
+func HandleSetEndpointBreaker(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if _, ok := config.APIEndpoints[key]; !ok {
+		WriteError(w, http.StatusNotFound, "Unknown endpoint: "+key)
+		return
+	}
+
+	var req struct {
+		Action   string `json:"action"`
+		Duration int    `json:"duration"` // 秒，0 表示使用配置的冷却时间
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	breaker := config.GetEndpointBreaker()
+	switch req.Action {
+	case "trip":
+		breaker.Trip(key, time.Duration(req.Duration)*time.Second)
+	case "reset":
+		breaker.Reset(key)
+	default:
+		WriteError(w, http.StatusBadRequest, "action must be trip or reset")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"breaker": breaker.Status(key),
+	})
+}
+
+// HandleGetSchemaDrift 获取上游响应结构漂移统计
+func HandleGetSchemaDrift(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": config.Get().SchemaDriftCheck,
+		"drifts":  vertex.SchemaDriftReports(),
+	})
+}
+
 // HandleGetLogs 获取请求日志
 func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
@@ -213,13 +398,47 @@ func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	logs := store.GetLogStore().GetAll(limit)
+	// since（RFC 3339 或毫秒时间戳）仅返回之后新增的日志，供面板增量轮询
+	var since time.Time
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		if since, err = parseSince(sinceStr); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// 以日志版本号作为 ETag，未变化时直接返回 304，无需读取日志存储
+	logStore := store.GetLogStore()
+	etag := fmt.Sprintf(`"logs-%d-%d-%d"`, logStore.Version(), limit, since.UnixMilli())
+	if checkETag(w, r, etag) {
+		return
+	}
+
+	var logs []store.LogEntry
+	if since.IsZero() {
+		logs = logStore.GetAll(limit)
+	} else {
+		logs = logStore.GetSince(since, limit)
+	}
 
 	WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"logs": logs,
 	})
 }
 
+// parseSince 解析 since 参数：RFC 3339 时间或 Unix 毫秒时间戳
+func parseSince(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since: %s", s)
+	}
+	return t, nil
+}
+
 // HandleGetLogDetail 获取日志详情
 func HandleGetLogDetail(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -270,6 +489,7 @@ func HandleGetUsage(w http.ResponseWriter, r *http.Request) {
 func HandleGetAccounts(w http.ResponseWriter, r *http.Request) {
 	accounts := store.GetAccountStore().GetAll()
 	allUsage := store.GetLogStore().GetAllAccountsUsage()
+	reveal := revealEmails(r)
 
 	// 构建前端期望的格式
 	result := make([]map[string]interface{}, len(accounts))
@@ -304,20 +524,123 @@ func HandleGetAccounts(w http.ResponseWriter, r *http.Request) {
 
 		result[i] = map[string]interface{}{
 			"index":     i,
-			"email":     maskEmail(acc.Email),
+			"email":     displayEmail(acc.Email, reveal),
 			"projectId": acc.ProjectID,
 			"enable":    acc.Enable,
+			"archived":  acc.Archived(),
 			"expired":   acc.IsExpired(),
+			"cooldown":  acc.InCooldown(),
 			"createdAt": acc.CreatedAt.Format(time.RFC3339),
 			"usage":     usageData,
 		}
 	}
 
-	WriteJSON(w, http.StatusOK, map[string]interface{}{
+	// 账号列表包含随时间变化的冷却与过期状态，以响应体哈希作为 ETag
+	writeJSONWithETag(w, r, map[string]interface{}{
 		"accounts": result,
 	})
 }
 
+// HandleGetAccount 获取单个账号详情：Token 有效期、最近刷新结果、最近错误、按模型用量与冷却状态
+func HandleGetAccount(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid index")
+		return
+	}
+
+	acc, err := store.GetAccountStore().Get(index)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	now := time.Now()
+	logStore := store.GetLogStore()
+
+	token := map[string]interface{}{
+		"expired":          acc.IsExpired(),
+		"expiresAt":        nil,
+		"expiresInSeconds": 0,
+	}
+	if acc.Timestamp > 0 {
+		expiresAt := acc.ExpiresAt()
+		token["expiresAt"] = expiresAt.Format(time.RFC3339)
+		token["expiresInSeconds"] = max(int(expiresAt.Sub(now).Seconds()), 0)
+	}
+
+	var lastRefresh map[string]interface{}
+	if !acc.LastRefreshAt.IsZero() {
+		lastRefresh = map[string]interface{}{
+			"at":      acc.LastRefreshAt.Format(time.RFC3339),
+			"success": acc.LastRefreshError == "",
+			"error":   acc.LastRefreshError,
+		}
+	}
+
+	cooldown := map[string]interface{}{
+		"active":           acc.InCooldown(),
+		"until":            nil,
+		"remainingSeconds": 0,
+	}
+	if acc.InCooldown() {
+		cooldown["until"] = acc.CooldownUntil.Format(time.RFC3339)
+		cooldown["remainingSeconds"] = int(acc.CooldownUntil.Sub(now).Seconds())
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"index":        index,
+		"email":        displayEmail(acc.Email, revealEmails(r)),
+		"projectId":    acc.ProjectID,
+		"enable":       acc.Enable,
+		"archivedAt":   acc.ArchivedAt,
+		"createdAt":    acc.CreatedAt.Format(time.RFC3339),
+		"token":        token,
+		"lastRefresh":  lastRefresh,
+		"cooldown":     cooldown,
+		"usageByModel": logStore.GetAccountModelUsage(acc.Email, acc.ProjectID),
+		"recentErrors": logStore.GetAccountErrors(acc.Email, acc.ProjectID, 20),
+	})
+}
+
+// HandleGetAccountUsage 单个账号按模型的请求/Token 用量与错误类别（基于持久化的按天汇总）
+// 查询参数 days 默认 30，最多为汇总保留天数
+func HandleGetAccountUsage(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid index")
+		return
+	}
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > store.RollupRetentionDays {
+			WriteError(w, http.StatusBadRequest, fmt.Sprintf("days must be an integer between 1 and %d", store.RollupRetentionDays))
+			return
+		}
+	}
+
+	acc, err := store.GetAccountStore().Get(index)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	report := store.GetUsageRollups().AccountReport(acc.Email, acc.ProjectID, days)
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"index":     index,
+		"email":     displayEmail(acc.Email, revealEmails(r)),
+		"projectId": acc.ProjectID,
+		"days":      days,
+		"since":     report.Since,
+		"totals":    report.Totals,
+		"models":    report.Models,
+		"errors":    report.Errors,
+		"daily":     report.Daily,
+	})
+}
+
 // HandleImportTOML 导入 TOML 格式账号
 func HandleImportTOML(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -410,7 +733,7 @@ func HandleToggleAccount(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
-// HandleDeleteAccount 删除账号
+// HandleDeleteAccount 删除账号：归档（软删除），保留 Token 与用量历史，可通过 restore 恢复
 func HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 	indexStr := r.PathValue("index")
 	index, err := strconv.Atoi(indexStr)
@@ -419,10 +742,49 @@ func HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := store.GetAccountStore().Delete(index); err != nil {
+	if err := store.GetAccountStore().Archive(index); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]bool{"success": true, "archived": true})
+}
+
+// HandleRestoreAccount 恢复已归档的账号
+func HandleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	indexStr := r.PathValue("index")
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid index")
+		return
+	}
+
+	if err := store.GetAccountStore().Restore(index); err != nil {
 		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	WriteJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
+
+// HandlePurgeAccount 永久删除已归档的账号
+func HandlePurgeAccount(w http.ResponseWriter, r *http.Request) {
+	indexStr := r.PathValue("index")
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid index")
+		return
+	}
+
+	if err := store.GetAccountStore().Purge(index); err != nil {
+		status := http.StatusBadRequest
+		var coded *i18n.Error
+		if errors.As(err, &coded) && coded.Code == i18n.AccountNotArchived {
+			status = http.StatusConflict
+		}
+		WriteError(w, status, err.Error())
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
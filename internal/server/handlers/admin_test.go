@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/store"
+)
+
+func TestGetSettingsMasksSecrets(t *testing.T) {
+	cfg := config.Get()
+	defer func(key, password, secret string) {
+		cfg.APIKey, cfg.PanelPassword, cfg.PanelOIDCClientSecret = key, password, secret
+	}(cfg.APIKey, cfg.PanelPassword, cfg.PanelOIDCClientSecret)
+	cfg.APIKey = "sk-secretapikey-1234"
+	cfg.PanelPassword = "hunter2-password"
+	cfg.PanelOIDCClientSecret = "oidc-client-secret-value"
+
+	w := httptest.NewRecorder()
+	HandleGetSettings(w, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	body := w.Body.String()
+	for _, secret := range []string{"secretapikey", "hunter2", "oidc-client-secret"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("settings response leaks %q: %s", secret, body)
+		}
+	}
+	if !strings.Contains(body, `"sk-s****1234"`) {
+		t.Errorf("expected partially masked API key in %s", body)
+	}
+}
+
+// captureStdout 捕获 fn 执行期间写入标准输出的日志
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestRevealAccountEmails(t *testing.T) {
+	cfg := config.Get()
+	defer func(mask bool) { cfg.MaskEmails = mask }(cfg.MaskEmails)
+	cfg.MaskEmails = true
+
+	const email = "reveal-test@example.com"
+	if err := store.GetAccountStore().Add(store.Account{Email: email, RefreshToken: "reveal-test-refresh", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, readToken, err := auth.GetAPITokenStore().Create("reveal-read", auth.ScopeRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, adminToken, err := auth.GetAPITokenStore().Create("reveal-admin", auth.ScopeAdmin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(target, token string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		logs := captureStdout(t, func() { HandleGetAccounts(w, req) })
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", target, w.Code, w.Body.String())
+		}
+		return w.Body.String(), logs
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		reveal bool
+	}{
+		{"admin without reveal", "/admin/accounts", adminToken, false},
+		{"read token", "/admin/accounts?reveal=true", readToken, false},
+		{"admin token", "/admin/accounts?reveal=true", adminToken, true},
+	}
+	for _, tt := range tests {
+		body, logs := list(tt.target, tt.token)
+		if got := strings.Contains(body, email); got != tt.reveal {
+			t.Errorf("%s: unmasked email returned = %v, want %v: %s", tt.name, got, tt.reveal, body)
+		}
+		if !tt.reveal && !strings.Contains(body, maskEmail(email)) {
+			t.Errorf("%s: masked email missing: %s", tt.name, body)
+		}
+		if got := strings.Contains(logs, "Audit: unmasked account emails revealed via GET /admin/accounts (api token"); got != tt.reveal {
+			t.Errorf("%s: audit logged = %v, want %v: %q", tt.name, got, tt.reveal, logs)
+		}
+	}
+}
+
+func TestGetAccountDetail(t *testing.T) {
+	cfg := config.Get()
+	defer func(mask bool) { cfg.MaskEmails = mask }(cfg.MaskEmails)
+	cfg.MaskEmails = true
+
+	// 日志存储为进程级单例，使用唯一账号避免 -count 多次运行时互相影响
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	email, projectID := "detail-"+suffix+"@example.com", "detail-"+suffix
+	accounts := store.GetAccountStore()
+	err := accounts.Add(store.Account{
+		Email:        email,
+		ProjectID:    projectID,
+		AccessToken:  "detail-access",
+		RefreshToken: "refresh-" + suffix,
+		Timestamp:    time.Now().UnixMilli(),
+		ExpiresIn:    3600,
+		Enable:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := -1
+	for i, acc := range accounts.GetAll() {
+		if acc.Email == email {
+			index = i
+		}
+	}
+	accounts.SetCooldown(email, projectID, time.Now().Add(time.Minute))
+
+	logs := store.GetLogStore()
+	logs.Add(store.LogEntry{ID: "ok-" + suffix, Timestamp: time.Now(), Status: 200, Success: true, Email: email, ProjectID: projectID,
+		Model: "gemini-3-pro-high", Usage: &store.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}})
+	logs.Add(store.LogEntry{ID: "err-" + suffix, Timestamp: time.Now(), Status: 429, Email: email, ProjectID: projectID,
+		Model: "gemini-3-pro-high", Message: "quota exhausted"})
+
+	get := func(index string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/auth/accounts/"+index, nil)
+		r.SetPathValue("index", index)
+		w := httptest.NewRecorder()
+		HandleGetAccount(w, r)
+		return w
+	}
+
+	w := get(strconv.Itoa(index))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	var detail struct {
+		Email string `json:"email"`
+		Token struct {
+			Expired          bool `json:"expired"`
+			ExpiresInSeconds int  `json:"expiresInSeconds"`
+		} `json:"token"`
+		LastRefresh *struct{} `json:"lastRefresh"`
+		Cooldown    struct {
+			Active           bool `json:"active"`
+			RemainingSeconds int  `json:"remainingSeconds"`
+		} `json:"cooldown"`
+		UsageByModel []store.ModelUsage `json:"usageByModel"`
+		RecentErrors []store.LogEntry   `json:"recentErrors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+
+	if detail.Email == email {
+		t.Errorf("email should be masked by default: %s", detail.Email)
+	}
+	if detail.Token.Expired || detail.Token.ExpiresInSeconds < 3500 || detail.Token.ExpiresInSeconds > 3600 {
+		t.Errorf("token: %+v", detail.Token)
+	}
+	if detail.LastRefresh != nil {
+		t.Error("lastRefresh should be null before any refresh")
+	}
+	if !detail.Cooldown.Active || detail.Cooldown.RemainingSeconds <= 0 || detail.Cooldown.RemainingSeconds > 60 {
+		t.Errorf("cooldown: %+v", detail.Cooldown)
+	}
+	if len(detail.UsageByModel) != 1 || detail.UsageByModel[0].Count != 2 || detail.UsageByModel[0].Failed != 1 || detail.UsageByModel[0].TotalTokens != 15 {
+		t.Errorf("usageByModel: %+v", detail.UsageByModel)
+	}
+	if len(detail.RecentErrors) != 1 || detail.RecentErrors[0].ID != "err-"+suffix || detail.RecentErrors[0].Message != "quota exhausted" {
+		t.Errorf("recentErrors: %+v", detail.RecentErrors)
+	}
+
+	if w := get("abc"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid index: got %d", w.Code)
+	}
+	if w := get(strconv.Itoa(accounts.Count())); w.Code != http.StatusNotFound {
+		t.Errorf("out of range index: got %d", w.Code)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"anti2api-golang/internal/auth"
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
 	"anti2api-golang/internal/store"
 )
 
@@ -29,6 +31,10 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg := config.Get()
+	if !cfg.PanelPasswordLogin {
+		WriteError(w, http.StatusForbidden, "Password login is disabled")
+		return
+	}
 	if req.Username != cfg.PanelUser || req.Password != cfg.PanelPassword {
 		WriteError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
@@ -43,6 +49,100 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleAuthProviders 登录页可用的登录方式
+func HandleAuthProviders(w http.ResponseWriter, r *http.Request) {
+	providers := make([]map[string]string, 0)
+	for _, p := range auth.Providers() {
+		providers = append(providers, map[string]string{"name": p.Name(), "label": p.Label()})
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"passwordLogin": config.Get().PanelPasswordLogin,
+		"providers":     providers,
+	})
+}
+
+// HandleProviderLogin 跳转到第三方登录提供方
+func HandleProviderLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := auth.GetProvider(r.PathValue("provider"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	state := auth.CreateLoginState(provider.Name())
+	authURL, err := provider.AuthURL(providerCallbackURL(r, provider.Name()), state)
+	if err != nil {
+		logger.Warn("Panel login via %s unavailable: %v", provider.Name(), err)
+		redirectLoginError(w, r, "provider unavailable")
+		return
+	}
+
+	// state 同时写入 Cookie，回调时校验发起登录的是同一浏览器
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    state,
+		Path:     "/admin/auth/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   600,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// HandleProviderCallback 第三方登录回调：换取身份、校验允许列表并创建会话
+func HandleProviderCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := auth.GetProvider(r.PathValue("provider"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	cookie, err := r.Cookie(loginStateCookie)
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Path: "/admin/auth/", MaxAge: -1})
+	if err != nil || state == "" || cookie.Value != state || !auth.ConsumeLoginState(state, provider.Name()) {
+		redirectLoginError(w, r, "login session expired, please try again")
+		return
+	}
+	if query.Get("code") == "" {
+		redirectLoginError(w, r, "authorization was denied")
+		return
+	}
+
+	identity, err := provider.Identify(r.Context(), query.Get("code"), providerCallbackURL(r, provider.Name()))
+	if err != nil {
+		logger.Warn("Panel login via %s failed: %v", provider.Name(), err)
+		redirectLoginError(w, r, "login failed")
+		return
+	}
+	if !auth.IsIdentityAllowed(identity) {
+		logger.Warn("Panel login denied for %s", identity)
+		redirectLoginError(w, r, "this account is not allowed")
+		return
+	}
+
+	logger.Info("Panel login: %s", identity)
+	auth.SetSessionCookie(w, auth.CreateSession())
+	http.Redirect(w, r, "/admin/", http.StatusFound)
+}
+
+const loginStateCookie = "panel_login_state"
+
+// providerCallbackURL 第三方登录回调地址，需在提供方处登记
+func providerCallbackURL(r *http.Request, provider string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/admin/auth/%s/callback", scheme, r.Host, provider)
+}
+
+// redirectLoginError 带错误信息返回登录页
+func redirectLoginError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/admin/login?error="+url.QueryEscape(message), http.StatusFound)
+}
+
 // HandleLogout logout handler
 func HandleLogout(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("panel_session")
@@ -209,6 +309,10 @@ const loginPageHTML = `<!DOCTYPE html>
         .btn:hover { background: #2563eb; }
         .btn:disabled { background: #475569; cursor: not-allowed; }
         .error { color: #ef4444; text-align: center; margin-top: 15px; display: none; }
+        .providers { display: flex; flex-direction: column; gap: 10px; }
+        .providers .btn { display: block; text-align: center; text-decoration: none; background: #334155; }
+        .providers .btn:hover { background: #475569; }
+        .divider { text-align: center; color: #64748b; margin: 20px 0; display: none; }
     </style>
 </head>
 <body>
@@ -226,8 +330,35 @@ const loginPageHTML = `<!DOCTYPE html>
             <button type="submit" class="btn" id="submitBtn">Login</button>
             <p class="error" id="errorMsg"></p>
         </form>
+        <p class="divider" id="divider">or</p>
+        <div class="providers" id="providers"></div>
+        <p class="error" id="providerError"></p>
     </div>
     <script>
+        (async () => {
+            const params = new URLSearchParams(location.search);
+            if (params.get('error')) {
+                const el = document.getElementById('providerError');
+                el.textContent = params.get('error');
+                el.style.display = 'block';
+            }
+            try {
+                const data = await (await fetch('/admin/auth/providers')).json();
+                const list = document.getElementById('providers');
+                (data.providers || []).forEach(p => {
+                    const a = document.createElement('a');
+                    a.className = 'btn';
+                    a.href = '/admin/auth/' + encodeURIComponent(p.name) + '/login';
+                    a.textContent = 'Sign in with ' + p.label;
+                    list.appendChild(a);
+                });
+                if (data.passwordLogin === false) {
+                    document.getElementById('loginForm').style.display = 'none';
+                } else if ((data.providers || []).length) {
+                    document.getElementById('divider').style.display = 'block';
+                }
+            } catch (err) {}
+        })();
         document.getElementById('loginForm').addEventListener('submit', async (e) => {
             e.preventDefault();
             const btn = document.getElementById('submitBtn');
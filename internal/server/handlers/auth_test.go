@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/config"
+)
+
+// fakeOIDCIssuer 模拟 OIDC 提供方：discovery、token 与 userinfo 端点，userinfo 返回给定的声明
+func fakeOIDCIssuer(t *testing.T, claims map[string]interface{}) *httptest.Server {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at"})
+	})
+	mux.HandleFunc("GET /userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProviderLoginFlow(t *testing.T) {
+	issuer := fakeOIDCIssuer(t, map[string]interface{}{"sub": "user-1", "email": "alice@example.com", "email_verified": true})
+	cfg := config.Get()
+	defer func(issuer, id string, allowed []string) {
+		cfg.PanelOIDCIssuer, cfg.PanelOIDCClientID, cfg.PanelAuthAllowed = issuer, id, allowed
+	}(cfg.PanelOIDCIssuer, cfg.PanelOIDCClientID, cfg.PanelAuthAllowed)
+	cfg.PanelOIDCIssuer = issuer.URL
+	cfg.PanelOIDCClientID = "client"
+
+	login := func() (state string, cookie *http.Cookie) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/auth/oidc/login", nil)
+		req.SetPathValue("provider", "oidc")
+		w := httptest.NewRecorder()
+		HandleProviderLogin(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("login: expected 302, got %d", w.Code)
+		}
+		location, _ := url.Parse(w.Header().Get("Location"))
+		if !strings.HasPrefix(location.String(), issuer.URL+"/authorize?") {
+			t.Fatalf("unexpected redirect %s", location)
+		}
+		return location.Query().Get("state"), w.Result().Cookies()[0]
+	}
+	callback := func(state, code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/auth/oidc/callback?state="+state+"&code="+code, nil)
+		req.SetPathValue("provider", "oidc")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		HandleProviderCallback(w, req)
+		return w
+	}
+	sessionOf := func(w *httptest.ResponseRecorder) string {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "panel_session" {
+				return c.Value
+			}
+		}
+		return ""
+	}
+
+	// 未在允许列表中
+	cfg.PanelAuthAllowed = []string{"@other.com"}
+	state, cookie := login()
+	w := callback(state, "good-code", cookie)
+	if sessionOf(w) != "" || !strings.Contains(w.Header().Get("Location"), "error=") {
+		t.Fatalf("identity outside allow list should be rejected, got %v", w.Header())
+	}
+
+	// 按域名允许
+	cfg.PanelAuthAllowed = []string{"@example.com"}
+	state, cookie = login()
+	w = callback(state, "good-code", cookie)
+	if token := sessionOf(w); token == "" || !auth.ValidateSession(token) {
+		t.Fatalf("expected a valid session, got %v", w.Header())
+	}
+
+	// state 只能使用一次，且必须与发起登录的 Cookie 一致
+	if sessionOf(callback(state, "good-code", cookie)) != "" {
+		t.Error("state should not be reusable")
+	}
+	state, _ = login()
+	if sessionOf(callback(state, "good-code", nil)) != "" {
+		t.Error("callback without the state cookie should be rejected")
+	}
+}
+
+func TestIsIdentityAllowed(t *testing.T) {
+	cfg := config.Get()
+	defer func(v []string) { cfg.PanelAuthAllowed = v }(cfg.PanelAuthAllowed)
+
+	cfg.PanelAuthAllowed = nil
+	if auth.IsIdentityAllowed(&auth.Identity{Provider: "github", Subject: "583231"}) {
+		t.Error("empty allow list should reject everyone")
+	}
+
+	cfg.PanelAuthAllowed = []string{"github:583231", "bob@example.com"}
+	cases := []struct {
+		id   auth.Identity
+		want bool
+	}{
+		{auth.Identity{Provider: "github", Subject: "583231", Name: "octocat"}, true},
+		// 用户名仅用于显示，不参与授权
+		{auth.Identity{Provider: "github", Subject: "1", Name: "583231"}, false},
+		{auth.Identity{Provider: "oidc", Subject: "583231"}, false},
+		{auth.Identity{Provider: "oidc", Subject: "x", Email: "Bob@example.com"}, true},
+		{auth.Identity{Provider: "oidc", Subject: "x", Email: "eve@example.com"}, false},
+	}
+	for _, c := range cases {
+		if got := auth.IsIdentityAllowed(&c.id); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.id.String(), got, c.want)
+		}
+	}
+}
+
+// TestLoginStateCap 未消费的 state 数量有上限，超过时淘汰最早的 state
+func TestLoginStateCap(t *testing.T) {
+	first := auth.CreateLoginState("oidc")
+	for i := 0; i < 1000; i++ {
+		auth.CreateLoginState("oidc")
+	}
+	if auth.ConsumeLoginState(first, "oidc") {
+		t.Error("oldest state should have been evicted")
+	}
+	if !auth.ConsumeLoginState(auth.CreateLoginState("oidc"), "oidc") {
+		t.Error("new state should still be accepted")
+	}
+}
+
+// TestOIDCUnverifiedEmail 未提供或为 false 的 email_verified 不采用邮箱
+func TestOIDCUnverifiedEmail(t *testing.T) {
+	cfg := config.Get()
+	defer func(issuer, id string) {
+		cfg.PanelOIDCIssuer, cfg.PanelOIDCClientID = issuer, id
+	}(cfg.PanelOIDCIssuer, cfg.PanelOIDCClientID)
+
+	for _, claims := range []map[string]interface{}{
+		{"sub": "user-1", "email": "alice@example.com"},
+		{"sub": "user-1", "email": "alice@example.com", "email_verified": false},
+	} {
+		issuer := fakeOIDCIssuer(t, claims)
+		cfg.PanelOIDCIssuer = issuer.URL
+		cfg.PanelOIDCClientID = "client"
+		provider, ok := auth.GetProvider("oidc")
+		if !ok {
+			t.Fatal("oidc provider not configured")
+		}
+		identity, err := provider.Identify(context.Background(), "good-code", "http://localhost/callback")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Email != "" {
+			t.Errorf("claims %v: expected email to be ignored, got %q", claims, identity.Email)
+		}
+	}
+}
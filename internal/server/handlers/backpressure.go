@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
+)
+
+// 慢客户端处理策略（STREAM_SLOW_CLIENT）
+const (
+	slowClientBlock = "block" // 等待客户端读取，上游读取随之暂停
+	slowClientDrop  = "drop"  // 断开客户端，继续读完上游以完整记录日志与用量
+	slowClientAbort = "abort" // 终止请求并取消上游
+)
+
+// slowClientWait 输出队列写满后等待客户端的最长时间，超过后按策略处理
+var slowClientWait = 10 * time.Second
+
+// errSlowClient 客户端读取跟不上上游输出
+var errSlowClient = errors.New("client could not keep up with the stream")
+
+// queuedWriter 带有界输出队列的响应写入器：上游读取循环只负责入队，由独立 goroutine 写给客户端
+type queuedWriter struct {
+	http.ResponseWriter
+	policy string
+	queue  chan []byte
+	done   chan struct{}
+	abort  func()
+
+	mu  sync.Mutex
+	err error // 写给客户端失败或判定为慢客户端后，不再写出
+}
+
+// wrapStreamOutput 按 STREAM_QUEUE_SIZE 为流式响应加上输出队列，abort 用于取消上游请求
+// 返回的 finish 需在输出结束后调用：等待队列写完，并返回慢客户端错误（如有）
+func wrapStreamOutput(w http.ResponseWriter, abort func()) (http.ResponseWriter, func() error) {
+	cfg := config.Get()
+	if cfg.StreamQueueSize <= 0 {
+		return w, func() error { return nil }
+	}
+	q := &queuedWriter{
+		ResponseWriter: w,
+		policy:         cfg.StreamSlowClient,
+		queue:          make(chan []byte, cfg.StreamQueueSize),
+		done:           make(chan struct{}),
+		abort:          abort,
+	}
+	go q.run()
+	return q, q.finish
+}
+
+// run 依次写出队列中的数据，队列暂时为空时刷新，合并连续写入的刷新
+func (q *queuedWriter) run() {
+	defer close(q.done)
+	flusher, _ := q.ResponseWriter.(http.Flusher)
+	for data := range q.queue {
+		if q.failed() != nil {
+			continue
+		}
+		if _, err := q.ResponseWriter.Write(data); err != nil {
+			q.fail(err)
+			continue
+		}
+		if flusher != nil && len(q.queue) == 0 {
+			flusher.Flush()
+		}
+	}
+}
+
+func (q *queuedWriter) failed() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+func (q *queuedWriter) fail(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// Write 将数据放入队列；队列满时按策略等待、断开客户端或终止请求
+func (q *queuedWriter) Write(p []byte) (int, error) {
+	if err := q.failed(); err != nil {
+		if err == errSlowClient && q.policy != slowClientAbort {
+			return len(p), nil
+		}
+		return 0, err
+	}
+
+	data := append([]byte(nil), p...)
+	if q.policy != slowClientDrop && q.policy != slowClientAbort {
+		q.queue <- data
+		return len(p), nil
+	}
+
+	timer := time.NewTimer(slowClientWait)
+	defer timer.Stop()
+	select {
+	case q.queue <- data:
+		return len(p), nil
+	case <-timer.C:
+	}
+
+	q.fail(errSlowClient)
+	// 使写给客户端的阻塞调用立即返回，丢弃队列中剩余的数据
+	if err := http.NewResponseController(q.ResponseWriter).SetWriteDeadline(time.Now()); err != nil {
+		logger.Warn("Failed to set write deadline for slow client, output goroutine may stay blocked: %v", err)
+	}
+	if q.policy == slowClientAbort {
+		logger.Warn("Client too slow, aborting stream and upstream request")
+		if q.abort != nil {
+			q.abort()
+		}
+		return 0, errSlowClient
+	}
+	logger.Warn("Client too slow, dropping client output and draining upstream")
+	return len(p), nil
+}
+
+// Flush 实现 http.Flusher 接口；实际刷新由写出 goroutine 在队列清空时进行
+func (q *queuedWriter) Flush() {}
+
+// finish 关闭队列并等待写出完成，返回慢客户端错误（如有）
+func (q *queuedWriter) finish() error {
+	close(q.queue)
+	<-q.done
+	if err := q.failed(); err == errSlowClient {
+		return err
+	}
+	return nil
+}
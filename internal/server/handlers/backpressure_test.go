@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+// blockingWriter 在 release 关闭前阻塞所有写入，模拟读取缓慢的客户端
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestStreamOutputQueue(t *testing.T) {
+	cfg := config.Get()
+	defer func(size int, policy string, wait time.Duration) {
+		cfg.StreamQueueSize, cfg.StreamSlowClient, slowClientWait = size, policy, wait
+	}(cfg.StreamQueueSize, cfg.StreamSlowClient, slowClientWait)
+	cfg.StreamQueueSize = 2
+	slowClientWait = 20 * time.Millisecond
+
+	t.Run("block preserves order", func(t *testing.T) {
+		cfg.StreamSlowClient = slowClientBlock
+		rec := httptest.NewRecorder()
+		out, finish := wrapStreamOutput(rec, nil)
+		for _, s := range []string{"a", "b", "c", "d", "e"} {
+			if _, err := out.Write([]byte(s)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if err := finish(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if rec.Body.String() != "abcde" {
+			t.Errorf("Expected abcde, got %q", rec.Body.String())
+		}
+	})
+
+	for _, policy := range []string{slowClientDrop, slowClientAbort} {
+		t.Run(policy, func(t *testing.T) {
+			cfg.StreamSlowClient = policy
+			client := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+			var aborted atomic.Bool
+			out, finish := wrapStreamOutput(client, func() { aborted.Store(true) })
+
+			var writeErr error
+			for i := 0; i < 5 && writeErr == nil; i++ {
+				_, writeErr = out.Write([]byte("x"))
+			}
+			close(client.release)
+
+			if policy == slowClientAbort && writeErr != errSlowClient {
+				t.Errorf("Expected errSlowClient, got %v", writeErr)
+			}
+			if policy == slowClientDrop && writeErr != nil {
+				t.Errorf("Expected drop to keep accepting writes, got %v", writeErr)
+			}
+			if aborted.Load() != (policy == slowClientAbort) {
+				t.Errorf("Expected upstream abort=%v", policy == slowClientAbort)
+			}
+			if err := finish(); err != errSlowClient {
+				t.Errorf("Expected finish to report errSlowClient, got %v", err)
+			}
+		})
+	}
+}
+
+// TestStreamOutputQueueUnblocksStalledClient 客户端不再读取时，abort 策略须让写出 goroutine 及时返回，
+// 而不是阻塞到服务器写超时
+func TestStreamOutputQueueUnblocksStalledClient(t *testing.T) {
+	cfg := config.Get()
+	defer func(size int, policy string, wait time.Duration) {
+		cfg.StreamQueueSize, cfg.StreamSlowClient, slowClientWait = size, policy, wait
+	}(cfg.StreamQueueSize, cfg.StreamSlowClient, slowClientWait)
+	cfg.StreamQueueSize = 4
+	cfg.StreamSlowClient = slowClientAbort
+	slowClientWait = 100 * time.Millisecond
+
+	finished := make(chan error, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, timeline := withTimeline(r, time.Now())
+		out, finish := wrapStreamOutput(w, nil)
+		out = timeline.wrapWriter(out)
+		chunk := []byte(strings.Repeat("x", 64*1024))
+		for {
+			if _, err := out.Write(chunk); err != nil {
+				break
+			}
+		}
+		finished <- finish()
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n"))
+
+	select {
+	case err := <-finished:
+		if err != errSlowClient {
+			t.Errorf("Expected errSlowClient, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("output goroutine stayed blocked on a stalled client")
+	}
+}
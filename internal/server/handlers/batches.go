@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/batch"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/store"
+)
+
+// batchPaths 批处理请求在日志中记录的路径
+var batchPaths = map[string]string{
+	adapter.ProtocolClaude: "/v1/messages",
+	adapter.ProtocolOpenAI: "/v1/chat/completions",
+}
+
+var (
+	batches     *batch.Manager
+	batchesOnce sync.Once
+
+	files     *batch.FileStore
+	filesOnce sync.Once
+)
+
+// getBatchManager 获取批处理管理器单例
+func getBatchManager() *batch.Manager {
+	batchesOnce.Do(func() {
+		cfg := config.Get()
+		batches = batch.NewManager(filepath.Join(cfg.DataDir, "batches"), runBatchRequest, batch.Options{
+			Concurrency: func() int {
+				if cfg.BatchConcurrency > 0 {
+					return cfg.BatchConcurrency
+				}
+				return store.GetAccountStore().EnabledCount()
+			},
+			MaxRetries: cfg.BatchMaxRetries,
+			Backoff:    max(time.Duration(cfg.AccountCooldown)*time.Second, 5*time.Second),
+			OnEnd: func(b batch.Batch) {
+				if b.Protocol == adapter.ProtocolOpenAI {
+					ensureOpenAIBatchFiles(b)
+				}
+			},
+		})
+	})
+	return batches
+}
+
+// getFileStore 获取文件存储单例（/v1/files）
+func getFileStore() *batch.FileStore {
+	filesOnce.Do(func() {
+		files = batch.NewFileStore(filepath.Join(config.Get().DataDir, "files"))
+	})
+	return files
+}
+
+// ResumeBatches 启动时加载批处理并续跑未完成的请求
+func ResumeBatches() {
+	getBatchManager().Resume()
+}
+
+// runBatchRequest 通过常规请求流程（账号轮询、转换、审核、日志）执行单个批处理请求
+func runBatchRequest(ctx context.Context, protocol string, body []byte) (int, []byte) {
+	r := httptest.NewRequest(http.MethodPost, batchPaths[protocol], bytes.NewReader(body)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	serveAdapter(rec, r, adapter.MustGet(protocol), "")
+	return rec.Code, rec.Body.Bytes()
+}
+
+// requestBaseURL 返回客户端访问本服务使用的基础 URL
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/i18n"
+	"anti2api-golang/internal/store"
+)
+
+// checkBudget 每日 token 预算（全局或当前 API Key）已用尽时写出 429 并返回 false
+func checkBudget(w http.ResponseWriter, r *http.Request, a adapter.ErrorRenderer) bool {
+	exceeded, resetAt := store.GetTokenBudget().Exceeded(auth.GetAPIKey(r))
+	if !exceeded {
+		return true
+	}
+
+	retryAfter := max(int(math.Ceil(time.Until(resetAt).Seconds())), 1)
+	resetTime := resetAt.UTC().Format(time.RFC3339)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	adapter.WriteErrorDetails(w, a, http.StatusTooManyRequests, string(i18n.BudgetExceeded),
+		i18n.Message(clientLanguage(r), i18n.BudgetExceeded, resetTime),
+		map[string]interface{}{
+			"reset_at":    resetTime,
+			"retry_after": retryAfter,
+		})
+	return false
+}
+
+// chargeBudget 将上游已消耗的 token 计入每日预算
+func chargeBudget(r *http.Request, usage *core.UsageMetadata) {
+	if usage != nil {
+		store.GetTokenBudget().Charge(auth.GetAPIKey(r), usage.TotalTokenCount)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/adapter/claude"
+	"anti2api-golang/internal/batch"
+	"anti2api-golang/internal/utils"
+)
+
+// claudeBatchExpiry 批处理过期时间（与 Anthropic 一致，仅用于展示）
+const claudeBatchExpiry = 24 * time.Hour
+
+// ClaudeBatchRequest 创建消息批处理的请求
+type ClaudeBatchRequest struct {
+	Requests []struct {
+		CustomID string                 `json:"custom_id"`
+		Params   map[string]interface{} `json:"params"`
+	} `json:"requests"`
+}
+
+// claudeBatch 转换为 Anthropic message_batch 对象
+func claudeBatch(r *http.Request, b batch.Batch) map[string]interface{} {
+	resultsURL := interface{}(nil)
+	if b.Status == batch.StatusEnded {
+		resultsURL = requestBaseURL(r) + "/v1/messages/batches/" + b.ID + "/results"
+	}
+	return map[string]interface{}{
+		"id":                b.ID,
+		"type":              "message_batch",
+		"processing_status": b.Status,
+		"request_counts": map[string]int{
+			"processing": b.Counts.Processing,
+			"succeeded":  b.Counts.Succeeded,
+			"errored":    b.Counts.Errored,
+			"canceled":   b.Counts.Canceled,
+			"expired":    0,
+		},
+		"created_at":          b.CreatedAt.UTC().Format(time.RFC3339),
+		"expires_at":          b.CreatedAt.Add(claudeBatchExpiry).UTC().Format(time.RFC3339),
+		"ended_at":            formatOptionalTime(b.EndedAt),
+		"cancel_initiated_at": formatOptionalTime(b.CancelInitiatedAt),
+		"archived_at":         nil,
+		"results_url":         resultsURL,
+	}
+}
+
+func formatOptionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// HandleCreateClaudeBatch 创建消息批处理：请求在后台通过常规流程执行（强制非流式）
+func HandleCreateClaudeBatch(w http.ResponseWriter, r *http.Request) {
+	var req ClaudeBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		claude.WriteClaudeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid request: "+err.Error())
+		return
+	}
+
+	a := adapter.MustGet(adapter.ProtocolClaude)
+	items := make([]batch.Item, 0, len(req.Requests))
+	for _, entry := range req.Requests {
+		if entry.Params == nil {
+			claude.WriteClaudeError(w, http.StatusBadRequest, "invalid_request_error", "params is required for "+entry.CustomID)
+			return
+		}
+		delete(entry.Params, "stream")
+		body, _ := json.Marshal(entry.Params)
+		if _, err := a.ParseRequest(r, body); err != nil {
+			claude.WriteClaudeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid params for "+entry.CustomID+": "+err.Error())
+			return
+		}
+		items = append(items, batch.Item{CustomID: entry.CustomID, Body: body})
+	}
+
+	b, err := getBatchManager().Create(batch.Batch{ID: utils.GenerateMessageBatchID(), Protocol: adapter.ProtocolClaude}, items)
+	if err != nil {
+		claude.WriteClaudeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, claudeBatch(r, b))
+}
+
+// HandleListClaudeBatches 列出消息批处理
+func HandleListClaudeBatches(w http.ResponseWriter, r *http.Request) {
+	list := getBatchManager().List(adapter.ProtocolClaude)
+	data := make([]map[string]interface{}, 0, len(list))
+	for _, b := range list {
+		data = append(data, claudeBatch(r, b))
+	}
+
+	resp := map[string]interface{}{"data": data, "has_more": false, "first_id": nil, "last_id": nil}
+	if len(list) > 0 {
+		resp["first_id"] = list[0].ID
+		resp["last_id"] = list[len(list)-1].ID
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleGetClaudeBatch 获取消息批处理
+func HandleGetClaudeBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := getClaudeBatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	WriteJSON(w, http.StatusOK, claudeBatch(r, b))
+}
+
+// HandleCancelClaudeBatch 取消消息批处理
+func HandleCancelClaudeBatch(w http.ResponseWriter, r *http.Request) {
+	if _, ok := getClaudeBatch(w, r.PathValue("id")); !ok {
+		return
+	}
+	b, _ := getBatchManager().Cancel(r.PathValue("id"))
+	WriteJSON(w, http.StatusOK, claudeBatch(r, b))
+}
+
+// HandleGetClaudeBatchResults 以 JSONL 返回已结束批处理的结果
+func HandleGetClaudeBatchResults(w http.ResponseWriter, r *http.Request) {
+	if _, ok := getClaudeBatch(w, r.PathValue("id")); !ok {
+		return
+	}
+
+	results, err := getBatchManager().Results(r.PathValue("id"))
+	if errors.Is(err, batch.ErrNotEnded) {
+		claude.WriteClaudeError(w, http.StatusBadRequest, "invalid_request_error", "Batch is still processing; results are available once processing_status is ended")
+		return
+	}
+	if err != nil {
+		claude.WriteClaudeError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-jsonl")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for _, result := range results {
+		enc.Encode(map[string]interface{}{
+			"custom_id": result.CustomID,
+			"result":    claudeBatchResult(result),
+		})
+	}
+}
+
+// claudeBatchResult 转换单个结果：成功为 message，失败为 Claude 错误响应体
+func claudeBatchResult(result batch.Result) map[string]interface{} {
+	switch {
+	case result.Canceled:
+		return map[string]interface{}{"type": "canceled"}
+	case result.Succeeded():
+		return map[string]interface{}{"type": "succeeded", "message": result.Body}
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(result.Body, &body); err != nil {
+		body = map[string]interface{}{
+			"type":  "error",
+			"error": map[string]string{"type": "api_error", "message": string(result.Body)},
+		}
+	}
+	return map[string]interface{}{"type": "errored", "error": body}
+}
+
+// getClaudeBatch 查找 Claude 批处理，不存在时写出 404
+func getClaudeBatch(w http.ResponseWriter, id string) (batch.Batch, bool) {
+	b, err := getBatchManager().Get(id)
+	if err != nil || b.Protocol != adapter.ProtocolClaude {
+		claude.WriteClaudeError(w, http.StatusNotFound, "not_found_error", "Batch not found: "+id)
+		return batch.Batch{}, false
+	}
+	return b, true
+}
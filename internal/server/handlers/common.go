@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"anti2api-golang/internal/adapter"
 )
 
 // WriteJSON 写入 JSON 响应
@@ -18,28 +20,11 @@ func WriteError(w http.ResponseWriter, status int, message string) {
 	WriteJSON(w, status, map[string]interface{}{
 		"error": map[string]interface{}{
 			"message": message,
-			"type":    getErrorType(status),
+			"type":    adapter.ErrorType(status),
 		},
 	})
 }
 
-func getErrorType(status int) string {
-	switch {
-	case status == 400:
-		return "invalid_request_error"
-	case status == 401:
-		return "authentication_error"
-	case status == 403:
-		return "permission_error"
-	case status == 404:
-		return "not_found_error"
-	case status == 429:
-		return "rate_limit_error"
-	default:
-		return "server_error"
-	}
-}
-
 // HandleHealthz 健康检查
 func HandleHealthz(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, map[string]string{
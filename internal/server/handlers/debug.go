@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+// debugAccount 转换调试使用的占位账号，避免在输出中暴露真实项目与会话
+var debugAccount = store.Account{ProjectID: "debug-project", SessionID: "debug-session"}
+
+// debugRequest 调试端点的请求体，request 为原样的客户端请求
+// model 为 Gemini 协议的路径模型
+type debugRequest struct {
+	Protocol string          `json:"protocol"`
+	Model    string          `json:"model"`
+	Request  json.RawMessage `json:"request"`
+}
+
+// parseDebugRequest 按客户端协议解析调试请求并完成协议转换（不含审核与截断）
+// 失败时已写出错误响应，返回 ok=false
+func parseDebugRequest(w http.ResponseWriter, r *http.Request) (*http.Request, adapter.Adapter, adapter.Request, *core.AntigravityRequest, bool) {
+	var body debugRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return r, nil, nil, nil, false
+	}
+	if len(body.Request) == 0 {
+		WriteError(w, http.StatusBadRequest, "request is required")
+		return r, nil, nil, nil, false
+	}
+
+	a, ok := adapter.Get(body.Protocol)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "Unknown protocol: "+body.Protocol)
+		return r, nil, nil, nil, false
+	}
+
+	if body.Protocol == adapter.ProtocolGemini || body.Protocol == adapter.ProtocolGeminiRaw {
+		if body.Model == "" {
+			WriteError(w, http.StatusBadRequest, "model is required for Gemini protocol")
+			return r, nil, nil, nil, false
+		}
+		r.SetPathValue("model", body.Model)
+		r.SetPathValue("action", "generateContent")
+	}
+	req, err := a.ParseRequest(r, body.Request)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return r, nil, nil, nil, false
+	}
+
+	r = withVirtualModel(r, req.ModelName())
+	r = withRoutedVariant(r, targetModel(r, req))
+
+	account := debugAccount
+	antigravityReq, err := translateRequest(r, a, req, &account)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return r, nil, nil, nil, false
+	}
+	return r, a, req, antigravityReq, true
+}
+
+// HandleDebugTranslate 返回给定客户端请求转换后的 Antigravity 请求（不调用上游）
+// 请求体: {"protocol": "openai|claude|gemini|gemini-raw", "model": "Gemini 协议的路径模型", "request": {...}}
+// 内容审核与历史截断可能访问外部服务，此处不执行
+func HandleDebugTranslate(w http.ResponseWriter, r *http.Request) {
+	r, a, req, antigravityReq, ok := parseDebugRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result := map[string]interface{}{
+		"protocol":        a.Name(),
+		"requestedModel":  req.ModelName(),
+		"upstreamModel":   antigravityReq.Model,
+		"variant":         routedVariant(r),
+		"estimatedTokens": core.EstimateRequestTokens(antigravityReq),
+		"contextWindow":   core.GetContextWindow(antigravityReq.Model),
+		"request":         antigravityReq,
+	}
+	if vm, ok := virtualModel(r); ok {
+		result["virtualModel"] = vm
+	}
+	if err := checkContextLength(antigravityReq); err != nil {
+		result["contextError"] = err.Error()
+	}
+
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// HandleGetSSECapture 获取 SSE 抓取布防状态与已保存的抓取文件
+func HandleGetSSECapture(w http.ResponseWriter, r *http.Request) {
+	capture := vertex.GetSSECapture()
+	files, err := capture.List()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status": capture.Status(),
+		"files":  files,
+	})
+}
+
+// HandleArmSSECapture 布防 SSE 抓取：接下来 count 个流式请求的上游原始字节流写入数据目录
+// 请求体: {"count": 1, "model": "可选，仅抓取该上游模型"}，count 为 0 表示撤防
+func HandleArmSSECapture(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Count int    `json:"count"`
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	capture := vertex.GetSSECapture()
+	capture.Arm(body.Count, body.Model)
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"status":  capture.Status(),
+	})
+}
+
+// HandleDownloadSSECapture 下载抓取文件
+// 抓取内容包含完整对话，只读令牌不允许下载
+func HandleDownloadSSECapture(w http.ResponseWriter, r *http.Request) {
+	if token := auth.GetSessionToken(r); strings.HasPrefix(token, auth.APITokenPrefix) {
+		if scope, _ := auth.GetAPITokenStore().Validate(token); scope != auth.ScopeAdmin {
+			WriteError(w, http.StatusForbidden, "Token scope does not allow this operation")
+			return
+		}
+	}
+
+	f, ok := openCapture(w, r.PathValue("name"))
+	if !ok {
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+r.PathValue("name")+`"`)
+	io.Copy(w, f)
+}
+
+// HandleReplaySSECapture 将抓取文件作为上游响应回放给指定协议的发射器，返回客户端将收到的原始字节
+// 请求体与 /admin/debug/translate 相同，用于离线复现与比对发射器输出
+func HandleReplaySSECapture(w http.ResponseWriter, r *http.Request) {
+	f, ok := openCapture(w, r.PathValue("name"))
+	if !ok {
+		return
+	}
+	defer f.Close()
+
+	r, a, req, antigravityReq, ok := parseDebugRequest(w, r)
+	if !ok {
+		return
+	}
+
+	upstream := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       f,
+		Request:    r,
+	}
+	newToolArgRepairer(antigravityReq).wrapStream(upstream)
+	newOutputFilter(antigravityReq, req.ModelName()).wrapStream(upstream)
+
+	rec := httptest.NewRecorder()
+	if _, err := a.EmitStream(rec, req, antigravityReq, upstream); err != nil {
+		rec.Header().Set("X-Replay-Error", err.Error())
+	}
+
+	for key, values := range rec.Header() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(rec.Body.Bytes())
+}
+
+// openCapture 打开抓取文件，失败时写出错误响应
+func openCapture(w http.ResponseWriter, name string) (*os.File, bool) {
+	f, err := vertex.GetSSECapture().Open(name)
+	if errors.Is(err, vertex.ErrCaptureNotFound) {
+		WriteError(w, http.StatusNotFound, "Capture not found")
+		return nil, false
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return f, true
+}
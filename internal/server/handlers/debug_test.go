@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDebugTranslate(t *testing.T) {
+	body := `{"protocol":"openai","request":{"model":"gemini-2.5-flash","messages":[{"role":"user","content":"hi"}]}}`
+	r := httptest.NewRequest(http.MethodPost, "/admin/debug/translate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	HandleDebugTranslate(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var result struct {
+		UpstreamModel string `json:"upstreamModel"`
+		Request       struct {
+			Project string `json:"project"`
+			Model   string `json:"model"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.UpstreamModel != "gemini-2.5-flash" || result.Request.Model != "gemini-2.5-flash" {
+		t.Errorf("model = %q / %q", result.UpstreamModel, result.Request.Model)
+	}
+	if result.Request.Project != debugAccount.ProjectID {
+		t.Errorf("project = %q, want placeholder", result.Request.Project)
+	}
+
+	for _, body := range []string{
+		`{"protocol":"unknown","request":{}}`,
+		`{"protocol":"gemini","request":{"contents":[]}}`,
+		`{"protocol":"openai"}`,
+	} {
+		w := httptest.NewRecorder()
+		HandleDebugTranslate(w, httptest.NewRequest(http.MethodPost, "/admin/debug/translate", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,581 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/i18n"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
+)
+
+// serveAdapter 通用请求处理流程：解析 → 取账号 → 转换 → 上游请求 → 按协议写出
+// credential 非空时使用指定账号（email 或 projectId）
+func serveAdapter(w http.ResponseWriter, r *http.Request, a adapter.Adapter, credential string) {
+	// 读取原始请求体
+	rawBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	// 记录原始客户端请求
+	logger.ClientRequest(r.Context(), r.Method, r.URL.Path, rawBody)
+
+	// 反序列化用于业务逻辑
+	req, err := a.ParseRequest(r, rawBody)
+	if err != nil {
+		adapter.WriteAdapterError(w, a, http.StatusBadRequest, invalidRequestError(err))
+		return
+	}
+
+	// 虚拟模型解析，随后按目标模型进行 A/B 路由
+	r = withVirtualModel(r, req.ModelName())
+	r = withRoutedVariant(r, targetModel(r, req))
+	if err := checkModel(upstreamModel(r, req)); err != nil {
+		adapter.WriteAdapterError(w, a, http.StatusNotFound, localizeError(r, http.StatusNotFound, err))
+		return
+	}
+	if !checkBudget(w, r, a) {
+		return
+	}
+
+	// 获取 token（虚拟模型限定账号组）
+	var group []string
+	if vm, ok := virtualModel(r); ok {
+		group = vm.Accounts
+	}
+	exclude, err := excludedAccounts(r)
+	if err != nil {
+		a.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	token, status, err := selectAccount(credential, group, exclude)
+	if err != nil {
+		writeAccountError(w, r, a, status, err)
+		return
+	}
+	logger.RequestLogFrom(r.Context()).SetAccount(token.Email)
+
+	if req.IsStream() {
+		if hs, ok := a.(adapter.HeartbeatStreamer); ok && core.IsBypassModel(upstreamModel(r, req)) {
+			serveHeartbeatStream(w, r, a, hs, req, token)
+			return
+		}
+		serveStream(w, r, a, req, token)
+	} else {
+		serveNonStream(w, r, a, req, token)
+	}
+}
+
+// invalidRequestError 为请求解析错误加上 "Invalid request: " 前缀，保留错误码与出错字段
+func invalidRequestError(err error) error {
+	var adapterErr *adapter.Error
+	if !errors.As(err, &adapterErr) {
+		return errors.New("Invalid request: " + err.Error())
+	}
+	prefixed := *adapterErr
+	prefixed.Message = "Invalid request: " + prefixed.Message
+	return &prefixed
+}
+
+// writeAccountError 写出选择账号失败的错误（消息按客户端语言本地化）
+// 所有账号均在冷却中时返回 503 与 Retry-After，并在错误对象中附带预计恢复时间 estimated_availability
+func writeAccountError(w http.ResponseWriter, r *http.Request, a adapter.ErrorRenderer, status int, err error) {
+	var exhausted *store.PoolExhaustedError
+	if !errors.As(err, &exhausted) {
+		adapter.WriteAdapterError(w, a, status, localizeError(r, status, err))
+		return
+	}
+
+	retryAfter := int(math.Ceil(time.Until(exhausted.AvailableAt).Seconds()))
+	retryAfter = max(retryAfter, 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	setRateLimitHeaders(w, r)
+	adapter.WriteErrorDetails(w, a, http.StatusServiceUnavailable, string(i18n.PoolExhausted),
+		exhausted.Message(clientLanguage(r)),
+		map[string]interface{}{
+			"estimated_availability": exhausted.AvailableAt.UTC().Format(time.RFC3339),
+			"retry_after":            retryAfter,
+		})
+}
+
+// clientLanguage 按 Accept-Language 与 ERROR_LANGUAGE 选择客户端错误语言
+func clientLanguage(r *http.Request) string {
+	return i18n.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// localizeError 将带错误码的错误转换为按客户端语言本地化的 *adapter.Error，其他错误原样返回
+func localizeError(r *http.Request, status int, err error) error {
+	var coded *i18n.Error
+	if !errors.As(err, &coded) {
+		return err
+	}
+	return &adapter.Error{Status: status, Code: string(coded.Code), Message: coded.Message(clientLanguage(r))}
+}
+
+// checkModel 未开启 MODEL_PASSTHROUGH 时拒绝支持列表以外的模型
+func checkModel(model string) error {
+	if config.Get().ModelPassthrough || core.IsKnownModel(model) {
+		return nil
+	}
+	return i18n.New(i18n.ModelNotFound, model)
+}
+
+// excludedAccounts 解析 X-Exclude-Accounts 请求头（逗号分隔的 email 或 projectId）
+// 仅接受携带登录会话或管理 API 令牌（X-Session-Token）的请求，便于运维在线上流量中复现问题时避开指定账号
+func excludedAccounts(r *http.Request) ([]string, error) {
+	header := r.Header.Get("X-Exclude-Accounts")
+	if header == "" {
+		return nil, nil
+	}
+	if !isAdminRequest(r) {
+		return nil, errors.New("X-Exclude-Accounts requires an admin session or admin API token")
+	}
+
+	var exclude []string
+	for _, account := range strings.Split(header, ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			exclude = append(exclude, account)
+		}
+	}
+	logger.Info("Request %s %s excludes accounts: %s", r.Method, r.URL.Path, strings.Join(exclude, ", "))
+	return exclude, nil
+}
+
+// isAdminRequest 请求是否携带有效的登录会话或管理权限 API 令牌
+func isAdminRequest(r *http.Request) bool {
+	token := auth.GetSessionToken(r)
+	if strings.HasPrefix(token, auth.APITokenPrefix) {
+		scope, ok := auth.GetAPITokenStore().Validate(token)
+		return ok && scope == auth.ScopeAdmin
+	}
+	return token != "" && auth.ValidateSession(token)
+}
+
+// selectAccount 选择账号，返回失败时对应的 HTTP 状态码
+// credential 优先（不受 exclude 影响）；否则在 group 指定的账号组内轮询，group 为空时使用全部账号，跳过 exclude 中的账号
+func selectAccount(credential string, group []string, exclude []string) (*store.Account, int, error) {
+	accountStore := store.GetAccountStore()
+
+	if credential == "" && len(exclude) > 0 {
+		token, err := accountStore.GetTokenExcept(group, exclude)
+		if err != nil {
+			return nil, http.StatusServiceUnavailable, err
+		}
+		return token, http.StatusOK, nil
+	}
+
+	if credential == "" && len(group) > 0 {
+		token, err := accountStore.GetTokenFrom(group)
+		if err != nil {
+			return nil, http.StatusServiceUnavailable, err
+		}
+		return token, http.StatusOK, nil
+	}
+
+	if credential == "" {
+		token, err := accountStore.GetToken()
+		if err != nil {
+			return nil, http.StatusServiceUnavailable, err
+		}
+		return token, http.StatusOK, nil
+	}
+
+	var token *store.Account
+	var err error
+	if strings.Contains(credential, "@") {
+		token, err = accountStore.GetTokenByEmail(credential)
+	} else {
+		token, err = accountStore.GetTokenByProjectID(credential)
+	}
+	if err != nil {
+		return nil, http.StatusNotFound, i18n.New(i18n.CredentialNotFound, credential)
+	}
+	return token, http.StatusOK, nil
+}
+
+func serveNonStream(w http.ResponseWriter, r *http.Request, a adapter.Adapter, req adapter.Request, token *store.Account) {
+	startTime := time.Now()
+	r, timeline := withTimeline(r, startTime)
+
+	// 转换请求
+	antigravityReq, err := convertRequest(r, a, req, token)
+	if err != nil {
+		adapter.WriteAdapterError(w, a, http.StatusBadRequest, localizeError(r, http.StatusBadRequest, err))
+		return
+	}
+	timeline.span(traceConvert, startTime)
+
+	mirror := startMirror(antigravityReq, token)
+
+	// 发送请求
+	ctx, trace := vertex.WithRetryTrace(vertex.WithServing(r.Context()))
+	upstreamStart := time.Now()
+	resp, err := vertex.GenerateContent(ctx, antigravityReq, token)
+	timeline.span(traceUpstream, upstreamStart)
+	if err != nil {
+		duration := time.Since(startTime)
+		logger.ClientResponse(r.Context(), getErrorStatus(err), duration, err.Error())
+		// 记录失败日志
+		mirror.attach(recordLog(r, req, token, getErrorStatus(err), false, duration, err.Error(), "", failureInfo(err, trace)))
+		cooldownOnRateLimit(token, err)
+		setEndpointHeader(w, trace)
+		setRateLimitHeaders(w, r)
+		writeUpstreamError(w, a, err, token)
+		return
+	}
+
+	repair := newToolArgRepairer(antigravityReq)
+	repair.repairResponse(resp)
+	newOutputFilter(antigravityReq, req.ModelName()).filterResponse(resp)
+	info := responseInfo(resp, trace)
+	setEndpointHeader(w, trace)
+	setRateLimitHeaders(w, r)
+	setUsageHeaders(w, info.usage)
+
+	// 转换并写出响应
+	result, err := a.EmitResponse(timeline.wrapWriter(w), req, antigravityReq, resp)
+	timeline.mark(traceComplete)
+	duration := time.Since(startTime)
+	if err != nil {
+		logger.Error("%s response error: %v", a.Name(), err)
+		mirror.attach(recordLog(r, req, token, http.StatusInternalServerError, false, duration, err.Error(), "", info))
+		return
+	}
+
+	logger.ClientResponse(r.Context(), http.StatusOK, duration, result.Body)
+
+	// 记录成功日志
+	logID := recordLog(r, req, token, http.StatusOK, true, duration, "", result.Output, info)
+	mirror.attach(logID)
+	repair.attach(logID)
+}
+
+func serveStream(w http.ResponseWriter, r *http.Request, a adapter.Adapter, req adapter.Request, token *store.Account) {
+	startTime := time.Now()
+	r, timeline := withTimeline(r, startTime)
+
+	// 转换请求
+	antigravityReq, err := convertRequest(r, a, req, token)
+	if err != nil {
+		adapter.WriteAdapterError(w, a, http.StatusBadRequest, localizeError(r, http.StatusBadRequest, err))
+		return
+	}
+	timeline.span(traceConvert, startTime)
+
+	mirror := startMirror(antigravityReq, token)
+
+	// 发送流式请求（慢客户端按 abort 策略处理时取消上游）
+	ctx, cancelUpstream := context.WithCancel(vertex.WithServing(r.Context()))
+	defer cancelUpstream()
+	ctx, trace := vertex.WithRetryTrace(ctx)
+	upstreamStart := time.Now()
+	resp, err := vertex.GenerateContentStream(ctx, antigravityReq, token)
+	timeline.span(traceUpstream, upstreamStart)
+	if err != nil {
+		duration := time.Since(startTime)
+		logger.Error("%s stream request failed: %v", a.Name(), err)
+		// 先进入冷却，使限流响应头反映本次限流
+		cooldownOnRateLimit(token, err)
+		setEndpointHeader(w, trace)
+		setRateLimitHeaders(w, r)
+		a.WriteStreamError(w, getErrorStatus(err), clientErrorMessage(err, token))
+		// 记录失败日志
+		mirror.attach(recordLog(r, req, token, getErrorStatus(err), false, duration, err.Error(), "", failureInfo(err, trace)))
+		return
+	}
+
+	timeline.wrapStream(resp)
+	wrapStreamDeadline(resp, antigravityReq)
+	repair := newToolArgRepairer(antigravityReq)
+	repair.wrapStream(resp)
+	newOutputFilter(antigravityReq, req.ModelName()).wrapStream(resp)
+	setEndpointHeader(w, trace)
+	setRateLimitHeaders(w, r)
+	declareUsageTrailers(w)
+
+	// 处理流式响应：经输出队列写给客户端，慢客户端不直接阻塞上游读取
+	out, finishOutput := wrapStreamOutput(w, cancelUpstream)
+	result, err := a.EmitStream(timeline.wrapWriter(out), req, antigravityReq, resp)
+	if slowErr := finishOutput(); slowErr != nil {
+		err = slowErr
+	}
+	timeline.mark(traceComplete)
+	setUsageHeaders(w, result.Usage)
+
+	duration := time.Since(startTime)
+	info := upstreamInfo{usage: result.Usage, finishReason: result.FinishReason, trace: trace}
+
+	// 记录后端流式响应日志（原始 Vertex 格式，仅合并 text）
+	logger.BackendStreamResponse(r.Context(), http.StatusOK, duration, result.Backend)
+
+	var logID string
+	if err != nil {
+		logger.Error("%s stream processing error: %v", a.Name(), err)
+		// 记录失败日志
+		logID = recordLog(r, req, token, http.StatusInternalServerError, false, duration, err.Error(), result.Output, info)
+	} else {
+		// 记录成功日志
+		logID = recordLog(r, req, token, http.StatusOK, true, duration, "", result.Output, info)
+	}
+	mirror.attach(logID)
+	repair.attach(logID)
+
+	// 记录客户端流式响应日志（透传原始 SSE 事件）
+	logger.ClientStreamResponse(r.Context(), http.StatusOK, duration, result.Body)
+}
+
+// serveHeartbeatStream bypass 模式：上游使用非流式请求规避截断，下游以心跳保活
+// 日志记录与流式路径一致：用量、结束原因与重试记录
+// 响应头在首个心跳时已写出，因此该路径不返回 X-Upstream-Endpoint，端点仅记录在日志中；用量与流式路径一样通过 Trailer 返回
+// 该路径不使用流式输出队列：上游为非流式请求，慢客户端不会阻塞上游读取，且心跳由独立 goroutine 写出
+func serveHeartbeatStream(w http.ResponseWriter, r *http.Request, a adapter.Adapter, hs adapter.HeartbeatStreamer, req adapter.Request, token *store.Account) {
+	startTime := time.Now()
+	r, timeline := withTimeline(r, startTime)
+
+	setRateLimitHeaders(w, r)
+	declareUsageTrailers(w)
+	stream := hs.StartHeartbeatStream(timeline.wrapWriter(w), req)
+
+	// 立即发送第一个心跳，确保客户端计时器启动
+	if err := stream.Heartbeat(); err != nil {
+		return
+	}
+
+	// 启动心跳 goroutine
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	// stopHeartbeat 停止心跳并等待 goroutine 退出，之后的写出不会与心跳交错，处理函数返回后也不再写入
+	stopHeartbeat := func() {
+		close(done)
+		<-stopped
+	}
+
+	cfg := config.Get()
+	interval := time.Duration(cfg.HeartbeatInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := stream.Heartbeat(); err != nil {
+					return
+				}
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// 转换请求（Convert 内部会解析真实模型名）
+	antigravityReq, err := convertRequest(r, a, req, token)
+	if err != nil {
+		stopHeartbeat()
+		stream.Fail(i18n.Localize(err, clientLanguage(r)))
+		recordLog(r, req, token, http.StatusBadRequest, false, time.Since(startTime), err.Error(), "", upstreamInfo{})
+		return
+	}
+
+	timeline.span(traceConvert, startTime)
+
+	mirror := startMirror(antigravityReq, token)
+
+	// 执行非流式请求（HEARTBEAT_MAX_WAIT 限制最长等待时间）
+	upstreamCtx, trace := vertex.WithRetryTrace(vertex.WithServing(ctx))
+	if cfg.HeartbeatMaxWait > 0 {
+		var cancelWait context.CancelFunc
+		upstreamCtx, cancelWait = context.WithTimeout(upstreamCtx, time.Duration(cfg.HeartbeatMaxWait)*time.Second)
+		defer cancelWait()
+	}
+	upstreamStart := time.Now()
+	resp, err := vertex.GenerateContent(upstreamCtx, antigravityReq, token)
+	stopHeartbeat()
+	timeline.span(traceUpstream, upstreamStart)
+
+	duration := time.Since(startTime)
+	if err != nil {
+		status := getErrorStatus(err)
+		if errors.Is(upstreamCtx.Err(), context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+			err = fmt.Errorf("upstream did not respond within %ds", cfg.HeartbeatMaxWait)
+		}
+		logger.Error("%s heartbeat request failed: %v", a.Name(), err)
+		stream.Fail(clientErrorMessage(err, token))
+		// 记录失败日志
+		mirror.attach(recordLog(r, req, token, status, false, duration, err.Error(), "", failureInfo(err, trace)))
+		cooldownOnRateLimit(token, err)
+		return
+	}
+
+	repair := newToolArgRepairer(antigravityReq)
+	repair.repairResponse(resp)
+	newOutputFilter(antigravityReq, req.ModelName()).filterResponse(resp)
+	info := responseInfo(resp, trace)
+
+	// 记录后端响应日志
+	logger.BackendResponse(r.Context(), http.StatusOK, duration, resp)
+
+	result := stream.Finish(resp)
+	timeline.mark(traceComplete)
+	setUsageHeaders(w, info.usage)
+
+	// 记录成功日志
+	logID := recordLog(r, req, token, http.StatusOK, true, duration, "", result.Output, info)
+	mirror.attach(logID)
+	repair.attach(logID)
+
+	// 记录客户端流式响应日志
+	logger.ClientStreamResponse(r.Context(), http.StatusOK, duration, result.Body)
+}
+
+// setEndpointHeader 按配置在响应头中返回实际使用的上游端点，须在写出响应前调用
+func setEndpointHeader(w http.ResponseWriter, trace *vertex.RetryTrace) {
+	if !config.Get().ExposeEndpointHeader {
+		return
+	}
+	if endpoint := trace.Endpoint(); endpoint != "" {
+		w.Header().Set("X-Upstream-Endpoint", endpoint)
+	}
+}
+
+// upstreamInfo 上游调用信息（写入日志）
+type upstreamInfo struct {
+	usage        *core.UsageMetadata
+	finishReason string
+	trace        *vertex.RetryTrace
+	apiErr       *vertex.APIError
+}
+
+// failureInfo 上游请求失败时的调用信息（保留上游原始错误响应）
+func failureInfo(err error, trace *vertex.RetryTrace) upstreamInfo {
+	apiErr, _ := err.(*vertex.APIError)
+	return upstreamInfo{trace: trace, apiErr: apiErr}
+}
+
+// cooldownOnRateLimit 账号被上游限流时按 ACCOUNT_COOLDOWN 暂停轮询
+func cooldownOnRateLimit(token *store.Account, err error) {
+	apiErr, ok := err.(*vertex.APIError)
+	seconds := config.Get().AccountCooldown
+	if !ok || apiErr.Status != http.StatusTooManyRequests || seconds <= 0 || token == nil {
+		return
+	}
+
+	delay := max(time.Duration(seconds)*time.Second, apiErr.RetryDelay)
+	store.GetAccountStore().SetCooldown(token.Email, token.ProjectID, time.Now().Add(delay))
+	logger.Warn("Account %s rate limited, cooling down for %s", token.Email, delay)
+}
+
+// responseInfo 从非流式响应中提取上游调用信息
+func responseInfo(resp *core.AntigravityResponse, trace *vertex.RetryTrace) upstreamInfo {
+	info := upstreamInfo{usage: resp.Response.UsageMetadata, trace: trace}
+	if len(resp.Response.Candidates) > 0 {
+		info.finishReason = resp.Response.Candidates[0].FinishReason
+	} else if resp.Response.PromptFeedback.Blocked() {
+		info.finishReason = core.NoCandidatesFinishReason
+	}
+	return info
+}
+
+// recordLog 记录 API 调用日志，返回日志 ID；同时将上游用量计入每日预算
+func recordLog(r *http.Request, req adapter.Request, token *store.Account, status int, success bool, duration time.Duration, errMsg string, responseContent string, info upstreamInfo) string {
+	chargeBudget(r, info.usage)
+
+	// 与控制台日志块使用同一请求 ID
+	id := logger.RequestLogFrom(r.Context()).ID()
+	if id == "" {
+		id = utils.GenerateRequestID()
+	}
+
+	attempts := info.trace.Attempts()
+	entry := store.LogEntry{
+		ID:           id,
+		Timestamp:    time.Now(),
+		Status:       status,
+		Success:      success,
+		Model:        req.ModelName(),
+		Variant:      routedVariant(r),
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		DurationMs:   duration.Milliseconds(),
+		Message:      errMsg,
+		FinishReason: info.finishReason,
+		Attempts:     len(attempts),
+		Endpoint:     info.trace.Endpoint(),
+		HasDetail:    true,
+		Detail: &store.LogDetail{
+			Request: &store.RequestSnapshot{
+				Body: req.Body(),
+			},
+			Response: &store.ResponseSnapshot{
+				StatusCode:  status,
+				ModelOutput: responseContent,
+			},
+			Attempts: attempts,
+			Trace:    timelineFrom(r).snapshot(attempts),
+		},
+	}
+
+	if e := info.apiErr; e != nil {
+		snapshot := &store.UpstreamErrorSnapshot{StatusCode: e.Status, Body: e.Body}
+		if len(e.Headers) > 0 {
+			snapshot.Headers = make(map[string]string, len(e.Headers))
+			for key := range e.Headers {
+				snapshot.Headers[key] = e.Headers.Get(key)
+			}
+		}
+		entry.Detail.UpstreamError = snapshot
+	}
+
+	if u := info.usage; u != nil {
+		entry.Usage = &store.TokenUsage{
+			PromptTokens:     u.PromptTokenCount,
+			CompletionTokens: u.CandidatesTokenCount,
+			ThoughtsTokens:   u.ThoughtsTokenCount,
+			TotalTokens:      u.TotalTokenCount,
+		}
+	}
+
+	if token != nil {
+		entry.ProjectID = token.ProjectID
+		entry.Email = token.Email
+	}
+	if u, ok := req.(adapter.EndUserRequest); ok {
+		entry.User = u.EndUser()
+	}
+
+	store.GetLogStore().Add(entry)
+	return entry.ID
+}
+
+func getErrorStatus(err error) int {
+	if apiErr, ok := err.(*vertex.APIError); ok {
+		return apiErr.Status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/i18n"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+)
+
+func TestWriteAccountErrorPoolExhausted(t *testing.T) {
+	availableAt := time.Now().Add(90 * time.Second)
+	err := &store.PoolExhaustedError{AvailableAt: availableAt}
+
+	for _, protocol := range []string{adapter.ProtocolOpenAI, adapter.ProtocolClaude, adapter.ProtocolGemini} {
+		w := httptest.NewRecorder()
+		writeAccountError(w, httptest.NewRequest(http.MethodPost, "/", nil), adapter.MustGet(protocol), http.StatusServiceUnavailable, err)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status = %d", protocol, w.Code)
+		}
+		if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry < 89 || retry > 90 {
+			t.Errorf("%s: Retry-After = %q", protocol, w.Header().Get("Retry-After"))
+		}
+
+		var body struct {
+			Error struct {
+				Code                  string `json:"code"`
+				EstimatedAvailability string `json:"estimated_availability"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if body.Error.Code != "pool_exhausted" || body.Error.EstimatedAvailability != availableAt.UTC().Format(time.RFC3339) {
+			t.Errorf("%s: body = %s", protocol, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	writeAccountError(w, httptest.NewRequest(http.MethodPost, "/", nil), adapter.MustGet(adapter.ProtocolOpenAI), http.StatusNotFound, errors.New("Credential not found: x"))
+	if w.Code != http.StatusNotFound || w.Header().Get("Retry-After") != "" {
+		t.Errorf("plain error: status = %d, headers = %v", w.Code, w.Header())
+	}
+}
+
+func TestWriteAccountErrorLocalized(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	w := httptest.NewRecorder()
+	writeAccountError(w, r, adapter.MustGet(adapter.ProtocolOpenAI), http.StatusServiceUnavailable, i18n.New(i18n.NoAccounts))
+
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusServiceUnavailable || body.Error.Code != "no_accounts" || body.Error.Message != "No accounts configured" {
+		t.Errorf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExcludedAccounts(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if exclude, err := excludedAccounts(r); exclude != nil || err != nil {
+		t.Errorf("no header: %v, %v", exclude, err)
+	}
+
+	r.Header.Set("X-Exclude-Accounts", "a@example.com, b@example.com")
+	if _, err := excludedAccounts(r); err == nil {
+		t.Error("expected error without admin credentials")
+	}
+
+	r.Header.Set("X-Session-Token", "not-a-session")
+	if _, err := excludedAccounts(r); err == nil {
+		t.Error("expected error with invalid session")
+	}
+}
+
+func TestCheckModel(t *testing.T) {
+	cfg := config.Get()
+	defer func(v bool) { cfg.ModelPassthrough = v }(cfg.ModelPassthrough)
+
+	cfg.ModelPassthrough = true
+	if err := checkModel("gemini-9-ultra"); err != nil {
+		t.Errorf("passthrough: %v", err)
+	}
+
+	cfg.ModelPassthrough = false
+	if err := checkModel("gemini-3-pro-low-bypass"); err != nil {
+		t.Errorf("known model: %v", err)
+	}
+	var coded *i18n.Error
+	if err := checkModel("gemini-9-ultra"); !errors.As(err, &coded) || coded.Code != i18n.ModelNotFound {
+		t.Errorf("unknown model: %v", err)
+	}
+}
+
+func TestCheckToolLoop(t *testing.T) {
+	cfg := config.Get()
+	defer func(limit int, action string) {
+		cfg.ToolLoopLimit, cfg.ToolLoopAction = limit, action
+	}(cfg.ToolLoopLimit, cfg.ToolLoopAction)
+
+	newReq := func() *core.AntigravityRequest {
+		req := &core.AntigravityRequest{}
+		req.Request.Contents = []core.Content{{Role: "user", Parts: []core.Part{{Text: "go"}}}}
+		for i := 0; i < 3; i++ {
+			req.Request.Contents = append(req.Request.Contents,
+				core.Content{Role: "model", Parts: []core.Part{{FunctionCall: &core.FunctionCall{Name: "ls", Args: map[string]interface{}{"dir": "."}}}}},
+				core.Content{Role: "user", Parts: []core.Part{{FunctionResponse: &core.FunctionResponse{Name: "ls"}}}})
+		}
+		return req
+	}
+
+	cfg.ToolLoopLimit = 0
+	if req := newReq(); checkToolLoop(req) != nil || req.Request.SystemInstruction != nil {
+		t.Error("disabled detection should leave the request untouched")
+	}
+
+	cfg.ToolLoopLimit, cfg.ToolLoopAction = 3, "nudge"
+	if req := newReq(); checkToolLoop(req) != nil || req.Request.SystemInstruction == nil {
+		t.Error("nudge should be injected into the system instruction")
+	}
+
+	cfg.ToolLoopAction = "reject"
+	var adapterErr *adapter.Error
+	if err := checkToolLoop(newReq()); !errors.As(err, &adapterErr) || adapterErr.Code != "tool_loop_detected" || adapterErr.Status != http.StatusBadRequest {
+		t.Errorf("expected tool_loop_detected, got %v", err)
+	}
+
+	cfg.ToolLoopLimit = 4
+	if err := checkToolLoop(newReq()); err != nil {
+		t.Errorf("below limit: %v", err)
+	}
+}
+
+func TestSetUsageHeaders(t *testing.T) {
+	cfg := config.Get()
+	defer func(v bool) { cfg.UsageHeaders = v }(cfg.UsageHeaders)
+
+	usage := &core.UsageMetadata{PromptTokenCount: 12, CandidatesTokenCount: 30, ThoughtsTokenCount: 8, TotalTokenCount: 50}
+
+	cfg.UsageHeaders = true
+	w := httptest.NewRecorder()
+	declareUsageTrailers(w)
+	setUsageHeaders(w, usage)
+	if got := w.Header().Get("Trailer"); got != "X-Usage-Prompt-Tokens, X-Usage-Completion-Tokens, X-Usage-Total-Tokens" {
+		t.Errorf("Trailer = %q", got)
+	}
+	for header, want := range map[string]string{"X-Usage-Prompt-Tokens": "12", "X-Usage-Completion-Tokens": "38", "X-Usage-Total-Tokens": "50"} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	cfg.UsageHeaders = false
+	w = httptest.NewRecorder()
+	declareUsageTrailers(w)
+	setUsageHeaders(w, usage)
+	if len(w.Header()) != 0 {
+		t.Errorf("disabled: headers = %v", w.Header())
+	}
+}
+
+func TestSetAnthropicRateLimitHeaders(t *testing.T) {
+	resetAt := time.Now().Add(time.Minute)
+	h := http.Header{}
+	setAnthropicRateLimitHeaders(h, 3, 0, resetAt)
+	if h.Get("anthropic-ratelimit-requests-limit") != "3" || h.Get("anthropic-ratelimit-requests-remaining") != "0" ||
+		h.Get("anthropic-ratelimit-requests-reset") != resetAt.UTC().Format(time.RFC3339) {
+		t.Errorf("headers = %v", h)
+	}
+
+	h = http.Header{}
+	setAnthropicRateLimitHeaders(h, 3, 3, time.Time{})
+	if reset, err := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-requests-reset")); err != nil || time.Since(reset) > time.Minute {
+		t.Errorf("full pool: reset = %q", h.Get("anthropic-ratelimit-requests-reset"))
+	}
+}
+
+// TestRecordLogUsesRequestID 日志条目 ID 与控制台日志块的请求 ID 一致
+func TestRecordLogUsesRequestID(t *testing.T) {
+	body := `{"model":"gemini-3-pro","messages":[{"role":"user","content":"hi"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	ctx, reqLog := logger.WithRequestLog(r.Context())
+	r = r.WithContext(ctx)
+	req, err := adapter.MustGet(adapter.ProtocolOpenAI).ParseRequest(r, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := recordLog(r, req, &store.Account{Email: "a@example.com"}, http.StatusOK, true, time.Second, "", "ok", upstreamInfo{})
+	if id != reqLog.ID() {
+		t.Errorf("log id %q does not match request id %q", id, reqLog.ID())
+	}
+	if store.GetLogStore().GetByID(reqLog.ID()) == nil {
+		t.Error("log entry not found by request id")
+	}
+}
+
+func TestDispatchRecordsUpstreamInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"non-stream", `{"model":"gemini-3-pro-high","messages":[{"role":"user","content":"hi"}]}`},
+		{"stream", `{"model":"gemini-3-pro-high","stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+		{"heartbeat", `{"model":"gemini-3-pro-high-bypass","stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 首次尝试失败，重试后成功
+			email, calls := fakeUpstream(t, 1, 0)
+			w, entry := serveChat(t, email, tt.body)
+
+			if w.Code != http.StatusOK || !entry.Success {
+				t.Fatalf("got %d success=%v: %s", w.Code, entry.Success, w.Body.String())
+			}
+			if calls.Load() != 2 || entry.Attempts != 2 || len(entry.Detail.Attempts) != 2 || entry.Detail.Attempts[0].Status != http.StatusInternalServerError {
+				t.Errorf("attempts: upstream calls=%d logged=%d detail=%+v", calls.Load(), entry.Attempts, entry.Detail.Attempts)
+			}
+			if entry.FinishReason != "STOP" {
+				t.Errorf("finish reason = %q, want STOP", entry.FinishReason)
+			}
+			if entry.Usage == nil || entry.Usage.PromptTokens != 12 || entry.Usage.CompletionTokens != 7 || entry.Usage.TotalTokens != 19 {
+				t.Errorf("usage = %+v", entry.Usage)
+			}
+		})
+	}
+}
+
+func TestProtocolAdaptersRegistered(t *testing.T) {
+	protocols := []string{
+		adapter.ProtocolClaude, adapter.ProtocolGemini, adapter.ProtocolGeminiRaw,
+		adapter.ProtocolOpenAI, adapter.ProtocolOpenAICompletions, adapter.ProtocolOpenAIImages,
+	}
+	if names := adapter.Names(); strings.Join(names, ",") != strings.Join(protocols, ",") {
+		t.Errorf("registered adapters = %v, want %v", names, protocols)
+	}
+	for _, name := range protocols {
+		a := adapter.MustGet(name)
+		if a.Name() != name {
+			t.Errorf("adapter registered as %q reports name %q", name, a.Name())
+		}
+		if _, ok := a.(adapter.DetailedErrorWriter); !ok {
+			t.Errorf("%s adapter cannot write detailed errors", name)
+		}
+		// 仅 OpenAI 聊天协议支持 bypass 心跳流
+		if _, ok := a.(adapter.HeartbeatStreamer); ok != (name == adapter.ProtocolOpenAI) {
+			t.Errorf("%s adapter heartbeat support = %v", name, ok)
+		}
+	}
+}
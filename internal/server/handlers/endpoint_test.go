@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"testing"
+
+	"anti2api-golang/internal/config"
+)
+
+func TestUpstreamEndpointRecorded(t *testing.T) {
+	email, _ := fakeUpstream(t, 0, 0)
+	cfg := config.Get()
+	defer func(expose bool) { cfg.ExposeEndpointHeader = expose }(cfg.ExposeEndpointHeader)
+	endpoint := config.GetEndpointManager().CurrentEndpoint().Key
+	if endpoint == "" {
+		t.Fatal("no current endpoint")
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		expose bool
+	}{
+		{"non-stream", `{"model":"gemini-3-pro-high","messages":[{"role":"user","content":"hi"}]}`, true},
+		{"stream", `{"model":"gemini-3-pro-high","stream":true,"messages":[{"role":"user","content":"hi"}]}`, true},
+		{"header disabled", `{"model":"gemini-3-pro-high","messages":[{"role":"user","content":"hi"}]}`, false},
+	}
+	for _, tt := range tests {
+		cfg.ExposeEndpointHeader = tt.expose
+		w, entry := serveChat(t, email, tt.body)
+
+		if entry.Endpoint != endpoint {
+			t.Errorf("%s: logged endpoint = %q, want %q", tt.name, entry.Endpoint, endpoint)
+		}
+		want := ""
+		if tt.expose {
+			want = endpoint
+		}
+		if got := w.Header().Get("X-Upstream-Endpoint"); got != want {
+			t.Errorf("%s: X-Upstream-Endpoint = %q, want %q", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// checkETag 设置 ETag 与 Cache-Control: no-cache（浏览器每次携带 If-None-Match 重新验证）
+// 与 If-None-Match 匹配时写出 304 并返回 true，调用方无需再生成响应体
+func checkETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches If-None-Match 是否命中（弱比较，支持列表与 *）
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONWithETag 以响应体哈希作为 ETag 写出 JSON；客户端缓存仍有效时返回 304
+// 适用于包含随时间变化字段（如冷却、过期状态）、无法用版本号判断的响应
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, data interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha256.Sum256(body.Bytes())
+	if checkETag(w, r, `"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
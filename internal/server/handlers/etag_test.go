@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSONWithETag(t *testing.T) {
+	body := map[string]interface{}{"accounts": []string{"a"}}
+
+	w := httptest.NewRecorder()
+	writeJSONWithETag(w, httptest.NewRequest("GET", "/auth/accounts", nil), body)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.Len() == 0 {
+		t.Fatalf("status = %d, etag = %q", w.Code, etag)
+	}
+
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		r := httptest.NewRequest("GET", "/auth/accounts", nil)
+		r.Header.Set("If-None-Match", inm)
+		w = httptest.NewRecorder()
+		writeJSONWithETag(w, r, body)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: status = %d", inm, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/auth/accounts", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	writeJSONWithETag(w, r, map[string]interface{}{"accounts": []string{"a", "b"}})
+	if w.Code != http.StatusOK {
+		t.Errorf("changed body: status = %d", w.Code)
+	}
+}
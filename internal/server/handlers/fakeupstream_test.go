@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+// upstreamResponse 非流式上游响应：finishReason STOP，带用量统计
+const upstreamResponse = `{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"finishReason":"STOP"}],` +
+	`"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":7,"totalTokenCount":19}}}`
+
+// upstreamStream 流式上游响应，用量与 finishReason 在最后一个事件中
+const upstreamStream = "data: {\"response\":{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hel\"}]}}]}}\n\n" +
+	"data: {\"response\":{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"STOP\"}]," +
+	"\"usageMetadata\":{\"promptTokenCount\":12,\"candidatesTokenCount\":7,\"totalTokenCount\":19}}}\n\n"
+
+// upstreamFailure 可重试的上游错误，重试延迟设为 10ms 以免拖慢测试
+const upstreamFailure = `{"error":{"code":500,"message":"internal error","status":"INTERNAL",` +
+	`"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.01s"}]}}`
+
+// fakeUpstream 将所有端点与全局上游客户端指向本地 TLS 上游，并添加一个 Token 未过期的测试账号
+// 上游前 failures 次请求返回可重试的 500，之后延迟 delay 按请求路径返回流式或非流式响应；返回账号 email 与上游请求计数
+func fakeUpstream(t *testing.T, failures int32, delay time.Duration) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	stop := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			http.Error(w, upstreamFailure, http.StatusInternalServerError)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		}
+		if strings.Contains(r.URL.Path, "streamGenerateContent") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(upstreamStream))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(upstreamResponse))
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(stop) })
+
+	old := config.APIEndpoints
+	t.Cleanup(func() { config.APIEndpoints = old })
+	endpoints := make(map[string]config.Endpoint, len(old))
+	for key, ep := range old {
+		ep.Host = strings.TrimPrefix(srv.URL, "https://")
+		endpoints[key] = ep
+	}
+	config.APIEndpoints = endpoints
+	t.Cleanup(vertex.UseHTTPClient(srv.Client()))
+
+	cfg := config.Get()
+	attempts := cfg.RetryMaxAttempts
+	t.Cleanup(func() { cfg.RetryMaxAttempts = attempts })
+	cfg.RetryMaxAttempts = int(failures) + 1
+
+	email := strings.ToLower(strings.NewReplacer("/", "-", " ", "-").Replace(t.Name())) + "@example.com"
+	err := store.GetAccountStore().Add(store.Account{
+		Email:        email,
+		ProjectID:    "project-" + email,
+		AccessToken:  "test-access-token",
+		RefreshToken: "refresh-" + email,
+		Timestamp:    time.Now().UnixMilli(),
+		ExpiresIn:    3600,
+		Enable:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return email, &calls
+}
+
+// serveChat 以指定账号发送 OpenAI 聊天请求，返回响应与本次请求的日志记录
+func serveChat(t *testing.T, email, body string) (*httptest.ResponseRecorder, *store.LogEntry) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/"+email+"/v1/chat/completions", strings.NewReader(body))
+	r.SetPathValue("credential", email)
+	ctx, reqLog := logger.WithRequestLog(r.Context())
+	r = r.WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	HandleChatCompletionsWithCredential(w, r)
+	entry := store.GetLogStore().GetByID(reqLog.ID())
+	if entry == nil {
+		t.Fatalf("no log entry recorded for request %s (status %d: %s)", reqLog.ID(), w.Code, w.Body.String())
+	}
+	return w, entry
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGeminiPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		model  string
+		action string
+		ok     bool
+	}{
+		{"/v1beta/models/gemini-3-pro:generateContent", "gemini-3-pro", "generateContent", true},
+		{"/gemini/v1beta/models/gemini-3-pro:streamGenerateContent", "gemini-3-pro", "streamGenerateContent", true},
+		{"/user@example.com/v1beta/models/gemini-3-pro:generateContent", "gemini-3-pro", "generateContent", true},
+		{"/v1beta/models/gemini-3-pro", "", "", false},
+		{"/v1beta/models/gemini-3-pro:latest:generateContent", "gemini-3-pro:latest", "generateContent", true},
+		{"/v1beta/tunedModels/my-model:generateContent", "my-model", "generateContent", true},
+		{"/v1beta/models/gemini-3-pro:generateContent/extra", "", "", false},
+	}
+	for _, tt := range tests {
+		model, action, ok := parseGeminiPath(tt.path)
+		if model != tt.model || action != tt.action || ok != tt.ok {
+			t.Errorf("parseGeminiPath(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.path, model, action, ok, tt.model, tt.action, tt.ok)
+		}
+	}
+}
+
+func TestParseGeminiResource(t *testing.T) {
+	tests := []struct {
+		path string
+		want geminiResource
+	}{
+		{
+			"/v1beta/projects/p1/locations/us-central1/publishers/google/models/gemini-3-pro:generateContent",
+			geminiResource{Model: "gemini-3-pro", Action: "generateContent", Project: "p1", Location: "us-central1"},
+		},
+		{
+			"/cred/v1beta/models/projects/p1/models/tuned-1:streamGenerateContent",
+			geminiResource{Model: "tuned-1", Action: "streamGenerateContent", Project: "p1"},
+		},
+		{
+			"/gemini/v1beta/models/gemini-3-pro:generateContent",
+			geminiResource{Model: "gemini-3-pro", Action: "generateContent"},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := parseGeminiResource(tt.path)
+		if !ok || got != tt.want {
+			t.Errorf("parseGeminiResource(%q) = %+v, %v, want %+v", tt.path, got, ok, tt.want)
+		}
+	}
+}
+
+func FuzzParseGeminiPath(f *testing.F) {
+	f.Add("/v1beta/models/gemini-3-pro:generateContent")
+	f.Add("/gemini/v1beta/models/gemini-3-pro:streamGenerateContent")
+	f.Add("/v1beta/models/:")
+	f.Add(":")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		model, action, ok := parseGeminiPath(path)
+		if !ok {
+			return
+		}
+		if strings.Contains(action, ":") {
+			t.Errorf("action %q contains ':'", action)
+		}
+		if !strings.HasSuffix(path, model+":"+action) {
+			t.Errorf("(%q, %q) does not round-trip from %q", model, action, path)
+		}
+	})
+}
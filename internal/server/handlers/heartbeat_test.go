@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+const bypassBody = `{"model":"gemini-3-pro-high-bypass","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+
+// restoreHeartbeatConfig 测试结束后恢复心跳配置
+func restoreHeartbeatConfig(t *testing.T) *config.Config {
+	cfg := config.Get()
+	interval, maxWait, style := cfg.HeartbeatInterval, cfg.HeartbeatMaxWait, cfg.HeartbeatStyle
+	t.Cleanup(func() { cfg.HeartbeatInterval, cfg.HeartbeatMaxWait, cfg.HeartbeatStyle = interval, maxWait, style })
+	return cfg
+}
+
+// emptyDeltaChunks 统计响应中空 delta 的心跳数据包
+func emptyDeltaChunks(body string) int {
+	return strings.Count(body, `"delta":{},"finish_reason":null`)
+}
+
+func TestHeartbeatStyleAndInterval(t *testing.T) {
+	cfg := restoreHeartbeatConfig(t)
+	cfg.HeartbeatInterval = 50
+	cfg.HeartbeatMaxWait = 0
+
+	// 上游耗时 300ms，按 50ms 间隔应收到至少 4 个心跳（含立即发送的第一个）
+	t.Run("delta", func(t *testing.T) {
+		cfg.HeartbeatStyle = "delta"
+		email, _ := fakeUpstream(t, 0, 300*time.Millisecond)
+		w, entry := serveChat(t, email, bypassBody)
+		body := w.Body.String()
+
+		if !entry.Success || !strings.Contains(body, "Hello") {
+			t.Fatalf("request failed: %s", body)
+		}
+		if strings.Contains(body, ": keep-alive") {
+			t.Errorf("delta style should not send SSE comments: %s", body)
+		}
+		if n := emptyDeltaChunks(body); n < 4 || n > 8 {
+			t.Errorf("got %d empty delta heartbeats, want about 6: %s", n, body)
+		}
+	})
+
+	t.Run("comment", func(t *testing.T) {
+		cfg.HeartbeatStyle = "comment"
+		email, _ := fakeUpstream(t, 0, 300*time.Millisecond)
+		w, entry := serveChat(t, email, bypassBody)
+		body := w.Body.String()
+
+		if !entry.Success || !strings.Contains(body, "Hello") {
+			t.Fatalf("request failed: %s", body)
+		}
+		if n := strings.Count(body, ": keep-alive\n\n"); n < 4 || n > 8 {
+			t.Errorf("got %d keep-alive comments, want about 6: %s", n, body)
+		}
+		if n := emptyDeltaChunks(body); n != 0 {
+			t.Errorf("comment style sent %d empty delta chunks: %s", n, body)
+		}
+	})
+}
+
+func TestHeartbeatMaxWait(t *testing.T) {
+	cfg := restoreHeartbeatConfig(t)
+	cfg.HeartbeatInterval = 100
+	cfg.HeartbeatMaxWait = 1
+	cfg.HeartbeatStyle = "comment"
+
+	email, _ := fakeUpstream(t, 0, 5*time.Second)
+	start := time.Now()
+	w, entry := serveChat(t, email, bypassBody)
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("request took %v, want it cut off after about 1s", elapsed)
+	}
+	if entry.Success || entry.Status != http.StatusGatewayTimeout || entry.Message != "upstream did not respond within 1s" {
+		t.Errorf("log entry: status=%d success=%v message=%q", entry.Status, entry.Success, entry.Message)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "Error:") || !strings.Contains(body, "[DONE]") {
+		t.Errorf("client should receive an error and end of stream: %s", body)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/imaging"
+	"anti2api-golang/internal/logger"
+)
+
+// checkInlineData 预处理内联图片后校验内联数据的大小（INLINE_DATA_MAX_MB / INLINE_DATA_REQUEST_MAX_MB）
+// 单张图片超限且开启 INLINE_IMAGE_DOWNSCALE 时缩小到上限以内，其余超限情况返回 413
+func checkInlineData(req *core.AntigravityRequest) error {
+	cfg := config.Get()
+	transcodeInlineImages(req, cfg)
+
+	itemLimit := cfg.InlineDataMaxMB << 20
+	requestLimit := cfg.InlineDataRequestMaxMB << 20
+	if itemLimit <= 0 && requestLimit <= 0 {
+		return nil
+	}
+
+	total := 0
+	for i, data := range inlineDataItems(req) {
+		size := decodedSize(data.Data)
+		if itemLimit > 0 && size > itemLimit {
+			if !cfg.InlineImageDownscale || !strings.HasPrefix(data.MimeType, "image/") {
+				return inlineDataTooLarge(fmt.Sprintf("Inline data item %d (%s) is %s, exceeding the per-item limit of %d MB.",
+					i+1, data.MimeType, formatMB(size), cfg.InlineDataMaxMB))
+			}
+			if err := downscaleInlineData(data, itemLimit); err != nil {
+				return inlineDataTooLarge(fmt.Sprintf("Image %d (%s) is %s, exceeding the per-item limit of %d MB, and could not be downscaled: %v.",
+					i+1, data.MimeType, formatMB(size), cfg.InlineDataMaxMB, err))
+			}
+			logger.Info("Inline image %d downscaled from %s to %s", i+1, formatMB(size), formatMB(decodedSize(data.Data)))
+			size = decodedSize(data.Data)
+		}
+		total += size
+	}
+
+	if requestLimit > 0 && total > requestLimit {
+		return inlineDataTooLarge(fmt.Sprintf("Inline data in this request totals %s, exceeding the per-request limit of %d MB.",
+			formatMB(total), cfg.InlineDataRequestMaxMB))
+	}
+	return nil
+}
+
+// transcodeInlineImages 按 INLINE_IMAGE_MAX_DIMENSION / INLINE_IMAGE_TRANSCODE 缩小图片并转换格式
+// 无法处理的图片原样发送，由上游判断是否可用
+func transcodeInlineImages(req *core.AntigravityRequest, cfg *config.Config) {
+	opts := imaging.Options{MaxDimension: cfg.InlineImageMaxDimension, ToJPEG: cfg.InlineImageTranscode}
+	if opts.MaxDimension <= 0 && !opts.ToJPEG {
+		return
+	}
+
+	for i, data := range inlineDataItems(req) {
+		if !strings.HasPrefix(data.MimeType, "image/") {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(data.Data)
+		if err != nil {
+			continue
+		}
+		out, mimeType, changed, err := imaging.Transcode(raw, opts)
+		if err != nil {
+			logger.Warn("Inline image %d (%s) not transcoded: %v", i+1, data.MimeType, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		logger.Debug("Inline image %d transcoded from %s (%s) to %s (%s)", i+1, data.MimeType, formatMB(len(raw)), mimeType, formatMB(len(out)))
+		data.Data = base64.StdEncoding.EncodeToString(out)
+		data.MimeType = mimeType
+	}
+}
+
+// inlineDataItems 按出现顺序返回 system 指令与对话中的全部内联数据
+func inlineDataItems(req *core.AntigravityRequest) []*core.InlineData {
+	var items []*core.InlineData
+	if si := req.Request.SystemInstruction; si != nil {
+		for _, part := range si.Parts {
+			if part.InlineData != nil {
+				items = append(items, part.InlineData)
+			}
+		}
+	}
+	for _, content := range req.Request.Contents {
+		for _, part := range content.Parts {
+			if part.InlineData != nil {
+				items = append(items, part.InlineData)
+			}
+		}
+	}
+	return items
+}
+
+// downscaleInlineData 解码图片并缩小到 limit 字节以内，原地替换数据与 MIME 类型
+func downscaleInlineData(data *core.InlineData, limit int) error {
+	raw, err := base64.StdEncoding.DecodeString(data.Data)
+	if err != nil {
+		return fmt.Errorf("invalid base64 data")
+	}
+	out, mimeType, err := imaging.DownscaleToFit(raw, limit)
+	if err != nil {
+		return err
+	}
+	data.Data = base64.StdEncoding.EncodeToString(out)
+	data.MimeType = mimeType
+	return nil
+}
+
+// decodedSize base64 数据解码后的字节数（不实际解码）
+func decodedSize(b64 string) int {
+	size := len(b64) / 4 * 3
+	return size - strings.Count(b64[max(len(b64)-2, 0):], "=")
+}
+
+func formatMB(size int) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+}
+
+func inlineDataTooLarge(message string) error {
+	return &adapter.Error{Status: http.StatusRequestEntityTooLarge, Code: "inline_data_too_large", Message: message}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+)
+
+func TestCheckInlineData(t *testing.T) {
+	cfg := config.Get()
+	defer func(item, total int, downscale bool) {
+		cfg.InlineDataMaxMB, cfg.InlineDataRequestMaxMB, cfg.InlineImageDownscale = item, total, downscale
+	}(cfg.InlineDataMaxMB, cfg.InlineDataRequestMaxMB, cfg.InlineImageDownscale)
+
+	// 约 1.2 MB 的噪点 PNG
+	img := image.NewNRGBA(image.Rect(0, 0, 640, 480))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	large := base64.StdEncoding.EncodeToString(buf.Bytes())
+	small := base64.StdEncoding.EncodeToString(make([]byte, 600<<10))
+
+	newReq := func(items ...*core.InlineData) *core.AntigravityRequest {
+		req := &core.AntigravityRequest{}
+		content := core.Content{Role: "user", Parts: []core.Part{{Text: "look"}}}
+		for _, item := range items {
+			content.Parts = append(content.Parts, core.Part{InlineData: item})
+		}
+		req.Request.Contents = []core.Content{content}
+		return req
+	}
+	expectTooLarge := func(err error) {
+		t.Helper()
+		var adapterErr *adapter.Error
+		if !errors.As(err, &adapterErr) || adapterErr.Status != http.StatusRequestEntityTooLarge || adapterErr.Code != "inline_data_too_large" {
+			t.Errorf("expected 413 inline_data_too_large, got %v", err)
+		}
+	}
+
+	cfg.InlineDataMaxMB, cfg.InlineDataRequestMaxMB, cfg.InlineImageDownscale = 0, 0, false
+	if err := checkInlineData(newReq(&core.InlineData{MimeType: "image/png", Data: large})); err != nil {
+		t.Errorf("limits disabled: %v", err)
+	}
+
+	cfg.InlineDataMaxMB = 1
+	expectTooLarge(checkInlineData(newReq(&core.InlineData{MimeType: "image/png", Data: large})))
+
+	cfg.InlineImageDownscale = true
+	data := &core.InlineData{MimeType: "image/png", Data: large}
+	if err := checkInlineData(newReq(data)); err != nil {
+		t.Fatalf("downscale: %v", err)
+	}
+	if size := decodedSize(data.Data); size > 1<<20 || data.MimeType != "image/png" {
+		t.Errorf("downscaled image is %d bytes (%s)", size, data.MimeType)
+	}
+	// 非图片内容无法缩小
+	expectTooLarge(checkInlineData(newReq(&core.InlineData{MimeType: "application/pdf", Data: large})))
+
+	cfg.InlineDataRequestMaxMB = 1
+	expectTooLarge(checkInlineData(newReq(
+		&core.InlineData{MimeType: "image/png", Data: small},
+		&core.InlineData{MimeType: "image/png", Data: small},
+	)))
+
+	if got := decodedSize(base64.StdEncoding.EncodeToString([]byte("abcd"))); got != 4 {
+		t.Errorf("decodedSize = %d", got)
+	}
+}
+
+func TestTranscodeInlineImages(t *testing.T) {
+	cfg := config.Get()
+	defer func(dim int, transcode bool) {
+		cfg.InlineImageMaxDimension, cfg.InlineImageTranscode = dim, transcode
+	}(cfg.InlineImageMaxDimension, cfg.InlineImageTranscode)
+	cfg.InlineImageMaxDimension, cfg.InlineImageTranscode = 100, false
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 400, 200)))
+	img := &core.InlineData{MimeType: "image/png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}
+	pdf := &core.InlineData{MimeType: "application/pdf", Data: base64.StdEncoding.EncodeToString([]byte("%PDF-1.4"))}
+	req := &core.AntigravityRequest{}
+	req.Request.Contents = []core.Content{{Role: "user", Parts: []core.Part{{InlineData: img}, {InlineData: pdf}}}}
+
+	if err := checkInlineData(req); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(img.Data)
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(raw)); err != nil || cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("image resized to %dx%d (%v), want 100x50", cfg.Width, cfg.Height, err)
+	}
+	if pdf.Data != base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")) {
+		t.Error("non-image data should be left unchanged")
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"anti2api-golang/internal/config"
+)
+
+// TestMain 将数据目录指向临时目录，避免测试写入的日志与配置落到工作目录
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "anti2api-handlers-test")
+	if err != nil {
+		panic(err)
+	}
+	config.Get().DataDir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
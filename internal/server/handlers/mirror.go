@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+// mirrorCall 一次镜像请求（不影响客户端响应，结果附加到日志详情）
+type mirrorCall struct {
+	done chan *store.MirrorSnapshot
+}
+
+// startMirror 按 MIRROR_PERCENT 采样，异步将请求以非流式方式复制到镜像端点/模型
+// 镜像请求使用原请求的账号，会消耗其配额；未命中采样时返回 nil
+func startMirror(req *core.AntigravityRequest, token *store.Account) *mirrorCall {
+	cfg := config.Get()
+	if cfg.MirrorPercent <= 0 || rand.Intn(100) >= cfg.MirrorPercent {
+		return nil
+	}
+
+	// 未指定或无效时使用当前端点，不推进轮询位置
+	endpoint, ok := config.APIEndpoints[cfg.MirrorEndpoint]
+	if !ok {
+		endpoint = config.GetEndpointManager().CurrentEndpoint()
+	}
+
+	// 浅拷贝即可：仅替换模型，内部结构只读
+	mirrorReq := *req
+	if cfg.MirrorModel != "" {
+		mirrorReq.Model = core.ResolveModelName(cfg.MirrorModel)
+	}
+
+	call := &mirrorCall{done: make(chan *store.MirrorSnapshot, 1)}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Millisecond)
+		defer cancel()
+
+		startTime := time.Now()
+		resp, err := vertex.GetClient().SendRequestTo(ctx, &mirrorReq, token, endpoint)
+
+		snapshot := &store.MirrorSnapshot{
+			Endpoint:   endpoint.Key,
+			Model:      mirrorReq.Model,
+			StatusCode: 200,
+			DurationMs: time.Since(startTime).Milliseconds(),
+		}
+		if err != nil {
+			logger.Warn("Mirror request to %s failed: %v", endpoint.Key, err)
+			snapshot.StatusCode = getErrorStatus(err)
+			snapshot.Error = err.Error()
+		} else {
+			snapshot.ModelOutput = mirrorResponseText(resp)
+		}
+		call.done <- snapshot
+	}()
+
+	return call
+}
+
+// attach 等待镜像请求完成后写入对应日志
+func (m *mirrorCall) attach(logID string) {
+	if m == nil {
+		return
+	}
+	go func() {
+		store.GetLogStore().SetMirror(logID, <-m.done)
+	}()
+}
+
+// mirrorResponseText 提取镜像响应中的正文文本
+func mirrorResponseText(resp *core.AntigravityResponse) string {
+	if len(resp.Response.Candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range resp.Response.Candidates[0].Content.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+func TestStartMirror(t *testing.T) {
+	cfg := config.Get()
+	defer func(percent int, endpoint, model string) {
+		cfg.MirrorPercent, cfg.MirrorEndpoint, cfg.MirrorModel = percent, endpoint, model
+	}(cfg.MirrorPercent, cfg.MirrorEndpoint, cfg.MirrorModel)
+	req := &core.AntigravityRequest{Model: "gemini-3-pro-high"}
+	token := &store.Account{AccessToken: "t", Email: "mirror@example.com"}
+
+	cfg.MirrorPercent = 0
+	if startMirror(req, token) != nil {
+		t.Error("MIRROR_PERCENT=0 should not sample any request")
+	}
+
+	// 镜像端点不可连接：结果仍应作为快照附加到日志
+	defer func(old map[string]config.Endpoint) { config.APIEndpoints = old }(config.APIEndpoints)
+	config.APIEndpoints = map[string]config.Endpoint{
+		"daily":  config.APIEndpoints["daily"],
+		"mirror": {Key: "mirror", Host: "127.0.0.1:1"},
+	}
+	cfg.MirrorPercent, cfg.MirrorEndpoint, cfg.MirrorModel = 100, "mirror", "gemini-3-pro-low"
+	call := startMirror(req, token)
+	if call == nil {
+		t.Fatal("MIRROR_PERCENT=100 should sample every request")
+	}
+	if req.Model != "gemini-3-pro-high" {
+		t.Errorf("original request model changed to %s", req.Model)
+	}
+
+	logs := store.GetLogStore()
+	logID := "mirror-test-log"
+	logs.Add(store.LogEntry{ID: logID, Model: req.Model})
+	call.attach(logID)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if entry := logs.GetByID(logID); entry.Detail != nil && entry.Detail.Mirror != nil {
+			mirror := entry.Detail.Mirror
+			if mirror.Endpoint != "mirror" || mirror.Model != "gemini-3-pro-low" || mirror.Error == "" {
+				t.Errorf("unexpected mirror snapshot %+v", mirror)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("mirror snapshot was not attached to the log entry")
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/moderation"
+	"anti2api-golang/internal/utils"
+	"anti2api-golang/internal/vertex"
+)
+
+var (
+	classifier     moderation.Classifier
+	classifierErr  error
+	classifierOnce sync.Once
+)
+
+// getClassifier 按配置构建 /v1/moderations 分类器（配置错误时每次请求返回同一错误）
+func getClassifier() (moderation.Classifier, error) {
+	classifierOnce.Do(func() {
+		classifier, classifierErr = moderation.NewClassifier(config.Get(), vertex.GetClient().SendRequest)
+		if classifierErr != nil {
+			logger.Error("Moderations classifier disabled: %v", classifierErr)
+		}
+	})
+	return classifier, classifierErr
+}
+
+// ModerationRequest OpenAI moderation 请求
+type ModerationRequest struct {
+	Input json.RawMessage `json:"input"`
+	Model string          `json:"model,omitempty"`
+}
+
+// moderationInputs 解析 input：字符串、字符串数组或多模态对象数组（仅取 text 部分）
+func moderationInputs(raw json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+		return list, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err == nil && len(parts) > 0 {
+		var inputs []string
+		for _, part := range parts {
+			if part.Type == "text" {
+				inputs = append(inputs, part.Text)
+			}
+		}
+		if len(inputs) > 0 {
+			return inputs, nil
+		}
+	}
+	return nil, fmt.Errorf("input must be a string, an array of strings or an array of text objects")
+}
+
+// HandleModerations OpenAI 兼容的内容审核接口（MODERATIONS_BACKEND 选择本地关键词或上游模型分类）
+func HandleModerations(w http.ResponseWriter, r *http.Request) {
+	a := adapter.MustGet(adapter.ProtocolOpenAI)
+
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+	inputs, err := moderationInputs(req.Input)
+	if err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+
+	c, err := getClassifier()
+	if err != nil {
+		a.WriteError(w, http.StatusServiceUnavailable, "Moderations are not configured: "+err.Error())
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "omni-moderation-latest"
+	}
+
+	// 模型分类需要上游账号
+	var results []moderation.Result
+	if c.NeedsAccount() {
+		token, status, err := selectAccount("", nil, nil)
+		if err != nil {
+			writeAccountError(w, r, a, status, err)
+			return
+		}
+		results, err = c.Classify(r.Context(), inputs, token)
+		if err != nil {
+			logger.Error("Moderation classification failed: %v", err)
+			cooldownOnRateLimit(token, err)
+			a.WriteError(w, getErrorStatus(err), clientErrorMessage(err, token))
+			return
+		}
+	} else {
+		results, _ = c.Classify(r.Context(), inputs, nil)
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      utils.GenerateModerationID(),
+		"model":   model,
+		"results": results,
+	})
+}
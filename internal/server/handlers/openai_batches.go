@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/batch"
+	"anti2api-golang/internal/utils"
+)
+
+const (
+	// maxUploadSize 上传文件大小上限（与 OpenAI 批处理输入文件上限一致）
+	maxUploadSize = 200 << 20
+	// openAIBatchEndpoint 批处理支持的接口
+	openAIBatchEndpoint = "/v1/chat/completions"
+	// openAIBatchWindow 批处理完成时限（仅用于展示）
+	openAIBatchWindow = 24 * time.Hour
+)
+
+// batchFilesMu 保证批处理输出文件只生成一次
+var batchFilesMu sync.Mutex
+
+// openAIFile 转换为 OpenAI file 对象
+func openAIFile(f batch.File) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt.Unix(),
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+		"status":     "processed",
+	}
+}
+
+// writeFileError 按文件存储错误写出 OpenAI 错误响应
+func writeFileError(w http.ResponseWriter, id string, err error) {
+	a := adapter.MustGet(adapter.ProtocolOpenAI)
+	if errors.Is(err, batch.ErrFileNotFound) {
+		a.WriteError(w, http.StatusNotFound, "No such File object: "+id)
+		return
+	}
+	a.WriteError(w, http.StatusInternalServerError, err.Error())
+}
+
+// HandleUploadFile 上传文件（multipart：file、purpose）
+func HandleUploadFile(w http.ResponseWriter, r *http.Request) {
+	a := adapter.MustGet(adapter.ProtocolOpenAI)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Invalid multipart form: "+err.Error())
+		return
+	}
+	purpose := r.FormValue("purpose")
+	if purpose == "" {
+		a.WriteError(w, http.StatusBadRequest, "purpose is required")
+		return
+	}
+	upload, header, err := r.FormFile("file")
+	if err != nil {
+		a.WriteError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer upload.Close()
+
+	data, err := io.ReadAll(upload)
+	if err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+
+	f, err := getFileStore().Save(batch.File{ID: utils.GenerateFileID(), Filename: header.Filename, Purpose: purpose}, data)
+	if err != nil {
+		a.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, openAIFile(f))
+}
+
+// HandleListFiles 列出文件（?purpose= 过滤）
+func HandleListFiles(w http.ResponseWriter, r *http.Request) {
+	list, err := getFileStore().List(r.URL.Query().Get("purpose"))
+	if err != nil {
+		adapter.MustGet(adapter.ProtocolOpenAI).WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := make([]map[string]interface{}, 0, len(list))
+	for _, f := range list {
+		data = append(data, openAIFile(f))
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data, "has_more": false})
+}
+
+// HandleGetFile 获取文件元数据
+func HandleGetFile(w http.ResponseWriter, r *http.Request) {
+	f, err := getFileStore().Get(r.PathValue("id"))
+	if err != nil {
+		writeFileError(w, r.PathValue("id"), err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, openAIFile(f))
+}
+
+// HandleGetFileContent 下载文件内容
+func HandleGetFileContent(w http.ResponseWriter, r *http.Request) {
+	data, err := getFileStore().Content(r.PathValue("id"))
+	if err != nil {
+		writeFileError(w, r.PathValue("id"), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// HandleDeleteFile 删除文件
+func HandleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := getFileStore().Delete(id); err != nil {
+		writeFileError(w, id, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"id": id, "object": "file", "deleted": true})
+}
+
+// OpenAIBatchRequest 创建批处理的请求
+type OpenAIBatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// parseBatchInput 解析输入 JSONL：每行 {custom_id, method, url, body}，强制非流式
+func parseBatchInput(r *http.Request, data []byte) ([]batch.Item, error) {
+	a := adapter.MustGet(adapter.ProtocolOpenAI)
+
+	var items []batch.Item
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxUploadSize)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry struct {
+			CustomID string                 `json:"custom_id"`
+			Method   string                 `json:"method"`
+			URL      string                 `json:"url"`
+			Body     map[string]interface{} `json:"body"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if entry.Method != http.MethodPost || entry.URL != openAIBatchEndpoint {
+			return nil, fmt.Errorf("line %d: only POST %s is supported", lineNo, openAIBatchEndpoint)
+		}
+		if entry.Body == nil {
+			return nil, fmt.Errorf("line %d: body is required", lineNo)
+		}
+		// custom_id 用于在输出文件中对应结果，必须唯一
+		if entry.CustomID == "" {
+			return nil, fmt.Errorf("line %d: custom_id is required", lineNo)
+		}
+		if seen[entry.CustomID] {
+			return nil, fmt.Errorf("line %d: duplicate custom_id %q", lineNo, entry.CustomID)
+		}
+		seen[entry.CustomID] = true
+
+		delete(entry.Body, "stream")
+		delete(entry.Body, "stream_options")
+		body, _ := json.Marshal(entry.Body)
+		if _, err := a.ParseRequest(r, body); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		items = append(items, batch.Item{CustomID: entry.CustomID, Body: body})
+	}
+	return items, scanner.Err()
+}
+
+// HandleCreateBatch 创建批处理：读取输入文件并在后台通过常规流程执行
+func HandleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	a := adapter.MustGet(adapter.ProtocolOpenAI)
+
+	var req OpenAIBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+	if req.Endpoint != openAIBatchEndpoint {
+		a.WriteError(w, http.StatusBadRequest, "Only "+openAIBatchEndpoint+" is supported")
+		return
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = "24h"
+	}
+
+	data, err := getFileStore().Content(req.InputFileID)
+	if err != nil {
+		writeFileError(w, req.InputFileID, err)
+		return
+	}
+	items, err := parseBatchInput(r, data)
+	if err != nil {
+		a.WriteError(w, http.StatusBadRequest, "Invalid input file: "+err.Error())
+		return
+	}
+
+	b, err := getBatchManager().Create(batch.Batch{
+		ID:       utils.GenerateBatchID(),
+		Protocol: adapter.ProtocolOpenAI,
+		Metadata: req.Metadata,
+		Attributes: map[string]string{
+			"input_file_id":     req.InputFileID,
+			"completion_window": req.CompletionWindow,
+		},
+	}, items)
+	if err != nil {
+		a.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, openAIBatch(b))
+}
+
+// HandleListBatches 列出批处理
+func HandleListBatches(w http.ResponseWriter, r *http.Request) {
+	list := getBatchManager().List(adapter.ProtocolOpenAI)
+	data := make([]map[string]interface{}, 0, len(list))
+	for _, b := range list {
+		data = append(data, openAIBatch(b))
+	}
+
+	resp := map[string]interface{}{"object": "list", "data": data, "has_more": false, "first_id": nil, "last_id": nil}
+	if len(list) > 0 {
+		resp["first_id"] = list[0].ID
+		resp["last_id"] = list[len(list)-1].ID
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleGetBatch 获取批处理
+func HandleGetBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := getOpenAIBatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if b.Status == batch.StatusEnded {
+		ensureOpenAIBatchFiles(b)
+	}
+	WriteJSON(w, http.StatusOK, openAIBatch(b))
+}
+
+// HandleCancelBatch 取消批处理
+func HandleCancelBatch(w http.ResponseWriter, r *http.Request) {
+	if _, ok := getOpenAIBatch(w, r.PathValue("id")); !ok {
+		return
+	}
+	b, _ := getBatchManager().Cancel(r.PathValue("id"))
+	WriteJSON(w, http.StatusOK, openAIBatch(b))
+}
+
+// getOpenAIBatch 查找 OpenAI 批处理，不存在时写出 404
+func getOpenAIBatch(w http.ResponseWriter, id string) (batch.Batch, bool) {
+	b, err := getBatchManager().Get(id)
+	if err != nil || b.Protocol != adapter.ProtocolOpenAI {
+		adapter.MustGet(adapter.ProtocolOpenAI).WriteError(w, http.StatusNotFound, "No such Batch object: "+id)
+		return batch.Batch{}, false
+	}
+	return b, true
+}
+
+// batchOutputFileID / batchErrorFileID 批处理输出文件使用由批处理 ID 派生的固定 ID
+func batchOutputFileID(id string) string { return "file-" + id + "-output" }
+func batchErrorFileID(id string) string  { return "file-" + id + "-errors" }
+
+// openAIBatch 转换为 OpenAI batch 对象
+func openAIBatch(b batch.Batch) map[string]interface{} {
+	created := b.CreatedAt.Unix()
+	obj := map[string]interface{}{
+		"id":                b.ID,
+		"object":            "batch",
+		"endpoint":          openAIBatchEndpoint,
+		"errors":            nil,
+		"input_file_id":     b.Attributes["input_file_id"],
+		"completion_window": b.Attributes["completion_window"],
+		"status":            b.Status,
+		"output_file_id":    nil,
+		"error_file_id":     nil,
+		"created_at":        created,
+		"in_progress_at":    created,
+		"expires_at":        b.CreatedAt.Add(openAIBatchWindow).Unix(),
+		"finalizing_at":     nil,
+		"completed_at":      nil,
+		"failed_at":         nil,
+		"expired_at":        nil,
+		"cancelling_at":     nil,
+		"cancelled_at":      nil,
+		"request_counts": map[string]int{
+			"total":     b.Counts.Processing + b.Counts.Succeeded + b.Counts.Errored + b.Counts.Canceled,
+			"completed": b.Counts.Succeeded,
+			"failed":    b.Counts.Errored + b.Counts.Canceled,
+		},
+		"metadata": b.Metadata,
+	}
+
+	if b.CancelInitiatedAt != nil {
+		obj["status"] = "cancelling"
+		obj["cancelling_at"] = b.CancelInitiatedAt.Unix()
+	}
+	if b.Status == batch.StatusEnded {
+		ended := b.EndedAt.Unix()
+		obj["finalizing_at"] = ended
+		if b.CancelInitiatedAt != nil {
+			obj["status"] = "cancelled"
+			obj["cancelled_at"] = ended
+		} else {
+			obj["status"] = "completed"
+			obj["completed_at"] = ended
+		}
+		if _, err := getFileStore().Get(batchOutputFileID(b.ID)); err == nil {
+			obj["output_file_id"] = batchOutputFileID(b.ID)
+		}
+		if _, err := getFileStore().Get(batchErrorFileID(b.ID)); err == nil {
+			obj["error_file_id"] = batchErrorFileID(b.ID)
+		}
+	}
+	return obj
+}
+
+// ensureOpenAIBatchFiles 为已结束的批处理生成输出文件与错误文件（已存在时跳过）
+// 成功的请求写入输出文件；非 200 响应与被取消的请求写入错误文件
+func ensureOpenAIBatchFiles(b batch.Batch) {
+	batchFilesMu.Lock()
+	defer batchFilesMu.Unlock()
+
+	store := getFileStore()
+	if _, err := store.Get(batchOutputFileID(b.ID)); err == nil {
+		return
+	}
+	if _, err := store.Get(batchErrorFileID(b.ID)); err == nil {
+		return
+	}
+
+	results, err := getBatchManager().Results(b.ID)
+	if err != nil {
+		return
+	}
+
+	var output, errorsOut bytes.Buffer
+	for i, result := range results {
+		line := map[string]interface{}{
+			"id":        fmt.Sprintf("batch_req_%s_%d", b.ID, i),
+			"custom_id": result.CustomID,
+			"response":  nil,
+			"error":     nil,
+		}
+		if result.Canceled {
+			line["error"] = map[string]string{"code": "batch_cancelled", "message": "Request was cancelled before execution"}
+		} else {
+			line["response"] = map[string]interface{}{
+				"status_code": result.Status,
+				"request_id":  utils.GenerateRequestID(),
+				"body":        result.Body,
+			}
+		}
+
+		data, _ := json.Marshal(line)
+		target := &output
+		if !result.Succeeded() {
+			target = &errorsOut
+		}
+		target.Write(data)
+		target.WriteByte('\n')
+	}
+
+	if output.Len() > 0 {
+		store.Save(batch.File{ID: batchOutputFileID(b.ID), Filename: b.ID + "_output.jsonl", Purpose: "batch_output"}, output.Bytes())
+	}
+	if errorsOut.Len() > 0 {
+		store.Save(batch.File{ID: batchErrorFileID(b.ID), Filename: b.ID + "_errors.jsonl", Purpose: "batch_output"}, errorsOut.Bytes())
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/batches", nil)
+	line := func(id string) string {
+		return `{"custom_id":"` + id + `","method":"POST","url":"/v1/chat/completions","body":{"model":"gemini-3-pro","stream":true,"messages":[{"role":"user","content":"hi"}]}}`
+	}
+
+	items, err := parseBatchInput(r, []byte(line("a")+"\n\n"+line("b")+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[1].CustomID != "b" || strings.Contains(string(items[0].Body), "stream") {
+		t.Errorf("items = %+v", items)
+	}
+
+	tests := []struct {
+		input  string
+		errMsg string
+	}{
+		{line("a") + "\n" + line("a"), `line 2: duplicate custom_id "a"`},
+		{line(""), "line 1: custom_id is required"},
+		{`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{}}`, "only POST /v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		if _, err := parseBatchInput(r, []byte(tt.input)); err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("err = %v, want %q", err, tt.errMsg)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleGetModel(t *testing.T) {
+	get := func(id string, anthropic bool) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/models/"+id, nil)
+		r.SetPathValue("id", id)
+		if anthropic {
+			r.Header.Set("anthropic-version", "2023-06-01")
+		}
+		w := httptest.NewRecorder()
+		HandleGetModel(w, r)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	w, body := get("claude-sonnet-4-5", false)
+	if w.Code != http.StatusOK || body["id"] != "claude-sonnet-4-5" || body["context_window"] != float64(200000) || body["created"] == float64(0) {
+		t.Errorf("status = %d, body = %v", w.Code, body)
+	}
+	if caps, _ := body["capabilities"].(map[string]interface{}); caps["tools"] != true || caps["thinking"] != false {
+		t.Errorf("capabilities = %v", body["capabilities"])
+	}
+
+	w, body = get("claude-sonnet-4-5", true)
+	if w.Code != http.StatusOK || body["type"] != "model" || body["created_at"] != "2025-09-29T00:00:00Z" {
+		t.Errorf("anthropic: status = %d, body = %v", w.Code, body)
+	}
+
+	w, body = get("gpt-unknown", false)
+	errObj, _ := body["error"].(map[string]interface{})
+	if w.Code != http.StatusNotFound || errObj["code"] != "model_not_found" {
+		t.Errorf("unknown: status = %d, body = %v", w.Code, body)
+	}
+}
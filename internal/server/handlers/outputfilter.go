@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+)
+
+// outputFilter 对上游返回的正文文本执行输出过滤（OUTPUT_FILTERS）
+// 过滤在协议转换之前进行，三种协议共用；思考内容与工具调用不受影响
+type outputFilter struct {
+	text *core.TextFilter
+}
+
+// newOutputFilter 按模型（依次尝试客户端模型名与上游模型名）构建过滤器，未启用时返回 nil（nil 上的方法均为空操作）
+// jsonGuard 仅对要求 JSON 输出（responseMimeType 为 application/json）的请求生效
+func newOutputFilter(antigravityReq *core.AntigravityRequest, clientModel string) *outputFilter {
+	set := config.GetOutputFilterManager().For(clientModel, antigravityReq.Model)
+
+	opts := core.TextFilterOptions{
+		StripThinking:      set.StripThinking,
+		StopArtifacts:      set.StopArtifacts,
+		CollapseWhitespace: set.CollapseWhitespace,
+		JSONValue:          set.JSONGuard && isJSONMode(antigravityReq),
+	}
+	for _, rep := range set.Replacements {
+		opts.Replacements = append(opts.Replacements, core.TextReplacement{Pattern: rep.Regexp(), Replacement: rep.Replacement})
+	}
+
+	text := core.NewTextFilter(opts)
+	if text == nil {
+		return nil
+	}
+	return &outputFilter{text: text}
+}
+
+// isJSONMode 请求是否要求 JSON 输出（OpenAI response_format / Gemini responseMimeType）
+func isJSONMode(antigravityReq *core.AntigravityRequest) bool {
+	genConfig := antigravityReq.Request.GenerationConfig
+	return genConfig != nil && genConfig.ResponseMimeType == "application/json"
+}
+
+// filterResponse 过滤非流式响应中的正文文本，过滤后为空的文本部分将被移除
+func (f *outputFilter) filterResponse(resp *core.AntigravityResponse) {
+	if f == nil || len(resp.Response.Candidates) == 0 {
+		return
+	}
+
+	candidate := &resp.Response.Candidates[0]
+	last := -1
+	for i := range candidate.Content.Parts {
+		part := &candidate.Content.Parts[i]
+		if part.Thought || part.Text == "" {
+			continue
+		}
+		part.Text = f.text.Write(part.Text)
+		last = i
+	}
+	if last < 0 {
+		return
+	}
+	candidate.Content.Parts[last].Text += f.text.Flush()
+
+	parts := candidate.Content.Parts[:0]
+	for _, part := range candidate.Content.Parts {
+		if part != (core.Part{}) {
+			parts = append(parts, part)
+		}
+	}
+	candidate.Content.Parts = parts
+}
+
+// wrapStream 替换流式响应体，逐行过滤 SSE 事件中的正文文本
+func (f *outputFilter) wrapStream(resp *http.Response) {
+	if f == nil {
+		return
+	}
+
+	reader, ok := decodedStreamBody(resp)
+	if !ok {
+		return
+	}
+
+	resp.Body = &filterStreamBody{
+		reader: bufio.NewReaderSize(reader, 4*1024),
+		closer: resp.Body,
+		filter: f,
+	}
+}
+
+// filterStreamBody 按行过滤的流式响应体
+type filterStreamBody struct {
+	reader  *bufio.Reader
+	closer  io.Closer
+	filter  *outputFilter
+	pending string
+	flushed bool
+	err     error
+}
+
+func (b *filterStreamBody) Read(p []byte) (int, error) {
+	for b.pending == "" {
+		if b.err != nil {
+			// 上游未发送结束原因即结束时，补发暂存文本
+			if !b.flushed {
+				b.flushed = true
+				if text := b.filter.text.Flush(); text != "" {
+					b.pending = textChunkLine(text)
+					continue
+				}
+			}
+			return 0, b.err
+		}
+		var line string
+		line, b.err = b.reader.ReadString('\n')
+		b.pending = b.filter.filterLine(line, &b.flushed)
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *filterStreamBody) Close() error {
+	return b.closer.Close()
+}
+
+// filterLine 过滤单行 SSE 数据；携带结束原因的数据块附带暂存文本；无需修改时原样返回
+func (f *outputFilter) filterLine(line string, flushed *bool) string {
+	if !strings.HasPrefix(line, "data: ") {
+		return line
+	}
+
+	payload := strings.TrimRight(line[6:], "\r\n")
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+		return line
+	}
+
+	response, _ := chunk["response"].(map[string]interface{})
+	candidates, _ := response["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		return line
+	}
+	candidate, _ := candidates[0].(map[string]interface{})
+	content, _ := candidate["content"].(map[string]interface{})
+	parts, _ := content["parts"].([]interface{})
+
+	changed := false
+	kept := parts[:0]
+	for _, p := range parts {
+		part, _ := p.(map[string]interface{})
+		text, ok := part["text"].(string)
+		if !ok || part["thought"] == true {
+			kept = append(kept, p)
+			continue
+		}
+		changed = true
+		if filtered := f.text.Write(text); filtered != "" {
+			part["text"] = filtered
+		} else {
+			delete(part, "text")
+		}
+		if len(part) > 0 {
+			kept = append(kept, part)
+		}
+	}
+
+	if reason, _ := candidate["finishReason"].(string); reason != "" && !*flushed {
+		*flushed = true
+		if text := f.text.Flush(); text != "" {
+			kept = append(kept, map[string]interface{}{"text": text})
+			changed = true
+		}
+	}
+	if !changed {
+		return line
+	}
+	if content == nil {
+		content = map[string]interface{}{"role": "model"}
+		candidate["content"] = content
+	}
+	content["parts"] = kept
+
+	data, err := marshalChunk(chunk)
+	if err != nil {
+		return line
+	}
+	return "data: " + data + line[len(strings.TrimRight(line, "\r\n")):]
+}
+
+// textChunkLine 构造仅含正文文本的 SSE 数据行
+func textChunkLine(text string) string {
+	chunk := map[string]interface{}{
+		"response": map[string]interface{}{
+			"candidates": []interface{}{
+				map[string]interface{}{
+					"content": map[string]interface{}{
+						"role":  "model",
+						"parts": []interface{}{map[string]interface{}{"text": text}},
+					},
+				},
+			},
+		},
+	}
+	data, _ := marshalChunk(chunk)
+	return "data: " + data + "\n\n"
+}
+
+// marshalChunk 序列化数据块（不转义 HTML 字符，保持正文中的 <、> 原样）
+func marshalChunk(chunk interface{}) (string, error) {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(chunk); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
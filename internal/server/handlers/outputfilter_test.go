@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/core"
+)
+
+func TestOutputFilterStream(t *testing.T) {
+	f := &outputFilter{text: core.NewTextFilter(core.TextFilterOptions{StripThinking: true})}
+	upstream := strings.Join([]string{
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"plan","thought":true}]}}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"Hi <think"}]}}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":">x</think> there <"}]},"finishReason":"STOP"}]}}`,
+		"",
+	}, "\n")
+	resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstream))}
+	f.wrapStream(resp)
+
+	data, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"plan","thought":true}]}}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"Hi "}]}}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":" there "},{"text":"<"}]},"finishReason":"STOP"}]}}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines: %s", len(lines), data)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %s, want %s", i, lines[i], want[i])
+		}
+	}
+
+	resp2 := &core.AntigravityResponse{}
+	resp2.Response.Candidates = []core.Candidate{{Content: core.Content{Parts: []core.Part{
+		{Text: "<thinking>a</thinking>"},
+		{Text: "b"},
+	}}}}
+	(&outputFilter{text: core.NewTextFilter(core.TextFilterOptions{StripThinking: true})}).filterResponse(resp2)
+	if parts := resp2.Response.Candidates[0].Content.Parts; len(parts) != 1 || parts[0].Text != "b" {
+		t.Errorf("filterResponse parts = %+v", parts)
+	}
+}
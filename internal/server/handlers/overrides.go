@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
+)
+
+// 生成参数覆盖请求头
+const (
+	overrideTemperature = "X-Override-Temperature"
+	overrideTopP        = "X-Override-Top-P"
+	overrideTopK        = "X-Override-Top-K"
+	overrideMaxTokens   = "X-Override-Max-Tokens"
+	overrideSeed        = "X-Override-Seed"
+)
+
+var overrideHeaders = []string{overrideTemperature, overrideTopP, overrideTopK, overrideMaxTokens, overrideSeed}
+
+// applyOverrideHeaders 应用 X-Override-* 请求头，优先于客户端请求体与虚拟模型默认值
+// 仅接受携带登录会话或管理 API 令牌（X-Session-Token）的请求，用于修正参数写死且无法修改的闭源客户端
+func applyOverrideHeaders(r *http.Request, req *core.AntigravityRequest) error {
+	var present []string
+	for _, header := range overrideHeaders {
+		if r.Header.Get(header) != "" {
+			present = append(present, header)
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+	if !isAdminRequest(r) {
+		return &adapter.Error{
+			Status:  http.StatusForbidden,
+			Code:    "override_not_allowed",
+			Message: "X-Override-* headers require an admin session or admin API token",
+		}
+	}
+
+	gc := req.Request.GenerationConfig
+	if gc == nil {
+		gc = &core.GenerationConfig{}
+		req.Request.GenerationConfig = gc
+	}
+
+	applied := make([]string, 0, len(present))
+	for _, header := range present {
+		value := strings.TrimSpace(r.Header.Get(header))
+		switch header {
+		case overrideTemperature:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 || f > 2 {
+				return adapter.InvalidParam(header, "%s must be a number between 0 and 2", header)
+			}
+			// 与请求体转换一致：Claude 的 temperature 取值范围为 [0, 1]，超出时截断
+			if core.IsClaudeModel(req.Model) {
+				f = min(f, 1)
+			}
+			gc.Temperature = &f
+		case overrideTopP:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 || f > 1 {
+				return adapter.InvalidParam(header, "%s must be a number between 0 and 1", header)
+			}
+			gc.TopP = &f
+		case overrideTopK:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return adapter.InvalidParam(header, "%s must be a positive integer", header)
+			}
+			gc.TopK = n
+		case overrideMaxTokens:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return adapter.InvalidParam(header, "%s must be a positive integer", header)
+			}
+			if limit := modelMaxOutputTokens(req.Model); limit > 0 {
+				n = min(n, limit)
+			}
+			gc.MaxOutputTokens = n
+			// 思考预算须小于输出上限，否则上游拒绝请求
+			if tc := gc.ThinkingConfig; tc != nil && tc.ThinkingBudget >= n {
+				tc.ThinkingBudget = n / 2
+			}
+		case overrideSeed:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return adapter.InvalidParam(header, "%s must be an integer", header)
+			}
+			gc.Seed = &n
+		}
+		applied = append(applied, header+"="+value)
+	}
+	logger.Info("Request %s %s overrides generation config: %s", r.Method, r.URL.Path, strings.Join(applied, ", "))
+	return nil
+}
+
+// modelMaxOutputTokens 模型的输出 Token 上限，未知时返回 0（不限制）
+func modelMaxOutputTokens(model string) int {
+	if core.IsClaudeModel(model) {
+		return core.GetClaudeMaxOutputTokens(model)
+	}
+	if m, ok := core.FindModel(model); ok {
+		return m.MaxOutputTokens
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/core"
+)
+
+func TestApplyOverrideHeaders(t *testing.T) {
+	temperature := 1.5
+	newReq := func() *core.AntigravityRequest {
+		req := &core.AntigravityRequest{}
+		req.Request.GenerationConfig = &core.GenerationConfig{Temperature: &temperature, MaxOutputTokens: 100}
+		return req
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if err := applyOverrideHeaders(r, newReq()); err != nil {
+		t.Errorf("no headers: %v", err)
+	}
+
+	r.Header.Set("X-Override-Temperature", "0.2")
+	var apiErr *adapter.Error
+	if err := applyOverrideHeaders(r, newReq()); !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Errorf("without admin credentials: %v", err)
+	}
+
+	session := auth.CreateSession()
+	defer auth.DeleteSession(session)
+	r.Header.Set("X-Session-Token", session)
+	r.Header.Set("X-Override-Max-Tokens", "4096")
+	req := newReq()
+	if err := applyOverrideHeaders(r, req); err != nil {
+		t.Fatal(err)
+	}
+	gc := req.Request.GenerationConfig
+	if *gc.Temperature != 0.2 || gc.MaxOutputTokens != 4096 {
+		t.Errorf("generation config = %+v", gc)
+	}
+
+	// Claude 模型：temperature 截断到 1，max tokens 不超过模型输出上限
+	claudeReq := newReq()
+	claudeReq.Model = "claude-sonnet-4-5"
+	r.Header.Set("X-Override-Temperature", "1.8")
+	r.Header.Set("X-Override-Max-Tokens", "10000000")
+	if err := applyOverrideHeaders(r, claudeReq); err != nil {
+		t.Fatal(err)
+	}
+	gc = claudeReq.Request.GenerationConfig
+	if *gc.Temperature != 1 || gc.MaxOutputTokens != core.GetClaudeMaxOutputTokens("claude-sonnet-4-5") {
+		t.Errorf("claude clamps: temperature=%v maxTokens=%d", *gc.Temperature, gc.MaxOutputTokens)
+	}
+
+	r.Header.Set("X-Override-Top-P", "1.5")
+	if err := applyOverrideHeaders(r, newReq()); !errors.As(err, &apiErr) || apiErr.Param != "X-Override-Top-P" {
+		t.Errorf("invalid top_p: %v", err)
+	}
+}
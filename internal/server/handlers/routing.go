@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/history"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/moderation"
+	"anti2api-golang/internal/store"
+)
+
+// variantKey 请求上下文中 A/B 路由变体的键
+type variantKey struct{}
+
+// withRoutedVariant 按路由规则为请求模型抽取变体并写入上下文
+func withRoutedVariant(r *http.Request, model string) *http.Request {
+	variant := config.GetRoutingManager().Pick(model)
+	if variant == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), variantKey{}, variant))
+}
+
+// routedVariant 获取请求命中的变体模型
+func routedVariant(r *http.Request) string {
+	variant, _ := r.Context().Value(variantKey{}).(string)
+	return variant
+}
+
+// virtualModelKey 请求上下文中虚拟模型的键
+type virtualModelKey struct{}
+
+// withVirtualModel 请求模型为虚拟模型时写入上下文
+func withVirtualModel(r *http.Request, model string) *http.Request {
+	vm, ok := config.GetVirtualModelManager().Get(model)
+	if !ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), virtualModelKey{}, vm))
+}
+
+// virtualModel 获取请求命中的虚拟模型
+func virtualModel(r *http.Request) (config.VirtualModel, bool) {
+	vm, ok := r.Context().Value(virtualModelKey{}).(config.VirtualModel)
+	return vm, ok
+}
+
+// targetModel 虚拟模型解析后的目标模型（A/B 路由前）
+func targetModel(r *http.Request, req adapter.Request) string {
+	if vm, ok := virtualModel(r); ok {
+		return vm.Model
+	}
+	return req.ModelName()
+}
+
+// upstreamModel 实际发往上游的模型：A/B 变体 > 虚拟模型目标 > 请求模型
+func upstreamModel(r *http.Request, req adapter.Request) string {
+	if variant := routedVariant(r); variant != "" {
+		return variant
+	}
+	return targetModel(r, req)
+}
+
+// applyVirtualDefaults 应用虚拟模型的生成参数：温度等仅在客户端未指定时生效，MaxTokens 为上限
+func applyVirtualDefaults(req *core.AntigravityRequest, vm config.VirtualModel) {
+	gc := req.Request.GenerationConfig
+	if gc == nil {
+		gc = &core.GenerationConfig{}
+		req.Request.GenerationConfig = gc
+	}
+	if gc.Temperature == nil && vm.Temperature != nil {
+		gc.Temperature = vm.Temperature
+	}
+	if gc.TopP == nil && vm.TopP != nil {
+		gc.TopP = vm.TopP
+	}
+	if gc.TopK == 0 && vm.TopK > 0 {
+		gc.TopK = vm.TopK
+	}
+	if vm.MaxTokens > 0 && (gc.MaxOutputTokens == 0 || gc.MaxOutputTokens > vm.MaxTokens) {
+		gc.MaxOutputTokens = vm.MaxTokens
+	}
+}
+
+// translateRequest 协议转换：以上游模型构建 Antigravity 请求并应用虚拟模型参数与覆盖请求头（不含审核与截断）
+func translateRequest(r *http.Request, a adapter.Adapter, req adapter.Request, token *store.Account) (*core.AntigravityRequest, error) {
+	if model := upstreamModel(r, req); model != req.ModelName() {
+		requested := req.ModelName()
+		req.SetModelName(model)
+		defer req.SetModelName(requested)
+	}
+
+	antigravityReq, err := a.Convert(req, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if vm, ok := virtualModel(r); ok {
+		applyVirtualDefaults(antigravityReq, vm)
+	}
+	if err := applyOverrideHeaders(r, antigravityReq); err != nil {
+		return nil, err
+	}
+	return antigravityReq, nil
+}
+
+// convertRequest 转换请求并执行内容审核
+// 命中虚拟模型或 A/B 路由时以目标模型构建上游请求，转换完成后恢复原模型名，客户端响应中的 model 保持不变
+func convertRequest(r *http.Request, a adapter.Adapter, req adapter.Request, token *store.Account) (*core.AntigravityRequest, error) {
+	antigravityReq, err := translateRequest(r, a, req, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkInlineData(antigravityReq); err != nil {
+		logger.Warn("%s request rejected: %v", a.Name(), err)
+		return nil, err
+	}
+
+	if err := moderation.Get().Apply(r.Context(), antigravityReq); err != nil {
+		logger.Warn("%s request rejected: %v", a.Name(), err)
+		return nil, err
+	}
+
+	if err := checkToolLoop(antigravityReq); err != nil {
+		logger.Warn("%s request rejected: %v", a.Name(), err)
+		return nil, err
+	}
+
+	truncateHistory(antigravityReq, token)
+
+	if err := checkContextLength(antigravityReq); err != nil {
+		logger.Warn("%s request rejected: %v", a.Name(), err)
+		return nil, err
+	}
+	return antigravityReq, nil
+}
+
+// truncateHistory 按配置在超出预算时压缩或截断最早的对话轮次
+func truncateHistory(req *core.AntigravityRequest, token *store.Account) {
+	cfg := config.Get()
+	if cfg.HistoryTruncation != "drop" && cfg.HistoryTruncation != "summarize" {
+		return
+	}
+
+	budget := cfg.HistoryTokenBudget
+	if budget <= 0 {
+		budget = core.GetContextWindow(req.Model)
+	}
+	if cfg.HistoryTruncation == "summarize" {
+		if summarized := history.Get().Compress(req, budget, token); summarized > 0 {
+			logger.Info("History compressed: replaced %d messages with cached summary", summarized)
+		}
+	}
+	// 摘要未命中或压缩后仍超出预算时丢弃最早的轮次
+	if dropped := core.TruncateHistory(req, budget); dropped > 0 {
+		logger.Info("History truncated: dropped %d oldest messages to fit %d tokens", dropped, budget)
+	}
+}
+
+// checkToolLoop 检测对话末尾重复的工具调用（TOOL_LOOP_LIMIT）：按 TOOL_LOOP_ACTION 注入提示或拒绝请求
+func checkToolLoop(req *core.AntigravityRequest) error {
+	cfg := config.Get()
+	if cfg.ToolLoopLimit <= 0 {
+		return nil
+	}
+	loop := core.DetectToolLoop(req.Request.Contents)
+	if loop.Count < cfg.ToolLoopLimit {
+		return nil
+	}
+
+	if cfg.ToolLoopAction == "reject" {
+		return &adapter.Error{
+			Status:  http.StatusBadRequest,
+			Code:    "tool_loop_detected",
+			Message: fmt.Sprintf("Tool %q was called with identical arguments %d times in a row (limit %d). The agent appears to be stuck in a loop; change the arguments or the approach before retrying.", loop.Name, loop.Count, cfg.ToolLoopLimit),
+		}
+	}
+	logger.Warn("Tool loop detected: %s called %d times with identical arguments, nudge injected", loop.Name, loop.Count)
+	core.InjectToolLoopNudge(req, loop)
+	return nil
+}
+
+// checkContextLength 估算输入 token，超出模型上下文窗口时返回 context_length_exceeded
+func checkContextLength(req *core.AntigravityRequest) error {
+	if !config.Get().ContextGuard {
+		return nil
+	}
+	limit := core.GetContextWindow(req.Model)
+	if limit <= 0 {
+		return nil
+	}
+	if estimated := core.EstimateRequestTokens(req); estimated > limit {
+		return &adapter.Error{
+			Status:  http.StatusBadRequest,
+			Code:    "context_length_exceeded",
+			Message: fmt.Sprintf("This model's maximum context length is %d tokens. However, your messages resulted in approximately %d tokens. Please reduce the length of the messages.", limit, estimated),
+		}
+	}
+	return nil
+}
+
+// HandleGetRouting 获取 A/B 路由规则
+func HandleGetRouting(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rules":   config.GetRoutingManager().GetRules(),
+		"fromEnv": config.GetRoutingManager().FromEnv(),
+	})
+}
+
+// HandleSetRouting 替换 A/B 路由规则
+func HandleSetRouting(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Rules []config.RoutingRule `json:"rules"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := config.GetRoutingManager().SetRules(req.Rules); err != nil {
+		WriteError(w, routingWriteStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"rules":   config.GetRoutingManager().GetRules(),
+	})
+}
+
+// routingWriteStatus 修改路由规则失败时的状态码：规则由环境变量指定时为 409
+func routingWriteStatus(err error, fallback int) int {
+	if errors.Is(err, config.ErrRoutingRulesFromEnv) {
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+// routingConfigVersion 路由配置文档格式版本
+const routingConfigVersion = 1
+
+// routingConfig 完整的路由配置文档：A/B 路由规则与虚拟模型（别名、账号组与生成参数默认值）
+// 用于版本管理与多实例间复制
+type routingConfig struct {
+	Version       int                   `json:"version"`
+	ExportedAt    string                `json:"exportedAt,omitempty"`
+	RoutingRules  []config.RoutingRule  `json:"routingRules"`
+	VirtualModels []config.VirtualModel `json:"virtualModels"`
+}
+
+// currentRoutingConfig 导出当前路由配置
+func currentRoutingConfig() routingConfig {
+	rules := config.GetRoutingManager().GetRules()
+	if rules == nil {
+		rules = []config.RoutingRule{}
+	}
+	return routingConfig{
+		Version:       routingConfigVersion,
+		ExportedAt:    time.Now().UTC().Format(time.RFC3339),
+		RoutingRules:  rules,
+		VirtualModels: config.GetVirtualModelManager().List(),
+	}
+}
+
+// HandleGetRoutingConfig 导出完整路由配置
+func HandleGetRoutingConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", `attachment; filename="routing-config.json"`)
+	WriteJSON(w, http.StatusOK, currentRoutingConfig())
+}
+
+// HandleSetRoutingConfig 导入完整路由配置：整体替换，全部校验通过后才生效
+func HandleSetRoutingConfig(w http.ResponseWriter, r *http.Request) {
+	var req routingConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Version != 0 && req.Version != routingConfigVersion {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported routing config version %d", req.Version))
+		return
+	}
+	if err := config.ValidateRoutingRules(req.RoutingRules); err != nil {
+		WriteError(w, http.StatusBadRequest, "routingRules: "+err.Error())
+		return
+	}
+	if err := config.ValidateVirtualModels(req.VirtualModels); err != nil {
+		WriteError(w, http.StatusBadRequest, "virtualModels: "+err.Error())
+		return
+	}
+
+	// 虚拟模型保存失败时恢复原路由规则，避免导入只生效一半
+	err := config.GetRoutingManager().SetRulesWith(req.RoutingRules, func() error {
+		return config.GetVirtualModelManager().SetModels(req.VirtualModels)
+	})
+	if err != nil {
+		WriteError(w, routingWriteStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	logger.Info("Routing config imported: %d routing rules, %d virtual models", len(req.RoutingRules), len(req.VirtualModels))
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"config":  currentRoutingConfig(),
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/core"
+)
+
+// streamRecorder 并发安全的 ResponseWriter，用于在流式输出过程中检查已写出的内容
+type streamRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (s *streamRecorder) Header() http.Header { return s.header }
+func (s *streamRecorder) WriteHeader(int)     {}
+func (s *streamRecorder) Flush()              {}
+
+func (s *streamRecorder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body.Write(p)
+}
+
+func (s *streamRecorder) waitFor(text string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		found := strings.Contains(s.body.String(), text)
+		s.mu.Unlock()
+		if found {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+// TestStreamSmoothness 各协议的流式路径应在上游事件到达后立即转发，而不是缓冲到流结束
+func TestStreamSmoothness(t *testing.T) {
+	routes := []struct {
+		protocol string
+		body     string
+	}{
+		{adapter.ProtocolOpenAI, `{"model":"gemini-3-pro","stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+		{adapter.ProtocolClaude, `{"model":"claude-sonnet-4-5","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+		{adapter.ProtocolGemini, `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`},
+		{adapter.ProtocolGeminiRaw, `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`},
+	}
+
+	const events = 4
+
+	for _, route := range routes {
+		t.Run(route.protocol, func(t *testing.T) {
+			a := adapter.MustGet(route.protocol)
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(route.body))
+			r.SetPathValue("model", "gemini-3-pro")
+			r.SetPathValue("action", "streamGenerateContent")
+			req, err := a.ParseRequest(r, []byte(route.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pr, pw := io.Pipe()
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: pr}
+			upstreamReq := &core.AntigravityRequest{Model: "gemini-3-pro", RequestID: "agent-test"}
+			newToolArgRepairer(upstreamReq).wrapStream(resp)
+			newOutputFilter(upstreamReq, req.ModelName()).wrapStream(resp)
+
+			w := &streamRecorder{header: http.Header{}}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				a.EmitStream(w, req, upstreamReq, resp)
+			}()
+
+			for i := 0; i < events; i++ {
+				marker := fmt.Sprintf("tok%d", i)
+				finish := ""
+				if i == events-1 {
+					finish = `,"finishReason":"STOP"`
+				}
+				fmt.Fprintf(pw, "data: {\"response\":{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"%s\"}]}%s}]}}\n\n", marker, finish)
+
+				// 事件须在上游产生下一个事件之前到达客户端（超时仅用于避免测试挂起）
+				if !w.waitFor(marker, 5*time.Second) {
+					t.Fatalf("event %d was not forwarded before the next upstream event", i)
+				}
+			}
+			pw.Close()
+			<-done
+		})
+	}
+}
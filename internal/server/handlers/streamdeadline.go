@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
+)
+
+// maxDurationChunk 流式响应达到最长持续时间后补发的结束数据块（前置空行结束可能被截断的上一行）
+const maxDurationChunk = "\n\ndata: {\"response\":{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[]},\"finishReason\":\"MAX_TOKENS\"}]}}\n\n"
+
+// maxStreamDuration 返回流式响应的最长持续时间，0 表示不限制
+// STREAM_MAX_DURATION_MODELS 中第一个匹配模型的规则优先，其次按请求是否开启思考选择
+func maxStreamDuration(req *core.AntigravityRequest) time.Duration {
+	cfg := config.Get()
+	for _, rule := range cfg.StreamMaxDurationModels {
+		pattern, value, ok := strings.Cut(rule, "=")
+		if !ok {
+			continue
+		}
+		if matched, _ := path.Match(strings.TrimSpace(pattern), req.Model); matched {
+			if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	seconds := cfg.StreamMaxDuration
+	if gc := req.Request.GenerationConfig; gc != nil && gc.ThinkingConfig != nil && gc.ThinkingConfig.IncludeThoughts {
+		seconds = cfg.ThinkingMaxDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// wrapStreamDeadline 为上游流式响应设置最长持续时间：超时后结束上游读取，
+// 并补发 finishReason 为 MAX_TOKENS 的数据块，由各协议按输出达到上限正常结束流
+func wrapStreamDeadline(resp *http.Response, req *core.AntigravityRequest) {
+	limit := maxStreamDuration(req)
+	if limit <= 0 {
+		return
+	}
+	reader, ok := decodedStreamBody(resp)
+	if !ok {
+		return
+	}
+
+	body := &deadlineStreamBody{reader: reader, closer: resp.Body}
+	body.timer = time.AfterFunc(limit, func() {
+		body.expired.Store(true)
+		logger.Warn("Stream for %s exceeded max duration %s, finishing with MAX_TOKENS", req.Model, limit)
+		body.closer.Close()
+	})
+	resp.Body = body
+}
+
+// deadlineStreamBody 超时后以结束数据块代替上游剩余内容的响应体
+type deadlineStreamBody struct {
+	reader  io.Reader
+	closer  io.Closer
+	timer   *time.Timer
+	expired atomic.Bool
+	tail    *strings.Reader
+}
+
+func (b *deadlineStreamBody) Read(p []byte) (int, error) {
+	if b.tail != nil {
+		return b.tail.Read(p)
+	}
+	n, err := b.reader.Read(p)
+	if !b.expired.Load() {
+		return n, err
+	}
+	// 已超时：上游读取因关闭而失败，先返回已读到的数据，随后补发结束数据块
+	b.tail = strings.NewReader(maxDurationChunk)
+	if n > 0 {
+		return n, nil
+	}
+	return b.tail.Read(p)
+}
+
+func (b *deadlineStreamBody) Close() error {
+	b.timer.Stop()
+	return b.closer.Close()
+}
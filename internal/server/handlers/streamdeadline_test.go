@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+)
+
+func TestMaxStreamDuration(t *testing.T) {
+	cfg := config.Get()
+	defer func(normal, thinking int, models []string) {
+		cfg.StreamMaxDuration, cfg.ThinkingMaxDuration, cfg.StreamMaxDurationModels = normal, thinking, models
+	}(cfg.StreamMaxDuration, cfg.ThinkingMaxDuration, cfg.StreamMaxDurationModels)
+	cfg.StreamMaxDuration, cfg.ThinkingMaxDuration = 180, 600
+	cfg.StreamMaxDurationModels = []string{"bad-rule", "claude-opus-*=1200"}
+
+	thinking := &core.GenerationConfig{ThinkingConfig: &core.ThinkingConfig{IncludeThoughts: true}}
+	tests := []struct {
+		model string
+		gc    *core.GenerationConfig
+		want  time.Duration
+	}{
+		{"gemini-3-pro-low", nil, 180 * time.Second},
+		{"gemini-3-pro-high", thinking, 600 * time.Second},
+		{"claude-opus-4-5-thinking", thinking, 1200 * time.Second},
+	}
+	for _, tt := range tests {
+		req := &core.AntigravityRequest{Model: tt.model}
+		req.Request.GenerationConfig = tt.gc
+		if got := maxStreamDuration(req); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.model, tt.want, got)
+		}
+	}
+}
+
+// TestStreamDeadlineFinishesWithLength 上游流一直不结束时，达到最长持续时间后以 length 正常结束
+func TestStreamDeadlineFinishesWithLength(t *testing.T) {
+	cfg := config.Get()
+	defer func(models []string) { cfg.StreamMaxDurationModels = models }(cfg.StreamMaxDurationModels)
+	cfg.StreamMaxDurationModels = []string{"gemini-3-pro=1"}
+
+	a := adapter.MustGet(adapter.ProtocolOpenAI)
+	body := `{"model":"gemini-3-pro","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req, err := a.ParseRequest(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: pr}
+	upstreamReq := &core.AntigravityRequest{Model: "gemini-3-pro", RequestID: "agent-test"}
+	wrapStreamDeadline(resp, upstreamReq)
+
+	// 上游只发送一个数据块，之后既不结束也不关闭
+	go fmt.Fprint(pw, "data: {\"response\":{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"partial\"}]}}]}}\n\n")
+
+	w := &streamRecorder{header: http.Header{}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.EmitStream(w, req, upstreamReq, resp)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not finish after max duration")
+	}
+	out := w.body.String()
+	if !strings.Contains(out, "partial") || !strings.Contains(out, `"finish_reason":"length"`) || !strings.Contains(out, "[DONE]") {
+		t.Errorf("expected partial output finished with length, got %s", out)
+	}
+}
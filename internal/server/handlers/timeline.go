@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/store"
+)
+
+type timelineKey struct{}
+
+// 时间线阶段名称
+const (
+	traceConvert          = "convert"           // 协议转换
+	traceUpstream         = "upstream"          // 上游请求（非流式含完整响应，流式为收到响应头，均含重试）
+	traceFirstChunk       = "first_chunk"       // 上游首个数据（TTFB）
+	traceFirstClientByte  = "first_client_byte" // 首次写给客户端
+	traceUpstreamComplete = "upstream_complete" // 上游流读取结束
+	traceComplete         = "complete"          // 响应写出完毕
+)
+
+// requestTimeline 收集单次请求的处理时间线，随 context 传递，recordLog 时写入日志详情
+type requestTimeline struct {
+	start time.Time
+
+	mu      sync.Mutex
+	events  []store.TraceEvent
+	chunks  int
+	emitter *store.EmitterStats
+}
+
+// withTimeline 返回携带时间线的请求，start 为请求处理开始时间
+func withTimeline(r *http.Request, start time.Time) (*http.Request, *requestTimeline) {
+	t := &requestTimeline{start: start}
+	return r.WithContext(context.WithValue(r.Context(), timelineKey{}, t)), t
+}
+
+// timelineFrom 获取请求的时间线，不存在时返回 nil
+func timelineFrom(r *http.Request) *requestTimeline {
+	t, _ := r.Context().Value(timelineKey{}).(*requestTimeline)
+	return t
+}
+
+// mark 记录时间点（nil 安全）
+func (t *requestTimeline) mark(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, store.TraceEvent{Name: name, AtMs: time.Since(t.start).Milliseconds()})
+}
+
+// span 记录从 from 到当前的阶段（nil 安全）
+func (t *requestTimeline) span(name string, from time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, store.TraceEvent{
+		Name:       name,
+		AtMs:       from.Sub(t.start).Milliseconds(),
+		DurationMs: time.Since(from).Milliseconds(),
+	})
+}
+
+// wrapStream 包装上游流式响应体，记录首个数据时间与 SSE 事件数（nil 安全）
+// 须在其他包装之前调用，以统计上游原始数据
+func (t *requestTimeline) wrapStream(resp *http.Response) {
+	if t == nil {
+		return
+	}
+	resp.Body = &timelineStreamBody{ReadCloser: resp.Body, timeline: t}
+}
+
+// wrapWriter 包装响应写入器，记录首次输出时间与输出统计（nil 安全）
+func (t *requestTimeline) wrapWriter(w http.ResponseWriter) http.ResponseWriter {
+	if t == nil {
+		return w
+	}
+	t.mu.Lock()
+	t.emitter = &store.EmitterStats{}
+	t.mu.Unlock()
+	return &timelineWriter{ResponseWriter: w, timeline: t}
+}
+
+// snapshot 生成写入日志的时间线（nil 安全）
+func (t *requestTimeline) snapshot(attempts []store.UpstreamAttempt) *store.RequestTrace {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trace := &store.RequestTrace{
+		Events:   append([]store.TraceEvent(nil), t.events...),
+		Chunks:   t.chunks,
+		Attempts: attempts,
+	}
+	if t.emitter != nil {
+		emitter := *t.emitter
+		trace.Emitter = &emitter
+	}
+	return trace
+}
+
+// timelineStreamBody 统计上游流式数据：首次读取记为 TTFB，空行计为一个 SSE 事件
+type timelineStreamBody struct {
+	io.ReadCloser
+	timeline  *requestTimeline
+	started   bool
+	prevBreak bool
+	done      bool
+}
+
+func (b *timelineStreamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.started {
+		b.started = true
+		b.timeline.mark(traceFirstChunk)
+	}
+
+	events := 0
+	for _, c := range p[:n] {
+		switch c {
+		case '\n':
+			if b.prevBreak {
+				events++
+			}
+			b.prevBreak = true
+		case '\r':
+		default:
+			b.prevBreak = false
+		}
+	}
+	if events > 0 {
+		b.timeline.mu.Lock()
+		b.timeline.chunks += events
+		b.timeline.mu.Unlock()
+	}
+
+	if err != nil && !b.done {
+		b.done = true
+		b.timeline.mark(traceUpstreamComplete)
+	}
+	return n, err
+}
+
+// timelineWriter 统计写给客户端的数据
+type timelineWriter struct {
+	http.ResponseWriter
+	timeline *requestTimeline
+}
+
+func (w *timelineWriter) Write(p []byte) (int, error) {
+	t := w.timeline
+	t.mu.Lock()
+	first := t.emitter.Writes == 0
+	t.emitter.Writes++
+	t.emitter.Bytes += int64(len(p))
+	t.mu.Unlock()
+	if first {
+		t.mark(traceFirstClientByte)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush 实现 http.Flusher 接口，支持流式响应
+func (w *timelineWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *timelineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeline(t *testing.T) {
+	r, timeline := withTimeline(httptest.NewRequest("POST", "/v1/chat/completions", nil), time.Now())
+	if timelineFrom(r) != timeline {
+		t.Fatal("timeline not attached to request context")
+	}
+
+	timeline.span(traceConvert, timeline.start)
+	upstream := "data: {\"a\":1}\n\ndata: {\"b\":2}\r\n\r\ndata: {\"c\":3}\n\n"
+	resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstream))}
+	timeline.wrapStream(resp)
+	io.ReadAll(resp.Body)
+
+	w := timeline.wrapWriter(httptest.NewRecorder())
+	w.Write([]byte("data: x\n\n"))
+	w.Write([]byte("data: [DONE]\n\n"))
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("wrapped writer must implement http.Flusher")
+	}
+
+	trace := timeline.snapshot(nil)
+	if trace.Chunks != 3 {
+		t.Errorf("chunks = %d, want 3", trace.Chunks)
+	}
+	if trace.Emitter == nil || trace.Emitter.Writes != 2 || trace.Emitter.Bytes != 23 {
+		t.Errorf("emitter = %+v", trace.Emitter)
+	}
+	var names []string
+	for _, e := range trace.Events {
+		names = append(names, e.Name)
+	}
+	want := []string{traceConvert, traceFirstChunk, traceUpstreamComplete, traceFirstClientByte}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", names, want)
+	}
+
+	// 没有时间线的请求不记录
+	if timelineFrom(httptest.NewRequest("GET", "/", nil)).snapshot(nil) != nil {
+		t.Error("snapshot without timeline should be nil")
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"anti2api-golang/internal/auth"
+)
+
+// HandleListAPITokens 列出管理 API 令牌（不含明文与哈希）
+func HandleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"tokens": auth.GetAPITokenStore().List(),
+	})
+}
+
+// HandleCreateAPIToken 创建管理 API 令牌，明文仅在此响应中返回一次
+func HandleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string `json:"name"`
+		Scope         string `json:"scope"`
+		ExpiresInDays int    `json:"expiresInDays"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, plain, err := auth.GetAPITokenStore().Create(req.Name, req.Scope, ttl)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"token":   plain,
+		"info":    token,
+	})
+}
+
+// HandleRevokeAPIToken 吊销管理 API 令牌
+func HandleRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	if err := auth.GetAPITokenStore().Revoke(r.PathValue("id")); err != nil {
+		WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
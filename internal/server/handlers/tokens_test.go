@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/config"
+)
+
+func TestAPITokenHandlers(t *testing.T) {
+	cfg := config.Get()
+	defer func(dir string) { cfg.DataDir = dir }(cfg.DataDir)
+	cfg.DataDir = t.TempDir()
+
+	w := httptest.NewRecorder()
+	HandleCreateAPIToken(w, httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(`{"name":"ci","scope":"read","expiresInDays":30}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "hash") {
+		t.Errorf("create response should not expose the hash: %s", w.Body.String())
+	}
+	var created struct {
+		Token string        `json:"token"`
+		Info  auth.APIToken `json:"info"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if scope, ok := auth.GetAPITokenStore().Validate(created.Token); !ok || scope != auth.ScopeRead || created.Info.ExpiresAt == nil {
+		t.Errorf("created token: scope=%q ok=%v info=%+v", scope, ok, created.Info)
+	}
+
+	w = httptest.NewRecorder()
+	HandleCreateAPIToken(w, httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(`{"name":"ci","scope":"root"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid scope: got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	HandleListAPITokens(w, httptest.NewRequest(http.MethodGet, "/admin/tokens", nil))
+	if !strings.Contains(w.Body.String(), created.Info.ID) || strings.Contains(w.Body.String(), "hash") {
+		t.Errorf("list: %s", w.Body.String())
+	}
+
+	revoke := func(id string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/admin/tokens/"+id, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		HandleRevokeAPIToken(w, req)
+		return w.Code
+	}
+	if code := revoke(created.Info.ID); code != http.StatusOK {
+		t.Errorf("revoke: got %d", code)
+	}
+	if _, ok := auth.GetAPITokenStore().Validate(created.Token); ok {
+		t.Error("revoked token should not validate")
+	}
+	if code := revoke(created.Info.ID); code != http.StatusNotFound {
+		t.Errorf("revoke unknown: got %d", code)
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+)
+
+// toolArgRepairer 按请求声明的函数 schema 修复上游返回的工具调用参数（TOOL_ARG_REPAIR）
+// 修复在协议转换之前进行，三种协议共用；原始参数记录到日志详情
+type toolArgRepairer struct {
+	tools []core.Tool
+
+	mu      sync.Mutex
+	repairs []store.ToolArgRepair
+}
+
+// newToolArgRepairer 未开启或请求未声明工具时返回 nil（nil 上的方法均为空操作）
+func newToolArgRepairer(req *core.AntigravityRequest) *toolArgRepairer {
+	if !config.Get().ToolArgRepair || len(req.Request.Tools) == 0 {
+		return nil
+	}
+	return &toolArgRepairer{tools: req.Request.Tools}
+}
+
+// repairCall 修复单个工具调用，返回是否发生修改
+func (t *toolArgRepairer) repairCall(call *core.FunctionCall) bool {
+	decl := core.FindFunctionDeclaration(t.tools, call.Name)
+	if decl == nil || decl.Parameters == nil {
+		return false
+	}
+
+	repaired, changed := core.RepairToolArgs(decl.Parameters, call.Args)
+	if !changed {
+		return false
+	}
+	logger.Warn("Repaired arguments of tool call %s", call.Name)
+
+	t.mu.Lock()
+	t.repairs = append(t.repairs, store.ToolArgRepair{
+		ID:       call.ID,
+		Name:     call.Name,
+		Original: call.Args,
+		Repaired: repaired,
+	})
+	t.mu.Unlock()
+
+	call.Args = repaired
+	return true
+}
+
+// repairResponse 修复非流式响应中的工具调用
+func (t *toolArgRepairer) repairResponse(resp *core.AntigravityResponse) {
+	if t == nil {
+		return
+	}
+	for i := range resp.Response.Candidates {
+		parts := resp.Response.Candidates[i].Content.Parts
+		for j := range parts {
+			if parts[j].FunctionCall != nil {
+				t.repairCall(parts[j].FunctionCall)
+			}
+		}
+	}
+}
+
+// wrapStream 替换流式响应体，逐行修复 SSE 事件中的工具调用
+func (t *toolArgRepairer) wrapStream(resp *http.Response) {
+	if t == nil {
+		return
+	}
+
+	reader, ok := decodedStreamBody(resp)
+	if !ok {
+		return
+	}
+
+	resp.Body = &repairStreamBody{
+		reader:   bufio.NewReaderSize(reader, 4*1024),
+		closer:   resp.Body,
+		repairer: t,
+	}
+}
+
+// decodedStreamBody 返回解压后的流式响应体读取器（并移除 Content-Encoding），解压失败时返回 false
+func decodedStreamBody(resp *http.Response) (io.Reader, bool) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return resp.Body, true
+	}
+	gzReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, false
+	}
+	resp.Header.Del("Content-Encoding")
+	return gzReader, true
+}
+
+// attach 将修复记录写入对应日志
+func (t *toolArgRepairer) attach(logID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	repairs := t.repairs
+	t.mu.Unlock()
+
+	if len(repairs) > 0 {
+		store.GetLogStore().SetToolArgRepairs(logID, repairs)
+	}
+}
+
+// repairStreamBody 按行修复的流式响应体
+type repairStreamBody struct {
+	reader   *bufio.Reader
+	closer   io.Closer
+	repairer *toolArgRepairer
+	pending  string
+	err      error
+}
+
+func (b *repairStreamBody) Read(p []byte) (int, error) {
+	for b.pending == "" {
+		if b.err != nil {
+			return 0, b.err
+		}
+		var line string
+		line, b.err = b.reader.ReadString('\n')
+		b.pending = b.repairer.repairLine(line)
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *repairStreamBody) Close() error {
+	return b.closer.Close()
+}
+
+// repairLine 修复单行 SSE 数据；无需修改时原样返回
+func (t *toolArgRepairer) repairLine(line string) string {
+	if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, "functionCall") {
+		return line
+	}
+
+	payload := strings.TrimRight(line[6:], "\r\n")
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+		return line
+	}
+
+	changed := false
+	response, _ := chunk["response"].(map[string]interface{})
+	candidates, _ := response["candidates"].([]interface{})
+	for _, c := range candidates {
+		candidate, _ := c.(map[string]interface{})
+		content, _ := candidate["content"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		for _, p := range parts {
+			part, _ := p.(map[string]interface{})
+			fc, ok := part["functionCall"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			call := &core.FunctionCall{}
+			call.ID, _ = fc["id"].(string)
+			call.Name, _ = fc["name"].(string)
+			call.Args, _ = fc["args"].(map[string]interface{})
+			if t.repairCall(call) {
+				fc["args"] = call.Args
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return line
+	}
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return line
+	}
+	return "data: " + string(data) + line[len(strings.TrimRight(line, "\r\n")):]
+}
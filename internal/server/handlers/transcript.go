@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"anti2api-golang/internal/store"
+)
+
+// transcript 由日志快照重建的可读对话记录（三种协议统一格式）
+type transcript struct {
+	ID        string              `json:"id"`
+	Timestamp time.Time           `json:"timestamp"`
+	Model     string              `json:"model"`
+	Path      string              `json:"path"`
+	Status    int                 `json:"status"`
+	Messages  []transcriptMessage `json:"messages"`
+	Output    string              `json:"output,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// transcriptMessage 对话中的一条记录
+// Role 为 system / user / assistant / tool_call / tool_result；工具调用的 Content 为参数 JSON
+type transcriptMessage struct {
+	Role    string `json:"role"`
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content"`
+}
+
+// HandleGetLogTranscript 按日志 ID 重建可读的对话记录，便于提交问题时分享而无需原始 JSON
+// 默认返回 Markdown（?format=json 返回结构化记录）
+func HandleGetLogTranscript(w http.ResponseWriter, r *http.Request) {
+	log := store.GetLogStore().GetByID(r.PathValue("id"))
+	if log == nil {
+		WriteError(w, http.StatusNotFound, "Log not found")
+		return
+	}
+	if log.Detail == nil || log.Detail.Request == nil {
+		WriteError(w, http.StatusNotFound, "Log has no request snapshot")
+		return
+	}
+
+	t := buildTranscript(log)
+	if r.URL.Query().Get("format") == "json" {
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"transcript": t})
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="transcript-`+log.ID+`.md"`)
+	w.Write([]byte(t.markdown()))
+}
+
+// buildTranscript 从请求快照（OpenAI / Claude / Gemini 客户端请求体）与响应快照重建对话
+func buildTranscript(log *store.LogEntry) *transcript {
+	t := &transcript{
+		ID:        log.ID,
+		Timestamp: log.Timestamp,
+		Model:     log.Model,
+		Path:      log.Path,
+		Status:    log.Status,
+		Messages:  []transcriptMessage{},
+		Error:     log.Message,
+	}
+	if log.Detail.Response != nil {
+		t.Output = log.Detail.Response.ModelOutput
+	}
+
+	// 内存中的快照为请求结构体，持久化后为 JSON 对象：统一转换为通用 map 处理
+	var body map[string]interface{}
+	if data, err := json.Marshal(log.Detail.Request.Body); err == nil {
+		json.Unmarshal(data, &body)
+	}
+
+	switch {
+	case body["contents"] != nil:
+		t.addGemini(body)
+	case body["messages"] != nil:
+		t.addMessages(body)
+	case body["prompt"] != nil:
+		t.add("user", "", contentText(body["prompt"]))
+	}
+	return t
+}
+
+func (t *transcript) add(role, name, content string) {
+	if content == "" && role != "tool_call" && role != "tool_result" {
+		return
+	}
+	t.Messages = append(t.Messages, transcriptMessage{Role: role, Name: name, Content: content})
+}
+
+// addMessages OpenAI / Claude 格式：messages 数组，Claude 的 system 在顶层
+func (t *transcript) addMessages(body map[string]interface{}) {
+	t.add("system", "", contentText(body["system"]))
+
+	messages, _ := body["messages"].([]interface{})
+	for _, item := range messages {
+		msg, _ := item.(map[string]interface{})
+		role, _ := msg["role"].(string)
+		switch role {
+		case "tool", "function":
+			name, _ := msg["name"].(string)
+			t.add("tool_result", name, contentText(msg["content"]))
+			continue
+		case "developer":
+			role = "system"
+		}
+
+		// Claude 内容块中的工具调用与结果按出现顺序展开
+		if blocks, ok := msg["content"].([]interface{}); ok {
+			var pending []interface{}
+			for _, b := range blocks {
+				block, _ := b.(map[string]interface{})
+				switch block["type"] {
+				case "tool_use":
+					t.add(role, "", contentText(pending))
+					pending = nil
+					name, _ := block["name"].(string)
+					t.add("tool_call", name, jsonText(block["input"]))
+				case "tool_result":
+					t.add(role, "", contentText(pending))
+					pending = nil
+					t.add("tool_result", "", contentText(block["content"]))
+				default:
+					pending = append(pending, b)
+				}
+			}
+			t.add(role, "", contentText(pending))
+		} else {
+			t.add(role, "", contentText(msg["content"]))
+		}
+
+		calls, _ := msg["tool_calls"].([]interface{})
+		for _, c := range calls {
+			call, _ := c.(map[string]interface{})
+			fn, _ := call["function"].(map[string]interface{})
+			name, _ := fn["name"].(string)
+			t.add("tool_call", name, argumentsText(fn["arguments"]))
+		}
+		if fn, ok := msg["function_call"].(map[string]interface{}); ok {
+			name, _ := fn["name"].(string)
+			t.add("tool_call", name, argumentsText(fn["arguments"]))
+		}
+	}
+}
+
+// addGemini Gemini 格式：systemInstruction 与 contents[].parts
+func (t *transcript) addGemini(body map[string]interface{}) {
+	if si, ok := body["systemInstruction"].(map[string]interface{}); ok {
+		t.add("system", "", partsText(si["parts"]))
+	}
+
+	contents, _ := body["contents"].([]interface{})
+	for _, item := range contents {
+		content, _ := item.(map[string]interface{})
+		role := "user"
+		if content["role"] == "model" {
+			role = "assistant"
+		}
+
+		parts, _ := content["parts"].([]interface{})
+		var pending []interface{}
+		for _, p := range parts {
+			part, _ := p.(map[string]interface{})
+			switch {
+			case part["functionCall"] != nil:
+				t.add(role, "", partsText(pending))
+				pending = nil
+				call, _ := part["functionCall"].(map[string]interface{})
+				name, _ := call["name"].(string)
+				t.add("tool_call", name, jsonText(call["args"]))
+			case part["functionResponse"] != nil:
+				t.add(role, "", partsText(pending))
+				pending = nil
+				resp, _ := part["functionResponse"].(map[string]interface{})
+				name, _ := resp["name"].(string)
+				t.add("tool_result", name, jsonText(resp["response"]))
+			case part["thought"] == true:
+				// 思考内容不计入对话记录
+			default:
+				pending = append(pending, p)
+			}
+		}
+		t.add(role, "", partsText(pending))
+	}
+}
+
+// contentText 提取 OpenAI / Claude 消息内容中的文本，图片等非文本内容以占位符表示
+func contentText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var texts []string
+		for _, item := range c {
+			switch block := item.(type) {
+			case string:
+				texts = append(texts, block)
+			case map[string]interface{}:
+				switch block["type"] {
+				case "text", "input_text":
+					if text, _ := block["text"].(string); text != "" {
+						texts = append(texts, text)
+					}
+				case "image_url", "image", "input_image":
+					texts = append(texts, "[image]")
+				case "document", "file":
+					texts = append(texts, "[document]")
+				case "input_audio":
+					texts = append(texts, "[audio]")
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// partsText 提取 Gemini parts 中的文本，内联数据以占位符表示
+func partsText(parts interface{}) string {
+	items, _ := parts.([]interface{})
+	var texts []string
+	for _, item := range items {
+		part, _ := item.(map[string]interface{})
+		if text, _ := part["text"].(string); text != "" {
+			texts = append(texts, text)
+		} else if data, ok := part["inlineData"].(map[string]interface{}); ok {
+			mimeType, _ := data["mimeType"].(string)
+			texts = append(texts, "["+mimeType+"]")
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// argumentsText OpenAI 工具调用参数（JSON 字符串）格式化为缩进 JSON
+func argumentsText(args interface{}) string {
+	s, ok := args.(string)
+	if !ok {
+		return jsonText(args)
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(s), &v) != nil {
+		return s
+	}
+	return jsonText(v)
+}
+
+func jsonText(v interface{}) string {
+	if v == nil {
+		return "{}"
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// markdown 渲染为 Markdown 文本
+func (t *transcript) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Transcript %s\n\n", t.ID)
+	fmt.Fprintf(&b, "- Model: %s\n- Path: %s\n- Time: %s\n- Status: %d\n",
+		t.Model, t.Path, t.Timestamp.UTC().Format(time.RFC3339), t.Status)
+
+	for _, msg := range t.Messages {
+		switch msg.Role {
+		case "tool_call":
+			fmt.Fprintf(&b, "\n### Tool call: %s\n\n```json\n%s\n```\n", msg.Name, msg.Content)
+		case "tool_result":
+			title := "Tool result"
+			if msg.Name != "" {
+				title += ": " + msg.Name
+			}
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n", title, fence(msg.Content))
+		default:
+			title := roleTitles[msg.Role]
+			if title == "" {
+				title = msg.Role
+			}
+			fmt.Fprintf(&b, "\n## %s\n\n%s\n", title, msg.Content)
+		}
+	}
+
+	if t.Output != "" {
+		fmt.Fprintf(&b, "\n## Output\n\n%s\n", t.Output)
+	}
+	if t.Error != "" {
+		fmt.Fprintf(&b, "\n## Error\n\n%s\n", fence(t.Error))
+	}
+	return b.String()
+}
+
+var roleTitles = map[string]string{"system": "System", "user": "User", "assistant": "Assistant"}
+
+// fence 以代码块包裹文本，围栏长度超过内容中最长的反引号序列
+func fence(text string) string {
+	longest, run := 0, 0
+	for _, c := range text {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + "\n" + text + "\n" + marker
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/store"
+)
+
+func TestBuildTranscript(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []transcriptMessage
+	}{
+		{"openai", `{"model":"m","messages":[
+			{"role":"system","content":"be brief"},
+			{"role":"user","content":[{"type":"text","text":"weather?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AA"}}]},
+			{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+			{"role":"tool","tool_call_id":"c1","content":"sunny"}]}`,
+			[]transcriptMessage{
+				{Role: "system", Content: "be brief"},
+				{Role: "user", Content: "weather?\n[image]"},
+				{Role: "tool_call", Name: "get_weather", Content: "{\n  \"city\": \"Paris\"\n}"},
+				{Role: "tool_result", Content: "sunny"},
+			}},
+		{"claude", `{"model":"m","system":[{"type":"text","text":"be brief"}],"messages":[
+			{"role":"user","content":"weather?"},
+			{"role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"tool_use","id":"t1","name":"get_weather","input":{"city":"Paris"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"sunny"}]}]}]}`,
+			[]transcriptMessage{
+				{Role: "system", Content: "be brief"},
+				{Role: "user", Content: "weather?"},
+				{Role: "tool_call", Name: "get_weather", Content: "{\n  \"city\": \"Paris\"\n}"},
+				{Role: "tool_result", Content: "sunny"},
+			}},
+		{"gemini", `{"systemInstruction":{"parts":[{"text":"be brief"}]},"contents":[
+			{"role":"user","parts":[{"text":"weather?"}]},
+			{"role":"model","parts":[{"text":"plan","thought":true},{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},
+			{"role":"user","parts":[{"functionResponse":{"name":"get_weather","response":{"result":"sunny"}}}]}]}`,
+			[]transcriptMessage{
+				{Role: "system", Content: "be brief"},
+				{Role: "user", Content: "weather?"},
+				{Role: "tool_call", Name: "get_weather", Content: "{\n  \"city\": \"Paris\"\n}"},
+				{Role: "tool_result", Name: "get_weather", Content: "{\n  \"result\": \"sunny\"\n}"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body interface{}
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			log := &store.LogEntry{ID: "log1", Model: "m", Status: 200, Detail: &store.LogDetail{
+				Request:  &store.RequestSnapshot{Body: body},
+				Response: &store.ResponseSnapshot{ModelOutput: "It is sunny."},
+			}}
+
+			tr := buildTranscript(log)
+			if len(tr.Messages) != len(tt.want) {
+				t.Fatalf("got %d messages: %+v", len(tr.Messages), tr.Messages)
+			}
+			for i := range tt.want {
+				if tr.Messages[i] != tt.want[i] {
+					t.Errorf("message %d = %+v, want %+v", i, tr.Messages[i], tt.want[i])
+				}
+			}
+
+			md := tr.markdown()
+			for _, s := range []string{"# Transcript log1", "## System", "### Tool call: get_weather", "## Output\n\nIt is sunny."} {
+				if !strings.Contains(md, s) {
+					t.Errorf("markdown missing %q:\n%s", s, md)
+				}
+			}
+		})
+	}
+
+	if got := fence("a ``` b"); !strings.HasPrefix(got, "````\n") {
+		t.Errorf("fence should outgrow inner backticks, got %q", got)
+	}
+}
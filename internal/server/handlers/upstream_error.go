@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+var (
+	upstreamURLPattern     = regexp.MustCompile(`https?://[^\s"']+`)
+	upstreamProjectPattern = regexp.MustCompile(`projects/[\w.:-]+`)
+	upstreamEmailPattern   = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+)
+
+// clientErrorMessage 返回给客户端的上游错误信息
+// 鉴权、限流与 5xx 错误返回通用描述；其余错误保留上游说明但移除项目 ID、邮箱与上游地址
+// 完整的上游响应仅记录在管理日志详情中（EXPOSE_UPSTREAM_ERRORS=true 时原样返回）
+func clientErrorMessage(err error, token *store.Account) string {
+	if config.Get().ExposeUpstreamErrors {
+		return err.Error()
+	}
+
+	apiErr, ok := err.(*vertex.APIError)
+	if !ok {
+		return redactUpstreamMessage(err.Error(), token)
+	}
+
+	switch {
+	case apiErr.Status == http.StatusTooManyRequests:
+		return "Upstream rate limit exceeded, please retry later"
+	case apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden:
+		return "Upstream authentication failed"
+	case apiErr.Status >= 500:
+		return fmt.Sprintf("Upstream service error (%d)", apiErr.Status)
+	}
+	return fmt.Sprintf("Upstream error %d: %s", apiErr.Status, redactUpstreamMessage(apiErr.Message, token))
+}
+
+// redactUpstreamMessage 移除错误信息中的账号与上游细节
+func redactUpstreamMessage(message string, token *store.Account) string {
+	if token != nil {
+		if token.ProjectID != "" {
+			message = strings.ReplaceAll(message, token.ProjectID, "[redacted]")
+		}
+		if token.Email != "" {
+			message = strings.ReplaceAll(message, token.Email, "[redacted]")
+		}
+	}
+	message = upstreamURLPattern.ReplaceAllString(message, "[upstream]")
+	message = upstreamProjectPattern.ReplaceAllString(message, "projects/[redacted]")
+	message = upstreamEmailPattern.ReplaceAllString(message, "[redacted]")
+	return message
+}
+
+// contextLengthPattern 上游提示输入超出上下文窗口的错误信息
+var contextLengthPattern = regexp.MustCompile(`(?i)(input token count|exceeds the maximum number of tokens|context (length|window))`)
+
+// writeUpstreamError 写出上游错误：附带与 OpenAI 一致的错误码（rate_limit_exceeded、context_length_exceeded 等）
+// 请求类错误（4xx，鉴权与限流除外）附带上游错误状态 upstream_status（如 INVALID_ARGUMENT）；
+// EXPOSE_UPSTREAM_ERRORS=true 时另附上游原始错误对象 upstream_error
+func writeUpstreamError(w http.ResponseWriter, a adapter.ErrorRenderer, err error, token *store.Account) {
+	status := getErrorStatus(err)
+	code, details := upstreamErrorDetails(err)
+	adapter.WriteErrorDetails(w, a, status, code, clientErrorMessage(err, token), details)
+}
+
+// upstreamErrorDetails 按上游错误推断错误码与附加字段
+func upstreamErrorDetails(err error) (string, map[string]interface{}) {
+	apiErr, ok := err.(*vertex.APIError)
+	if !ok {
+		return "", nil
+	}
+
+	var code string
+	switch {
+	case apiErr.Status == http.StatusTooManyRequests:
+		code = "rate_limit_exceeded"
+	case apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden:
+		// 上游账号鉴权失败，与客户端 API Key 无关
+		code = "upstream_authentication_failed"
+	case apiErr.Status == http.StatusBadRequest && contextLengthPattern.MatchString(apiErr.Message):
+		code = "context_length_exceeded"
+	case apiErr.Status >= 500:
+		code = "upstream_error"
+	}
+
+	// 上游错误体形如 {"error":{"code":400,"message":"...","status":"INVALID_ARGUMENT","details":[...]}}
+	var body struct {
+		Error map[string]interface{} `json:"error"`
+	}
+	if json.Unmarshal([]byte(apiErr.Body), &body) != nil || body.Error == nil {
+		return code, nil
+	}
+
+	details := map[string]interface{}{}
+	clientError := apiErr.Status >= 400 && apiErr.Status < 500 &&
+		apiErr.Status != http.StatusUnauthorized && apiErr.Status != http.StatusForbidden && apiErr.Status != http.StatusTooManyRequests
+	if status, _ := body.Error["status"].(string); status != "" && clientError {
+		details["upstream_status"] = status
+	}
+	if config.Get().ExposeUpstreamErrors {
+		details["upstream_error"] = body.Error
+	}
+	if len(details) == 0 {
+		details = nil
+	}
+	return code, details
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"anti2api-golang/internal/adapter"
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/store"
+	"anti2api-golang/internal/vertex"
+)
+
+func TestClientErrorMessage(t *testing.T) {
+	token := &store.Account{ProjectID: "bright-owl-123", Email: "user@example.com"}
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&vertex.APIError{Status: 429, Message: "Quota exceeded for project bright-owl-123"}, "Upstream rate limit exceeded, please retry later"},
+		{&vertex.APIError{Status: 403, Message: "Permission denied on projects/bright-owl-123"}, "Upstream authentication failed"},
+		{&vertex.APIError{Status: 503, Message: "backend unavailable"}, "Upstream service error (503)"},
+		{&vertex.APIError{Status: 400, Message: "Invalid argument for bright-owl-123 (user@example.com) at projects/other-1/locations"}, "Upstream error 400: Invalid argument for [redacted] ([redacted]) at projects/[redacted]/locations"},
+		{errors.New(`Post "https://daily-cloudcode-pa.googleapis.com/v1internal:generateContent": EOF`), `Post "[upstream]": EOF`},
+	}
+	for _, tt := range tests {
+		if got := clientErrorMessage(tt.err, token); got != tt.want {
+			t.Errorf("clientErrorMessage(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+
+	if got := clientErrorMessage(&vertex.APIError{Status: 400, Message: "bad"}, nil); !strings.HasSuffix(got, "bad") {
+		t.Errorf("nil token: %q", got)
+	}
+}
+
+func TestWriteUpstreamError(t *testing.T) {
+	cfg := config.Get()
+	defer func(v bool) { cfg.ExposeUpstreamErrors = v }(cfg.ExposeUpstreamErrors)
+	cfg.ExposeUpstreamErrors = false
+
+	write := func(err error) map[string]interface{} {
+		w := httptest.NewRecorder()
+		writeUpstreamError(w, adapter.MustGet(adapter.ProtocolOpenAI), err, nil)
+		var body struct {
+			Error map[string]interface{} `json:"error"`
+		}
+		if jsonErr := json.Unmarshal(w.Body.Bytes(), &body); jsonErr != nil {
+			t.Fatal(jsonErr)
+		}
+		return body.Error
+	}
+
+	invalid := &vertex.APIError{Status: 400, Message: "The input token count (2000000) exceeds the maximum number of tokens allowed (1048576).",
+		Body: `{"error":{"code":400,"message":"The input token count exceeds the maximum number of tokens allowed","status":"INVALID_ARGUMENT"}}`}
+	obj := write(invalid)
+	if obj["code"] != "context_length_exceeded" || obj["upstream_status"] != "INVALID_ARGUMENT" || obj["type"] != "invalid_request_error" {
+		t.Errorf("context length error = %v", obj)
+	}
+	if _, ok := obj["param"]; !ok || obj["upstream_error"] != nil {
+		t.Errorf("param should be present and upstream body hidden: %v", obj)
+	}
+
+	obj = write(&vertex.APIError{Status: 429, Message: "quota", Body: `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED"}}`})
+	if obj["code"] != "rate_limit_exceeded" || obj["upstream_status"] != nil {
+		t.Errorf("rate limit error = %v", obj)
+	}
+
+	cfg.ExposeUpstreamErrors = true
+	obj = write(invalid)
+	if upstream, _ := obj["upstream_error"].(map[string]interface{}); upstream["status"] != "INVALID_ARGUMENT" {
+		t.Errorf("exposed upstream error = %v", obj)
+	}
+}
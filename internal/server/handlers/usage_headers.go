@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+// 用量响应头：便于网关按用量计费或限额而无需解析响应体
+const (
+	headerUsagePrompt     = "X-Usage-Prompt-Tokens"
+	headerUsageCompletion = "X-Usage-Completion-Tokens"
+	headerUsageTotal      = "X-Usage-Total-Tokens"
+)
+
+// 账号池限流响应头：可用账号数与最早恢复时间
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit-Accounts"
+	headerRateLimitRemaining = "X-RateLimit-Remaining-Accounts"
+	headerRateLimitReset     = "X-RateLimit-Reset-Accounts"
+)
+
+// usageHeaders 用量响应头，流式响应中以 HTTP Trailer 形式在末尾返回
+var usageHeaders = []string{headerUsagePrompt, headerUsageCompletion, headerUsageTotal}
+
+// setUsageHeaders 写入用量响应头；非流式响应须在写出响应前调用，流式响应须先 declareUsageTrailers
+// completion 包含思考 token，与 total - prompt 一致
+func setUsageHeaders(w http.ResponseWriter, usage *core.UsageMetadata) {
+	if !config.Get().UsageHeaders || usage == nil {
+		return
+	}
+	h := w.Header()
+	h.Set(headerUsagePrompt, strconv.Itoa(usage.PromptTokenCount))
+	h.Set(headerUsageCompletion, strconv.Itoa(usage.CandidatesTokenCount+usage.ThoughtsTokenCount))
+	h.Set(headerUsageTotal, strconv.Itoa(usage.TotalTokenCount))
+}
+
+// declareUsageTrailers 声明用量 Trailer，须在流式响应写出响应头前调用
+// SSE 结束时用量才可知，因此通过 Trailer 返回；不支持 Trailer 的客户端或代理会忽略它们
+func declareUsageTrailers(w http.ResponseWriter) {
+	if !config.Get().UsageHeaders {
+		return
+	}
+	w.Header().Set("Trailer", strings.Join(usageHeaders, ", "))
+}
+
+// Anthropic 限流响应头：Claude 客户端据此调整请求节奏
+// 代理没有按 API Key 的配额，请求数额度由账号池折算：每个启用账号计 1，冷却中的账号不计入剩余
+const (
+	headerAnthropicRequestsLimit     = "anthropic-ratelimit-requests-limit"
+	headerAnthropicRequestsRemaining = "anthropic-ratelimit-requests-remaining"
+	headerAnthropicRequestsReset     = "anthropic-ratelimit-requests-reset"
+)
+
+// setRateLimitHeaders 写入账号池限流响应头，须在写出响应前调用
+// Reset 为冷却中账号最早恢复的剩余秒数，无冷却账号时不返回
+// 携带 anthropic-version 请求头的 Claude 客户端额外返回 anthropic-ratelimit-requests-*
+func setRateLimitHeaders(w http.ResponseWriter, r *http.Request) {
+	if !config.Get().UsageHeaders {
+		return
+	}
+	enabled, available, resetAt := store.GetAccountStore().PoolStatus()
+	h := w.Header()
+	h.Set(headerRateLimitLimit, strconv.Itoa(enabled))
+	h.Set(headerRateLimitRemaining, strconv.Itoa(available))
+	if !resetAt.IsZero() {
+		reset := max(int(math.Ceil(time.Until(resetAt).Seconds())), 1)
+		h.Set(headerRateLimitReset, strconv.Itoa(reset))
+	}
+
+	if r.Header.Get("anthropic-version") != "" {
+		setAnthropicRateLimitHeaders(h, enabled, available, resetAt)
+	}
+}
+
+// setAnthropicRateLimitHeaders 按 Anthropic 格式写入请求数限流头，reset 为 RFC 3339 时间
+// 没有冷却账号时额度已满，reset 为当前时间
+func setAnthropicRateLimitHeaders(h http.Header, enabled, available int, resetAt time.Time) {
+	if resetAt.IsZero() {
+		resetAt = time.Now()
+	}
+	h.Set(headerAnthropicRequestsLimit, strconv.Itoa(enabled))
+	h.Set(headerAnthropicRequestsRemaining, strconv.Itoa(available))
+	h.Set(headerAnthropicRequestsReset, resetAt.UTC().Format(time.RFC3339))
+}
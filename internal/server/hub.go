@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/store"
+)
+
+// 管理面板推送事件类型
+const (
+	EventAccounts     = "accounts"      // 账号启用/冷却/过期/刷新状态变化
+	EventEndpointMode = "endpoint_mode" // 端点模式变化（手动切换或定时调度）
+	EventStats        = "stats"         // 有新的请求日志
+)
+
+const (
+	hubPollInterval = 2 * time.Second  // 状态变化检测间隔
+	hubPingInterval = 30 * time.Second // 客户端心跳间隔
+	hubSendBuffer   = 16               // 每个客户端的待发送事件数，写满时断开慢客户端
+)
+
+// HubEvent 推送给管理面板的事件
+type HubEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+	Time time.Time   `json:"time"`
+}
+
+// hubClient 一个面板 WebSocket 连接
+type hubClient struct {
+	conn *wsConn
+	send chan []byte
+}
+
+// hubState 用于检测变化的状态快照
+type hubState struct {
+	accounts    string
+	mode        string
+	logsVersion uint64
+}
+
+// Hub 管理面板推送中心：单个后台协程检测账号、端点模式与日志变化，并广播给所有已连接的面板
+// 仅在有客户端连接时才检测状态
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*hubClient]struct{}
+	state   hubState
+	started bool
+}
+
+var (
+	hub     *Hub
+	hubOnce sync.Once
+)
+
+// GetHub 获取推送中心单例
+func GetHub() *Hub {
+	hubOnce.Do(func() {
+		hub = &Hub{clients: make(map[*hubClient]struct{})}
+	})
+	return hub
+}
+
+// HandleAdminEvents 升级为 WebSocket 并推送面板事件
+func HandleAdminEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err == errNotWebSocket {
+		http.Error(w, "Expected WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+	if err == errCrossOrigin {
+		http.Error(w, "Cross-origin WebSocket connection rejected", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logger.Warn("Admin WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	h := GetHub()
+	client := &hubClient{conn: conn, send: make(chan []byte, hubSendBuffer)}
+	h.register(client)
+	defer h.unregister(client)
+
+	go client.writeLoop()
+	conn.ReadLoop()
+}
+
+// Clients 当前连接数
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Publish 立即广播事件（例如管理接口已知状态发生变化时）
+func (h *Hub) Publish(eventType string, data interface{}) {
+	payload, err := json.Marshal(HubEvent{Type: eventType, Data: data, Time: time.Now()})
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client.send <- payload:
+		default:
+			// 客户端消费过慢，断开后由面板重连并全量刷新
+			h.removeLocked(client)
+		}
+	}
+}
+
+func (h *Hub) register(client *hubClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.clients) == 0 {
+		// 以连接时的状态为基准，只推送之后的变化
+		h.state = captureHubState()
+	}
+	h.clients[client] = struct{}{}
+	if !h.started {
+		h.started = true
+		go h.run()
+	}
+}
+
+func (h *Hub) unregister(client *hubClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(client)
+}
+
+func (h *Hub) removeLocked(client *hubClient) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	close(client.send)
+}
+
+// run 定时检测状态变化并广播
+func (h *Hub) run() {
+	ticker := time.NewTicker(hubPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if h.Clients() == 0 {
+			continue
+		}
+		h.checkChanges()
+	}
+}
+
+// checkChanges 对比状态快照，为每类变化广播一个事件
+func (h *Hub) checkChanges() {
+	current := captureHubState()
+	h.mu.Lock()
+	prev := h.state
+	h.state = current
+	h.mu.Unlock()
+
+	if current.accounts != prev.accounts {
+		accounts := store.GetAccountStore()
+		enabled, available, resetAt := accounts.PoolStatus()
+		data := map[string]interface{}{
+			"total":     accounts.Count(),
+			"enabled":   enabled,
+			"available": available,
+		}
+		if !resetAt.IsZero() {
+			data["resetAt"] = resetAt
+		}
+		h.Publish(EventAccounts, data)
+	}
+	if current.mode != prev.mode {
+		h.Publish(EventEndpointMode, map[string]interface{}{"mode": current.mode})
+	}
+	if current.logsVersion != prev.logsVersion {
+		h.Publish(EventStats, map[string]interface{}{"logsVersion": current.logsVersion})
+	}
+}
+
+// captureHubState 采集当前状态
+func captureHubState() hubState {
+	var sb strings.Builder
+	for _, acc := range store.GetAccountStore().GetAll() {
+		fmt.Fprintf(&sb, "%s|%s|%t|%t|%t|%t|%s;", acc.Email, acc.ProjectID, acc.Enable, acc.Archived(), acc.InCooldown(), acc.IsExpired(), acc.LastRefreshError)
+	}
+	return hubState{
+		accounts:    sb.String(),
+		mode:        config.GetEndpointManager().ActiveMode(),
+		logsVersion: store.GetLogStore().Version(),
+	}
+}
+
+// writeLoop 发送事件与心跳，send 关闭或写失败时关闭连接
+func (c *hubClient) writeLoop() {
+	ticker := time.NewTicker(hubPingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := c.conn.WriteText(payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminEventsWebSocket(t *testing.T) {
+	srv := httptest.NewServer(RequestLogger(http.HandlerFunc(HandleAdminEvents)))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte("GET /admin/ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %q", got)
+	}
+
+	h := GetHub()
+	for i := 0; h.Clients() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Publish(EventEndpointMode, map[string]string{"mode": "daily"})
+
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[0] != 0x80|wsOpText || head[1]&0x80 != 0 {
+		t.Fatalf("expected unmasked final text frame, got % x", head)
+	}
+	payload := make([]byte, head[1]&0x7F)
+	io.ReadFull(br, payload)
+	var event HubEvent
+	if err := json.Unmarshal(payload, &event); err != nil || event.Type != EventEndpointMode {
+		t.Fatalf("unexpected event %s (%v)", payload, err)
+	}
+
+	// 客户端关闭帧（带掩码）应得到关闭回应并注销客户端
+	conn.Write([]byte{0x80 | wsOpClose, 0x80, 1, 2, 3, 4})
+	if _, err := io.ReadFull(br, head[:]); err != nil || head[0]&0x0F != wsOpClose {
+		t.Fatalf("expected close frame, got % x (%v)", head, err)
+	}
+	for i := 0; h.Clients() != 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := h.Clients(); n != 0 {
+		t.Errorf("expected client to be unregistered, %d remain", n)
+	}
+}
+
+func TestAdminEventsRejectsPlainRequest(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HandleAdminEvents(rec, httptest.NewRequest(http.MethodGet, "/admin/ws", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAdminEventsRejectsCrossOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		origin string
+		want   int
+	}{
+		{"https://evil.example", http.StatusForbidden},
+		{"http://panel.example:8045.evil", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://panel.example:8045/admin/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		HandleAdminEvents(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("origin %s: expected %d, got %d", tt.origin, tt.want, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://panel.example:8045/admin/ws", nil)
+	req.Header.Set("Origin", "http://Panel.example:8045")
+	if !sameOrigin(req) {
+		t.Error("same-origin request should be allowed")
+	}
+}
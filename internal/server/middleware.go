@@ -1,7 +1,10 @@
 package server
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -29,6 +32,21 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 设置写超时等
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack 实现 http.Hijacker 接口，支持 WebSocket 升级
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
 // RequestLogger 请求日志中间件
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,9 +59,12 @@ func RequestLogger(next http.Handler) http.Handler {
 
 		start := time.Now()
 		wrapper := &responseWriter{ResponseWriter: w, statusCode: 200}
+		ctx, reqLog := logger.WithRequestLog(r.Context())
 
-		next.ServeHTTP(wrapper, r)
+		next.ServeHTTP(wrapper, r.WithContext(ctx))
 
+		// LOG_GROUP_BY_REQUEST 开启时本请求的调试日志块在此一并输出
+		reqLog.Flush()
 		duration := time.Since(start)
 		logger.Request(r.Method, r.URL.Path, wrapper.statusCode, duration)
 	})
@@ -61,26 +82,7 @@ func RequireAPIKey(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		var providedKey string
-
-		// 1. Authorization header: Bearer sk-xxx 或直接 sk-xxx
-		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
-			providedKey = strings.TrimPrefix(authHeader, "Bearer ")
-		}
-		// 2. x-api-key header (Claude 标准)
-		if providedKey == "" {
-			providedKey = r.Header.Get("x-api-key")
-		}
-		// 3. x-goog-api-key header (Gemini 标准)
-		if providedKey == "" {
-			providedKey = r.Header.Get("x-goog-api-key")
-		}
-		// 4. Query 参数 ?key=
-		if providedKey == "" {
-			providedKey = r.URL.Query().Get("key")
-		}
-
-		if providedKey != apiKey {
+		if auth.GetAPIKey(r) != apiKey {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]interface{}{
@@ -97,6 +99,7 @@ func RequireAPIKey(next http.HandlerFunc) http.HandlerFunc {
 }
 
 // RequirePanelAuth 管理面板认证中间件
+// 接受登录会话或管理 API 令牌（ata_ 前缀）；只读令牌仅允许 GET 请求
 func RequirePanelAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := auth.GetSessionToken(r)
@@ -105,6 +108,24 @@ func RequirePanelAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(token, auth.APITokenPrefix) {
+			scope, ok := auth.GetAPITokenStore().Validate(token)
+			if !ok {
+				handleUnauthorized(w, r)
+				return
+			}
+			if scope == auth.ScopeRead && r.Method != http.MethodGet {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Token scope does not allow this operation",
+				})
+				return
+			}
+			next(w, r)
+			return
+		}
+
 		if !auth.ValidateSession(token) {
 			handleUnauthorized(w, r)
 			return
@@ -115,10 +136,12 @@ func RequirePanelAuth(next http.HandlerFunc) http.HandlerFunc {
 }
 
 func handleUnauthorized(w http.ResponseWriter, r *http.Request) {
-	// API 请求返回 JSON
+	// API 请求（含脚本使用令牌的请求）返回 JSON
 	if strings.HasPrefix(r.URL.Path, "/auth/") ||
 		strings.HasPrefix(r.URL.Path, "/admin/api/") ||
-		r.Header.Get("Accept") == "application/json" {
+		r.Header.Get("Accept") == "application/json" ||
+		r.Header.Get("X-Session-Token") != "" ||
+		r.Header.Get("Authorization") != "" {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -136,7 +159,8 @@ func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-Token, x-api-key, x-goog-api-key, anthropic-version")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-Token, x-api-key, x-goog-api-key, anthropic-version, anthropic-beta, X-Exclude-Accounts")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Usage-Prompt-Tokens, X-Usage-Completion-Tokens, X-Usage-Total-Tokens, X-RateLimit-Limit-Accounts, X-RateLimit-Remaining-Accounts, X-RateLimit-Reset-Accounts, anthropic-ratelimit-requests-limit, anthropic-ratelimit-requests-remaining, anthropic-ratelimit-requests-reset, Retry-After")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
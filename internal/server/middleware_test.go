@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/auth"
+	"anti2api-golang/internal/config"
+)
+
+// TestRequestLoggerWriteDeadline 经过 RequestLogger 包装后仍可通过 http.ResponseController 设置写超时，
+// 使写给不读取数据的客户端的阻塞调用能够及时返回
+func TestRequestLoggerWriteDeadline(t *testing.T) {
+	result := make(chan error, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+			result <- err
+			return
+		}
+		chunk := []byte(strings.Repeat("x", 64*1024))
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				result <- nil
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	})
+	srv := httptest.NewServer(RequestLogger(handler))
+	defer srv.Close()
+
+	// 客户端发送请求后不读取响应
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /v1/chat/completions HTTP/1.1\r\nHost: test\r\n\r\n"))
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("SetWriteDeadline through RequestLogger failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write to a non-reading client did not time out")
+	}
+}
+
+// TestRequirePanelAuthTokenScope 只读令牌仅允许 GET 请求
+func TestRequirePanelAuthTokenScope(t *testing.T) {
+	cfg := config.Get()
+	defer func(dir string) { cfg.DataDir = dir }(cfg.DataDir)
+	cfg.DataDir = t.TempDir()
+
+	store := auth.GetAPITokenStore()
+	readToken, readPlain, err := store.Create("reader", auth.ScopeRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Revoke(readToken.ID)
+	adminToken, adminPlain, err := store.Create("admin", auth.ScopeAdmin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Revoke(adminToken.ID)
+
+	handler := RequirePanelAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tests := []struct {
+		method, token string
+		want          int
+	}{
+		{http.MethodGet, readPlain, http.StatusNoContent},
+		{http.MethodPost, readPlain, http.StatusForbidden},
+		{http.MethodDelete, readPlain, http.StatusForbidden},
+		{http.MethodPost, adminPlain, http.StatusNoContent},
+		{http.MethodGet, auth.APITokenPrefix + "unknown", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/admin/settings", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s with %s: got %d, want %d", tt.method, tt.token[:len(auth.APITokenPrefix)+6], w.Code, tt.want)
+		}
+	}
+}
@@ -37,15 +37,37 @@ func SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /admin/login", handlers.HandleLoginPage)
 	mux.HandleFunc("POST /admin/login", handlers.HandleLogin)
 	mux.HandleFunc("POST /admin/logout", handlers.HandleLogout)
+	mux.HandleFunc("GET /admin/auth/providers", handlers.HandleAuthProviders)
+	mux.HandleFunc("GET /admin/auth/{provider}/login", handlers.HandleProviderLogin)
+	mux.HandleFunc("GET /admin/auth/{provider}/callback", handlers.HandleProviderCallback)
 
 	// ===== 管理面板 API（需要认证）=====
 	mux.HandleFunc("GET /admin/settings", RequirePanelAuth(handlers.HandleGetSettings))
+	mux.HandleFunc("PUT /admin/settings/debug", RequirePanelAuth(handlers.HandleSetDebugLevel))
 	mux.HandleFunc("GET /admin/endpoints", RequirePanelAuth(handlers.HandleGetEndpoints))
 	mux.HandleFunc("POST /admin/endpoints", RequirePanelAuth(handlers.HandleSetEndpoint))
 	mux.HandleFunc("POST /admin/endpoints/mode", RequirePanelAuth(handlers.HandleSetEndpointMode))
+	mux.HandleFunc("GET /admin/endpoints/schedule", RequirePanelAuth(handlers.HandleGetEndpointSchedule))
+	mux.HandleFunc("PUT /admin/endpoints/schedule", RequirePanelAuth(handlers.HandleSetEndpointSchedule))
+	mux.HandleFunc("POST /admin/endpoints/{key}/breaker", RequirePanelAuth(handlers.HandleSetEndpointBreaker))
+	mux.HandleFunc("GET /admin/schema-drift", RequirePanelAuth(handlers.HandleGetSchemaDrift))
+	mux.HandleFunc("POST /admin/debug/translate", RequirePanelAuth(handlers.HandleDebugTranslate))
+	mux.HandleFunc("GET /admin/debug/sse-capture", RequirePanelAuth(handlers.HandleGetSSECapture))
+	mux.HandleFunc("POST /admin/debug/sse-capture", RequirePanelAuth(handlers.HandleArmSSECapture))
+	mux.HandleFunc("GET /admin/debug/sse-capture/{name}", RequirePanelAuth(handlers.HandleDownloadSSECapture))
+	mux.HandleFunc("POST /admin/debug/sse-capture/{name}/replay", RequirePanelAuth(handlers.HandleReplaySSECapture))
+	mux.HandleFunc("GET /admin/routing", RequirePanelAuth(handlers.HandleGetRouting))
+	mux.HandleFunc("PUT /admin/routing", RequirePanelAuth(handlers.HandleSetRouting))
+	mux.HandleFunc("GET /admin/routing-config", RequirePanelAuth(handlers.HandleGetRoutingConfig))
+	mux.HandleFunc("PUT /admin/routing-config", RequirePanelAuth(handlers.HandleSetRoutingConfig))
+	mux.HandleFunc("GET /admin/tokens", RequirePanelAuth(handlers.HandleListAPITokens))
+	mux.HandleFunc("POST /admin/tokens", RequirePanelAuth(handlers.HandleCreateAPIToken))
+	mux.HandleFunc("DELETE /admin/tokens/{id}", RequirePanelAuth(handlers.HandleRevokeAPIToken))
 	mux.HandleFunc("GET /admin/logs", RequirePanelAuth(handlers.HandleGetLogs))
 	mux.HandleFunc("GET /admin/logs/usage", RequirePanelAuth(handlers.HandleGetLogsUsage))
 	mux.HandleFunc("GET /admin/logs/{id}", RequirePanelAuth(handlers.HandleGetLogDetail))
+	mux.HandleFunc("GET /admin/logs/{id}/transcript", RequirePanelAuth(handlers.HandleGetLogTranscript))
+	mux.HandleFunc("GET /admin/ws", RequirePanelAuth(HandleAdminEvents))
 
 	// ===== OAuth =====
 	mux.HandleFunc("GET /auth/oauth/url", RequirePanelAuth(handlers.HandleGetOAuthURL))
@@ -56,26 +78,59 @@ func SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /auth/accounts", RequirePanelAuth(handlers.HandleGetAccounts))
 	mux.HandleFunc("POST /auth/accounts/import-toml", RequirePanelAuth(handlers.HandleImportTOML))
 	mux.HandleFunc("POST /auth/accounts/refresh-all", RequirePanelAuth(handlers.HandleRefreshAllAccounts))
+	mux.HandleFunc("GET /auth/accounts/{index}", RequirePanelAuth(handlers.HandleGetAccount))
+	mux.HandleFunc("GET /auth/accounts/{index}/usage", RequirePanelAuth(handlers.HandleGetAccountUsage))
 	mux.HandleFunc("POST /auth/accounts/{index}/refresh", RequirePanelAuth(handlers.HandleRefreshAccount))
 	mux.HandleFunc("POST /auth/accounts/{index}/enable", RequirePanelAuth(handlers.HandleToggleAccount))
 	mux.HandleFunc("DELETE /auth/accounts/{index}", RequirePanelAuth(handlers.HandleDeleteAccount))
+	mux.HandleFunc("POST /auth/accounts/{index}/restore", RequirePanelAuth(handlers.HandleRestoreAccount))
+	mux.HandleFunc("DELETE /auth/accounts/{index}/purge", RequirePanelAuth(handlers.HandlePurgeAccount))
 
 	// ===== OpenAI 兼容 API =====
 	mux.HandleFunc("GET /v1/models", RequireAPIKey(handlers.HandleGetModels))
+	mux.HandleFunc("GET /v1/models/{id}", RequireAPIKey(handlers.HandleGetModel))
 	mux.HandleFunc("POST /v1/chat/completions", RequireAPIKey(handlers.HandleChatCompletions))
 	mux.HandleFunc("POST /v1/chat/completions/", RequireAPIKey(handlers.HandleChatCompletions))
 	mux.HandleFunc("POST /{credential}/v1/chat/completions", RequireAPIKey(handlers.HandleChatCompletionsWithCredential))
+	mux.HandleFunc("POST /v1/completions", RequireAPIKey(handlers.HandleCompletions))
+	mux.HandleFunc("POST /{credential}/v1/completions", RequireAPIKey(handlers.HandleCompletions))
+	mux.HandleFunc("POST /v1/images/generations", RequireAPIKey(handlers.HandleImageGenerations))
+	mux.HandleFunc("POST /{credential}/v1/images/generations", RequireAPIKey(handlers.HandleImageGenerations))
+	mux.HandleFunc("POST /v1/moderations", RequireAPIKey(handlers.HandleModerations))
+	mux.HandleFunc("POST /v1/files", RequireAPIKey(handlers.HandleUploadFile))
+	mux.HandleFunc("GET /v1/files", RequireAPIKey(handlers.HandleListFiles))
+	mux.HandleFunc("GET /v1/files/{id}", RequireAPIKey(handlers.HandleGetFile))
+	mux.HandleFunc("GET /v1/files/{id}/content", RequireAPIKey(handlers.HandleGetFileContent))
+	mux.HandleFunc("DELETE /v1/files/{id}", RequireAPIKey(handlers.HandleDeleteFile))
+	mux.HandleFunc("POST /v1/batches", RequireAPIKey(handlers.HandleCreateBatch))
+	mux.HandleFunc("GET /v1/batches", RequireAPIKey(handlers.HandleListBatches))
+	mux.HandleFunc("GET /v1/batches/{id}", RequireAPIKey(handlers.HandleGetBatch))
+	mux.HandleFunc("POST /v1/batches/{id}/cancel", RequireAPIKey(handlers.HandleCancelBatch))
 
 	// ===== Claude 兼容 API =====
 	mux.HandleFunc("POST /v1/messages", RequireAPIKey(handlers.HandleClaudeMessages))
 	mux.HandleFunc("POST /v1/messages/count_tokens", RequireAPIKey(handlers.HandleClaudeCountTokens))
+	mux.HandleFunc("POST /v1/messages/batches", RequireAPIKey(handlers.HandleCreateClaudeBatch))
+	mux.HandleFunc("GET /v1/messages/batches", RequireAPIKey(handlers.HandleListClaudeBatches))
+	mux.HandleFunc("GET /v1/messages/batches/{id}", RequireAPIKey(handlers.HandleGetClaudeBatch))
+	mux.HandleFunc("POST /v1/messages/batches/{id}/cancel", RequireAPIKey(handlers.HandleCancelClaudeBatch))
+	mux.HandleFunc("GET /v1/messages/batches/{id}/results", RequireAPIKey(handlers.HandleGetClaudeBatchResults))
+	mux.HandleFunc("POST /{credential}/v1/messages", RequireAPIKey(handlers.HandleClaudeMessagesWithCredential))
+	mux.HandleFunc("POST /{credential}/v1/messages/count_tokens", RequireAPIKey(handlers.HandleClaudeCountTokens))
 
 	// ===== Gemini 兼容 API =====
 	mux.HandleFunc("GET /v1beta/models", RequireAPIKey(handlers.HandleGeminiModels))
 	mux.HandleFunc("POST /v1beta/models/", RequireAPIKey(handlers.HandleGeminiAPI))
+	mux.HandleFunc("POST /{credential}/v1beta/models/", RequireAPIKey(handlers.HandleGeminiAPI))
+	mux.HandleFunc("POST /v1beta/tunedModels/", RequireAPIKey(handlers.HandleGeminiAPI))
+	mux.HandleFunc("POST /{credential}/v1beta/tunedModels/", RequireAPIKey(handlers.HandleGeminiAPI))
+	mux.HandleFunc("POST /v1beta/projects/", RequireAPIKey(handlers.HandleGeminiAPI))
+	mux.HandleFunc("POST /{credential}/v1beta/projects/", RequireAPIKey(handlers.HandleGeminiAPI))
 
 	// ===== 原始 Gemini 透传 =====
 	mux.HandleFunc("POST /gemini/v1beta/models/", RequireAPIKey(handlers.HandleRawGeminiAPI))
+	mux.HandleFunc("POST /gemini/v1beta/tunedModels/", RequireAPIKey(handlers.HandleRawGeminiAPI))
+	mux.HandleFunc("POST /gemini/v1beta/projects/", RequireAPIKey(handlers.HandleRawGeminiAPI))
 }
 
 // isStaticAsset 检查是否是静态资源
@@ -12,6 +12,7 @@ import (
 
 	"anti2api-golang/internal/config"
 	"anti2api-golang/internal/logger"
+	"anti2api-golang/internal/server/handlers"
 	"anti2api-golang/internal/store"
 )
 
@@ -51,6 +52,18 @@ func (s *Server) Start() error {
 	// 加载账号
 	store.GetAccountStore()
 
+	for _, warning := range s.config.Warnings() {
+		logger.Warn("%s", warning)
+	}
+
+	// 加载 A/B 路由规则，无效配置被忽略时提示
+	if err := config.GetRoutingManager().LoadError(); err != nil {
+		logger.Warn("Invalid routing rules ignored: %v", err)
+	}
+
+	// 续跑未完成的批处理
+	handlers.ResumeBatches()
+
 	// 打印启动横幅
 	logger.Banner(s.config.Port, s.config.EndpointMode)
 
@@ -0,0 +1,87 @@
+// Package signature 服务端思维签名缓存
+// 许多 OpenAI 客户端会丢弃 extra_content 等未知字段，导致 Gemini 3 工具调用回传时缺少 thoughtSignature
+// 在下发工具调用时按 tool_call id 记录签名，客户端回传时据此补齐
+package signature
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxEntries 缓存上限
+	maxEntries = 10000
+	// ttl 签名保留时长
+	ttl = 24 * time.Hour
+)
+
+type entry struct {
+	signature string
+	expiresAt time.Time
+}
+
+// Store 签名缓存（tool_call id → thoughtSignature）
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	order   []string
+	now     func() time.Time
+}
+
+var (
+	store     *Store
+	storeOnce sync.Once
+)
+
+// Get 获取全局签名缓存
+func Get() *Store {
+	storeOnce.Do(func() {
+		store = New()
+	})
+	return store
+}
+
+// New 创建签名缓存
+func New() *Store {
+	return &Store{
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// Put 记录工具调用的签名，id 或签名为空时忽略
+func (s *Store) Put(id, signature string) {
+	if id == "" || signature == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.entries[id] = entry{signature: signature, expiresAt: s.now().Add(ttl)}
+
+	for len(s.order) > maxEntries {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Lookup 查找工具调用的签名，未命中或已过期时返回空字符串
+func (s *Store) Lookup(id string) string {
+	if id == "" {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 过期条目留待容量淘汰时清理
+	e, ok := s.entries[id]
+	if !ok || s.now().After(e.expiresAt) {
+		return ""
+	}
+	return e.signature
+}
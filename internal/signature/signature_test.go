@@ -0,0 +1,42 @@
+package signature
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s := New()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Put("call_1", "sig_1")
+	s.Put("", "sig_ignored")
+	s.Put("call_2", "")
+
+	if got := s.Lookup("call_1"); got != "sig_1" {
+		t.Errorf("Lookup(call_1) = %q, want sig_1", got)
+	}
+	if got := s.Lookup("call_2"); got != "" {
+		t.Errorf("Lookup(call_2) = %q, want empty", got)
+	}
+
+	now = now.Add(ttl + time.Second)
+	if got := s.Lookup("call_1"); got != "" {
+		t.Errorf("expired Lookup(call_1) = %q, want empty", got)
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	s := New()
+	for i := 0; i <= maxEntries; i++ {
+		s.Put(fmt.Sprintf("call_%d", i), "sig")
+	}
+	if got := s.Lookup("call_0"); got != "" {
+		t.Errorf("oldest entry not evicted: %q", got)
+	}
+	if got := s.Lookup(fmt.Sprintf("call_%d", maxEntries)); got != "sig" {
+		t.Errorf("newest entry missing: %q", got)
+	}
+}
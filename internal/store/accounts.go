@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/i18n"
 	"anti2api-golang/internal/logger"
 	"anti2api-golang/internal/utils"
 )
@@ -23,7 +24,14 @@ type Account struct {
 	Email        string    `json:"email,omitempty"`
 	Enable       bool      `json:"enable"`
 	CreatedAt    time.Time `json:"created_at"`
-	SessionID    string    `json:"-"` // 运行时生成，不持久化
+	// ArchivedAt 归档时间：归档账号不参与选择，但保留 Token 与用量历史，可恢复或永久删除
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	SessionID  string     `json:"-"` // 运行时生成，不持久化
+
+	// 运行时状态，不持久化
+	LastRefreshAt    time.Time `json:"-"` // 最近一次刷新 Token 的时间
+	LastRefreshError string    `json:"-"` // 最近一次刷新失败的原因，成功时为空
+	CooldownUntil    time.Time `json:"-"` // 上游限流后的冷却截止时间
 }
 
 // AccountStore 账号存储
@@ -107,35 +115,155 @@ func (a *Account) IsExpired() bool {
 	return time.Now().UnixMilli() >= expiresAt-300000
 }
 
+// ExpiresAt 返回 Token 过期时间
+func (a *Account) ExpiresAt() time.Time {
+	return time.UnixMilli(a.Timestamp + int64(a.ExpiresIn*1000))
+}
+
+// Archived 账号是否已归档
+func (a *Account) Archived() bool {
+	return a.ArchivedAt != nil
+}
+
+// Selectable 账号是否可参与选择（启用且未归档）
+func (a *Account) Selectable() bool {
+	return a.Enable && !a.Archived()
+}
+
+// InCooldown 检查账号是否处于限流冷却中
+func (a *Account) InCooldown() bool {
+	return time.Now().Before(a.CooldownUntil)
+}
+
 // GetToken 获取可用 Token（轮询 + 自动刷新）
 func (s *AccountStore) GetToken() (*Account, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if len(s.accounts) == 0 {
-		return nil, errors.New("没有可用的账号")
+		return nil, i18n.New(i18n.NoAccounts)
+	}
+
+	account, availableAt := s.nextToken(func(*Account) bool { return true })
+	if account != nil {
+		return account, nil
+	}
+	if !availableAt.IsZero() {
+		return nil, &PoolExhaustedError{AvailableAt: availableAt}
 	}
+	return nil, i18n.New(i18n.NoAvailableToken)
+}
+
+// PoolExhaustedError 所有可用账号均处于限流冷却中；AvailableAt 为最早结束冷却的时间
+type PoolExhaustedError struct {
+	AvailableAt time.Time
+}
+
+func (e *PoolExhaustedError) Error() string {
+	return e.Message(i18n.DefaultLanguage())
+}
+
+// Message 返回指定语言的错误消息
+func (e *PoolExhaustedError) Message(lang string) string {
+	return i18n.Message(lang, i18n.PoolExhausted, e.AvailableAt.UTC().Format(time.RFC3339))
+}
+
+// nextToken 轮询选择满足条件的启用账号（内部方法，需要已持有锁）
+// 冷却中的账号会被跳过；没有可用账号时返回 nil 及冷却中账号最早恢复的时间（无冷却账号时为零值）
+func (s *AccountStore) nextToken(match func(*Account) bool) (*Account, time.Time) {
+	var availableAt time.Time
 
 	for attempts := 0; attempts < len(s.accounts); attempts++ {
 		account := &s.accounts[s.currentIndex]
 		s.currentIndex = (s.currentIndex + 1) % len(s.accounts)
 
-		if !account.Enable {
+		if !account.Selectable() || !match(account) {
 			continue
 		}
 
-		if account.IsExpired() {
-			if err := s.refreshToken(account); err != nil {
-				logger.Warn("Token refresh failed for %s: %v", account.Email, err)
-				continue
+		if account.InCooldown() {
+			if availableAt.IsZero() || account.CooldownUntil.Before(availableAt) {
+				availableAt = account.CooldownUntil
 			}
-			s.saveUnlocked()
+			continue
+		}
+
+		if s.ensureFresh(account) != nil {
+			continue
 		}
+		return account, time.Time{}
+	}
+	return nil, availableAt
+}
 
+// ensureFresh Token 过期时刷新并保存（内部方法，需要已持有锁）
+func (s *AccountStore) ensureFresh(account *Account) error {
+	if !account.IsExpired() {
+		return nil
+	}
+	if err := s.refreshToken(account); err != nil {
+		logger.Warn("Token refresh failed for %s: %v", account.Email, err)
+		return err
+	}
+	s.saveUnlocked()
+	return nil
+}
+
+// GetTokenFrom 在指定账号组（email 或 projectId）内轮询获取 Token
+func (s *AccountStore) GetTokenFrom(credentials []string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	allowed := credentialSet(credentials)
+
+	account, availableAt := s.nextToken(func(a *Account) bool {
+		return allowed[a.Email] || allowed[a.ProjectID]
+	})
+	if account != nil {
 		return account, nil
 	}
+	if !availableAt.IsZero() {
+		return nil, &PoolExhaustedError{AvailableAt: availableAt}
+	}
+	return nil, i18n.New(i18n.NoGroupToken)
+}
+
+// GetTokenExcept 轮询获取 Token，跳过 exclude 中的账号（email 或 projectId）
+// group 非空时仅在该账号组内选择
+func (s *AccountStore) GetTokenExcept(group []string, exclude []string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	allowed := credentialSet(group)
+	excluded := credentialSet(exclude)
 
-	return nil, errors.New("没有可用的 token")
+	account, availableAt := s.nextToken(func(a *Account) bool {
+		if excluded[a.Email] || excluded[a.ProjectID] {
+			return false
+		}
+		return len(allowed) == 0 || allowed[a.Email] || allowed[a.ProjectID]
+	})
+	if account != nil {
+		return account, nil
+	}
+	if !availableAt.IsZero() {
+		return nil, &PoolExhaustedError{AvailableAt: availableAt}
+	}
+	if len(allowed) > 0 {
+		return nil, i18n.New(i18n.NoGroupToken)
+	}
+	return nil, i18n.New(i18n.NoAvailableToken)
+}
+
+// credentialSet 将账号标识（email 或 projectId）列表转换为集合，忽略空值
+func credentialSet(credentials []string) map[string]bool {
+	set := make(map[string]bool, len(credentials))
+	for _, c := range credentials {
+		if c != "" {
+			set[c] = true
+		}
+	}
+	return set
 }
 
 // GetTokenByProjectID 按 ProjectID 获取指定 Token
@@ -145,7 +273,7 @@ func (s *AccountStore) GetTokenByProjectID(projectID string) (*Account, error) {
 
 	for i := range s.accounts {
 		account := &s.accounts[i]
-		if account.ProjectID == projectID && account.Enable {
+		if account.ProjectID == projectID && account.Selectable() {
 			if account.IsExpired() {
 				if err := s.refreshToken(account); err != nil {
 					return nil, err
@@ -156,7 +284,7 @@ func (s *AccountStore) GetTokenByProjectID(projectID string) (*Account, error) {
 		}
 	}
 
-	return nil, errors.New("未找到指定的账号")
+	return nil, i18n.New(i18n.AccountNotFound)
 }
 
 // GetTokenByEmail 按 Email 获取指定 Token
@@ -166,7 +294,7 @@ func (s *AccountStore) GetTokenByEmail(email string) (*Account, error) {
 
 	for i := range s.accounts {
 		account := &s.accounts[i]
-		if account.Email == email && account.Enable {
+		if account.Email == email && account.Selectable() {
 			if account.IsExpired() {
 				if err := s.refreshToken(account); err != nil {
 					return nil, err
@@ -177,14 +305,33 @@ func (s *AccountStore) GetTokenByEmail(email string) (*Account, error) {
 		}
 	}
 
-	return nil, errors.New("未找到指定的账号")
+	return nil, i18n.New(i18n.AccountNotFound)
 }
 
-// refreshToken 刷新 Token（内部方法，需要已持有锁）
+// refreshToken 刷新 Token 并记录结果（内部方法，需要已持有锁）
 func (s *AccountStore) refreshToken(account *Account) error {
 	// 这里调用 OAuth 刷新逻辑
 	// 实际实现在 auth/oauth.go 中
-	return refreshAccountToken(account)
+	err := refreshAccountToken(account)
+	account.LastRefreshAt = time.Now()
+	account.LastRefreshError = ""
+	if err != nil {
+		account.LastRefreshError = err.Error()
+	}
+	return err
+}
+
+// SetCooldown 设置账号的限流冷却截止时间（按 email / projectId 匹配）
+func (s *AccountStore) SetCooldown(email, projectID string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.accounts {
+		a := &s.accounts[i]
+		if a.Email == email && a.ProjectID == projectID {
+			a.CooldownUntil = until
+		}
+	}
 }
 
 // saveUnlocked 保存（内部方法，不加锁）
@@ -206,6 +353,17 @@ func (s *AccountStore) GetAll() []Account {
 	return result
 }
 
+// Get 按索引获取账号副本
+func (s *AccountStore) Get(index int) (Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if index < 0 || index >= len(s.accounts) {
+		return Account{}, i18n.New(i18n.IndexOutOfRange)
+	}
+	return s.accounts[index], nil
+}
+
 // Count 获取账号数量
 func (s *AccountStore) Count() int {
 	s.mu.RLock()
@@ -220,13 +378,33 @@ func (s *AccountStore) EnabledCount() int {
 
 	count := 0
 	for _, a := range s.accounts {
-		if a.Enable {
+		if a.Selectable() {
 			count++
 		}
 	}
 	return count
 }
 
+// PoolStatus 获取账号池状态：启用账号数、未冷却账号数，以及冷却中账号最早恢复的时间（无冷却账号时为零值）
+func (s *AccountStore) PoolStatus() (enabled, available int, resetAt time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.accounts {
+		a := &s.accounts[i]
+		if !a.Selectable() {
+			continue
+		}
+		enabled++
+		if !a.InCooldown() {
+			available++
+		} else if resetAt.IsZero() || a.CooldownUntil.Before(resetAt) {
+			resetAt = a.CooldownUntil
+		}
+	}
+	return enabled, available, resetAt
+}
+
 // Clear 清空所有账号
 func (s *AccountStore) Clear() error {
 	s.mu.Lock()
@@ -265,13 +443,43 @@ func (s *AccountStore) Add(account Account) error {
 	return s.saveUnlocked()
 }
 
-// Delete 删除账号
-func (s *AccountStore) Delete(index int) error {
+// Archive 归档账号（软删除）：不再参与选择，保留 Token 与用量历史
+func (s *AccountStore) Archive(index int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if index < 0 || index >= len(s.accounts) {
-		return errors.New("索引超出范围")
+		return i18n.New(i18n.IndexOutOfRange)
+	}
+	if !s.accounts[index].Archived() {
+		now := time.Now()
+		s.accounts[index].ArchivedAt = &now
+	}
+	return s.saveUnlocked()
+}
+
+// Restore 恢复已归档的账号
+func (s *AccountStore) Restore(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index < 0 || index >= len(s.accounts) {
+		return i18n.New(i18n.IndexOutOfRange)
+	}
+	s.accounts[index].ArchivedAt = nil
+	return s.saveUnlocked()
+}
+
+// Purge 永久删除账号；只能删除已归档的账号，避免误删仍有效的 Refresh Token
+func (s *AccountStore) Purge(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index < 0 || index >= len(s.accounts) {
+		return i18n.New(i18n.IndexOutOfRange)
+	}
+	if !s.accounts[index].Archived() {
+		return i18n.New(i18n.AccountNotArchived)
 	}
 
 	s.accounts = append(s.accounts[:index], s.accounts[index+1:]...)
@@ -290,7 +498,7 @@ func (s *AccountStore) SetEnable(index int, enable bool) error {
 	defer s.mu.Unlock()
 
 	if index < 0 || index >= len(s.accounts) {
-		return errors.New("索引超出范围")
+		return i18n.New(i18n.IndexOutOfRange)
 	}
 
 	s.accounts[index].Enable = enable
@@ -303,7 +511,7 @@ func (s *AccountStore) RefreshAccount(index int) error {
 	defer s.mu.Unlock()
 
 	if index < 0 || index >= len(s.accounts) {
-		return errors.New("索引超出范围")
+		return i18n.New(i18n.IndexOutOfRange)
 	}
 
 	if err := s.refreshToken(&s.accounts[index]); err != nil {
@@ -322,6 +530,10 @@ func (s *AccountStore) RefreshAll() (int, int) {
 	failed := 0
 
 	for i := range s.accounts {
+		// 归档账号不参与选择，无需刷新
+		if s.accounts[i].Archived() {
+			continue
+		}
 		if err := s.refreshToken(&s.accounts[i]); err != nil {
 			failed++
 			logger.Warn("Refresh failed for account %d: %v", i, err)
@@ -338,7 +550,7 @@ func (s *AccountStore) RefreshAll() (int, int) {
 func (s *AccountStore) ImportFromTOML(tomlData map[string]interface{}) (int, error) {
 	accounts, ok := tomlData["accounts"].([]map[string]interface{})
 	if !ok {
-		return 0, errors.New("无效的 TOML 格式")
+		return 0, i18n.New(i18n.InvalidTOML)
 	}
 
 	imported := 0
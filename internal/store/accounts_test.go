@@ -0,0 +1,55 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/i18n"
+)
+
+func TestAccountStoreArchive(t *testing.T) {
+	s := &AccountStore{filePath: filepath.Join(t.TempDir(), "accounts.json")}
+	now := time.Now().UnixMilli()
+	s.Add(Account{Email: "a@x.com", RefreshToken: "ra", Timestamp: now, ExpiresIn: 3600, Enable: true})
+	s.Add(Account{Email: "b@x.com", RefreshToken: "rb", Timestamp: now, ExpiresIn: 3600, Enable: true})
+
+	if err := s.Archive(0); err != nil {
+		t.Fatal(err)
+	}
+	// 归档账号不参与选择，也不计入启用账号
+	for i := 0; i < 3; i++ {
+		acc, err := s.GetToken()
+		if err != nil || acc.Email != "b@x.com" {
+			t.Fatalf("GetToken = %v, %v", acc, err)
+		}
+	}
+	if _, err := s.GetTokenByEmail("a@x.com"); err == nil {
+		t.Error("archived account selected by email")
+	}
+	if n := s.EnabledCount(); n != 1 {
+		t.Errorf("EnabledCount = %d, want 1", n)
+	}
+
+	// 未归档的账号不能永久删除
+	var coded *i18n.Error
+	if err := s.Purge(1); !errors.As(err, &coded) || coded.Code != i18n.AccountNotArchived {
+		t.Errorf("Purge(unarchived) = %v", err)
+	}
+
+	if err := s.Restore(0); err != nil {
+		t.Fatal(err)
+	}
+	if acc, err := s.GetTokenByEmail("a@x.com"); err != nil || acc.Archived() {
+		t.Errorf("restored account: %v, %v", acc, err)
+	}
+
+	s.Archive(1)
+	if err := s.Purge(1); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 1 {
+		t.Errorf("Count = %d, want 1", s.Count())
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+// TokenBudget 每日 token 预算计数（内存计数，本地时间零点重置）
+type TokenBudget struct {
+	mu     sync.Mutex
+	day    string
+	total  int
+	perKey map[string]int
+	now    func() time.Time
+}
+
+var (
+	tokenBudget     *TokenBudget
+	tokenBudgetOnce sync.Once
+)
+
+// GetTokenBudget 获取每日 token 预算单例
+func GetTokenBudget() *TokenBudget {
+	tokenBudgetOnce.Do(func() {
+		tokenBudget = NewTokenBudget()
+	})
+	return tokenBudget
+}
+
+// NewTokenBudget 创建预算计数器
+func NewTokenBudget() *TokenBudget {
+	return &TokenBudget{perKey: make(map[string]int), now: time.Now}
+}
+
+// rollover 跨天时清零计数（内部方法，需要已持有锁）
+func (b *TokenBudget) rollover() {
+	if day := b.now().Format("2006-01-02"); day != b.day {
+		b.day = day
+		b.total = 0
+		b.perKey = make(map[string]int)
+	}
+}
+
+// Charge 累计一次请求消耗的 token
+func (b *TokenBudget) Charge(key string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	b.total += tokens
+	b.perKey[key] += tokens
+}
+
+// Exceeded 检查全局或指定 Key 的预算是否已用尽，同时返回下次重置时间
+func (b *TokenBudget) Exceeded(key string) (bool, time.Time) {
+	cfg := config.Get()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	now := b.now()
+	resetAt := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	exceeded := (cfg.DailyTokenBudget > 0 && b.total >= cfg.DailyTokenBudget) ||
+		(cfg.DailyTokenBudgetPerKey > 0 && b.perKey[key] >= cfg.DailyTokenBudgetPerKey)
+	return exceeded, resetAt
+}
+
+// Usage 获取今日全局与指定 Key 已消耗的 token
+func (b *TokenBudget) Usage(key string) (total, keyTotal int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	return b.total, b.perKey[key]
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+func TestTokenBudget(t *testing.T) {
+	cfg := config.Get()
+	saved := *cfg
+	defer func() { *cfg = saved }()
+	cfg.DailyTokenBudget = 1000
+	cfg.DailyTokenBudgetPerKey = 300
+
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.Local)
+	b := NewTokenBudget()
+	b.now = func() time.Time { return now }
+
+	b.Charge("sk-a", 299)
+	if exceeded, _ := b.Exceeded("sk-a"); exceeded {
+		t.Fatal("exceeded below per-key budget")
+	}
+	b.Charge("sk-a", 1)
+	exceeded, resetAt := b.Exceeded("sk-a")
+	if !exceeded || !resetAt.Equal(time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("per-key: exceeded = %v, resetAt = %v", exceeded, resetAt)
+	}
+	if exceeded, _ := b.Exceeded("sk-b"); exceeded {
+		t.Error("other key should not be limited")
+	}
+
+	b.Charge("sk-b", 700)
+	if exceeded, _ := b.Exceeded("sk-c"); !exceeded {
+		t.Error("global budget not enforced")
+	}
+
+	// 跨天重置
+	now = now.Add(2 * time.Hour)
+	if exceeded, _ := b.Exceeded("sk-a"); exceeded {
+		t.Error("budget not reset on a new day")
+	}
+	if total, key := b.Usage("sk-a"); total != 0 || key != 0 {
+		t.Errorf("usage after reset = %d, %d", total, key)
+	}
+}
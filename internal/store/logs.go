@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"anti2api-golang/internal/config"
@@ -18,11 +20,18 @@ type LogEntry struct {
 	Success    bool        `json:"success"`
 	ProjectID  string      `json:"projectId"`
 	Email      string      `json:"email,omitempty"`
+	User       string      `json:"user,omitempty"` // 客户端传入的终端用户标识（OpenAI user 字段）
 	Model      string      `json:"model"`
+	Variant    string      `json:"variant,omitempty"` // A/B 路由命中的变体模型
 	Method     string      `json:"method"`
 	Path       string      `json:"path"`
 	DurationMs int64       `json:"durationMs"`
 	Message    string      `json:"message,omitempty"`
+	// 上游调用信息：结束原因、用量与尝试次数（含重试）
+	FinishReason string      `json:"finishReason,omitempty"`
+	Usage        *TokenUsage `json:"usage,omitempty"`
+	Attempts     int         `json:"attempts,omitempty"`
+	Endpoint     string      `json:"endpoint,omitempty"` // 实际使用的上游端点（daily/autopush/production）
 	HasDetail  bool        `json:"hasDetail"`
 	Detail     *LogDetail  `json:"detail,omitempty"`
 }
@@ -31,6 +40,59 @@ type LogEntry struct {
 type LogDetail struct {
 	Request  *RequestSnapshot  `json:"request,omitempty"`
 	Response *ResponseSnapshot `json:"response,omitempty"`
+	Mirror   *MirrorSnapshot   `json:"mirror,omitempty"`
+	// ToolArgRepairs 被修复的工具调用参数（保留上游原始参数）
+	ToolArgRepairs []ToolArgRepair `json:"toolArgRepairs,omitempty"`
+	// Attempts 每次上游尝试的结果（含重试）
+	Attempts []UpstreamAttempt `json:"attempts,omitempty"`
+	// UpstreamError 上游错误原始响应（仅管理员可见）
+	UpstreamError *UpstreamErrorSnapshot `json:"upstreamError,omitempty"`
+	// Trace 请求处理时间线（转换、上游连接、首字节、输出统计）
+	Trace *RequestTrace `json:"trace,omitempty"`
+}
+
+// RequestTrace 单次请求的处理时间线，时间均为相对请求开始的毫秒数
+type RequestTrace struct {
+	Events   []TraceEvent      `json:"events"`
+	Chunks   int               `json:"chunks,omitempty"` // 上游流式数据块（SSE 事件）数
+	Attempts []UpstreamAttempt `json:"attempts,omitempty"`
+	Emitter  *EmitterStats     `json:"emitter,omitempty"`
+}
+
+// TraceEvent 时间线上的一个阶段；DurationMs 为 0 表示时间点
+type TraceEvent struct {
+	Name       string `json:"name"`
+	AtMs       int64  `json:"atMs"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// EmitterStats 写给客户端的输出统计
+type EmitterStats struct {
+	Writes int   `json:"writes"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// UpstreamErrorSnapshot 上游错误响应快照
+type UpstreamErrorSnapshot struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// TokenUsage token 用量
+type TokenUsage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	ThoughtsTokens   int `json:"thoughtsTokens,omitempty"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// UpstreamAttempt 一次上游请求尝试
+type UpstreamAttempt struct {
+	Status     int    `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
 // RequestSnapshot 请求快照
@@ -46,6 +108,24 @@ type ResponseSnapshot struct {
 	ModelOutput string      `json:"modelOutput,omitempty"`
 }
 
+// MirrorSnapshot 镜像请求结果
+type MirrorSnapshot struct {
+	Endpoint    string `json:"endpoint"`
+	Model       string `json:"model"`
+	StatusCode  int    `json:"statusCode"`
+	DurationMs  int64  `json:"durationMs"`
+	ModelOutput string `json:"modelOutput,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ToolArgRepair 工具调用参数修复记录
+type ToolArgRepair struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Original map[string]interface{} `json:"original"`
+	Repaired map[string]interface{} `json:"repaired"`
+}
+
 // UsageStats 用量统计
 type UsageStats struct {
 	ProjectID   string     `json:"projectId"`
@@ -64,6 +144,8 @@ type LogStore struct {
 	filePath   string
 	maxLogs    int
 	usageCache map[string]*UsageStats // 按 email 或 projectId 缓存用量
+	version    atomic.Uint64          // 日志列表每次变化时递增，用于 ETag
+	rollups    *UsageRollups          // 持久化的按天用量汇总（可为 nil）
 }
 
 // getAccountKey 获取账号的唯一标识（优先 email，其次 projectId）
@@ -90,6 +172,7 @@ func GetLogStore() *LogStore {
 			filePath:   filepath.Join(cfg.DataDir, "logs.json"),
 			maxLogs:    1000, // 最多保存 1000 条日志
 			usageCache: make(map[string]*UsageStats),
+			rollups:    GetUsageRollups(),
 		}
 		logStore.Load()
 	})
@@ -123,6 +206,7 @@ func (s *LogStore) Load() error {
 
 	// 重建用量缓存
 	s.rebuildUsageCache()
+	s.version.Add(1)
 	return nil
 }
 
@@ -171,6 +255,10 @@ func (s *LogStore) Add(entry LogEntry) {
 
 	// 更新用量缓存
 	s.updateUsageCache(&entry)
+	if s.rollups != nil {
+		s.rollups.Record(&entry)
+	}
+	s.version.Add(1)
 
 	// 异步保存
 	go func() {
@@ -197,6 +285,30 @@ func (s *LogStore) GetAll(limit int) []LogEntry {
 	return result
 }
 
+// GetSince 获取 since 之后新增的日志（不含详情），最多 limit 条
+func (s *LogStore) GetSince(since time.Time, limit int) []LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// 并发请求的日志可能不严格按时间顺序插入，因此扫描全部而非遇到旧日志即停止
+	result := []LogEntry{}
+	for _, log := range s.logs {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if log.Timestamp.After(since) {
+			log.Detail = nil
+			result = append(result, log)
+		}
+	}
+	return result
+}
+
+// Version 返回日志列表版本号，列表变化（新增、清空、补充详情）时递增，读取无需加锁
+func (s *LogStore) Version() uint64 {
+	return s.version.Load()
+}
+
 // GetByID 按 ID 获取日志（含详情）
 func (s *LogStore) GetByID(id string) *LogEntry {
 	s.mu.RLock()
@@ -210,6 +322,43 @@ func (s *LogStore) GetByID(id string) *LogEntry {
 	return nil
 }
 
+// SetMirror 为已记录的日志附加镜像请求结果
+func (s *LogStore) SetMirror(id string, mirror *MirrorSnapshot) {
+	s.updateDetail(id, func(detail *LogDetail) {
+		detail.Mirror = mirror
+	})
+}
+
+// SetToolArgRepairs 为已记录的日志附加工具参数修复记录
+func (s *LogStore) SetToolArgRepairs(id string, repairs []ToolArgRepair) {
+	s.updateDetail(id, func(detail *LogDetail) {
+		detail.ToolArgRepairs = repairs
+	})
+}
+
+// updateDetail 修改已记录日志的详情
+func (s *LogStore) updateDetail(id string, update func(detail *LogDetail)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.logs {
+		if s.logs[i].ID == id {
+			// 复制详情，避免修改已被读取方持有的指针
+			var detail LogDetail
+			if s.logs[i].Detail != nil {
+				detail = *s.logs[i].Detail
+			}
+			update(&detail)
+			s.logs[i].Detail = &detail
+			if !s.logs[i].HasDetail {
+				s.logs[i].HasDetail = true
+				s.version.Add(1)
+			}
+			return
+		}
+	}
+}
+
 // GetUsageStats 获取用量统计
 func (s *LogStore) GetUsageStats(windowMinutes int) []UsageStats {
 	s.mu.RLock()
@@ -281,6 +430,86 @@ func (s *LogStore) GetAccountUsage(projectID string) *UsageStats {
 	return nil
 }
 
+// ModelUsage 单个模型的用量统计
+type ModelUsage struct {
+	Model            string     `json:"model"`
+	Count            int        `json:"count"`
+	Success          int        `json:"success"`
+	Failed           int        `json:"failed"`
+	PromptTokens     int        `json:"promptTokens"`
+	CompletionTokens int        `json:"completionTokens"`
+	TotalTokens      int        `json:"totalTokens"`
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// GetAccountModelUsage 按模型统计指定账号的用量（按调用次数倒序）
+func (s *LogStore) GetAccountModelUsage(email, projectID string) []ModelUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := getAccountKey(email, projectID)
+	byModel := make(map[string]*ModelUsage)
+	for _, log := range s.logs {
+		if getAccountKey(log.Email, log.ProjectID) != key || log.Model == "" {
+			continue
+		}
+
+		usage, ok := byModel[log.Model]
+		if !ok {
+			usage = &ModelUsage{Model: log.Model}
+			byModel[log.Model] = usage
+		}
+
+		usage.Count++
+		if log.Success {
+			usage.Success++
+		} else {
+			usage.Failed++
+		}
+		if log.Usage != nil {
+			usage.PromptTokens += log.Usage.PromptTokens
+			usage.CompletionTokens += log.Usage.CompletionTokens
+			usage.TotalTokens += log.Usage.TotalTokens
+		}
+		if usage.LastUsedAt == nil || log.Timestamp.After(*usage.LastUsedAt) {
+			t := log.Timestamp
+			usage.LastUsedAt = &t
+		}
+	}
+
+	result := make([]ModelUsage, 0, len(byModel))
+	for _, usage := range byModel {
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// GetAccountErrors 获取指定账号最近的失败日志（不含详情）
+func (s *LogStore) GetAccountErrors(email, projectID string, limit int) []LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := getAccountKey(email, projectID)
+	result := make([]LogEntry, 0)
+	for _, log := range s.logs {
+		if len(result) >= limit {
+			break
+		}
+		if log.Success || getAccountKey(log.Email, log.ProjectID) != key {
+			continue
+		}
+		log.Detail = nil
+		result = append(result, log)
+	}
+	return result
+}
+
 // GetAllAccountsUsage 获取所有账号的用量
 func (s *LogStore) GetAllAccountsUsage() map[string]*UsageStats {
 	s.mu.RLock()
@@ -391,5 +620,6 @@ func (s *LogStore) Clear() error {
 
 	s.logs = []LogEntry{}
 	s.usageCache = make(map[string]*UsageStats)
+	s.version.Add(1)
 	return s.saveUnlocked()
 }
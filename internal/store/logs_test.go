@@ -0,0 +1,33 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogStoreGetSince(t *testing.T) {
+	s := &LogStore{
+		filePath:   filepath.Join(t.TempDir(), "logs.json"),
+		maxLogs:    10,
+		usageCache: make(map[string]*UsageStats),
+	}
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	v0 := s.Version()
+	s.Add(LogEntry{ID: "a", Timestamp: base})
+	s.Add(LogEntry{ID: "c", Timestamp: base.Add(2 * time.Second), Detail: &LogDetail{}})
+	// 并发请求的日志可能晚于更新的日志插入
+	s.Add(LogEntry{ID: "b", Timestamp: base.Add(time.Second)})
+	if s.Version() != v0+3 {
+		t.Errorf("version = %d, want %d", s.Version(), v0+3)
+	}
+
+	logs := s.GetSince(base, 0)
+	if len(logs) != 2 || logs[0].ID != "b" || logs[1].ID != "c" || logs[1].Detail != nil {
+		t.Errorf("logs = %+v", logs)
+	}
+	if logs := s.GetSince(base, 1); len(logs) != 1 {
+		t.Errorf("limit: got %d logs", len(logs))
+	}
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+)
+
+// RollupRetentionDays 用量汇总保留天数
+const RollupRetentionDays = 90
+
+// UsageRollup 按天、账号、模型汇总的用量（持久化，不受日志条数上限影响）
+type UsageRollup struct {
+	Day              string         `json:"day"` // 本地日期 2006-01-02
+	Account          string         `json:"account"`
+	Model            string         `json:"model"`
+	Count            int            `json:"count"`
+	Success          int            `json:"success"`
+	Failed           int            `json:"failed"`
+	PromptTokens     int            `json:"promptTokens"`
+	CompletionTokens int            `json:"completionTokens"`
+	TotalTokens      int            `json:"totalTokens"`
+	Errors           map[string]int `json:"errors,omitempty"` // 错误类别 → 次数
+}
+
+// AccountUsageReport 单个账号在一段时间内的用量汇总
+type AccountUsageReport struct {
+	Since  string            `json:"since"`
+	Totals AccountUsageTotal `json:"totals"`
+	Models []ModelRollup     `json:"models"`
+	Errors map[string]int    `json:"errors"`
+	Daily  []DailyRollup     `json:"daily"`
+}
+
+// AccountUsageTotal 用量合计
+type AccountUsageTotal struct {
+	Count            int `json:"count"`
+	Success          int `json:"success"`
+	Failed           int `json:"failed"`
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// ModelRollup 单个模型的用量与错误类别
+type ModelRollup struct {
+	Model string `json:"model"`
+	AccountUsageTotal
+	Errors map[string]int `json:"errors,omitempty"`
+}
+
+// DailyRollup 单日用量
+type DailyRollup struct {
+	Day         string `json:"day"`
+	Count       int    `json:"count"`
+	Failed      int    `json:"failed"`
+	TotalTokens int    `json:"totalTokens"`
+}
+
+type rollupKey struct {
+	day, account, model string
+}
+
+// UsageRollups 用量汇总存储：每条日志写入时累加，保存在 data/usage_rollups.json
+type UsageRollups struct {
+	mu       sync.Mutex
+	filePath string
+	rollups  map[rollupKey]*UsageRollup
+	now      func() time.Time
+}
+
+var (
+	usageRollups     *UsageRollups
+	usageRollupsOnce sync.Once
+)
+
+// GetUsageRollups 获取用量汇总存储单例
+func GetUsageRollups() *UsageRollups {
+	usageRollupsOnce.Do(func() {
+		usageRollups = NewUsageRollups(filepath.Join(config.Get().DataDir, "usage_rollups.json"))
+		usageRollups.load()
+	})
+	return usageRollups
+}
+
+// NewUsageRollups 创建用量汇总存储（filePath 为空时不持久化）
+func NewUsageRollups(filePath string) *UsageRollups {
+	return &UsageRollups{filePath: filePath, rollups: make(map[rollupKey]*UsageRollup), now: time.Now}
+}
+
+func (u *UsageRollups) load() {
+	data, err := os.ReadFile(u.filePath)
+	if err != nil {
+		return
+	}
+	var list []*UsageRollup
+	if json.Unmarshal(data, &list) != nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, r := range list {
+		u.rollups[rollupKey{r.Day, r.Account, r.Model}] = r
+	}
+}
+
+// saveLocked 清理过期汇总后写入文件（需要已持有锁）
+func (u *UsageRollups) saveLocked() error {
+	if u.filePath == "" {
+		return nil
+	}
+	cutoff := u.now().AddDate(0, 0, -RollupRetentionDays).Format("2006-01-02")
+	list := make([]*UsageRollup, 0, len(u.rollups))
+	for key, r := range u.rollups {
+		if r.Day < cutoff {
+			delete(u.rollups, key)
+			continue
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Day != list[j].Day {
+			return list[i].Day < list[j].Day
+		}
+		if list[i].Account != list[j].Account {
+			return list[i].Account < list[j].Account
+		}
+		return list[i].Model < list[j].Model
+	})
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(u.filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(u.filePath, data, 0644)
+}
+
+// Record 将一条日志计入汇总并异步保存
+func (u *UsageRollups) Record(entry *LogEntry) {
+	if entry.Model == "" {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := rollupKey{entry.Timestamp.Local().Format("2006-01-02"), getAccountKey(entry.Email, entry.ProjectID), entry.Model}
+	r, ok := u.rollups[key]
+	if !ok {
+		r = &UsageRollup{Day: key.day, Account: key.account, Model: key.model}
+		u.rollups[key] = r
+	}
+	r.Count++
+	if entry.Success {
+		r.Success++
+	} else {
+		r.Failed++
+		if r.Errors == nil {
+			r.Errors = make(map[string]int)
+		}
+		r.Errors[ErrorCategory(entry.Status, entry.Message)]++
+	}
+	if entry.Usage != nil {
+		r.PromptTokens += entry.Usage.PromptTokens
+		r.CompletionTokens += entry.Usage.CompletionTokens
+		r.TotalTokens += entry.Usage.TotalTokens
+	}
+
+	go func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.saveLocked()
+	}()
+}
+
+// ErrorCategory 按状态码与错误信息归类失败请求
+func ErrorCategory(status int, message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case status == 429:
+		return "rate_limited"
+	case status == 401 || status == 403:
+		return "auth"
+	case status == 504 || strings.Contains(lower, "timeout") || strings.Contains(lower, "deadline exceeded"):
+		return "timeout"
+	case status >= 500:
+		return "upstream_error"
+	case status >= 400:
+		return "invalid_request"
+	default:
+		return "other"
+	}
+}
+
+// AccountReport 汇总指定账号最近 days 天（含今天）的用量
+func (u *UsageRollups) AccountReport(email, projectID string, days int) *AccountUsageReport {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	account := getAccountKey(email, projectID)
+	since := u.now().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	report := &AccountUsageReport{Since: since, Models: []ModelRollup{}, Errors: map[string]int{}, Daily: []DailyRollup{}}
+	models := make(map[string]*ModelRollup)
+	daily := make(map[string]*DailyRollup)
+
+	for _, r := range u.rollups {
+		if r.Account != account || r.Day < since {
+			continue
+		}
+		m, ok := models[r.Model]
+		if !ok {
+			m = &ModelRollup{Model: r.Model}
+			models[r.Model] = m
+		}
+		d, ok := daily[r.Day]
+		if !ok {
+			d = &DailyRollup{Day: r.Day}
+			daily[r.Day] = d
+		}
+
+		for _, total := range []*AccountUsageTotal{&report.Totals, &m.AccountUsageTotal} {
+			total.Count += r.Count
+			total.Success += r.Success
+			total.Failed += r.Failed
+			total.PromptTokens += r.PromptTokens
+			total.CompletionTokens += r.CompletionTokens
+			total.TotalTokens += r.TotalTokens
+		}
+		d.Count += r.Count
+		d.Failed += r.Failed
+		d.TotalTokens += r.TotalTokens
+		for category, n := range r.Errors {
+			if m.Errors == nil {
+				m.Errors = make(map[string]int)
+			}
+			m.Errors[category] += n
+			report.Errors[category] += n
+		}
+	}
+
+	for _, m := range models {
+		report.Models = append(report.Models, *m)
+	}
+	sort.Slice(report.Models, func(i, j int) bool {
+		if report.Models[i].Count != report.Models[j].Count {
+			return report.Models[i].Count > report.Models[j].Count
+		}
+		return report.Models[i].Model < report.Models[j].Model
+	})
+	for _, d := range daily {
+		report.Daily = append(report.Daily, *d)
+	}
+	sort.Slice(report.Daily, func(i, j int) bool { return report.Daily[i].Day < report.Daily[j].Day })
+	return report
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageRollupsAccountReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage_rollups.json")
+	u := NewUsageRollups("") // 记录时不触发异步保存
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+	u.now = func() time.Time { return now }
+
+	usage := &TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	u.Record(&LogEntry{Timestamp: now, Email: "a@x.com", Model: "m1", Success: true, Usage: usage})
+	u.Record(&LogEntry{Timestamp: now.AddDate(0, 0, -1), Email: "a@x.com", Model: "m1", Success: true, Usage: usage})
+	u.Record(&LogEntry{Timestamp: now, Email: "a@x.com", Model: "m2", Status: 429, Message: "quota"})
+	u.Record(&LogEntry{Timestamp: now, Email: "a@x.com", Model: "m2", Status: 500, Message: "context deadline exceeded"})
+	// 窗口外与其他账号的记录不计入
+	u.Record(&LogEntry{Timestamp: now.AddDate(0, 0, -10), Email: "a@x.com", Model: "m1", Success: true})
+	u.Record(&LogEntry{Timestamp: now, Email: "b@x.com", Model: "m1", Success: true})
+
+	report := u.AccountReport("a@x.com", "", 7)
+	if report.Since != "2025-03-04" {
+		t.Errorf("since = %s", report.Since)
+	}
+	if report.Totals.Count != 4 || report.Totals.Failed != 2 || report.Totals.TotalTokens != 30 {
+		t.Errorf("totals = %+v", report.Totals)
+	}
+	if len(report.Models) != 2 || report.Models[0].Model != "m1" || report.Models[0].Count != 2 {
+		t.Errorf("models = %+v", report.Models)
+	}
+	if report.Errors["rate_limited"] != 1 || report.Errors["timeout"] != 1 {
+		t.Errorf("errors = %v", report.Errors)
+	}
+	if len(report.Daily) != 2 || report.Daily[1].Day != "2025-03-10" || report.Daily[1].Count != 3 {
+		t.Errorf("daily = %+v", report.Daily)
+	}
+
+	// 持久化后重新加载：使用不经 Record 填充的独立实例，避免与 u 的异步保存竞争
+	saved := NewUsageRollups(path)
+	saved.now = u.now
+	u.mu.Lock()
+	for key, r := range u.rollups {
+		copied := *r
+		saved.rollups[key] = &copied
+	}
+	u.mu.Unlock()
+	if err := saved.saveLocked(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewUsageRollups(path)
+	reloaded.now = u.now
+	reloaded.load()
+	if got := reloaded.AccountReport("a@x.com", "", 30).Totals.Count; got != 5 {
+		t.Errorf("reloaded count = %d, want 5", got)
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+	cases := []struct {
+		status  int
+		message string
+		want    string
+	}{
+		{429, "", "rate_limited"},
+		{403, "", "auth"},
+		{504, "", "timeout"},
+		{0, "request timeout", "timeout"},
+		{503, "", "upstream_error"},
+		{400, "", "invalid_request"},
+		{0, "", "other"},
+	}
+	for _, c := range cases {
+		if got := ErrorCategory(c.status, c.message); got != c.want {
+			t.Errorf("ErrorCategory(%d, %q) = %s, want %s", c.status, c.message, got, c.want)
+		}
+	}
+}
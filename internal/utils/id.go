@@ -52,6 +52,31 @@ func GenerateChatCompletionID() string {
 	return fmt.Sprintf("chatcmpl-%s", uuid.New().String()[:8])
 }
 
+// GenerateCompletionID 生成文本补全 ID
+func GenerateCompletionID() string {
+	return fmt.Sprintf("cmpl-%s", uuid.New().String()[:8])
+}
+
+// GenerateModerationID 生成内容审核 ID
+func GenerateModerationID() string {
+	return "modr-" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// GenerateMessageBatchID 生成 Claude 消息批处理 ID
+func GenerateMessageBatchID() string {
+	return "msgbatch_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// GenerateBatchID 生成 OpenAI 批处理 ID
+func GenerateBatchID() string {
+	return "batch_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// GenerateFileID 生成 OpenAI 文件 ID
+func GenerateFileID() string {
+	return "file-" + randomAlphanumeric(24)
+}
+
 // 辅助函数
 
 func randInt(max int) int {
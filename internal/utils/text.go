@@ -0,0 +1,23 @@
+package utils
+
+import "unicode/utf8"
+
+// SplitUTF8 按最大字节数切分字符串，不拆分 UTF-8 字符（用于流式下发较长的参数片段）
+func SplitUTF8(s string, size int) []string {
+	var fragments []string
+	for len(s) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = size
+		}
+		fragments = append(fragments, s[:cut])
+		s = s[cut:]
+	}
+	if s != "" {
+		fragments = append(fragments, s)
+	}
+	return fragments
+}
@@ -9,7 +9,6 @@ import (
 func ParseTOML(input string) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	var currentArrayName string
-	var currentArray []map[string]interface{}
 	var currentObj map[string]interface{}
 
 	lines := strings.Split(input, "\n")
@@ -26,12 +25,7 @@ func ParseTOML(input string) (map[string]interface{}, error) {
 
 			// 保存之前的对象
 			if currentObj != nil && currentArrayName != "" {
-				if result[currentArrayName] == nil {
-					result[currentArrayName] = []map[string]interface{}{}
-				}
-				currentArray = result[currentArrayName].([]map[string]interface{})
-				currentArray = append(currentArray, currentObj)
-				result[currentArrayName] = currentArray
+				appendTable(result, currentArrayName, currentObj)
 			}
 
 			currentArrayName = section
@@ -43,12 +37,7 @@ func ParseTOML(input string) (map[string]interface{}, error) {
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
 			// 保存之前的对象
 			if currentObj != nil && currentArrayName != "" {
-				if result[currentArrayName] == nil {
-					result[currentArrayName] = []map[string]interface{}{}
-				}
-				currentArray = result[currentArrayName].([]map[string]interface{})
-				currentArray = append(currentArray, currentObj)
-				result[currentArrayName] = currentArray
+				appendTable(result, currentArrayName, currentObj)
 			}
 			currentArrayName = ""
 			currentObj = nil
@@ -70,17 +59,19 @@ func ParseTOML(input string) (map[string]interface{}, error) {
 
 	// 保存最后一个对象
 	if currentObj != nil && currentArrayName != "" {
-		if result[currentArrayName] == nil {
-			result[currentArrayName] = []map[string]interface{}{}
-		}
-		currentArray = result[currentArrayName].([]map[string]interface{})
-		currentArray = append(currentArray, currentObj)
-		result[currentArrayName] = currentArray
+		appendTable(result, currentArrayName, currentObj)
 	}
 
 	return result, nil
 }
 
+// appendTable 将 [[table]] 对象追加到同名数组
+// 同名键已被普通值占用时以数组覆盖，避免类型断言 panic
+func appendTable(result map[string]interface{}, name string, obj map[string]interface{}) {
+	tables, _ := result[name].([]map[string]interface{})
+	result[name] = append(tables, obj)
+}
+
 func stripInlineComment(line string) string {
 	// 查找不在引号内的 # 号
 	inQuote := false
@@ -99,12 +90,12 @@ func parseValue(raw string) interface{} {
 	raw = strings.TrimSpace(raw)
 
 	// 字符串 "..."
-	if strings.HasPrefix(raw, `"`) && strings.HasSuffix(raw, `"`) {
+	if len(raw) >= 2 && strings.HasPrefix(raw, `"`) && strings.HasSuffix(raw, `"`) {
 		return raw[1 : len(raw)-1]
 	}
 
 	// 字符串 '...'
-	if strings.HasPrefix(raw, `'`) && strings.HasSuffix(raw, `'`) {
+	if len(raw) >= 2 && strings.HasPrefix(raw, `'`) && strings.HasSuffix(raw, `'`) {
 		return raw[1 : len(raw)-1]
 	}
 
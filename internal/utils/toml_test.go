@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	input := `
+name = "root" # 注释
+[[accounts]]
+email = "a@example.com"
+enable = true
+expires_in = 3599
+tags = ["x", 'y', 1]
+
+[[accounts]]
+email = "b#c@example.com"
+`
+	result, err := ParseTOML(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["name"] != "root" {
+		t.Errorf("Expected name 'root', got %v", result["name"])
+	}
+
+	accounts, ok := result["accounts"].([]map[string]interface{})
+	if !ok || len(accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %v", result["accounts"])
+	}
+	if accounts[0]["enable"] != true || accounts[0]["expires_in"] != int64(3599) {
+		t.Errorf("Unexpected first account: %v", accounts[0])
+	}
+	if tags, _ := accounts[0]["tags"].([]interface{}); len(tags) != 3 {
+		t.Errorf("Expected 3 tags, got %v", accounts[0]["tags"])
+	}
+	if accounts[1]["email"] != "b#c@example.com" {
+		t.Errorf("Expected quoted '#' to be kept, got %v", accounts[1]["email"])
+	}
+}
+
+func TestParseTOMLMalformed(t *testing.T) {
+	inputs := []string{
+		`key = "`,
+		`key = '`,
+		"accounts = 1\n[[accounts]]\nemail = \"a\"",
+		"[[accounts]]\n[[accounts]]\n[x]\n[[accounts]]",
+	}
+	for _, input := range inputs {
+		if _, err := ParseTOML(input); err != nil {
+			t.Errorf("ParseTOML(%q) returned error: %v", input, err)
+		}
+	}
+}
+
+func FuzzParseTOML(f *testing.F) {
+	f.Add("[[accounts]]\nemail = \"a@example.com\"\nenable = true\n")
+	f.Add("a = [1, 2.5, \"x\", 'y']\n# comment\n[table]\nb = false")
+	f.Add("accounts = 1\n[[accounts]]\nx = \"")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		if _, err := ParseTOML(input); err != nil {
+			t.Errorf("ParseTOML returned error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package vertex
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
+)
+
+const captureExt = ".sse"
+
+// ErrCaptureNotFound 抓取文件不存在或名称非法
+var ErrCaptureNotFound = errors.New("capture not found")
+
+// SSECapture 上游原始 SSE 字节流抓取
+// 由管理员按次数布防，命中的流式请求将原样写入数据目录，用于离线复现发射器问题
+type SSECapture struct {
+	mu        sync.Mutex
+	dir       string
+	remaining int
+	model     string
+}
+
+// CaptureStatus 抓取布防状态
+type CaptureStatus struct {
+	Remaining int    `json:"remaining"`
+	Model     string `json:"model,omitempty"`
+}
+
+// CaptureFile 已保存的抓取文件
+type CaptureFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var (
+	sseCapture     *SSECapture
+	sseCaptureOnce sync.Once
+)
+
+// GetSSECapture 获取 SSE 抓取器单例
+func GetSSECapture() *SSECapture {
+	sseCaptureOnce.Do(func() {
+		sseCapture = &SSECapture{dir: filepath.Join(config.Get().DataDir, "captures")}
+	})
+	return sseCapture
+}
+
+// Arm 布防：接下来 count 个流式请求（model 非空时仅匹配该上游模型）将被抓取，count <= 0 表示撤防
+func (c *SSECapture) Arm(count int, model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if count <= 0 {
+		c.remaining, c.model = 0, ""
+		return
+	}
+	c.remaining, c.model = count, model
+}
+
+// Status 返回当前布防状态
+func (c *SSECapture) Status() CaptureStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CaptureStatus{Remaining: c.remaining, Model: c.model}
+}
+
+// take 判断本次请求是否需要抓取，命中时消耗一次计数
+func (c *SSECapture) take(model string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.remaining <= 0 || (c.model != "" && c.model != model) {
+		return false
+	}
+	c.remaining--
+	return true
+}
+
+// wrap 命中布防时将响应体替换为边读边落盘的读取器
+func (c *SSECapture) wrap(resp *http.Response, model string) {
+	if !c.take(model) {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		logger.Warn("SSE capture: failed to create dir: %v", err)
+		return
+	}
+
+	name := time.Now().Format("20060102-150405.000") + "-" + sanitizeCaptureName(model) + captureExt
+	f, err := os.Create(filepath.Join(c.dir, name))
+	if err != nil {
+		logger.Warn("SSE capture: failed to create file: %v", err)
+		return
+	}
+	resp.Body = &captureBody{ReadCloser: resp.Body, file: f}
+	logger.Info("SSE capture: recording upstream stream to %s", name)
+}
+
+// List 列出已保存的抓取文件（新的在前）
+func (c *SSECapture) List() ([]CaptureFile, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []CaptureFile{}, nil
+		}
+		return nil, err
+	}
+
+	files := make([]CaptureFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), captureExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, CaptureFile{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+// Open 打开指定抓取文件，名称仅允许为抓取目录下的文件名
+func (c *SSECapture) Open(name string) (*os.File, error) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, captureExt) {
+		return nil, ErrCaptureNotFound
+	}
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if os.IsNotExist(err) {
+		return nil, ErrCaptureNotFound
+	}
+	return f, err
+}
+
+// sanitizeCaptureName 将模型名转换为可用作文件名的片段
+func sanitizeCaptureName(model string) string {
+	if model == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, model)
+}
+
+// captureBody 读取上游响应体的同时原样写入抓取文件
+type captureBody struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.file.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	b.file.Close()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,56 @@
+package vertex
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSSECapture(t *testing.T) {
+	c := &SSECapture{dir: t.TempDir()}
+	stream := "data: {\"response\":{}}\n\n"
+
+	newResp := func() *http.Response {
+		return &http.Response{Body: io.NopCloser(strings.NewReader(stream))}
+	}
+
+	// 未布防时不抓取
+	resp := newResp()
+	c.wrap(resp, "gemini-3-pro")
+	if _, ok := resp.Body.(*captureBody); ok {
+		t.Fatal("captured while disarmed")
+	}
+
+	c.Arm(1, "gemini-3-pro")
+	resp = newResp()
+	c.wrap(resp, "gemini-2.5-flash")
+	if _, ok := resp.Body.(*captureBody); ok {
+		t.Fatal("captured non-matching model")
+	}
+
+	resp = newResp()
+	c.wrap(resp, "gemini-3-pro")
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if c.Status().Remaining != 0 {
+		t.Errorf("remaining = %d, want 0", c.Status().Remaining)
+	}
+
+	files, err := c.List()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("List() = %v, %v", files, err)
+	}
+	f, err := c.Open(files[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, _ := io.ReadAll(f); string(got) != stream {
+		t.Errorf("captured %q, want %q", got, stream)
+	}
+
+	if _, err := c.Open("../" + files[0].Name); err != ErrCaptureNotFound {
+		t.Errorf("path traversal: err = %v", err)
+	}
+}
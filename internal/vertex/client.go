@@ -32,6 +32,10 @@ type APIError struct {
 	Message      string
 	RetryDelay   time.Duration
 	DisableToken bool
+
+	// 上游原始响应（仅记录到管理日志，不返回给客户端）
+	Headers http.Header
+	Body    string
 }
 
 func (e *APIError) Error() string {
@@ -86,21 +90,28 @@ func (c *Client) BuildStreamHeaders(token *store.Account, endpoint config.Endpoi
 		"User-Agent":    {c.config.UserAgent},
 		"Authorization": {"Bearer " + token.AccessToken},
 		"Content-Type":  {"application/json"},
-		// 不设置 Accept-Encoding: gzip，避免上游服务器缓冲压缩数据导致流式输出不平滑
+		// 显式声明 identity：未设置 Accept-Encoding 时 http.Transport 会隐式请求 gzip，
+		// 上游缓冲压缩数据会导致流式输出不平滑
+		"Accept-Encoding": {"identity"},
 	}
 }
 
 // SendRequest 发送非流式请求
 func (c *Client) SendRequest(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error) {
-	endpoint := config.GetEndpointManager().GetActiveEndpoint()
+	return c.SendRequestTo(ctx, req, token, config.GetEndpointManager().GetActiveEndpoint())
+}
+
+// SendRequestTo 向指定端点发送非流式请求
+func (c *Client) SendRequestTo(ctx context.Context, req *core.AntigravityRequest, token *store.Account, endpoint config.Endpoint) (*core.AntigravityResponse, error) {
 	reqURL := endpoint.NoStreamURL()
+	retryTraceFrom(ctx).setEndpoint(endpoint.Key)
 
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	logger.BackendRequest("POST", reqURL, body)
+	logger.BackendRequest(ctx, "POST", reqURL, body)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewReader(body))
 	if err != nil {
@@ -115,6 +126,7 @@ func (c *Client) SendRequest(ctx context.Context, req *core.AntigravityRequest,
 
 	startTime := time.Now()
 	resp, err := c.httpClient.Do(httpReq)
+	recordEndpointResult(ctx, endpoint, resp, err)
 	if err != nil {
 		return nil, err
 	}
@@ -140,17 +152,22 @@ func (c *Client) SendRequest(ctx context.Context, req *core.AntigravityRequest,
 
 	if resp.StatusCode != 200 {
 		apiErr := ExtractErrorDetails(resp, respBody)
-		logger.BackendResponse(resp.StatusCode, duration, string(respBody))
+		logger.BackendResponse(ctx, resp.StatusCode, duration, string(respBody))
 		return nil, apiErr
 	}
 
+	var rawResp map[string]interface{}
+	if json.Unmarshal(respBody, &rawResp) == nil {
+		CheckSchemaDrift(rawResp, false)
+	}
+
 	var antigravityResp core.AntigravityResponse
 	if err := json.Unmarshal(respBody, &antigravityResp); err != nil {
-		logger.BackendResponse(resp.StatusCode, duration, string(respBody))
+		logger.BackendResponse(ctx, resp.StatusCode, duration, string(respBody))
 		return nil, err
 	}
 
-	logger.BackendResponse(resp.StatusCode, duration, antigravityResp)
+	logger.BackendResponse(ctx, resp.StatusCode, duration, antigravityResp)
 	return &antigravityResp, nil
 }
 
@@ -158,13 +175,14 @@ func (c *Client) SendRequest(ctx context.Context, req *core.AntigravityRequest,
 func (c *Client) SendStreamRequest(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*http.Response, error) {
 	endpoint := config.GetEndpointManager().GetActiveEndpoint()
 	reqURL := endpoint.StreamURL()
+	retryTraceFrom(ctx).setEndpoint(endpoint.Key)
 
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	logger.BackendRequest("POST", reqURL, body)
+	logger.BackendRequest(ctx, "POST", reqURL, body)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewReader(body))
 	if err != nil {
@@ -179,6 +197,7 @@ func (c *Client) SendStreamRequest(ctx context.Context, req *core.AntigravityReq
 	}
 
 	resp, err := c.httpClient.Do(httpReq)
+	recordEndpointResult(ctx, endpoint, resp, err)
 	if err != nil {
 		return nil, err
 	}
@@ -199,18 +218,42 @@ func (c *Client) SendStreamRequest(ctx context.Context, req *core.AntigravityReq
 
 		respBody, _ := io.ReadAll(reader)
 		apiErr := ExtractErrorDetails(resp, respBody)
-		logger.BackendResponse(resp.StatusCode, 0, string(respBody))
+		logger.BackendResponse(ctx, resp.StatusCode, 0, string(respBody))
 		return nil, apiErr
 	}
 
+	GetSSECapture().wrap(resp, req.Model)
 	return resp, nil
 }
 
+type servingKey struct{}
+
+// WithServing 标记为面向客户端的请求：仅此类请求计入端点熔断统计，镜像、预检等旁路流量不计
+func WithServing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, servingKey{}, true)
+}
+
+// recordEndpointResult 将请求结果计入端点熔断统计（5xx 与网络错误视为失败，客户端取消与非服务流量不计）
+func recordEndpointResult(ctx context.Context, endpoint config.Endpoint, resp *http.Response, err error) {
+	if serving, _ := ctx.Value(servingKey{}).(bool); !serving {
+		return
+	}
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	failed := err != nil || resp.StatusCode >= 500
+	if config.GetEndpointBreaker().Record(endpoint.Key, failed) {
+		logger.Warn("Endpoint %s tripped circuit breaker, removed from rotation for %ds", endpoint.Key, config.Get().BreakerCooldown)
+	}
+}
+
 // ExtractErrorDetails 提取错误详情
 func ExtractErrorDetails(resp *http.Response, body []byte) *APIError {
 	apiErr := &APIError{
 		Status:  resp.StatusCode,
 		Message: "Unknown error",
+		Headers: resp.Header.Clone(),
+		Body:    string(body),
 	}
 
 	var errorResp struct {
@@ -263,9 +306,14 @@ func ExtractErrorDetails(resp *http.Response, body []byte) *APIError {
 // WithRetry 带重试的请求
 func (c *Client) WithRetry(ctx context.Context, operation func() error) error {
 	var lastErr error
+	trace := retryTraceFrom(ctx)
 
 	for attempt := 0; attempt < c.config.RetryMaxAttempts; attempt++ {
+		startTime := time.Now()
 		err := operation()
+		if trace != nil {
+			trace.record(err, time.Since(startTime))
+		}
 		if err == nil {
 			return nil
 		}
@@ -323,6 +371,14 @@ func GetClient() *Client {
 	return apiClient
 }
 
+// UseHTTPClient 替换全局客户端的 HTTP 客户端并返回恢复函数（测试中连接本地 TLS 上游）
+func UseHTTPClient(httpClient *http.Client) (restore func()) {
+	client := GetClient()
+	old := client.httpClient
+	client.httpClient = httpClient
+	return func() { client.httpClient = old }
+}
+
 // GenerateContent 非流式生成内容
 func GenerateContent(ctx context.Context, req *core.AntigravityRequest, token *store.Account) (*core.AntigravityResponse, error) {
 	client := GetClient()
@@ -0,0 +1,155 @@
+package vertex
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/store"
+)
+
+const (
+	streamEvents   = 5
+	streamInterval = 50 * time.Millisecond
+)
+
+// gzipBufferingUpstream 模拟上游：客户端接受 gzip 时压缩并缓冲整个流，否则逐事件刷新
+func gzipBufferingUpstream(t *testing.T, acceptEncoding *string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		var out io.Writer = w
+		flush := w.(http.Flusher).Flush
+		if strings.Contains(*acceptEncoding, "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out, flush = gz, func() {}
+		}
+
+		for i := 0; i < streamEvents; i++ {
+			fmt.Fprintf(out, "data: {\"response\":{\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"%d\"}]}}]}}\n\n", i)
+			flush()
+			time.Sleep(streamInterval)
+		}
+	}))
+}
+
+// useTestEndpoints 将所有端点临时指向测试上游，无论当前端点模式如何
+func useTestEndpoints(t *testing.T, srv *httptest.Server) {
+	old := config.APIEndpoints
+	t.Cleanup(func() { config.APIEndpoints = old })
+	endpoints := make(map[string]config.Endpoint, len(old))
+	for key, ep := range old {
+		ep.Host = strings.TrimPrefix(srv.URL, "https://")
+		endpoints[key] = ep
+	}
+	config.APIEndpoints = endpoints
+}
+
+func TestSendStreamRequestNoGzip(t *testing.T) {
+	var acceptEncoding string
+	srv := gzipBufferingUpstream(t, &acceptEncoding)
+	defer srv.Close()
+
+	useTestEndpoints(t, srv)
+
+	c := &Client{httpClient: srv.Client(), config: config.Get()}
+	resp, err := c.SendStreamRequest(context.Background(), &core.AntigravityRequest{}, &store.Account{AccessToken: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if acceptEncoding != "identity" {
+		t.Errorf("Accept-Encoding = %q, want identity", acceptEncoding)
+	}
+
+	// 事件应按上游节奏逐个到达，而不是在流结束时集中到达
+	var arrivals []time.Time
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			arrivals = append(arrivals, time.Now())
+		}
+	}
+	if len(arrivals) != streamEvents {
+		t.Fatalf("got %d events, want %d", len(arrivals), streamEvents)
+	}
+	for i := 1; i < len(arrivals); i++ {
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < streamInterval/2 {
+			t.Errorf("event %d arrived %v after previous, want about %v", i, gap, streamInterval)
+		}
+	}
+}
+
+// TestGenerateContentStreamIdentityEncoding 各路由的流式请求都经由 GenerateContentStream 发出，
+// 均应使用 BuildStreamHeaders 声明 Accept-Encoding: identity
+func TestGenerateContentStreamIdentityEncoding(t *testing.T) {
+	var acceptEncoding string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer srv.Close()
+	useTestEndpoints(t, srv)
+
+	defer func(old *Client) { apiClient = old }(apiClient)
+	apiClient = &Client{httpClient: srv.Client(), config: config.Get()}
+
+	routes := []struct {
+		name  string
+		req   *core.AntigravityRequest
+		token *store.Account
+	}{
+		{"openai", &core.AntigravityRequest{Model: "gemini-3-pro"}, &store.Account{AccessToken: "t"}},
+		{"claude", &core.AntigravityRequest{Model: "claude-sonnet-4-5"}, &store.Account{AccessToken: "t"}},
+		{"gemini", &core.AntigravityRequest{Model: "gemini-3-pro"}, &store.Account{AccessToken: "t"}},
+		{"gemini-raw", &core.AntigravityRequest{Model: "gemini-3-pro", RequestType: "agent"}, &store.Account{AccessToken: "t"}},
+		{"credential-pinned", &core.AntigravityRequest{Model: "claude-sonnet-4-5"}, &store.Account{AccessToken: "pinned", Email: "pinned@example.com"}},
+	}
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			acceptEncoding = ""
+			resp, err := GenerateContentStream(context.Background(), route.req, route.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if acceptEncoding != "identity" {
+				t.Errorf("Accept-Encoding = %q, want identity", acceptEncoding)
+			}
+		})
+	}
+}
+
+// TestRecordEndpointResultServingOnly 仅面向客户端的请求计入熔断统计
+func TestRecordEndpointResultServingOnly(t *testing.T) {
+	cfg := config.Get()
+	defer func(enabled bool, min int) { cfg.BreakerEnabled, cfg.BreakerMinRequests = enabled, min }(cfg.BreakerEnabled, cfg.BreakerMinRequests)
+	cfg.BreakerEnabled, cfg.BreakerMinRequests = true, 100
+
+	breaker := config.GetEndpointBreaker()
+	endpoint := config.Endpoint{Key: "breaker-test"}
+	defer breaker.Reset(endpoint.Key)
+
+	failure := &http.Response{StatusCode: http.StatusInternalServerError}
+	recordEndpointResult(context.Background(), endpoint, failure, nil)
+	if n := breaker.Status(endpoint.Key).Requests; n != 0 {
+		t.Errorf("mirror/preflight traffic recorded %d requests, want 0", n)
+	}
+	recordEndpointResult(WithServing(context.Background()), endpoint, failure, nil)
+	if n := breaker.Status(endpoint.Key).Requests; n != 1 {
+		t.Errorf("serving traffic recorded %d requests, want 1", n)
+	}
+}
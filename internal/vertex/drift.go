@@ -0,0 +1,196 @@
+package vertex
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/config"
+	"anti2api-golang/internal/logger"
+)
+
+// 上游响应的预期结构：每一层允许出现的字段。
+// 这里只列出已知字段，未列出的字段视为格式漂移，仅告警不影响处理。
+var (
+	driftTopFields       = fieldSet("response", "traceId", "metadata")
+	driftResponseFields  = fieldSet("candidates", "usageMetadata", "modelVersion", "responseId", "createTime", "promptFeedback")
+	driftCandidateFields = fieldSet("content", "finishReason", "finishMessage", "index", "safetyRatings",
+		"citationMetadata", "groundingMetadata", "avgLogprobs", "logprobsResult")
+	driftContentFields = fieldSet("role", "parts")
+	driftPartFields    = fieldSet("text", "thought", "thoughtSignature", "functionCall", "functionResponse",
+		"inlineData", "executableCode", "codeExecutionResult")
+	driftUsageFields = fieldSet("promptTokenCount", "candidatesTokenCount", "totalTokenCount", "thoughtsTokenCount",
+		"cachedContentTokenCount", "toolUsePromptTokenCount", "promptTokensDetails", "candidatesTokensDetails",
+		"cacheTokensDetails", "toolUsePromptTokensDetails", "trafficType")
+)
+
+const (
+	driftSampleSize  = 2000 // 样本原文最大字符数
+	driftLogInterval = 100  // 同一类漂移每出现 N 次重新记录一次样本
+)
+
+func fieldSet(fields ...string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		set[f] = true
+	}
+	return set
+}
+
+// SchemaDrift 一类上游格式漂移的统计
+type SchemaDrift struct {
+	Issues    []string  `json:"issues"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Sample    string    `json:"sample"`
+}
+
+var (
+	driftMu      sync.Mutex
+	driftReports = make(map[string]*SchemaDrift)
+)
+
+// CheckSchemaDrift 校验上游响应结构，发现未知字段或缺失 candidates 时记录告警
+// stream 为 true 时允许数据块只携带 usageMetadata
+func CheckSchemaDrift(raw map[string]interface{}, stream bool) {
+	if !config.Get().SchemaDriftCheck || raw == nil {
+		return
+	}
+
+	issues := detectSchemaDrift(raw, stream)
+	if len(issues) == 0 {
+		return
+	}
+	recordSchemaDrift(issues, raw)
+}
+
+// detectSchemaDrift 返回排序后的漂移描述（字段路径形式），无漂移时返回 nil
+func detectSchemaDrift(raw map[string]interface{}, stream bool) []string {
+	seen := make(map[string]bool)
+	add := func(issue string) { seen[issue] = true }
+
+	unknownFields(raw, driftTopFields, "", add)
+
+	response, ok := raw["response"].(map[string]interface{})
+	if !ok {
+		add("missing: response")
+		return sortedIssues(seen)
+	}
+	unknownFields(response, driftResponseFields, "response", add)
+
+	candidates, _ := response["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		_, hasUsage := response["usageMetadata"]
+		_, hasFeedback := response["promptFeedback"]
+		if !hasFeedback && (!stream || !hasUsage) {
+			add("missing: response.candidates")
+		}
+	}
+	for _, c := range candidates {
+		candidate, ok := c.(map[string]interface{})
+		if !ok {
+			add("invalid: response.candidates[]")
+			continue
+		}
+		unknownFields(candidate, driftCandidateFields, "response.candidates[]", add)
+
+		content, ok := candidate["content"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		unknownFields(content, driftContentFields, "response.candidates[].content", add)
+
+		parts, _ := content["parts"].([]interface{})
+		for _, p := range parts {
+			if part, ok := p.(map[string]interface{}); ok {
+				unknownFields(part, driftPartFields, "response.candidates[].content.parts[]", add)
+			}
+		}
+	}
+
+	if usage, ok := response["usageMetadata"].(map[string]interface{}); ok {
+		unknownFields(usage, driftUsageFields, "response.usageMetadata", add)
+	}
+
+	return sortedIssues(seen)
+}
+
+func unknownFields(obj map[string]interface{}, known map[string]bool, path string, add func(string)) {
+	for key := range obj {
+		if known[key] {
+			continue
+		}
+		if path == "" {
+			add("unknown: " + key)
+		} else {
+			add("unknown: " + path + "." + key)
+		}
+	}
+}
+
+func sortedIssues(seen map[string]bool) []string {
+	if len(seen) == 0 {
+		return nil
+	}
+	issues := make([]string, 0, len(seen))
+	for issue := range seen {
+		issues = append(issues, issue)
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+// recordSchemaDrift 累计漂移次数，首次出现及之后每 driftLogInterval 次输出一条带样本的告警
+func recordSchemaDrift(issues []string, raw map[string]interface{}) {
+	key := strings.Join(issues, ";")
+	now := time.Now()
+
+	driftMu.Lock()
+	report, ok := driftReports[key]
+	if !ok {
+		report = &SchemaDrift{Issues: issues, FirstSeen: now}
+		driftReports[key] = report
+	}
+	report.Count++
+	report.LastSeen = now
+	var sample string
+	if report.Count == 1 || report.Count%driftLogInterval == 0 {
+		sample = driftSample(raw)
+		report.Sample = sample
+	}
+	count := report.Count
+	driftMu.Unlock()
+
+	if sample != "" {
+		logger.Warn("Upstream schema drift (seen %d times): %s\nsample: %s", count, key, sample)
+	}
+}
+
+func driftSample(raw map[string]interface{}) string {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return ""
+	}
+	if len(data) > driftSampleSize {
+		return string(data[:driftSampleSize]) + "...(truncated)"
+	}
+	return string(data)
+}
+
+// SchemaDriftReports 返回已记录的漂移统计（按最近出现时间倒序）
+func SchemaDriftReports() []SchemaDrift {
+	driftMu.Lock()
+	defer driftMu.Unlock()
+
+	reports := make([]SchemaDrift, 0, len(driftReports))
+	for _, r := range driftReports {
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].LastSeen.After(reports[j].LastSeen)
+	})
+	return reports
+}
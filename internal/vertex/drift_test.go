@@ -0,0 +1,57 @@
+package vertex
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDetectSchemaDrift(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		stream bool
+		want   []string
+	}{
+		{
+			name: "known shape",
+			body: `{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":1},"modelVersion":"m"},"traceId":"t"}`,
+		},
+		{
+			name: "unknown fields",
+			body: `{"response":{"candidates":[{"content":{"parts":[{"text":"hi","citation":{}}]},"score":1}],"newField":true}}`,
+			want: []string{
+				"unknown: response.candidates[].content.parts[].citation",
+				"unknown: response.candidates[].score",
+				"unknown: response.newField",
+			},
+		},
+		{
+			name: "missing candidates",
+			body: `{"response":{"usageMetadata":{"promptTokenCount":1}}}`,
+			want: []string{"missing: response.candidates"},
+		},
+		{
+			name:   "usage-only stream chunk",
+			body:   `{"response":{"usageMetadata":{"promptTokenCount":1}}}`,
+			stream: true,
+		},
+		{
+			name: "missing response",
+			body: `{"result":{}}`,
+			want: []string{"missing: response", "unknown: result"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.body), &raw); err != nil {
+				t.Fatal(err)
+			}
+			if got := detectSchemaDrift(raw, tt.stream); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"anti2api-golang/internal/core"
+	"anti2api-golang/internal/logger"
 )
 
 // StreamData 原始流式数据
@@ -20,13 +21,17 @@ type StreamData struct {
 				Parts []struct {
 					Text             string             `json:"text,omitempty"`
 					FunctionCall     *core.FunctionCall `json:"functionCall,omitempty"`
+					InlineData       *core.InlineData   `json:"inlineData,omitempty"`
 					Thought          bool               `json:"thought,omitempty"`
 					ThoughtSignature string             `json:"thoughtSignature,omitempty"`
 				} `json:"parts"`
 			} `json:"content"`
-			FinishReason string `json:"finishReason,omitempty"`
+			FinishReason   string               `json:"finishReason,omitempty"`
+			Index          int                  `json:"index,omitempty"`
+			LogprobsResult *core.LogprobsResult `json:"logprobsResult,omitempty"`
 		} `json:"candidates"`
-		UsageMetadata *core.UsageMetadata `json:"usageMetadata,omitempty"`
+		PromptFeedback *core.PromptFeedback `json:"promptFeedback,omitempty"`
+		UsageMetadata  *core.UsageMetadata  `json:"usageMetadata,omitempty"`
 	} `json:"response"`
 }
 
@@ -117,7 +122,11 @@ func ParseStreamWithResult(resp *http.Response, receiver func(data *StreamData)
 		if err := json.Unmarshal([]byte(jsonData), &rawChunk); err != nil {
 			continue
 		}
-		rawChunks = append(rawChunks, rawChunk)
+		// 原始数据块仅在 DEBUG=high 时收集（可在运行时切换）
+		if logger.GetLevel() >= logger.LogHigh {
+			rawChunks = append(rawChunks, rawChunk)
+		}
+		CheckSchemaDrift(rawChunk, true)
 
 		// 同时解析为结构化数据用于处理
 		var data StreamData
@@ -136,6 +145,12 @@ func ParseStreamWithResult(resp *http.Response, receiver func(data *StreamData)
 			}
 		}
 
+		// 提示词被拦截时上游只返回 promptFeedback，不含候选
+		if len(data.Response.Candidates) == 0 && data.Response.PromptFeedback.Blocked() && result.FinishReason == "" {
+			result.FinishReason = core.NoCandidatesFinishReason
+			lastFinishReason = core.NoCandidatesFinishReason
+		}
+
 		// 收集原始 parts 和合并 text
 		if len(data.Response.Candidates) > 0 {
 			candidate := data.Response.Candidates[0]
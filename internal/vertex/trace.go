@@ -0,0 +1,81 @@
+package vertex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"anti2api-golang/internal/store"
+)
+
+type retryTraceKey struct{}
+
+// RetryTrace 记录一次请求中每次上游尝试的结果（用于日志）
+type RetryTrace struct {
+	mu       sync.Mutex
+	attempts []store.UpstreamAttempt
+	endpoint string // 当前尝试使用的端点
+}
+
+// WithRetryTrace 返回携带重试记录的 context，WithRetry 会将每次尝试写入其中
+func WithRetryTrace(ctx context.Context) (context.Context, *RetryTrace) {
+	trace := &RetryTrace{}
+	return context.WithValue(ctx, retryTraceKey{}, trace), trace
+}
+
+// record 记录一次尝试
+func (t *RetryTrace) record(err error, duration time.Duration) {
+	t.mu.Lock()
+	attempt := store.UpstreamAttempt{
+		Status:     200,
+		DurationMs: duration.Milliseconds(),
+		Endpoint:   t.endpoint,
+	}
+	t.mu.Unlock()
+	if err != nil {
+		attempt.Status = 500
+		if apiErr, ok := err.(*APIError); ok {
+			attempt.Status = apiErr.Status
+		}
+		attempt.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	t.attempts = append(t.attempts, attempt)
+	t.mu.Unlock()
+}
+
+// setEndpoint 记录当前尝试实际使用的端点（nil 安全）
+func (t *RetryTrace) setEndpoint(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.endpoint = key
+	t.mu.Unlock()
+}
+
+// Endpoint 返回最近一次尝试使用的端点（nil 安全）
+func (t *RetryTrace) Endpoint() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.endpoint
+}
+
+// Attempts 返回已记录的尝试（nil 安全）
+func (t *RetryTrace) Attempts() []store.UpstreamAttempt {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]store.UpstreamAttempt(nil), t.attempts...)
+}
+
+func retryTraceFrom(ctx context.Context) *RetryTrace {
+	trace, _ := ctx.Value(retryTraceKey{}).(*RetryTrace)
+	return trace
+}
@@ -31,6 +31,7 @@ const logPrevPageBtn = document.getElementById('logPrevPageBtn');
 const logNextPageBtn = document.getElementById('logNextPageBtn');
 const statusFilterSelect = document.getElementById('statusFilter');
 const errorFilterCheckbox = document.getElementById('errorFilter');
+const revealEmailsCheckbox = document.getElementById('revealEmails');
 const themeToggleBtn = document.getElementById('themeToggleBtn');
 
 const HOUR_WINDOW_MINUTES = 60;
@@ -203,12 +204,47 @@ function bindAccountActions() {
   document.querySelectorAll('[data-action="delete"]')?.forEach(btn => {
     btn.addEventListener('click', async () => {
       const idx = btn.dataset.index;
-      if (!confirm('确认删除这个账号吗？删除后无法恢复')) return;
+      if (!confirm('确认删除这个账号吗？账号将被归档，可随时恢复')) return;
       btn.disabled = true;
-      setStatus('正在删除账号...', 'info', manageStatusEl);
+      setStatus('正在归档账号...', 'info', manageStatusEl);
       try {
         await fetchJson(`/auth/accounts/${idx}`, { method: 'DELETE' });
-        setStatus('账号已删除', 'success', manageStatusEl);
+        setStatus('账号已归档', 'success', manageStatusEl);
+        refreshAccounts();
+      } catch (e) {
+        setStatus('删除失败: ' + e.message, 'error', manageStatusEl);
+      } finally {
+        btn.disabled = false;
+      }
+    });
+  });
+
+  document.querySelectorAll('[data-action="restore"]')?.forEach(btn => {
+    btn.addEventListener('click', async () => {
+      const idx = btn.dataset.index;
+      btn.disabled = true;
+      setStatus('正在恢复账号...', 'info', manageStatusEl);
+      try {
+        await fetchJson(`/auth/accounts/${idx}/restore`, { method: 'POST' });
+        setStatus('账号已恢复', 'success', manageStatusEl);
+        refreshAccounts();
+      } catch (e) {
+        setStatus('恢复失败: ' + e.message, 'error', manageStatusEl);
+      } finally {
+        btn.disabled = false;
+      }
+    });
+  });
+
+  document.querySelectorAll('[data-action="purge"]')?.forEach(btn => {
+    btn.addEventListener('click', async () => {
+      const idx = btn.dataset.index;
+      if (!confirm('确认永久删除这个账号吗？删除后无法恢复')) return;
+      btn.disabled = true;
+      setStatus('正在永久删除账号...', 'info', manageStatusEl);
+      try {
+        await fetchJson(`/auth/accounts/${idx}/purge`, { method: 'DELETE' });
+        setStatus('账号已永久删除', 'success', manageStatusEl);
         refreshAccounts();
       } catch (e) {
         setStatus('删除失败: ' + e.message, 'error', manageStatusEl);
@@ -229,7 +265,8 @@ function bindAccountActions() {
 
 async function refreshAccounts() {
   try {
-    const data = await fetchJson('/auth/accounts');
+    const reveal = revealEmailsCheckbox && revealEmailsCheckbox.checked;
+    const data = await fetchJson(reveal ? '/auth/accounts?reveal=true' : '/auth/accounts');
     accountsData = data.accounts || [];
     updateFilteredAccounts();
     loadHourlyUsage();
@@ -255,8 +292,8 @@ function renderAccountsList() {
   listEl.innerHTML = pageItems
     .map(acc => {
       const created = acc.createdAt ? new Date(acc.createdAt).toLocaleString() : '时间未知';
-      const statusClass = acc.enable ? 'status-ok' : 'status-off';
-      const statusText = acc.enable ? '启用中' : '已停用';
+      const statusClass = acc.enable && !acc.archived ? 'status-ok' : 'status-off';
+      const statusText = acc.archived ? '已归档' : acc.enable ? '启用中' : '已停用';
       const displayName = escapeHtml(getAccountDisplayName(acc));
       return `
         <div class="account-item">
@@ -284,7 +321,11 @@ function renderAccountsList() {
                 <button class="mini-btn" data-action="toggle" data-enable="${acc.enable}" data-index="${acc.index}">${acc.enable ? '⏸️ 停用' : '▶️ 启用'
         }</button>
                 <button class="mini-btn" data-action="reauthorize" data-index="${acc.index}">🔑 重新授权</button>
-                <button class="mini-btn danger" data-action="delete" data-index="${acc.index}">🗑️ 删除</button>
+                ${acc.archived
+          ? `<button class="mini-btn" data-action="restore" data-index="${acc.index}">♻️ 恢复</button>
+                <button class="mini-btn danger" data-action="purge" data-index="${acc.index}">🗑️ 永久删除</button>`
+          : `<button class="mini-btn danger" data-action="delete" data-index="${acc.index}">🗑️ 删除</button>`
+        }
               </div>
             </div>
           </div>
@@ -303,14 +344,14 @@ function renderAccountsList() {
 
 async function deleteDisabledAccounts() {
   const disabledAccounts = accountsData
-    .filter(acc => !acc.enable)
+    .filter(acc => !acc.enable && !acc.archived)
     .sort((a, b) => b.index - a.index);
   if (disabledAccounts.length === 0) {
     setStatus('没有停用的凭证需要删除。', 'info', manageStatusEl);
     return;
   }
 
-  if (!confirm(`确认删除 ${disabledAccounts.length} 个停用凭证吗？删除后无法恢复。`)) return;
+  if (!confirm(`确认删除 ${disabledAccounts.length} 个停用凭证吗？凭证将被归档，可随时恢复。`)) return;
 
   deleteDisabledBtn.disabled = true;
   setStatus('正在删除停用凭证...', 'info', manageStatusEl);
@@ -447,6 +488,46 @@ function renderLogDetailContent(detail, container) {
       </div>
     </details>
 
+    ${detail.detail?.mirror ? `
+    <details class="log-detail-section" open>
+      <summary>镜像请求结果 (${escapeHtml(detail.detail.mirror.endpoint)} / ${escapeHtml(detail.detail.mirror.model)})</summary>
+      <div class="log-detail-body">
+        <pre>${formatJson(detail.detail.mirror)}</pre>
+      </div>
+    </details>` : ''}
+
+    ${detail.detail?.upstreamError ? `
+    <details class="log-detail-section" open>
+      <summary>上游错误响应 (HTTP ${detail.detail.upstreamError.statusCode})</summary>
+      <div class="log-detail-body">
+        <pre>${formatJson(detail.detail.upstreamError)}</pre>
+      </div>
+    </details>` : ''}
+
+    ${detail.detail?.trace ? `
+    <details class="log-detail-section">
+      <summary>处理时间线 (${detail.durationMs ?? 0}ms)</summary>
+      <div class="log-detail-body">
+        ${renderTraceTimeline(detail.detail.trace, detail.durationMs)}
+      </div>
+    </details>` : ''}
+
+    ${!detail.detail?.trace && detail.detail?.attempts?.length > 1 ? `
+    <details class="log-detail-section">
+      <summary>上游尝试记录 (${detail.detail.attempts.length})</summary>
+      <div class="log-detail-body">
+        <pre>${formatJson(detail.detail.attempts)}</pre>
+      </div>
+    </details>` : ''}
+
+    ${detail.detail?.toolArgRepairs?.length ? `
+    <details class="log-detail-section" open>
+      <summary>工具参数修复 (${detail.detail.toolArgRepairs.length})</summary>
+      <div class="log-detail-body">
+        <pre>${formatJson(detail.detail.toolArgRepairs)}</pre>
+      </div>
+    </details>` : ''}
+
     <details class="log-detail-section">
       <summary>用户完整请求体</summary>
       <div class="log-detail-body">
@@ -470,6 +551,47 @@ function renderLogDetailContent(detail, container) {
   `;
 }
 
+const TRACE_EVENT_LABELS = {
+  convert: '协议转换',
+  upstream: '上游请求',
+  first_chunk: '上游首个数据 (TTFB)',
+  first_client_byte: '首次输出给客户端',
+  upstream_complete: '上游流结束',
+  complete: '响应完成'
+};
+
+// 渲染请求时间线：阶段以条形显示起止位置，时间点以标记显示
+function renderTraceTimeline(trace, totalMs) {
+  const events = trace.events || [];
+  const total = Math.max(totalMs || 0, ...events.map(e => e.atMs + (e.durationMs || 0)), 1);
+  const rows = events.map(e => {
+    const left = (e.atMs / total) * 100;
+    const width = e.durationMs ? Math.max((e.durationMs / total) * 100, 0.5) : 0;
+    const timeText = e.durationMs ? `${e.atMs}ms +${e.durationMs}ms` : `${e.atMs}ms`;
+    return `
+      <div class="trace-row">
+        <span class="trace-label">${escapeHtml(TRACE_EVENT_LABELS[e.name] || e.name)}</span>
+        <span class="trace-track">
+          ${e.durationMs
+            ? `<span class="trace-bar" style="left:${left}%;width:${width}%"></span>`
+            : `<span class="trace-point" style="left:${left}%"></span>`}
+        </span>
+        <span class="trace-time">${timeText}</span>
+      </div>`;
+  }).join('');
+
+  const stats = [];
+  if (trace.chunks) stats.push(`上游数据块 ${trace.chunks}`);
+  if (trace.emitter) stats.push(`输出 ${trace.emitter.writes} 次 / ${trace.emitter.bytes} 字节`);
+  if (trace.attempts?.length > 1) stats.push(`上游尝试 ${trace.attempts.length} 次`);
+
+  return `
+    <div class="trace-timeline">${rows || '暂无时间线'}</div>
+    ${stats.length ? `<div class="trace-stats">${stats.join(' | ')}</div>` : ''}
+    ${trace.attempts?.length > 1 ? `<pre>${formatJson(trace.attempts)}</pre>` : ''}
+  `;
+}
+
 function renderErrorDetailContent(detail, container) {
   if (!container) return;
   if (!detail) {
@@ -586,6 +708,10 @@ function renderLogs() {
       const errorDetailId = `log-error-${start + idx}`;
       const statusText = log.status ? `HTTP ${log.status}` : log.success ? '成功' : '失败';
       const durationText = log.durationMs ? `${log.durationMs} ms` : '未知耗时';
+      const usageText = log.usage ? ` | tokens：${log.usage.promptTokens} → ${log.usage.completionTokens}` : '';
+      const finishText = log.finishReason ? ` | ${escapeHtml(log.finishReason)}` : '';
+      const attemptsText = log.attempts > 1 ? ` | 尝试 ${log.attempts} 次` : '';
+      const endpointText = log.endpoint ? ` | ${escapeHtml(log.endpoint)}` : '';
       const pathText = `${log.method || '未知方法'} ${log.path || log.route || '未知路径'}`;
       const errorHint = hasError && log.message ? `<div class="log-error-hint">失败原因：${escapeHtml(log.message)}</div>` : '';
       const detailButton =
@@ -604,9 +730,9 @@ function renderLogs() {
         <div class="log-item ${cls}">
           <div class="log-content">
             <div class="log-time">${time}</div>
-            <div class="log-meta">模型：${log.model || '未知模型'} | 项目：${log.projectId || '未知项目'}</div>
+            <div class="log-meta">模型：${log.model || '未知模型'}${log.variant ? ' → ' + escapeHtml(log.variant) : ''} | 项目：${log.projectId || '未知项目'}</div>
             <div class="log-meta">${pathText}</div>
-            <div class="log-meta">${statusText} | ${durationText}</div>
+            <div class="log-meta">${statusText} | ${durationText}${usageText}${finishText}${attemptsText}${endpointText}</div>
             ${errorHint}
             ${errorButton}
             ${detailButton}
@@ -847,6 +973,10 @@ if (errorFilterCheckbox) {
   });
 }
 
+if (revealEmailsCheckbox) {
+  revealEmailsCheckbox.addEventListener('change', refreshAccounts);
+}
+
 if (themeToggleBtn) {
   themeToggleBtn.addEventListener('click', () => {
     const current = document.documentElement.getAttribute('data-theme') || 'light';
@@ -950,11 +1080,49 @@ async function loadEndpoints() {
     currentEndpointMode = data.mode || 'daily';
     endpointModeSelect.value = currentEndpointMode;
     setStatus(`当前模式: ${getModeLabel(currentEndpointMode)}`, 'success', endpointStatusEl);
+    renderEndpointBreakers(data.endpoints || []);
   } catch (e) {
     setStatus('加载端点失败: ' + e.message, 'error', endpointStatusEl);
   }
 }
 
+function renderEndpointBreakers(endpoints) {
+  const container = document.getElementById('endpointBreakers');
+  if (!container) return;
+
+  container.innerHTML = endpoints
+    .slice()
+    .sort((a, b) => a.key.localeCompare(b.key))
+    .map(ep => {
+      const br = ep.breaker || {};
+      const state = br.open
+        ? `<span class="badge badge-error">已熔断${br.manual ? '(手动)' : ''} 至 ${new Date(br.openUntil).toLocaleTimeString()}</span>`
+        : `<span class="badge badge-success">正常</span>`;
+      const action = br.open ? 'reset' : 'trip';
+      return `
+        <div class="status-row">
+          <span>${escapeHtml(ep.label)}</span>
+          ${state}
+          <span class="badge">${br.failures || 0}/${br.requests || 0} 失败</span>
+          <button class="refresh-btn" onclick="setEndpointBreaker('${escapeHtml(ep.key)}', '${action}')">${br.open ? '恢复' : '熔断'}</button>
+        </div>`;
+    })
+    .join('');
+}
+
+async function setEndpointBreaker(key, action) {
+  try {
+    await fetchJson(`/admin/endpoints/${encodeURIComponent(key)}/breaker`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ action })
+    });
+    await loadEndpoints();
+  } catch (e) {
+    setStatus('操作失败: ' + e.message, 'error', endpointStatusEl);
+  }
+}
+
 function getModeLabel(mode) {
   const labels = {
     'daily': 'Daily',
@@ -1003,10 +1171,55 @@ if (switchEndpointBtn) {
   switchEndpointBtn.addEventListener('click', switchEndpointMode);
 }
 
+// ===== 实时推送 =====
+// 单个 WebSocket 接收账号状态、端点模式与新日志事件，按事件类型刷新对应区域
+
+const adminEventHandlers = {
+  accounts: () => refreshAccounts(),
+  endpoint_mode: () => {
+    loadEndpoints();
+    loadSettings();
+  },
+  stats: () => {
+    loadHourlyUsage();
+    if (logsRefreshBtn && !logsRefreshBtn.disabled) logsRefreshBtn.textContent = '🔄 刷新日志（有新日志）';
+  }
+};
+const adminEventTimers = {};
+let adminEventRetry = 1000;
+
+function connectAdminEvents() {
+  if (!window.WebSocket) return;
+  const scheme = location.protocol === 'https:' ? 'wss' : 'ws';
+  const ws = new WebSocket(`${scheme}://${location.host}/admin/ws`);
+
+  ws.addEventListener('open', () => {
+    adminEventRetry = 1000;
+  });
+  ws.addEventListener('message', evt => {
+    let event;
+    try {
+      event = JSON.parse(evt.data);
+    } catch (e) {
+      return;
+    }
+    const handler = adminEventHandlers[event.type];
+    if (!handler) return;
+    // 合并短时间内的同类事件，避免重复请求
+    clearTimeout(adminEventTimers[event.type]);
+    adminEventTimers[event.type] = setTimeout(handler, 300);
+  });
+  ws.addEventListener('close', () => {
+    setTimeout(connectAdminEvents, adminEventRetry);
+    adminEventRetry = Math.min(adminEventRetry * 2, 30000);
+  });
+}
+
 refreshAccounts();
 loadLogs();
 loadHourlyUsage();
 loadSettings();
 loadEndpoints();
+connectAdminEvents();
 
 